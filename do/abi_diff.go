@@ -0,0 +1,187 @@
+package main
+
+import (
+	"archive/zip"
+	"fmt"
+	"io"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// do check abi compares libmupdf.dll from out/rel64 with libmupdf.dll of
+// the latest release: exported functions and their signatures (from
+// .pdb type info, via llvm-pdbutil). It's for people who load libmupdf.dll
+// in their own programs: removed exports and changed signatures break them.
+// Release libmupdf.dll comes from the 64-bit installer, its .pdb from
+// SumatraPDF-${ver}-64.pdb.zip. Both are cached in out/abi-diff/${ver}
+// Report is written to out/abi-diff/report.txt
+
+// matches: func [0x0001a2b0+ 4 - 0x0001a2c9- 4 | sizeof= 25] (FPO) void __cdecl fz_drop_context(fz_context* ctx)
+var rxPdbPrettyFunc = regexp.MustCompile(`^\s*func \[[^\]]*\]\s*(?:\([^)]*\)\s*)?(.+)$`)
+
+// returns function name => signature, from llvm-pdbutil pretty -functions
+func parsePdbPrettyFunctions(s string) map[string]string {
+	res := map[string]string{}
+	for _, line := range strings.Split(s, "\n") {
+		m := rxPdbPrettyFunc.FindStringSubmatch(strings.TrimRight(line, "\r"))
+		if m == nil {
+			continue
+		}
+		sig := strings.TrimSpace(m[1])
+		idx := strings.Index(sig, "(")
+		if idx <= 0 {
+			continue
+		}
+		fields := strings.Fields(sig[:idx])
+		if len(fields) == 0 {
+			continue
+		}
+		name := strings.TrimLeft(fields[len(fields)-1], "*&")
+		res[name] = sig
+	}
+	return res
+}
+
+// ABIDiff is a difference in exported functions of libmupdf.dll
+type ABIDiff struct {
+	Removed []string
+	Added   []string
+	// "name: old => new"
+	Changed []string
+}
+
+func diffABI(prevExports, currExports []string, prevSigs, currSigs map[string]string) *ABIDiff {
+	res := &ABIDiff{}
+	curr := map[string]bool{}
+	for _, name := range currExports {
+		curr[name] = true
+	}
+	prev := map[string]bool{}
+	for _, name := range prevExports {
+		prev[name] = true
+		if !curr[name] {
+			res.Removed = append(res.Removed, name)
+			continue
+		}
+		a, b := prevSigs[name], currSigs[name]
+		if a != "" && b != "" && a != b {
+			res.Changed = append(res.Changed, fmt.Sprintf("%s:\n    %s\n => %s", name, a, b))
+		}
+	}
+	for _, name := range currExports {
+		if !prev[name] {
+			res.Added = append(res.Added, name)
+		}
+	}
+	sort.Strings(res.Removed)
+	sort.Strings(res.Added)
+	sort.Strings(res.Changed)
+	return res
+}
+
+func fmtABIDiff(prevVer string, d *ABIDiff) string {
+	s := fmt.Sprintf("libmupdf.dll ABI changes since %s\n", prevVer)
+	if len(d.Removed) == 0 && len(d.Changed) == 0 {
+		s += "\ncompatible: no exports removed, no signatures changed\n"
+	} else {
+		s += "\nINCOMPATIBLE: programs using removed or changed functions must be updated\n"
+	}
+	s += fmt.Sprintf("\nremoved exports (%d):\n", len(d.Removed))
+	for _, name := range d.Removed {
+		s += "  " + name + "\n"
+	}
+	s += fmt.Sprintf("\nchanged signatures (%d):\n", len(d.Changed))
+	for _, c := range d.Changed {
+		s += "  " + c + "\n"
+	}
+	s += fmt.Sprintf("\nadded exports (%d):\n", len(d.Added))
+	for _, name := range d.Added {
+		s += "  " + name + "\n"
+	}
+	return s
+}
+
+func extractFileFromZipMust(zipPath string, name string, dstPath string) {
+	r, err := zip.OpenReader(zipPath)
+	must(err)
+	defer r.Close()
+	for _, f := range r.File {
+		if !strings.EqualFold(filepath.Base(f.Name), name) {
+			continue
+		}
+		rc, err := f.Open()
+		must(err)
+		d, err := io.ReadAll(rc)
+		rc.Close()
+		must(err)
+		writeFileMust(dstPath, d)
+		return
+	}
+	panicIf(true, "'%s' not found in '%s'", name, zipPath)
+}
+
+// downloads libmupdf.dll and libmupdf.pdb of the latest release,
+// returns version and directory with the files
+func downloadReleaseLibmupdfMust() (string, string) {
+	panicIf(r2Access == "", "R2_ACCESS env variable not set")
+	mc := newMinioR2Client()
+	ver := parseLatestVer(string(minioDownloadDataMust(mc, "sumatrapdf/sumpdf-latest.txt")))
+	dir := createDirMust(filepath.Join("out", "abi-diff", ver))
+	dllPath := filepath.Join(dir, "libmupdf.dll")
+	pdbPath := filepath.Join(dir, "libmupdf.pdb")
+	if fileExists(dllPath) && fileExists(pdbPath) {
+		return ver, dir
+	}
+	remoteDir := fmt.Sprintf("software/sumatrapdf/rel/%s/", ver)
+	installerPath := filepath.Join(dir, "installer.exe")
+	must(mc.DownloadFileAtomically(installerPath, remoteDir+fmt.Sprintf("SumatraPDF-%s-64-install.exe", ver)))
+	found := false
+	for _, f := range loadInstallerPayloadMust(installerPath) {
+		if !strings.EqualFold(filepath.Base(f.Name), "libmupdf.dll") {
+			continue
+		}
+		d, err := f.Uncompress()
+		must(err)
+		writeFileMust(dllPath, d)
+		found = true
+	}
+	panicIf(!found, "no libmupdf.dll in '%s'", installerPath)
+	pdbZipPath := filepath.Join(dir, "pdb.zip")
+	must(mc.DownloadFileAtomically(pdbZipPath, remoteDir+fmt.Sprintf("SumatraPDF-%s-64.pdb.zip", ver)))
+	extractFileFromZipMust(pdbZipPath, "libmupdf.pdb", pdbPath)
+	logf("downloaded libmupdf.dll and libmupdf.pdb of %s to '%s'\n", ver, dir)
+	return ver, dir
+}
+
+func getPdbFunctionSignaturesMust(pdbPath string) map[string]string {
+	out, err := exec.Command(detectLlvmPdbutilMust(), "pretty", "-functions", "-no-compiler-generated", pdbPath).Output()
+	panicIf(err != nil, "llvm-pdbutil pretty -functions '%s' failed with '%s'", pdbPath, err)
+	return parsePdbPrettyFunctions(string(out))
+}
+
+func getExportsMust(dllPath string) []string {
+	res, err := peExports(readFileMust(dllPath))
+	panicIf(err != nil, "failed to read exports of '%s': %s", dllPath, err)
+	return res
+}
+
+func abiDiff() {
+	currDll := filepath.Join(rel64Dir, "libmupdf.dll")
+	currPdb := filepath.Join(rel64Dir, "libmupdf.pdb")
+	panicIf(!fileExists(currDll) || !fileExists(currPdb), "'%s' or '%s' doesn't exist, build release 64-bit first", currDll, currPdb)
+	prevVer, prevDir := downloadReleaseLibmupdfMust()
+
+	d := diffABI(
+		getExportsMust(filepath.Join(prevDir, "libmupdf.dll")),
+		getExportsMust(currDll),
+		getPdbFunctionSignaturesMust(filepath.Join(prevDir, "libmupdf.pdb")),
+		getPdbFunctionSignaturesMust(currPdb),
+	)
+	report := fmtABIDiff(prevVer, d)
+	reportPath := filepath.Join("out", "abi-diff", "report.txt")
+	writeFileMust(reportPath, []byte(report))
+	logf("%s\nwrote '%s'\n", report, reportPath)
+}
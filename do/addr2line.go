@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"debug/pe"
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// do builds addr2line ${ver} -module libmupdf.dll -offset 0x1234 resolves
+// an address in a module of a build to function, file and line.
+// Downloads the build with getBuildMust and uses llvm-symbolizer (LLVM).
+// -offset is relative to module's base address (RVA) or, as in frames
+// without symbols in crash reports, section:offset e.g. 01:0000000000003F0C.
+// Multiple offsets can be separated with comma
+
+var (
+	flgAddr2lineModule string
+	flgAddr2lineOffset string
+)
+
+// ResolvedAddr is function, file and line for an address. With inlining
+// there can be more than one
+type ResolvedAddr struct {
+	Offset string
+	RVA    uint32
+	Frames []string
+}
+
+// converts "0x1234" or section:offset "01:0000000000003F0C" to RVA
+func parseModuleOffset(f *pe.File, s string) (uint32, error) {
+	if sec, off, ok := strings.Cut(s, ":"); ok {
+		secIdx, err := strconv.ParseUint(sec, 16, 32)
+		if err != nil {
+			return 0, fmt.Errorf("invalid section in '%s'", s)
+		}
+		n, err := strconv.ParseUint(off, 16, 64)
+		if err != nil {
+			return 0, fmt.Errorf("invalid offset in '%s'", s)
+		}
+		if f == nil || secIdx < 1 || int(secIdx) > len(f.Sections) {
+			return 0, fmt.Errorf("invalid section %d in '%s'", secIdx, s)
+		}
+		return f.Sections[secIdx-1].VirtualAddress + uint32(n), nil
+	}
+	n, err := strconv.ParseUint(strings.TrimPrefix(strings.ToLower(s), "0x"), 16, 32)
+	if err != nil {
+		return 0, fmt.Errorf("invalid offset '%s'", s)
+	}
+	return uint32(n), nil
+}
+
+// parses output of llvm-symbolizer which for each address prints
+// pairs of lines (function, file:line:column) followed by an empty line
+func parseSymbolizerOutput(s string) [][]string {
+	var res [][]string
+	var curr []string
+	lines := strings.Split(strings.ReplaceAll(s, "\r\n", "\n"), "\n")
+	for i := 0; i < len(lines); i++ {
+		l := strings.TrimSpace(lines[i])
+		if l == "" {
+			if curr != nil {
+				res = append(res, curr)
+				curr = nil
+			}
+			continue
+		}
+		loc := ""
+		if i+1 < len(lines) {
+			loc = strings.TrimSpace(lines[i+1])
+			i++
+		}
+		curr = append(curr, l+" "+loc)
+	}
+	if curr != nil {
+		res = append(res, curr)
+	}
+	return res
+}
+
+// resolves offsets in module of build files in dir (see getBuildMust)
+func resolveModuleOffsetsMust(dir string, module string, offsets []string) []*ResolvedAddr {
+	modulePath := filepath.Join(dir, module)
+	panicIf(!fileExists(modulePath), "'%s' doesn't exist", modulePath)
+	f, err := pe.NewFile(bytes.NewReader(readFileMust(modulePath)))
+	must(err)
+	defer f.Close()
+
+	var res []*ResolvedAddr
+	args := []string{"--obj=" + modulePath, "--relative-address", "--inlines"}
+	for _, s := range offsets {
+		rva, err := parseModuleOffset(f, s)
+		must(err)
+		res = append(res, &ResolvedAddr{Offset: s, RVA: rva})
+		args = append(args, fmt.Sprintf("0x%x", rva))
+	}
+	out, err := exec.Command(detectLlvmToolMust("llvm-symbolizer"), args...).Output()
+	panicIf(err != nil, "llvm-symbolizer failed with '%s'", err)
+	resolved := parseSymbolizerOutput(string(out))
+	panicIf(len(resolved) != len(res), "llvm-symbolizer returned %d results for %d addresses", len(resolved), len(res))
+	for i, frames := range resolved {
+		res[i].Frames = frames
+	}
+	return res
+}
+
+func addr2line(ver string, platform string, module string, offsets string) {
+	panicIf(module == "", "need -module e.g. libmupdf.dll")
+	panicIf(offsets == "", "need -offset e.g. 0x1234")
+	dir := getBuildMust(ver, platform)
+	for _, r := range resolveModuleOffsetsMust(dir, module, strings.Split(offsets, ",")) {
+		logf("%s!%s (rva 0x%x):\n", module, r.Offset, r.RVA)
+		for _, frame := range r.Frames {
+			logf("  %s\n", frame)
+		}
+	}
+}
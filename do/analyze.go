@@ -0,0 +1,122 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builds with MSVC's /analyze, converts the warnings to SARIF and compares
+// them with do/analyze_baseline.txt
+// complements CodeQL (do analyze trigger-codeql) which runs on GitHub
+
+// src\Foo.cpp(123,5): warning C6011: Dereferencing NULL pointer 'p'. [D:\sumatrapdf\vs2022\SumatraPDF.vcxproj]
+var rxMsvcWarning = regexp.MustCompile(`^(?:\d+>)?\s*(.+?)\((\d+)(?:,(\d+))?\): warning (C\d+): (.*?)(?: \[[^\]]+\])?$`)
+
+// /analyze warnings are C6xxx, C26xxx, C28xxx and C33xxx
+func isAnalyzeWarning(code string) bool {
+	for _, prefix := range []string{"C6", "C26", "C28", "C33"} {
+		if strings.HasPrefix(code, prefix) && len(code) >= len(prefix)+3 {
+			return true
+		}
+	}
+	return false
+}
+
+// parses warnings from msbuild log. msbuild prints each warning twice
+// (once during build and once in the summary) so we dedup them
+func parseAnalyzeWarnings(s string) []*SarifResult {
+	var res []*SarifResult
+	seen := map[string]bool{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		m := rxMsvcWarning.FindStringSubmatch(line)
+		if m == nil || !isAnalyzeWarning(m[4]) {
+			continue
+		}
+		path := m[1]
+		lineNo, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		r := newSarifResult(m[4], "warning", m[5], path, lineNo, col)
+		key := fmt.Sprintf("%s:%d %s", r.Key(), lineNo, m[3])
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		res = append(res, r)
+	}
+	return res
+}
+
+func buildAnalyze(outDir string) string {
+	msbuildPath := detectMsbuildPath()
+	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
+	logPath := filepath.Join(outDir, "msbuild.log")
+	p := fmt.Sprintf(`/p:Configuration=Release;Platform=%s;EnablePREfast=true`, kPlatformIntel64)
+	flp := fmt.Sprintf(`/flp:logfile=%s;verbosity=normal`, logPath)
+	runExeLoggedMust(msbuildPath, slnPath, `/t:SumatraPDF:Rebuild`, p, `/m`, `/fl`, flp)
+	return logPath
+}
+
+func runAnalyze(updateBaseline bool, upload bool) {
+	outDir := filepath.Join("out", "analyze")
+	must(os.RemoveAll(outDir))
+	createDirMust(outDir)
+	logPath := buildAnalyze(outDir)
+
+	results := parseAnalyzeWarnings(string(readFileMust(logPath)))
+	run := &SarifRun{
+		Tool: SarifTool{Driver: SarifDriver{
+			Name:           "MSVC /analyze",
+			InformationURI: "https://learn.microsoft.com/en-us/cpp/code-quality/code-analysis-for-c-cpp-overview",
+		}},
+		Results: results,
+	}
+	sarifPath := filepath.Join(outDir, "analyze.sarif")
+	writeSarifMust(sarifPath, newSarifLog(run))
+
+	baselinePath := filepath.Join("do", "analyze_baseline.txt")
+	if updateBaseline {
+		var keys []string
+		for _, r := range results {
+			keys = append(keys, r.Key())
+		}
+		sort.Strings(keys)
+		keys = uniqueStrings(keys)
+		s := "# known /analyze warnings, re-generate with: do analyze msvc -update-baseline\n"
+		s += strings.Join(keys, "\n") + "\n"
+		writeFileMust(baselinePath, []byte(s))
+		logf("wrote '%s' with %d warnings\n", baselinePath, len(keys))
+	}
+
+	if upload {
+		uploadSarifToGitHubMust(sarifPath)
+	}
+
+	baseline := readSarifBaseline(baselinePath)
+	var newWarnings []string
+	for _, r := range results {
+		if !baseline[r.Key()] {
+			path, line := r.Location()
+			newWarnings = append(newWarnings, fmt.Sprintf("%s(%d): %s: %s", path, line, r.RuleID, r.Message.Text))
+		}
+	}
+	panicIf(len(newWarnings) > 0, "%d new /analyze warnings:\n%s\n", len(newWarnings), strings.Join(newWarnings, "\n"))
+	logf("no new /analyze warnings (%d known)\n", len(results))
+}
+
+// returns sorted s without duplicates
+func uniqueStrings(s []string) []string {
+	var res []string
+	for i, v := range s {
+		if i > 0 && v == s[i-1] {
+			continue
+		}
+		res = append(res, v)
+	}
+	return res
+}
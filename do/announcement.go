@@ -0,0 +1,237 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// after daily build is uploaded we generate an announcement of the
+// pre-release (markdown and html) with version, notable commits since the
+// previous pre-release, download links and sha256 of files. It's saved in
+// out/announcements/ and, if ANNOUNCE_WEBHOOK_URL is set, posted to it (the
+// website / forum). Without the webhook, or if posting fails, it's meant to
+// be posted manually.
+// do announce generates it for the build in out/final-prerel
+// Changes are commits since the previous daily build, whose sha we record
+// after uploading it (see setDailyLastShaMust)
+
+var announceWebhookURL string
+
+var announcementsDir = filepath.Join("out", "announcements")
+
+// don't list more than this many commits
+const announceMaxCommits = 40
+
+// commits not worth announcing
+var announceSkipCommitRx = regexp.MustCompile(`(?i)^(update(d)? translations|translations|wip\b|fix(ed)? typos?|typo|ci\b|bump\b|merge\b|formatting|clang-format)`)
+
+// AnnouncementCommit is a commit listed in the announcement
+type AnnouncementCommit struct {
+	Sha1    string
+	Author  string
+	Subject string
+}
+
+// Announcement of a pre-release build
+type Announcement struct {
+	Title   string
+	Ver     string
+	Date    string
+	GitSha1 string
+	// "" if there's no previous daily build
+	PrevVer string
+	Commits []*AnnouncementCommit
+	// number of notable commits we didn't list
+	MoreCommits int
+	Files       []*DownloadFile
+}
+
+func isNotableCommit(subject string) bool {
+	return !announceSkipCommitRx.MatchString(strings.TrimSpace(subject))
+}
+
+// returns notable commits selected by args of git log (e.g. sha..HEAD),
+// newest first
+func getNotableCommitsMust(args ...string) []*AnnouncementCommit {
+	args = append([]string{"log", "--no-merges", "--format=%h%x09%an%x09%s"}, args...)
+	out := runExeMust("git", args...)
+	var res []*AnnouncementCommit
+	for _, l := range toTrimmedLines(out) {
+		parts := strings.SplitN(l, "\t", 3)
+		if len(parts) != 3 || !isNotableCommit(parts[2]) {
+			continue
+		}
+		res = append(res, &AnnouncementCommit{Sha1: parts[0], Author: parts[1], Subject: parts[2]})
+	}
+	return res
+}
+
+func genAnnouncementMust(mc Storage) *Announcement {
+	verStr := getPreReleaseVer()
+	a := &Announcement{
+		Title:   fmt.Sprintf("SumatraPDF pre-release %s", verStr),
+		Ver:     verStr,
+		Date:    time.Now().UTC().Format("2006-01-02"),
+		GitSha1: getGitSha1Must(),
+	}
+
+	prevSha := getDailyLastSha(mc)
+	if prevSha != "" && !isGitCommit(prevSha) {
+		// e.g. history was rewritten
+		logf("previous daily build '%s' is not in git history, listing recent commits\n", prevSha)
+		prevSha = ""
+	}
+	if prevSha != "" {
+		a.PrevVer = strconv.Itoa(getGitLinearVersionOfMust(prevSha))
+		a.Commits = getNotableCommitsMust(prevSha + "..HEAD")
+	} else {
+		a.Commits = getNotableCommitsMust("-n", strconv.Itoa(announceMaxCommits))
+	}
+	if len(a.Commits) > announceMaxCommits {
+		a.MoreCommits = len(a.Commits) - announceMaxCommits
+		a.Commits = a.Commits[:announceMaxCommits]
+	}
+
+	dir := getFinalDirForBuildType(buildTypePreRel)
+	panicIf(!dirExists(dir), "'%s' doesn't exist, build pre-release first", dir)
+	hashes := calcDirHashesMust(dir)
+	for _, dl := range listDownloads(getDownloadUrlsViaWebsite(buildTypePreRel, verStr)) {
+		name := path.Base(dl[2])
+		sha, ok := hashes[name]
+		if !ok {
+			// partial build (-platforms)
+			continue
+		}
+		a.Files = append(a.Files, &DownloadFile{
+			Arch:   dl[0],
+			Kind:   dl[1],
+			URL:    dl[2],
+			Size:   fileSizeMust(filepath.Join(dir, name)),
+			Sha256: sha,
+		})
+	}
+	return a
+}
+
+func getCommitURL(sha string) string {
+	return fmt.Sprintf("https://github.com/%s/commit/%s", getGitHubRepo(), sha)
+}
+
+func fmtAnnouncementMarkdown(a *Announcement) string {
+	var lines []string
+	push(&lines, "# "+a.Title, "")
+	push(&lines, fmt.Sprintf("Version: %s, built on %s from [%s](%s)", a.Ver, a.Date, a.GitSha1[:8], getCommitURL(a.GitSha1)), "")
+	if a.PrevVer != "" {
+		push(&lines, fmt.Sprintf("## Changes since %s", a.PrevVer), "")
+	} else {
+		push(&lines, "## Recent changes", "")
+	}
+	if len(a.Commits) == 0 {
+		push(&lines, "No notable changes.")
+	}
+	for _, c := range a.Commits {
+		push(&lines, fmt.Sprintf("- %s ([%s](%s), %s)", c.Subject, c.Sha1, getCommitURL(c.Sha1), c.Author))
+	}
+	if a.MoreCommits > 0 {
+		push(&lines, fmt.Sprintf("- and %d more", a.MoreCommits))
+	}
+	push(&lines, "", "## Downloads", "")
+	push(&lines, "| | | size | sha256 |", "|---|---|---|---|")
+	for _, f := range a.Files {
+		push(&lines, fmt.Sprintf("| %s | [%s](%s) | %s | `%s` |", f.Arch, f.Kind, f.URL, formatSize(f.Size), f.Sha256))
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func fmtAnnouncementHTML(a *Announcement) string {
+	var lines []string
+	push(&lines, fmt.Sprintf("<h2>%s</h2>", html.EscapeString(a.Title)))
+	push(&lines, fmt.Sprintf("<p>Version: %s, built on %s from <a href=\"%s\">%s</a></p>", a.Ver, a.Date, getCommitURL(a.GitSha1), a.GitSha1[:8]))
+	if a.PrevVer != "" {
+		push(&lines, fmt.Sprintf("<h3>Changes since %s</h3>", a.PrevVer))
+	} else {
+		push(&lines, "<h3>Recent changes</h3>")
+	}
+	if len(a.Commits) == 0 {
+		push(&lines, "<p>No notable changes.</p>")
+	} else {
+		push(&lines, "<ul>")
+		for _, c := range a.Commits {
+			push(&lines, fmt.Sprintf("  <li>%s (<a href=\"%s\">%s</a>, %s)</li>", html.EscapeString(c.Subject), getCommitURL(c.Sha1), c.Sha1, html.EscapeString(c.Author)))
+		}
+		if a.MoreCommits > 0 {
+			push(&lines, fmt.Sprintf("  <li>and %d more</li>", a.MoreCommits))
+		}
+		push(&lines, "</ul>")
+	}
+	push(&lines, "<h3>Downloads</h3>", "<table class=\"downloads\">")
+	for _, f := range a.Files {
+		push(&lines, fmt.Sprintf("  <tr><td>%s</td><td><a href=\"%s\">%s</a></td><td>%s</td><td class=\"sha256\">%s</td></tr>", f.Arch, html.EscapeString(f.URL), f.Kind, formatSize(f.Size), f.Sha256))
+	}
+	push(&lines, "</table>")
+	return strings.Join(lines, "\n") + "\n"
+}
+
+// posts announcement to ANNOUNCE_WEBHOOK_URL as json with title, markdown
+// and html
+func postAnnouncement(a *Announcement, md string, htmlStr string) error {
+	body := map[string]string{
+		"title":    a.Title,
+		"version":  a.Ver,
+		"markdown": md,
+		"html":     htmlStr,
+	}
+	d, err := json.Marshal(body)
+	must(err)
+	req, err := http.NewRequest(http.MethodPost, announceWebhookURL, bytes.NewReader(d))
+	must(err)
+	req.Header.Set("Content-Type", "application/json")
+	client := &http.Client{Timeout: time.Minute}
+	rsp, err := client.Do(req)
+	if err != nil {
+		// error has the url which has a secret
+		return fmt.Errorf("%s", redactSecrets(err.Error()))
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode >= 400 {
+		d, _ = io.ReadAll(rsp.Body)
+		return fmt.Errorf("failed with %s: %s", rsp.Status, string(d))
+	}
+	return nil
+}
+
+// generates, saves and posts announcement of the pre-release in
+// out/final-prerel. Failing to post doesn't fail the build because the
+// build is already uploaded
+func announcePreReleaseMust() {
+	a := genAnnouncementMust(newMinioR2Client())
+	md := fmtAnnouncementMarkdown(a)
+	htmlStr := fmtAnnouncementHTML(a)
+	dir := createDirMust(announcementsDir)
+	name := "prerel-" + a.Ver
+	writeFileMust(filepath.Join(dir, name+".md"), []byte(md))
+	writeFileMust(filepath.Join(dir, name+".html"), []byte(htmlStr))
+	logf("wrote announcement of %s to '%s', %d notable commits since '%s'\n", a.Ver, filepath.Join(dir, name+".{md,html}"), len(a.Commits), a.PrevVer)
+
+	if announceWebhookURL == "" {
+		logf("ANNOUNCE_WEBHOOK_URL not set, post the announcement manually\n")
+		return
+	}
+	if err := postAnnouncement(a, md, htmlStr); err != nil {
+		msg := fmt.Sprintf("failed to post announcement of %s: %s, post '%s' manually", a.Ver, err, filepath.Join(dir, name+".md"))
+		logf("%s\n", msg)
+		emitGitHubAnnotation("warning", "", 0, 0, msg)
+		return
+	}
+	logf("posted announcement of %s\n", a.Ver)
+}
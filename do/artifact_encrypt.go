@@ -0,0 +1,155 @@
+package main
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// builds for a private pre-release channel (e.g. ASAN builds we share with
+// specific testers) can be encrypted before upload:
+// -encrypt-artifacts '*-asan*' replaces matching files in out/final-* with
+// ${name}.enc encrypted with AES-256-GCM so only those files are uploaded.
+// The key is ARTIFACTS_KEY (64 hex chars) from secrets, testers get it from
+// us and decrypt with:
+// do artifacts decrypt ${path or url of .enc file}
+// New key: do artifacts gen-key
+// Encrypted file is: encryptedMagic, id of the key (first 8 bytes of its
+// sha256, in hex), '\n', 12 byte nonce, ciphertext
+
+var (
+	flgEncryptArtifacts string
+	artifactsKey        string
+)
+
+const (
+	encryptedMagic = "do-enc-v1\n"
+	encryptedExt   = ".enc"
+)
+
+func getArtifactsKeyMust() []byte {
+	failIf(errKindPreflight, artifactsKey == "", "need ARTIFACTS_KEY env variable or secret, generate one with: do artifacts gen-key")
+	key, err := hex.DecodeString(strings.TrimSpace(artifactsKey))
+	failIf(errKindPreflight, err != nil || len(key) != 32, "ARTIFACTS_KEY must be 64 hex characters (256-bit key)")
+	return key
+}
+
+func getArtifactsKeyID(key []byte) string {
+	return sha256Hex(key)[:16]
+}
+
+func genArtifactsKey() {
+	key := make([]byte, 32)
+	_, err := rand.Read(key)
+	must(err)
+	s := hex.EncodeToString(key)
+	logf("ARTIFACTS_KEY=%s\n", s)
+	logf("key id: %s\n", getArtifactsKeyID(key))
+}
+
+func newArtifactsAEADMust(key []byte) cipher.AEAD {
+	block, err := aes.NewCipher(key)
+	must(err)
+	aead, err := cipher.NewGCM(block)
+	must(err)
+	return aead
+}
+
+func encryptArtifact(key []byte, d []byte) []byte {
+	aead := newArtifactsAEADMust(key)
+	nonce := make([]byte, aead.NonceSize())
+	_, err := rand.Read(nonce)
+	must(err)
+	var buf bytes.Buffer
+	buf.WriteString(encryptedMagic)
+	buf.WriteString(getArtifactsKeyID(key) + "\n")
+	buf.Write(nonce)
+	// header is authenticated so it can't be swapped
+	header := buf.Bytes()
+	return aead.Seal(header, nonce, d, header)
+}
+
+func decryptArtifact(key []byte, d []byte) ([]byte, error) {
+	if !bytes.HasPrefix(d, []byte(encryptedMagic)) {
+		return nil, fmt.Errorf("not an encrypted artifact")
+	}
+	s := d[len(encryptedMagic):]
+	keyID, _, ok := bytes.Cut(s, []byte("\n"))
+	if !ok {
+		return nil, fmt.Errorf("invalid header")
+	}
+	if string(keyID) != getArtifactsKeyID(key) {
+		return nil, fmt.Errorf("encrypted with key %s, ARTIFACTS_KEY is %s", keyID, getArtifactsKeyID(key))
+	}
+	aead := newArtifactsAEADMust(key)
+	headerLen := len(encryptedMagic) + len(keyID) + 1 + aead.NonceSize()
+	if len(d) < headerLen {
+		return nil, fmt.Errorf("file is truncated")
+	}
+	nonce := d[headerLen-aead.NonceSize() : headerLen]
+	return aead.Open(nil, nonce, d[headerLen:], d[:headerLen])
+}
+
+// returns true if file name matches one of comma-separated patterns
+func matchesArtifactPatterns(name string, patterns string) bool {
+	for _, pattern := range strings.Split(patterns, ",") {
+		pattern = strings.TrimSpace(pattern)
+		if ok, err := filepath.Match(pattern, name); err == nil && ok {
+			return true
+		}
+	}
+	return false
+}
+
+// replaces files in out/final-* matching -encrypt-artifacts with encrypted
+// ${name}.enc. Must be called before uploading
+func encryptSelectedArtifactsMust(buildType BuildType) {
+	if flgEncryptArtifacts == "" {
+		return
+	}
+	key := getArtifactsKeyMust()
+	dir := getFinalDirForBuildType(buildType)
+	files, err := os.ReadDir(dir)
+	must(err)
+	n := 0
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !matchesArtifactPatterns(strings.TrimSuffix(name, encryptedExt), flgEncryptArtifacts) {
+			continue
+		}
+		n++
+		if strings.HasSuffix(name, encryptedExt) {
+			// encrypted by previous upload step
+			continue
+		}
+		path := filepath.Join(dir, name)
+		writeFileMust(path+encryptedExt, encryptArtifact(key, readFileMust(path)))
+		must(os.Remove(path))
+		logf("encrypted '%s' => '%s'\n", path, path+encryptedExt)
+	}
+	panicIf(n == 0, "no files in '%s' match -encrypt-artifacts '%s'", dir, flgEncryptArtifacts)
+}
+
+// src is path or url of .enc file, writes decrypted file to current
+// directory without .enc
+func decryptArtifactMust(src string) {
+	key := getArtifactsKeyMust()
+	var d []byte
+	if strings.HasPrefix(src, "https://") || strings.HasPrefix(src, "http://") {
+		d = httpGetMust(src)
+	} else {
+		d = readFileMust(src)
+	}
+	res, err := decryptArtifact(key, d)
+	failIf(errKindVerify, err != nil, "failed to decrypt '%s': %s", src, err)
+	name := strings.TrimSuffix(filepath.Base(src), encryptedExt)
+	panicIf(name == filepath.Base(src), "'%s' doesn't end with %s", src, encryptedExt)
+	writeFileMust(name, res)
+	logf("decrypted '%s' => '%s' (%s)\n", src, name, formatSize(int64(len(res))))
+}
@@ -0,0 +1,181 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// do builds bisect finds the build (commit) that introduced a regression:
+// do builds bisect -bisect-good 15432 -bisect-bad 15480 -- repro.bat ${exe}
+// Build numbers are pre-release versions (see getGitLinearVersionMust).
+// For each candidate we download pre-release SumatraPDF-prerel-64.exe from
+// storage or, if it was already deleted, build it from a git worktree in
+// out/bisect/${ver}/src. Then we run the repro command with ${exe} replaced
+// by path of SumatraPDF.exe (also in SUMATRAPDF_EXE env variable).
+// Like with git bisect run: exit code 0 means good, 125 means
+// can't test (skip), anything else means bad
+
+var (
+	flgBisectGood int
+	flgBisectBad  int
+)
+
+// exit code of repro command for candidates that can't be tested
+const bisectSkipExitCode = 125
+
+type bisectResult int
+
+const (
+	bisectGood bisectResult = iota
+	bisectBad
+	bisectSkip
+)
+
+// returns sha of commit for build number ver, same as printBuildNoInfo
+func getCommitForBuildMust(ver int) string {
+	out := runExeMust("git", "log", "--format=%H")
+	lines := toTrimmedLines(out)
+	n := len(lines) - (ver - 1000)
+	panicIf(n < 0 || n >= len(lines), "build %d is not in git history", ver)
+	return lines[n]
+}
+
+func downloadPreReleaseExe(ver int, dstPath string) bool {
+	if r2Access == "" {
+		return false
+	}
+	mc := newMinioR2Client()
+	remotePath := fmt.Sprintf("software/sumatrapdf/%s/%d/SumatraPDF-prerel-64.exe", buildTypePreRel, ver)
+	if !mc.Exists(remotePath) {
+		logf("'%s' doesn't exist in storage\n", remotePath)
+		return false
+	}
+	must(mc.DownloadFileAtomically(dstPath, remotePath))
+	logf("downloaded '%s'\n", remotePath)
+	return true
+}
+
+// builds SumatraPDF.exe at sha in a separate git worktree so that
+// the current checkout and out/ are not affected
+func buildExeAtCommitMust(sha string, dir string, dstPath string) {
+	srcDir := absPathMust(filepath.Join(dir, "src"))
+	os.RemoveAll(srcDir)
+	runExeLoggedMust("git", "worktree", "add", "--detach", srcDir, sha)
+	defer runExeLoggedMust("git", "worktree", "remove", "--force", srcDir)
+
+	p := fmt.Sprintf(`/p:Configuration=Release;Platform=%s`, kPlatformIntel64)
+	cmd := exec.Command(detectMsbuildPath(), filepath.Join("vs2022", "SumatraPDF.sln"), `/t:SumatraPDF`, p, `/m`)
+	cmd.Dir = srcDir
+	runCmdLoggedMust(cmd)
+	must(copyFile(dstPath, filepath.Join(srcDir, "out", "rel64", "SumatraPDF.exe")))
+}
+
+// returns path of SumatraPDF.exe for build ver
+func getExeForBuildMust(ver int, sha string) string {
+	dir := createDirMust(filepath.Join("out", "bisect", strconv.Itoa(ver)))
+	exePath := filepath.Join(dir, "SumatraPDF.exe")
+	if fileExists(exePath) {
+		return absPathMust(exePath)
+	}
+	if !downloadPreReleaseExe(ver, exePath) {
+		logf("building %d (%s)\n", ver, sha)
+		buildExeAtCommitMust(sha, dir, exePath)
+	}
+	return absPathMust(exePath)
+}
+
+func runBisectRepro(repro []string, exePath string) bisectResult {
+	var args []string
+	for _, arg := range repro {
+		args = append(args, strings.ReplaceAll(arg, "${exe}", exePath))
+	}
+	cmd := exec.Command(args[0], args[1:]...)
+	cmd.Env = append(os.Environ(), "SUMATRAPDF_EXE="+exePath)
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	err := cmd.Run()
+	if err == nil {
+		return bisectGood
+	}
+	var exitErr *exec.ExitError
+	panicIf(!errors.As(err, &exitErr), "failed to run '%s': %s", strings.Join(args, " "), err)
+	if exitErr.ExitCode() == bisectSkipExitCode {
+		return bisectSkip
+	}
+	return bisectBad
+}
+
+// candidates are builds between good and bad. test returns result for
+// candidate at index. Returns index of first bad build, len(candidates)
+// if it's bad, and skipped candidates that prevented narrowing it down
+func bisectBuilds(candidates []int, test func(ver int) bisectResult) (int, []int) {
+	lo, hi := 0, len(candidates)
+	skipped := map[int]bool{}
+	for lo < hi {
+		mid := (lo + hi) / 2
+		// if mid can't be tested, try the closest candidate that can
+		idx := -1
+		for d := 0; d < hi-lo; d++ {
+			for _, i := range []int{mid - d, mid + d} {
+				if idx == -1 && i >= lo && i < hi && !skipped[i] {
+					idx = i
+				}
+			}
+		}
+		if idx == -1 {
+			break
+		}
+		switch test(candidates[idx]) {
+		case bisectGood:
+			lo = idx + 1
+		case bisectBad:
+			hi = idx
+		case bisectSkip:
+			skipped[idx] = true
+		}
+	}
+	var unresolved []int
+	for i := lo; i < hi; i++ {
+		if skipped[i] {
+			unresolved = append(unresolved, candidates[i])
+		}
+	}
+	return hi, unresolved
+}
+
+func bisect(repro []string) {
+	good, bad := flgBisectGood, flgBisectBad
+	panicIf(good == 0 || bad == 0, "need -bisect-good and -bisect-bad build numbers")
+	panicIf(good >= bad, "-bisect-good (%d) must be smaller than -bisect-bad (%d)", good, bad)
+	panicIf(len(repro) == 0, "need repro command after --, e.g.: do builds bisect -bisect-good 15432 -bisect-bad 15480 -- repro.bat ${exe}")
+
+	var candidates []int
+	for ver := good + 1; ver < bad; ver++ {
+		candidates = append(candidates, ver)
+	}
+	logf("bisecting %d builds between good %d and bad %d\n", len(candidates), good, bad)
+	test := func(ver int) bisectResult {
+		sha := getCommitForBuildMust(ver)
+		exePath := getExeForBuildMust(ver, sha)
+		res := runBisectRepro(repro, exePath)
+		logf("build %d (%s): %s\n", ver, sha[:8], []string{"good", "bad", "skip"}[res])
+		return res
+	}
+	idx, unresolved := bisectBuilds(candidates, test)
+	firstBad := bad
+	if idx < len(candidates) {
+		firstBad = candidates[idx]
+	}
+	if len(unresolved) > 0 {
+		logf("couldn't test builds %v, first bad build is one of them or %d\n", unresolved, firstBad)
+	}
+	sha := getCommitForBuildMust(firstBad)
+	logf("first bad build: %d\n", firstBad)
+	out := runExeMust("git", "show", "--no-patch", "--format=commit %H%nAuthor: %an <%ae>%nDate:   %ad%n%n    %s", sha)
+	logf("%s\n", string(out))
+}
@@ -2,10 +2,13 @@ package main
 
 import (
 	"archive/zip"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/exec"
 	"path/filepath"
+	"sort"
 	"strconv"
 	"strings"
 	"time"
@@ -48,14 +51,25 @@ func verifyCorrectVersionMust(ver string) {
 func getFileNamesWithPrefix(prefix string) [][]string {
 	files := [][]string{
 		{"SumatraPDF.exe", fmt.Sprintf("%s.exe", prefix)},
-		{"SumatraPDF.zip", fmt.Sprintf("%s.zip", prefix)},
 		{"SumatraPDF-dll.exe", fmt.Sprintf("%s-install.exe", prefix)},
 		{"SumatraPDF.pdb.zip", fmt.Sprintf("%s.pdb.zip", prefix)},
 		{"SumatraPDF.pdb.lzsa", fmt.Sprintf("%s.pdb.lzsa", prefix)},
 	}
+	for _, f := range portableFormats {
+		files = append(files, []string{f.FileName, prefix + f.ReleaseSuffix})
+	}
 	return files
 }
 
+// prefix of final file names for a given platform e.g. "SumatraPDF-3.5-64"
+// or "SumatraPDF-prerel-arm64". 32-bit release files have no suffix
+func getPrefixForPlatform(prefix string, buildType BuildType, platform string) string {
+	if buildType == buildTypeRel && platform == kPlatformIntel32 {
+		return prefix
+	}
+	return prefix + "-" + getSuffixForPlatform(platform)
+}
+
 func copyBuiltFiles(dstDir string, srcDir string, prefix string) {
 	files := getFileNamesWithPrefix(prefix)
 	for _, f := range files {
@@ -106,6 +120,7 @@ func detectVersions() {
 
 // remove all files and directories under out/ except settings files
 func cleanPreserveSettings() {
+	acquireBuildLockMust()
 	entries, err := os.ReadDir("out")
 	if err != nil {
 		// assuming 'out' doesn't exist, which is fine
@@ -144,6 +159,7 @@ func cleanPreserveSettings() {
 }
 
 func cleanReleaseBuilds() {
+	acquireBuildLockMust()
 	os.RemoveAll(rel32Dir)
 	os.RemoveAll(rel64Dir)
 	os.RemoveAll(relArm64Dir)
@@ -153,7 +169,13 @@ func cleanReleaseBuilds() {
 func runTestUtilMust(dir string) {
 	cmd := exec.Command(`.\test_util.exe`)
 	cmd.Dir = dir
-	runCmdLoggedMust(cmd)
+	logf("> %s\n", fmdCmdShort(cmd))
+	var out bytes.Buffer
+	flush := redactCmdOutput(cmd, io.MultiWriter(os.Stdout, &out), io.MultiWriter(os.Stderr, &out))
+	err := runCmdWithTimeout(cmd)
+	flush()
+	recordTestResult(dir, err == nil, out.String())
+	must(err)
 }
 
 func buildLzsa() {
@@ -163,14 +185,28 @@ func buildLzsa() {
 	defer makePrintDuration("buildLzsa")()
 	cleanPreserveSettings()
 
-	msbuildPath := detectMsbuildPath()
-	runExeLoggedMust(msbuildPath, `vs2022\MakeLZSA.sln`, `/t:MakeLZSA:Rebuild`, `/p:Configuration=Release;Platform=Win32`, `/m`)
+	runMsbuildMust(`vs2022\MakeLZSA.sln`, `/t:MakeLZSA:Rebuild`, `/p:Configuration=Release;Platform=Win32`, `/m`)
 
 	path := filepath.Join("out", "rel32", "MakeLZSA.exe")
 	signMust(path)
 	logf("build and signed '%s'\n", path)
 }
 
+// bin\MakeLZSA.exe stores modification times of files, so the installer payload
+// (InstallerData.dat) and .pdb.lzsa would differ between builds of the same code.
+// We build MakeLZSA.exe from src/tools/MakeLzSA.cpp, which doesn't, and pass it
+// to the prebuild step of SumatraPDF-dll (see MakeLzsaExe in premake5.lua)
+// It's built into out/tools and not out/rel32, which would make it look like
+// we've built 32-bit release (e.g. in createManifestMust)
+func buildMakeLzsaMust() string {
+	dir := absPathMust(filepath.Join("out", "tools"))
+	outDir := fmt.Sprintf(`/p:OutDir=%s\;IntDir=%s\`, dir, filepath.Join(dir, "obj"))
+	runMsbuildMust(`vs2022\MakeLZSA.sln`, `/t:MakeLZSA`, `/p:Configuration=Release;Platform=Win32`, outDir, `/m`)
+	path := filepath.Join(dir, "MakeLZSA.exe")
+	panicIf(!fileExists(path), "file '%s' doesn't exist", path)
+	return path
+}
+
 func buildConfigPath() string {
 	return filepath.Join("src", "utils", "BuildConfig.h")
 }
@@ -201,13 +237,18 @@ func revertBuildConfig() {
 	runExeMust("git", "checkout", buildConfigPath())
 }
 
+// all files in archives we create get the same time and permissions so that
+// archives created from identical files are byte-identical
+// Must match kArchiveModTime in src/tools/MakeLzSA.cpp
+var archiveModTime = time.Date(2000, 1, 1, 0, 0, 0, 0, time.UTC)
+
 func addZipFileWithNameMust(w *zip.Writer, path, nameInZip string) {
-	fi, err := os.Stat(path)
-	must(err)
-	fih, err := zip.FileInfoHeader(fi)
-	must(err)
-	fih.Name = nameInZip
-	fih.Method = zip.Deflate
+	fih := &zip.FileHeader{
+		Name:     filepath.ToSlash(nameInZip),
+		Method:   zip.Deflate,
+		Modified: archiveModTime,
+	}
+	fih.SetMode(0644)
 	d, err := os.ReadFile(path)
 	must(err)
 	fw, err := w.CreateHeader(fih)
@@ -275,7 +316,9 @@ func createPdbZipMust(dir string) {
 	defer f.Close()
 	w := zip.NewWriter(f)
 
-	for _, file := range pdbFiles {
+	files := append([]string{}, pdbFiles...)
+	sort.Strings(files)
+	for _, file := range files {
 		addZipFileMust(w, filepath.Join(dir, file))
 	}
 
@@ -286,20 +329,17 @@ func createPdbZipMust(dir string) {
 func createPdbLzsaMust(dir string) {
 	args := []string{"SumatraPDF.pdb.lzsa"}
 	args = append(args, pdbFiles...)
-	curDir, err := os.Getwd()
-	must(err)
-	makeLzsaPath := filepath.Join(curDir, "bin", "MakeLZSA.exe")
-	cmd := exec.Command(makeLzsaPath, args...)
+	cmd := exec.Command(buildMakeLzsaMust(), args...)
 	cmd.Dir = dir
 	runCmdLoggedMust(cmd)
 }
 
 // manifest is build for pre-release builds and contains information about file sizes
+// and hashes
 func createManifestMust() {
 	var lines []string
 	files := []string{
 		"SumatraPDF.exe",
-		"SumatraPDF.zip",
 		"SumatraPDF-dll.exe",
 		"libmupdf.dll",
 		"PdfFilter.dll",
@@ -307,22 +347,29 @@ func createManifestMust() {
 		"SumatraPDF.pdb.zip",
 		"SumatraPDF.pdb.lzsa",
 	}
+	files = append(files, getPortableArchiveFileNames()...)
 	var dirs []string
 	// 32bit / arm64 are only in daily build
-	for _, dir := range []string{rel32Dir, rel64Dir, relArm64Dir} {
+	for _, dir := range getSelectedOutDirs() {
 		if pathExists(dir) {
 			dirs = append(dirs, dir)
 		}
 	}
 	panicIf(len(dirs) == 0, "didn't find any dirs for the manifest")
+	var paths []string
 	for _, dir := range dirs {
 		for _, file := range files {
-			path := filepath.Join(dir, file)
-			size := fileSizeMust(path)
-			line := fmt.Sprintf("%s: %d", path, size)
-			lines = append(lines, line)
+			paths = append(paths, filepath.Join(dir, file))
 		}
 	}
+	sizes := map[string]int64{}
+	for _, a := range hashFilesMust(paths) {
+		lines = append(lines, a.ManifestLine())
+		sizes[strings.TrimPrefix(filepath.ToSlash(a.Path), "out/")] = a.Size
+	}
+	lines = append(lines, checkSizeBudgetsMust(sizes)...)
+	lines = append(lines, fmtUpxResultsForManifest()...)
+	lines = append(lines, fmtPortableArchivesForManifest()...)
 
 	s := strings.Join(lines, "\n")
 	artifactsDir := filepath.Join("out", "artifacts")
@@ -342,16 +389,38 @@ func createManifestMust() {
 // }
 
 func signFilesMust(dir string) {
+	defer stepHooks("sign", filepath.Base(dir))()
 	logf("signFileMust: '%s'\n", dir)
 	//listFilesInDir(dir)
 
-	if fileExists(filepath.Join(dir, "SumatraPDF.exe")) {
-		signMust(filepath.Join(dir, "SumatraPDF.exe"))
+	signFilesBatchMust(getFilesToSign(dir))
+}
+
+func getFilesToSign(dir string) []string {
+	var res []string
+	for _, name := range signedFileNames {
+		path := filepath.Join(dir, name)
+		// SumatraPDF.exe is not built in all configurations
+		if name == "SumatraPDF.exe" && !fileExists(path) {
+			continue
+		}
+		res = append(res, path)
 	}
-	signMust(filepath.Join(dir, "libmupdf.dll"))
-	signMust(filepath.Join(dir, "PdfFilter.dll"))
-	signMust(filepath.Join(dir, "PdfPreview.dll"))
-	signMust(filepath.Join(dir, "SumatraPDF-dll.exe"))
+	return res
+}
+
+// signs files in multiple directories in parallel
+func signFilesInDirsMust(dirs ...string) {
+	var names []string
+	for _, dir := range dirs {
+		names = append(names, filepath.Base(dir))
+	}
+	defer stepHooks("sign", strings.Join(names, ","))()
+	var paths []string
+	for _, dir := range dirs {
+		paths = append(paths, getFilesToSign(dir)...)
+	}
+	signFilesBatchMust(paths)
 }
 
 func signFilesOptional(dir string) {
@@ -389,44 +458,78 @@ func getOutDirForPlatform(platform string) string {
 }
 
 func build(config, platform string, sign bool) {
-	msbuildPath := detectMsbuildPath()
+	acquireBuildLockMust()
 	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
 
 	dir := getOutDirForPlatform(platform)
+	defer stepHooks("build", filepath.Base(dir))()
 
 	p := fmt.Sprintf(`/p:Configuration=%s;Platform=%s`, config, platform)
-	runExeLoggedMust(msbuildPath, slnPath, `/t:test_util:Rebuild`, p, `/m`)
+	runStepWithRetry("build test_util", func() {
+		runMsbuildMust(slnPath, `/t:test_util:Rebuild`, p, `/m`)
+	})
 	// can't run arm binaries in x86 CI
 	if platform != kPlatformArm64 {
 		runTestUtilMust(dir)
 	}
 
-	runExeLoggedMust(msbuildPath, slnPath, `/t:SumatraPDF:Rebuild;SumatraPDF-dll:Rebuild;PdfFilter:Rebuild;PdfPreview:Rebuild`, p, `/m`)
+	makeLzsaArg := "/p:MakeLzsaExe=" + buildMakeLzsaMust()
+	args := []string{slnPath, `/t:SumatraPDF:Rebuild;SumatraPDF-dll:Rebuild;PdfFilter:Rebuild;PdfPreview:Rebuild`, p, makeLzsaArg, `/m`}
+	if pgoArg := getPgoMsbuildArg(platform); pgoArg != "" {
+		args = append(args, pgoArg)
+	}
+	runStepWithRetry("build", func() {
+		runMsbuildMust(args...)
+	})
+	auditInstallerPayloadMust(dir, platform)
+	auditManifestsMust(dir)
+	checkLibmupdfExportsMust(dir, false)
+	verifyPdbsMatchMust(dir)
+	if flgUpx {
+		upxCompressMust(dir, platform)
+	}
 	if sign {
+		// signBatchInDirMust retries on timestamp server errors
 		signFilesMust(dir)
 	}
+	sourceIndexPdbsMust(dir)
 	createPdbZipMust(dir)
 	createPdbLzsaMust(dir)
 }
 
 // builds more targets, even those not used, to prevent code rot
 func buildAll(config, platform string, sign bool) {
-	msbuildPath := detectMsbuildPath()
+	acquireBuildLockMust()
 	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
 
 	dir := getOutDirForPlatform(platform)
+	defer stepHooks("build", filepath.Base(dir))()
 
 	p := fmt.Sprintf(`/p:Configuration=%s;Platform=%s`, config, platform)
-	runExeLoggedMust(msbuildPath, slnPath, `/t:test_util:Rebuild`, p, `/m`)
+	runStepWithRetry("build test_util", func() {
+		runMsbuildMust(slnPath, `/t:test_util:Rebuild`, p, `/m`)
+	})
 	// can't run arm binaries in x86 CI
 	if platform != kPlatformArm64 {
 		runTestUtilMust(dir)
 	}
 
-	runExeLoggedMust(msbuildPath, slnPath, `/t:signfile:Rebuild;sizer:Rebuild;PdfFilter:Rebuild;plugin-test:Rebuild;PdfPreview:Rebuild;PdfPreviewTest:Rebuild;SumatraPDF:Rebuild;SumatraPDF-dll:Rebuild`, p, `/m`)
+	makeLzsaArg := "/p:MakeLzsaExe=" + buildMakeLzsaMust()
+	runStepWithRetry("build", func() {
+		runMsbuildMust(slnPath, `/t:signfile:Rebuild;sizer:Rebuild;PdfFilter:Rebuild;plugin-test:Rebuild;PdfPreview:Rebuild;PdfPreviewTest:Rebuild;SumatraPDF:Rebuild;SumatraPDF-dll:Rebuild`, p, makeLzsaArg, `/m`)
+	})
+	auditInstallerPayloadMust(dir, platform)
+	auditManifestsMust(dir)
+	checkLibmupdfExportsMust(dir, false)
+	verifyPdbsMatchMust(dir)
+	if flgUpx {
+		upxCompressMust(dir, platform)
+	}
 	if sign {
+		// signBatchInDirMust retries on timestamp server errors
 		signFilesMust(dir)
 	}
+	sourceIndexPdbsMust(dir)
 	createPdbZipMust(dir)
 	createPdbLzsaMust(dir)
 }
@@ -444,38 +547,48 @@ func getSuffixForPlatform(platform string) string {
 	return ""
 }
 
-func buildCiDaily() {
+// returns false if daily build should be skipped because there were no
+// new commits or it was already uploaded
+func shouldBuildDaily() bool {
+	if r2Access != "" {
+		sha := getGitSha1Must()
+		if getDailyLastSha(newMinioR2Client()) == sha {
+			msg := fmt.Sprintf("skipping daily build because there were no commits since the last daily build (%s)", sha[:8])
+			logf("shouldBuildDaily: %s\n", msg)
+			emitGitHubAnnotation("notice", "", 0, 0, msg)
+			return false
+		}
+	}
 	isUploaded := isBuildAlreadyUploaded(newMinioBackblazeClient(), buildTypePreRel)
 	if isUploaded {
-		logf("buildCiDaily: skipping build because already built and uploaded")
-		return
+		logf("shouldBuildDaily: skipping build because already built and uploaded")
+		return false
 	}
-
-	cleanReleaseBuilds()
-	buildPreRelease(kPlatformArm64, false)
-	buildPreRelease(kPlatformIntel32, false)
-	buildPreRelease(kPlatformIntel64, false)
+	return true
 }
 
 func buildCi() {
-	gev := getGitHubEventType()
+	gev := getCIEventType()
 	switch gev {
-	case githubEventPush:
+	case ciEventPush:
+		checkGeneratedDocsMust()
 		cleanReleaseBuilds()
 		// I'm typically building 64-bit so in ci build 32-bit
 		// and build all projects, to find regressions in code
 		// I'm not regularly building while developing
 		buildPreRelease(kPlatformIntel32, true)
-	case githubEventTypeCodeQL:
+	case ciEventTypeCodeQL:
 		// code ql is just a regular build, I assume intercepted by
 		// by their tooling
 		buildSmoke()
 	default:
-		panic("unkown value from getGitHubEventType()")
+		panic("unkown value from getCIEventType()")
 	}
 }
 
 func buildPreRelease(platform string, all bool) {
+	preflightMust()
+	checkToolchainMust()
 	// make sure we can sign the executables, early exit if missing
 	detectSigntoolPath()
 
@@ -491,21 +604,32 @@ func buildPreRelease(platform string, all bool) {
 	} else {
 		build("Release", platform, true)
 	}
+	packagePreRelease(platform)
+}
 
+// creates portable archives and manifests from signed files and copies them to
+// the directory for upload
+func packagePreRelease(platform string) {
+	defer stepHooks("package", filepath.Base(getOutDirForPlatform(platform)))()
+	ver := getVerForBuildType(buildTypePreRel)
 	suffix := getSuffixForPlatform(platform)
 	outDir := getOutDirForPlatform(platform)
 	nameInZip := fmt.Sprintf("SumatraPDF-prerel-%s-%s.exe", ver, suffix)
-	createExeZipWithGoWithNameMust(outDir, nameInZip)
+	createPortableArchivesMust(outDir, nameInZip)
 
 	createManifestMust()
 
 	dstDir := getFinalDirForBuildType(buildTypePreRel)
 	prefix := "SumatraPDF-prerel"
-	copyBuiltFiles(dstDir, outDir, prefix+"-"+suffix)
+	copyBuiltFiles(dstDir, outDir, getPrefixForPlatform(prefix, buildTypePreRel, platform))
 	copyBuiltManifest(dstDir, prefix)
+	createSigningManifestMust(dstDir, prefix, buildTypePreRel)
+	mergePriorBuildFilesMust(buildTypePreRel, dstDir, prefix)
 }
 
 func buildRelease() {
+	preflightMust()
+	checkToolchainMust()
 	// make sure we can sign the executables, early exit if missing
 	detectSigntoolPath()
 
@@ -513,33 +637,44 @@ func buildRelease() {
 	s := fmt.Sprintf("buidling release version %s", ver)
 	defer makePrintDuration(s)()
 
-	verifyBuildNotInStorageMust(newMinioR2Client(), buildTypeRel)
-	verifyBuildNotInStorageMust(newMinioBackblazeClient(), buildTypeRel)
+	// respin of some platforms replaces files of the uploaded build
+	if !isPartialPlatformsBuild() {
+		verifyBuildNotInStorageMust(newMinioR2Client(), buildTypeRel)
+		verifyBuildNotInStorageMust(newMinioBackblazeClient(), buildTypeRel)
+	}
 
 	cleanReleaseBuilds()
 	setBuildConfigRelease()
 	defer revertBuildConfig()
 
-	build("Release", kPlatformIntel32, true)
-	nameInZip := fmt.Sprintf("SumatraPDF-%s-32.exe", ver)
-	createExeZipWithGoWithNameMust(rel32Dir, nameInZip)
-
-	build("Release", kPlatformIntel64, true)
-	nameInZip = fmt.Sprintf("SumatraPDF-%s-64.exe", ver)
-	createExeZipWithGoWithNameMust(rel64Dir, nameInZip)
-
-	build("Release", kPlatformArm64, true)
-	nameInZip = fmt.Sprintf("SumatraPDF-%s-arm64.exe", ver)
-	createExeZipWithGoWithNameMust(relArm64Dir, nameInZip)
+	platforms := filterSelectedPlatforms([]string{kPlatformIntel32, kPlatformIntel64, kPlatformArm64})
+	// sign all platforms at once, in parallel, after they're built
+	for _, platform := range platforms {
+		runIndependentStep("build "+platform, func() {
+			build("Release", platform, false)
+		})
+	}
+	failIfStepsFailed()
+	dirs := getSelectedOutDirs()
+	verifyVersionInfoMust("", dirs...)
+	signFilesInDirsMust(dirs...)
+
+	for _, platform := range platforms {
+		nameInZip := fmt.Sprintf("SumatraPDF-%s-%s.exe", ver, getSuffixForPlatform(platform))
+		createPortableArchivesMust(getOutDirForPlatform(platform), nameInZip)
+	}
 
 	createManifestMust()
 
 	dstDir := getFinalDirForBuildType(buildTypeRel)
 	prefix := fmt.Sprintf("SumatraPDF-%s", ver)
-	copyBuiltFiles(dstDir, rel32Dir, prefix)
-	copyBuiltFiles(dstDir, rel64Dir, prefix+"-64")
-	copyBuiltFiles(dstDir, relArm64Dir, prefix+"-arm64")
+	for _, platform := range platforms {
+		copyBuiltFiles(dstDir, getOutDirForPlatform(platform), getPrefixForPlatform(prefix, buildTypeRel, platform))
+	}
 	copyBuiltManifest(dstDir, prefix)
+	createSigningManifestMust(dstDir, prefix, buildTypeRel)
+	createReleaseTorrentsMust(dstDir, prefix, ver)
+	mergePriorBuildFilesMust(buildTypeRel, dstDir, prefix)
 }
 
 // smoke build is meant to be run locally to check that we can build everything
@@ -550,11 +685,8 @@ func buildSmoke() {
 	defer makePrintDuration("smoke build")()
 	cleanReleaseBuilds()
 
-	lzsa := absPathMust(filepath.Join("bin", "MakeLZSA.exe"))
-	panicIf(!fileExists(lzsa), "file '%s' doesn't exist", lzsa)
-
-	msbuildPath := detectMsbuildPath()
-	runExeLoggedMust(msbuildPath, `vs2022\SumatraPDF.sln`, `/t:SumatraPDF-dll:Rebuild;test_util:Rebuild`, `/p:Configuration=Release;Platform=x64`, `/m`)
+	lzsa := buildMakeLzsaMust()
+	runMsbuildMust(`vs2022\SumatraPDF.sln`, `/t:SumatraPDF-dll:Rebuild;test_util:Rebuild`, `/p:Configuration=Release;Platform=x64`, "/p:MakeLzsaExe="+lzsa, `/m`)
 	outDir := filepath.Join("out", "rel64")
 	runTestUtilMust(outDir)
 
@@ -563,23 +695,22 @@ func buildSmoke() {
 		cmd.Dir = outDir
 		runCmdLoggedMust(cmd)
 	}
+	auditInstallerPayloadMust(outDir, kPlatformIntel64)
 	signFilesMust(outDir)
 }
 
 func buildJustPortableExe(dir, config, platform string) {
-	msbuildPath := detectMsbuildPath()
 	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
 
 	p := fmt.Sprintf(`/p:Configuration=%s;Platform=%s`, config, platform)
-	runExeLoggedMust(msbuildPath, slnPath, `/t:SumatraPDF`, p, `/m`)
+	runMsbuildMust(slnPath, `/t:SumatraPDF`, p, `/m`)
 	signFilesOptional(dir)
 }
 
 func buildTestUtil() {
-	msbuildPath := detectMsbuildPath()
 	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
 
 	config := "Release"
 	p := fmt.Sprintf(`/p:Configuration=%s;Platform=%s`, config, kPlatformIntel64)
-	runExeLoggedMust(msbuildPath, slnPath, `/t:test_util:Rebuild`, p, `/m`)
+	runMsbuildMust(slnPath, `/t:test_util:Rebuild`, p, `/m`)
 }
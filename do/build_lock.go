@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// two runs of do building into the same out/ (e.g. scheduled daily build
+// and a manual build) would corrupt each other's files. Operations that
+// build or clean out/ first take a lock by creating buildLockPath with
+// our pid. The lock is released when do exits. A lock whose process no
+// longer runs (e.g. killed do) or that is older than buildLockMaxAge
+// is stale and we take it over. So is a lock we can't read (empty or
+// corrupt, e.g. do killed while writing it) older than buildLockWriteTimeout.
+// A stale lock is renamed before being removed so that two runs that both
+// found it stale don't remove the lock the other one created after that.
+// -wait waits for the other run to finish instead of failing
+
+// not in out/ because we delete out/
+const buildLockPath = "out.lock"
+
+const buildLockMaxAge = 24 * time.Hour
+
+// tryCreateBuildLock writes the lock right after creating the file
+const buildLockWriteTimeout = 10 * time.Second
+
+var flgWaitForLock bool
+
+// BuildLock is the content of buildLockPath
+type BuildLock struct {
+	Pid     int
+	Started time.Time
+	Args    string
+}
+
+var (
+	buildLockMu   sync.Mutex
+	buildLockHeld bool
+)
+
+func isProcessRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	defer p.Release()
+	if runtime.GOOS == "windows" {
+		// FindProcess opens the process so it exists
+		return true
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+// returns nil if lock file doesn't exist or is invalid
+func readBuildLock(path string) *BuildLock {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	var res BuildLock
+	if json.Unmarshal(d, &res) != nil {
+		return nil
+	}
+	return &res
+}
+
+func isBuildLockStale(path string, l *BuildLock) bool {
+	if l == nil {
+		// being written or garbage
+		st, err := os.Stat(path)
+		if err != nil {
+			// removed since we tried to create it
+			return errors.Is(err, os.ErrNotExist)
+		}
+		return time.Since(st.ModTime()) > buildLockWriteTimeout
+	}
+	return l.Pid == os.Getpid() || !isProcessRunning(l.Pid) || time.Since(l.Started) > buildLockMaxAge
+}
+
+func tryCreateBuildLock() bool {
+	f, err := os.OpenFile(buildLockPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0644)
+	if errors.Is(err, os.ErrExist) {
+		return false
+	}
+	must(err)
+	l := &BuildLock{
+		Pid:     os.Getpid(),
+		Started: time.Now(),
+		Args:    strings.Join(os.Args[1:], " "),
+	}
+	d, err := json.MarshalIndent(l, "", "  ")
+	must(err)
+	_, err = f.Write(d)
+	must(err)
+	must(f.Close())
+	return true
+}
+
+func removeStaleBuildLock() {
+	tmpPath := fmt.Sprintf("%s.%d", buildLockPath, os.Getpid())
+	if os.Rename(buildLockPath, tmpPath) != nil {
+		// already removed by another do
+		return
+	}
+	// what we renamed might be a lock created after we've checked it
+	if isBuildLockStale(tmpPath, readBuildLock(tmpPath)) {
+		os.Remove(tmpPath)
+		return
+	}
+	logf("another do took over stale build lock '%s'\n", buildLockPath)
+	os.Rename(tmpPath, buildLockPath)
+}
+
+// acquires the lock once, subsequent calls do nothing
+func acquireBuildLockMust() {
+	buildLockMu.Lock()
+	defer buildLockMu.Unlock()
+	if buildLockHeld {
+		return
+	}
+	logged := false
+	for !tryCreateBuildLock() {
+		l := readBuildLock(buildLockPath)
+		if isBuildLockStale(buildLockPath, l) {
+			if l != nil {
+				logf("removing stale build lock '%s' of pid %d\n", buildLockPath, l.Pid)
+			} else if fileExists(buildLockPath) {
+				logf("removing invalid build lock '%s'\n", buildLockPath)
+			}
+			removeStaleBuildLock()
+			continue
+		}
+		if !flgWaitForLock {
+			msg := "another do is building in out/"
+			if l != nil {
+				msg = fmt.Sprintf("another do (pid %d, started %s, args '%s') is building in out/", l.Pid, l.Started.Format(time.RFC3339), l.Args)
+			}
+			failIf(errKindPreflight, true, "%s. Use -wait to wait for it or delete '%s' if it's not running", msg, buildLockPath)
+		}
+		if !logged {
+			logf("waiting for another do to finish building in out/\n")
+			logged = true
+		}
+		time.Sleep(5 * time.Second)
+	}
+	buildLockHeld = true
+}
+
+func releaseBuildLock() {
+	buildLockMu.Lock()
+	defer buildLockMu.Unlock()
+	if !buildLockHeld {
+		return
+	}
+	os.Remove(buildLockPath)
+	buildLockHeld = false
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// do build project builds a single project from vs2022 solutions, e.g.
+// do build project PdfPreview -platform x64 -sign
+// for faster iteration on shell extensions, MakeLZSA etc. than building
+// everything with do build prerel
+
+var flgSignProject bool
+
+var slnsWithProjects = []string{
+	filepath.Join("vs2022", "SumatraPDF.sln"),
+	filepath.Join("vs2022", "MakeLZSA.sln"),
+}
+
+// Project("{8BC9CEB8-8B4A-11D0-8D11-00A0C91BC942}") = "PdfPreview", "PdfPreview.vcxproj", "{...}"
+var rxSlnProject = regexp.MustCompile(`(?m)^Project\("[^"]*"\) = "([^"]+)", "[^"]+\.vcxproj"`)
+
+// returns project names in .sln file
+func parseSlnProjects(s string) []string {
+	var res []string
+	for _, m := range rxSlnProject.FindAllStringSubmatch(s, -1) {
+		res = append(res, m[1])
+	}
+	return res
+}
+
+// returns .sln that has the project (case-insensitive) and the project's
+// name as spelled in .sln
+func findSlnForProjectMust(project string) (string, string) {
+	var all []string
+	for _, sln := range slnsWithProjects {
+		for _, name := range parseSlnProjects(string(readFileMust(sln))) {
+			if strings.EqualFold(name, project) {
+				return sln, name
+			}
+			all = append(all, name)
+		}
+	}
+	panicIf(true, "project '%s' not found, must be one of: %s", project, strings.Join(all, ", "))
+	return "", ""
+}
+
+func buildProjectMust(project string, platform string, sign bool) {
+	if platform == "" {
+		platform = kPlatformIntel64
+	}
+	panicIf(!stringInSlice(fanoutPlatforms, platform), "invalid platform '%s'", platform)
+	if sign {
+		// early exit if missing
+		detectSigntoolPath()
+	}
+	sln, name := findSlnForProjectMust(project)
+	acquireBuildLockMust()
+	defer makePrintDuration("build " + name)()
+
+	// msbuild replaces '.' in target names with '_'
+	target := strings.ReplaceAll(name, ".", "_")
+	p := fmt.Sprintf(`/p:Configuration=Release;Platform=%s`, platform)
+	runMsbuildMust(sln, `/t:`+target, p, `/m`)
+
+	dir := getOutDirForPlatform(platform)
+	var built []string
+	for _, ext := range []string{".exe", ".dll", ".lib"} {
+		path := filepath.Join(dir, name+ext)
+		if fileExists(path) {
+			built = append(built, path)
+		}
+	}
+	panicIf(len(built) == 0, "didn't find output of '%s' in '%s'", name, dir)
+	for _, path := range built {
+		if sign && filepath.Ext(path) != ".lib" {
+			signMust(path)
+		}
+		logf("built '%s' (%s)\n", path, formatSize(fileSizeMust(path)))
+	}
+}
@@ -37,7 +37,10 @@ type accessGroup struct {
 func extractAccesskeyGroups(path string) map[string]*accessGroup {
 	lines, err := readLinesFromFile(path)
 	must(err)
+	return extractAccesskeyGroupsFromLines(lines)
+}
 
+func extractAccesskeyGroupsFromLines(lines []string) map[string]*accessGroup {
 	groups := map[string]*accessGroup{}
 	groupName := ""
 	var group *accessGroup
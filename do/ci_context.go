@@ -0,0 +1,181 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"strings"
+)
+
+// information about the CI run, independent of CI system, so that forks
+// can run the same pipeline on Azure Pipelines or GitLab CI
+// when not running in CI, Provider is ""
+
+const (
+	ciProviderGitHub = "github"
+	ciProviderAzure  = "azure"
+	ciProviderGitLab = "gitlab"
+
+	ciTriggerPush        = "push"
+	ciTriggerPullRequest = "pull_request"
+	ciTriggerSchedule    = "schedule"
+	// triggered via API, Action says what to do
+	ciTriggerDispatch = "dispatch"
+	// started from the UI, Action (if set) says what to do
+	ciTriggerManual = "manual"
+)
+
+// CIContext describes the current CI run
+type CIContext struct {
+	Provider string
+	// owner/name e.g. sumatrapdfreader/sumatrapdf
+	Repo   string
+	Branch string
+	// one of ciTrigger*
+	Trigger string
+	// for ciTriggerDispatch and ciTriggerManual, e.g. "codeql"
+	Action string
+	RunID  string
+}
+
+var cachedCIContext *CIContext
+
+func getCIContext() *CIContext {
+	if cachedCIContext == nil {
+		cachedCIContext = detectCIContext()
+	}
+	return cachedCIContext
+}
+
+func detectCIContext() *CIContext {
+	switch {
+	case os.Getenv("GITHUB_ACTIONS") == "true":
+		return detectGitHubContext()
+	case strings.EqualFold(os.Getenv("TF_BUILD"), "true"):
+		return detectAzureContext()
+	case os.Getenv("GITLAB_CI") == "true":
+		return detectGitLabContext()
+	}
+	return &CIContext{}
+}
+
+// "action": "codeql"
+type gitHubEventJSON struct {
+	Action string `json:"action"`
+}
+
+// https://docs.github.com/en/actions/learn-github-actions/variables#default-environment-variables
+func detectGitHubContext() *CIContext {
+	res := &CIContext{
+		Provider: ciProviderGitHub,
+		Repo:     os.Getenv("GITHUB_REPOSITORY"),
+		Branch:   strings.TrimPrefix(os.Getenv("GITHUB_REF"), "refs/heads/"),
+		RunID:    os.Getenv("GITHUB_RUN_ID"),
+	}
+	switch ev := os.Getenv("GITHUB_EVENT_NAME"); ev {
+	case "pull_request":
+		res.Trigger = ciTriggerPullRequest
+		res.Branch = os.Getenv("GITHUB_HEAD_REF")
+	case "schedule":
+		res.Trigger = ciTriggerSchedule
+	case "workflow_dispatch":
+		res.Trigger = ciTriggerManual
+	case "repository_dispatch":
+		res.Trigger = ciTriggerDispatch
+		d, err := os.ReadFile(os.Getenv("GITHUB_EVENT_PATH"))
+		must(err)
+		var js gitHubEventJSON
+		must(json.Unmarshal(d, &js))
+		res.Action = js.Action
+	default:
+		res.Trigger = ciTriggerPush
+	}
+	return res
+}
+
+// https://learn.microsoft.com/en-us/azure/devops/pipelines/build/variables
+// manual runs can set DO_CI_ACTION pipeline variable
+func detectAzureContext() *CIContext {
+	res := &CIContext{
+		Provider: ciProviderAzure,
+		Repo:     os.Getenv("BUILD_REPOSITORY_NAME"),
+		Branch:   strings.TrimPrefix(os.Getenv("BUILD_SOURCEBRANCH"), "refs/heads/"),
+		RunID:    os.Getenv("BUILD_BUILDID"),
+	}
+	switch os.Getenv("BUILD_REASON") {
+	case "PullRequest":
+		res.Trigger = ciTriggerPullRequest
+		res.Branch = strings.TrimPrefix(os.Getenv("SYSTEM_PULLREQUEST_SOURCEBRANCH"), "refs/heads/")
+	case "Schedule":
+		res.Trigger = ciTriggerSchedule
+	case "Manual":
+		res.Trigger = ciTriggerManual
+		res.Action = os.Getenv("DO_CI_ACTION")
+	default:
+		// IndividualCI, BatchedCI
+		res.Trigger = ciTriggerPush
+	}
+	return res
+}
+
+// https://docs.gitlab.com/ee/ci/variables/predefined_variables.html
+// api / web / trigger pipelines can set DO_CI_ACTION variable
+func detectGitLabContext() *CIContext {
+	res := &CIContext{
+		Provider: ciProviderGitLab,
+		Repo:     os.Getenv("CI_PROJECT_PATH"),
+		Branch:   os.Getenv("CI_COMMIT_BRANCH"),
+		RunID:    os.Getenv("CI_PIPELINE_ID"),
+	}
+	switch os.Getenv("CI_PIPELINE_SOURCE") {
+	case "merge_request_event", "external_pull_request_event":
+		res.Trigger = ciTriggerPullRequest
+		res.Branch = os.Getenv("CI_MERGE_REQUEST_SOURCE_BRANCH_NAME")
+	case "schedule":
+		res.Trigger = ciTriggerSchedule
+	case "web":
+		res.Trigger = ciTriggerManual
+		res.Action = os.Getenv("DO_CI_ACTION")
+	case "api", "trigger", "pipeline":
+		res.Trigger = ciTriggerDispatch
+		res.Action = os.Getenv("DO_CI_ACTION")
+	default:
+		res.Trigger = ciTriggerPush
+	}
+	return res
+}
+
+const (
+	ciEventTypeCodeQL = "codeql"
+	ciEventPush       = "push"
+)
+
+// what "do build ci" should build
+func getCIEventType() string {
+	ctx := getCIContext()
+	if (ctx.Trigger != ciTriggerDispatch && ctx.Trigger != ciTriggerManual) || ctx.Action == "" {
+		return ciEventPush
+	}
+	// validate this is an action we understand
+	switch ctx.Action {
+	case ciEventTypeCodeQL:
+		return ctx.Action
+	}
+	panicIf(true, "invalid CI action '%s'", ctx.Action)
+	return ""
+}
+
+// we should only sign and upload to s3 if this is my repo and a push event
+// or building locally
+// don't sign if it's a fork or pull requests
+func isMyMasterBranch() bool {
+	ctx := getCIContext()
+	if ctx.Repo != "sumatrapdfreader/sumatrapdf" {
+		return false
+	}
+	if ctx.Branch != "master" {
+		logf("%s branch: '%s'\n", ctx.Provider, ctx.Branch)
+		return false
+	}
+	// not ciTriggerManual: manual runs (e.g. workflow_dispatch of fan-out workers) don't sign
+	return ctx.Trigger == ciTriggerPush || ctx.Trigger == ciTriggerDispatch
+}
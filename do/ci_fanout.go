@@ -0,0 +1,222 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// fan-out CI: instead of building all platforms one after another in a single
+// job, the coordinator (do build fanout) dispatches .github/workflows/build-platform.yml
+// for each platform, waits for them to finish, downloads their artifacts and
+// then signs, creates manifests and uploads centrally
+// workers (do build platform <platform>) build without signing so that signing
+// certificate is only needed by the coordinator
+
+const (
+	fanoutWorkflow     = "build-platform.yml"
+	fanoutPollInterval = 30 * time.Second
+	fanoutTimeout      = 90 * time.Minute
+	// staging directory that build-platform.yml uploads as artifact
+	fanoutArtifactDir = "out/platform-artifact"
+)
+
+var fanoutPlatforms = []string{kPlatformArm64, kPlatformIntel32, kPlatformIntel64}
+
+func getGitHubRepo() string {
+	if repo := os.Getenv("GITHUB_REPOSITORY"); repo != "" {
+		return repo
+	}
+	return "sumatrapdfreader/sumatrapdf"
+}
+
+// calls GitHub REST API of the repo. body and res can be nil
+func gitHubAPIMust(method string, path string, body interface{}, res interface{}) {
+	gitHubAPIURLMust(method, "https://api.github.com/repos/"+getGitHubRepo()+path, body, res)
+}
+
+// like gitHubAPIMust but for any endpoint, e.g. /search/issues
+func gitHubAPIURLMust(method string, uri string, body interface{}, res interface{}) {
+	ghtoken := os.Getenv("GITHUB_TOKEN")
+	panicIf(ghtoken == "", "need GITHUB_TOKEN env variable")
+	var r io.Reader
+	if body != nil {
+		d, err := json.Marshal(body)
+		must(err)
+		r = bytes.NewReader(d)
+	}
+	req, err := http.NewRequest(method, uri, r)
+	must(err)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", ghtoken))
+	rsp, err := http.DefaultClient.Do(req)
+	must(err)
+	defer rsp.Body.Close()
+	d, err := io.ReadAll(rsp.Body)
+	must(err)
+	panicIf(rsp.StatusCode >= 400, "%s %s failed with %s:\n%s\n", method, uri, rsp.Status, string(d))
+	if res != nil {
+		must(json.Unmarshal(d, res))
+	}
+}
+
+// GitHubWorkflowRun is a subset of https://docs.github.com/en/rest/actions/workflow-runs
+type GitHubWorkflowRun struct {
+	ID           int64  `json:"id"`
+	DisplayTitle string `json:"display_title"`
+	Status       string `json:"status"`
+	Conclusion   string `json:"conclusion"`
+	HTMLURL      string `json:"html_url"`
+}
+
+type gitHubArtifact struct {
+	Name               string `json:"name"`
+	ArchiveDownloadURL string `json:"archive_download_url"`
+}
+
+// must match run-name in build-platform.yml
+func fanoutRunTitle(platform string, id string) string {
+	return fmt.Sprintf("build %s %s", platform, id)
+}
+
+// worker: builds a single platform and stages files for upload as artifact
+func ciBuildPlatform(platform string) {
+	panicIf(!stringInSlice(fanoutPlatforms, platform), "invalid platform '%s', must be one of: %s", platform, strings.Join(fanoutPlatforms, ", "))
+	defer makePrintDuration("building " + platform)()
+	cleanReleaseBuilds()
+	setBuildConfigPreRelease()
+	defer revertBuildConfig()
+	build("Release", platform, false)
+
+	outDir := getOutDirForPlatform(platform)
+	must(os.RemoveAll(fanoutArtifactDir))
+	createDirMust(fanoutArtifactDir)
+	files, err := os.ReadDir(outDir)
+	must(err)
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f.Name()))
+		if f.IsDir() || !stringInSlice([]string{".exe", ".dll", ".pdb", ".zip", ".lzsa"}, ext) {
+			continue
+		}
+		must(copyFile(filepath.Join(fanoutArtifactDir, f.Name()), filepath.Join(outDir, f.Name())))
+	}
+}
+
+func dispatchFanoutWorkflowsMust(id string) {
+	ref := os.Getenv("GITHUB_REF_NAME")
+	if ref == "" {
+		ref = getCurrentBranchMust()
+	}
+	for _, platform := range filterSelectedPlatforms(fanoutPlatforms) {
+		body := map[string]interface{}{
+			"ref": ref,
+			"inputs": map[string]string{
+				"platform": platform,
+				"id":       id,
+			},
+		}
+		gitHubAPIMust(http.MethodPost, "/actions/workflows/"+fanoutWorkflow+"/dispatches", body, nil)
+		logf("dispatched %s for %s on %s\n", fanoutWorkflow, platform, ref)
+	}
+}
+
+// waits until runs for all platforms complete, returns platform => run
+func waitForFanoutRunsMust(id string) map[string]*GitHubWorkflowRun {
+	timeStart := time.Now()
+	for {
+		var rsp struct {
+			WorkflowRuns []*GitHubWorkflowRun `json:"workflow_runs"`
+		}
+		gitHubAPIMust(http.MethodGet, "/actions/workflows/"+fanoutWorkflow+"/runs?event=workflow_dispatch&per_page=50", nil, &rsp)
+		runs := map[string]*GitHubWorkflowRun{}
+		nCompleted := 0
+		for _, platform := range filterSelectedPlatforms(fanoutPlatforms) {
+			for _, run := range rsp.WorkflowRuns {
+				if run.DisplayTitle == fanoutRunTitle(platform, id) {
+					runs[platform] = run
+					if run.Status == "completed" {
+						nCompleted++
+					}
+				}
+			}
+		}
+		nPlatforms := len(filterSelectedPlatforms(fanoutPlatforms))
+		logf("fan-out: %d of %d runs completed after %s\n", nCompleted, nPlatforms, formatDuration(time.Since(timeStart)))
+		if nCompleted == nPlatforms {
+			return runs
+		}
+		panicIf(time.Since(timeStart) > fanoutTimeout, "fan-out runs didn't finish in %s", fanoutTimeout)
+		time.Sleep(fanoutPollInterval)
+	}
+}
+
+func unzipToDirMust(d []byte, dstDir string) {
+	zr, err := zip.NewReader(bytes.NewReader(d), int64(len(d)))
+	must(err)
+	createDirMust(dstDir)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		// artifacts are flat so ignore directories, also protects against ../
+		dstPath := filepath.Join(dstDir, filepath.Base(f.Name))
+		rc, err := f.Open()
+		must(err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		must(err)
+		writeFileMust(dstPath, data)
+	}
+}
+
+func downloadFanoutArtifactMust(run *GitHubWorkflowRun, dstDir string) {
+	var rsp struct {
+		Artifacts []*gitHubArtifact `json:"artifacts"`
+	}
+	gitHubAPIMust(http.MethodGet, fmt.Sprintf("/actions/runs/%d/artifacts", run.ID), nil, &rsp)
+	panicIf(len(rsp.Artifacts) == 0, "no artifacts in %s", run.HTMLURL)
+	a := rsp.Artifacts[0]
+	req, err := http.NewRequest(http.MethodGet, a.ArchiveDownloadURL, nil)
+	must(err)
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", os.Getenv("GITHUB_TOKEN")))
+	d := httpDownloadMust(req)
+	unzipToDirMust(d, dstDir)
+	logf("downloaded artifact '%s' (%s) to '%s'\n", a.Name, formatSize(int64(len(d))), dstDir)
+}
+
+// coordinator
+func ciFanout() {
+	// make sure we can sign the executables, early exit if missing
+	detectSigntoolPath()
+	defer makePrintDuration("fan-out build")()
+
+	id := os.Getenv("GITHUB_RUN_ID")
+	if id == "" {
+		id = fmt.Sprintf("%d", time.Now().Unix())
+	}
+	dispatchFanoutWorkflowsMust(id)
+	runs := waitForFanoutRunsMust(id)
+	var failed []string
+	for _, platform := range filterSelectedPlatforms(fanoutPlatforms) {
+		run := runs[platform]
+		if run.Conclusion != "success" {
+			failed = append(failed, fmt.Sprintf("%s: %s %s", platform, run.Conclusion, run.HTMLURL))
+		}
+	}
+	panicIf(len(failed) > 0, "fan-out builds failed:\n%s\n", strings.Join(failed, "\n"))
+
+	cleanReleaseBuilds()
+	for _, platform := range filterSelectedPlatforms(fanoutPlatforms) {
+		dir := getOutDirForPlatform(platform)
+		downloadFanoutArtifactMust(runs[platform], dir)
+		signFilesMust(dir)
+		packagePreRelease(platform)
+	}
+}
@@ -1,11 +1,14 @@
 package main
 
 import (
+	"io/fs"
 	"os/exec"
 	"path/filepath"
 	"runtime"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 )
 
 var printClangPath bool
@@ -86,3 +89,153 @@ func clangFormatFiles() {
 	wg.Wait()
 	logf("used '%s'\n", path)
 }
+
+// do format all formats all C/C++ sources in src/ and ext/ except those
+// ignored by .clang-format-ignore. Files are formatted in batches (one
+// clang-format process formats many files) by a worker per cpu
+
+const clangFormatIgnorePath = ".clang-format-ignore"
+
+// files per clang-format invocation, limited by command line length
+const clangFormatBatchSize = 32
+
+var clangFormatExts = []string{".c", ".cc", ".cpp", ".cxx", ".h", ".hh", ".hpp"}
+
+// ClangFormatIgnorePattern is a line of .clang-format-ignore
+type ClangFormatIgnorePattern struct {
+	Pattern string
+	Negate  bool
+}
+
+func parseClangFormatIgnore(s string) []*ClangFormatIgnorePattern {
+	var res []*ClangFormatIgnorePattern
+	for _, line := range toTrimmedLines([]byte(s)) {
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		p := &ClangFormatIgnorePattern{}
+		if strings.HasPrefix(line, "!") {
+			p.Negate = true
+			line = line[1:]
+		}
+		p.Pattern = strings.Trim(line, "/")
+		res = append(res, p)
+	}
+	return res
+}
+
+// path is relative, with / separators. Pattern matches the path or one of
+// its parent directories
+func (p *ClangFormatIgnorePattern) Matches(path string) bool {
+	parts := strings.Split(path, "/")
+	for i := 1; i <= len(parts); i++ {
+		dir := strings.Join(parts[:i], "/")
+		if ok, _ := filepath.Match(p.Pattern, dir); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func isClangFormatIgnored(patterns []*ClangFormatIgnorePattern, path string) bool {
+	ignored := false
+	for _, p := range patterns {
+		if p.Matches(path) {
+			ignored = !p.Negate
+		}
+	}
+	return ignored
+}
+
+// returns C/C++ files in src/ and ext/ that are not ignored
+func findFilesToFormatMust() []string {
+	var patterns []*ClangFormatIgnorePattern
+	if fileExists(clangFormatIgnorePath) {
+		patterns = parseClangFormatIgnore(string(readFileMust(clangFormatIgnorePath)))
+	}
+	var res []string
+	for _, dir := range []string{"src", "ext"} {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			relPath := filepath.ToSlash(path)
+			if isClangFormatIgnored(patterns, relPath) {
+				if d.IsDir() {
+					return filepath.SkipDir
+				}
+				return nil
+			}
+			if d.IsDir() {
+				return nil
+			}
+			ext := strings.ToLower(filepath.Ext(path))
+			if stringInSlice(clangFormatExts, ext) {
+				res = append(res, path)
+			}
+			return nil
+		})
+		must(err)
+	}
+	return res
+}
+
+func clangFormatAllFiles() {
+	timeStart := time.Now()
+	clangFormatPath := detectClangFormat()
+	files := findFilesToFormatMust()
+	nTotal := len(files)
+	logf("formatting %d files\n", nTotal)
+
+	var batches [][]string
+	for len(files) > 0 {
+		n := min(len(files), clangFormatBatchSize)
+		batches = append(batches, files[:n])
+		files = files[n:]
+	}
+
+	var mu sync.Mutex
+	var changed []string
+	nDone := 0
+	formatBatch := func(batch []string) {
+		before := map[string]string{}
+		for _, path := range batch {
+			before[path] = sha256Hex(readFileMust(path))
+		}
+		args := append([]string{"-i", "-style=file"}, batch...)
+		cmd := exec.Command(clangFormatPath, args...)
+		out, err := combinedOutputWithTimeout(cmd)
+		panicIf(err != nil, "clang-format failed with '%s'. Output:\n%s\n", err, out)
+
+		mu.Lock()
+		defer mu.Unlock()
+		for _, path := range batch {
+			if sha256Hex(readFileMust(path)) != before[path] {
+				changed = append(changed, path)
+			}
+		}
+		nDone += len(batch)
+		logf("formatted %d of %d files\n", nDone, nTotal)
+	}
+
+	sem := make(chan bool, runtime.NumCPU())
+	var wg sync.WaitGroup
+	for _, batch := range batches {
+		sem <- true
+		wg.Add(1)
+		go func(batch []string) {
+			defer func() {
+				wg.Done()
+				<-sem
+			}()
+			formatBatch(batch)
+		}(batch)
+	}
+	wg.Wait()
+
+	sort.Strings(changed)
+	for _, path := range changed {
+		logf("changed: %s\n", path)
+	}
+	logf("formatted %d files, %d changed, in %s\n", nTotal, len(changed), time.Since(timeStart).Round(time.Millisecond))
+}
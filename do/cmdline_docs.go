@@ -0,0 +1,348 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// generates docs/Command-line-arguments.md and docs/cmdline.json (for
+// shell completion) from command-line arguments parsed in src/Flags.cpp
+// and DDE commands handled in src/SearchAndDDE.cpp
+
+var (
+	cmdLineDocPath  = filepath.Join("docs", "Command-line-arguments.md")
+	cmdLineJSONPath = filepath.Join("docs", "cmdline.json")
+	flagsCppPath    = filepath.Join("src", "Flags.cpp")
+	ddeCppPath      = filepath.Join("src", "SearchAndDDE.cpp")
+)
+
+// V(PrintTo, "print-to")
+var rxArgDef = regexp.MustCompile(`^\s*V\((\w+),\s*"([^"]+)"\)`)
+
+// Arg::PrintTo
+var rxArgRef = regexp.MustCompile(`Arg::(\w+)`)
+
+// static const char* HandleOpenCmd(const char* cmd, bool* ack) {
+var rxDdeHandler = regexp.MustCompile(`^static const char\* (Handle\w+)\(`)
+
+// [Open( or [NewWindow]
+var rxDdeName = regexp.MustCompile(`^\[(\w+)`)
+
+// CmdLineArg is a command-line argument
+type CmdLineArg struct {
+	Name    string   `json:"name"`
+	Aliases []string `json:"aliases,omitempty"`
+	// number of parameters, 0 for switches
+	MinParams int `json:"minParams"`
+	MaxParams int `json:"maxParams"`
+	// from comments in Flags.cpp
+	Notes string `json:"notes,omitempty"`
+}
+
+// DdeCommand is a command sent with -dde or DDE
+type DdeCommand struct {
+	Name        string   `json:"name"`
+	Syntax      []string `json:"syntax"`
+	Description string   `json:"description,omitempty"`
+	Examples    []string `json:"examples,omitempty"`
+}
+
+// CmdLineDoc is docs/cmdline.json
+type CmdLineDoc struct {
+	Args []*CmdLineArg `json:"args"`
+	Dde  []*DdeCommand `json:"dde"`
+}
+
+// returns enum name => arg name, and enum names in order of ARGS()
+func parseArgsDefs(s string) (map[string]string, []string) {
+	names := map[string]string{}
+	var order []string
+	start := strings.Index(s, "#define ARGS(V)")
+	if start < 0 {
+		return names, nil
+	}
+	for _, line := range strings.Split(s[start:], "\n")[1:] {
+		m := rxArgDef.FindStringSubmatch(line)
+		if m == nil {
+			break
+		}
+		names[m[1]] = m[2]
+		order = append(order, m[1])
+	}
+	return names, order
+}
+
+// "BgCol2" => "BgCol", "ForwardSearch1" => "ForwardSearch"
+func argEnumStem(s string) string {
+	return strings.TrimRight(s, "0123456789")
+}
+
+func isCommentLine(s string) bool {
+	return strings.HasPrefix(strings.TrimSpace(s), "//")
+}
+
+// parses `if (arg == Arg::Foo || arg == Arg::Bar) { ... }` blocks in
+// ParseFlags(). Args handled after `param = args.EatParam()` take a
+// parameter, each args.EatParam() in the block is an additional one
+func parseArgHandlers(s string, names map[string]string, order []string) []*CmdLineArg {
+	lines := strings.Split(s, "\n")
+	byEnum := map[string]*CmdLineArg{}
+	takesParam := false
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		if strings.HasPrefix(trimmed, "param = args.EatParam();") {
+			takesParam = true
+			continue
+		}
+		if !strings.HasPrefix(trimmed, "if (") || !strings.Contains(trimmed, "arg == Arg::") {
+			continue
+		}
+		indent := line[:len(line)-len(strings.TrimLeft(line, " "))]
+		cond := trimmed
+		for !strings.HasSuffix(strings.TrimSpace(lines[i]), "{") && i+1 < len(lines) {
+			i++
+			cond += " " + strings.TrimSpace(lines[i])
+		}
+		var body []string
+		for i+1 < len(lines) && lines[i+1] != indent+"}" {
+			i++
+			body = append(body, lines[i])
+		}
+		var enums []string
+		for _, m := range rxArgRef.FindAllStringSubmatch(cond, -1) {
+			if _, ok := names[m[1]]; ok {
+				enums = append(enums, m[1])
+			}
+		}
+		if len(enums) == 0 {
+			continue
+		}
+		var notes []string
+		nExtra := 0
+		// handlers that pass argName on handle different args
+		usesName := false
+		inTodo := false
+		for _, l := range body {
+			if isCommentLine(l) {
+				note := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(l), "//"))
+				// TODO comments are not for users
+				inTodo = inTodo || strings.HasPrefix(note, "TODO")
+				if !inTodo {
+					notes = append(notes, note)
+				}
+				continue
+			}
+			inTodo = false
+			nExtra += strings.Count(l, "args.EatParam()")
+			usesName = usesName || strings.Contains(l, "argName")
+		}
+		for _, e := range enums {
+			a := &CmdLineArg{Name: names[e]}
+			// aliases are handled together, the same handler can
+			// also handle different args e.g. -bgcolor and -manga-mode
+			for _, e2 := range enums {
+				if e2 == e {
+					continue
+				}
+				isAlias := !usesName || argEnumStem(e2) == argEnumStem(e)
+				if isAlias {
+					if byEnum[e2] != nil {
+						a = byEnum[e2]
+						break
+					}
+					a.Aliases = append(a.Aliases, names[e2])
+				}
+			}
+			if byEnum[e] != nil || a.Name != names[e] {
+				byEnum[e] = a
+				continue
+			}
+			if takesParam {
+				// args.AdditionalParam(1) in condition means extra params
+				// are required
+				a.MinParams = 1
+				a.MaxParams = 1 + nExtra
+				if strings.Contains(cond, "args.AdditionalParam(1)") {
+					a.MinParams = a.MaxParams
+				}
+			}
+			a.Notes = strings.Join(notes, " ")
+			byEnum[e] = a
+		}
+	}
+	var res []*CmdLineArg
+	seen := map[*CmdLineArg]bool{}
+	for _, e := range order {
+		a := byEnum[e]
+		if a == nil {
+			a = &CmdLineArg{Name: names[e]}
+			byEnum[e] = a
+		}
+		if !seen[a] {
+			seen[a] = true
+			res = append(res, a)
+		}
+	}
+	return res
+}
+
+// parses comments before Handle*Cmd functions
+func parseDdeCommands(s string) []*DdeCommand {
+	var res []*DdeCommand
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		m := rxDdeHandler.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		// find /* ... */ right before the function
+		end := i - 1
+		for end >= 0 && !strings.HasSuffix(strings.TrimSpace(lines[end]), "*/") {
+			if strings.TrimSpace(lines[end]) != "" && !strings.HasPrefix(lines[end], "constexpr") {
+				break
+			}
+			end--
+		}
+		if end < 0 || !strings.HasSuffix(strings.TrimSpace(lines[end]), "*/") {
+			continue
+		}
+		start := end
+		for start >= 0 && !strings.HasPrefix(strings.TrimSpace(lines[start]), "/*") {
+			start--
+		}
+		if start < 0 {
+			continue
+		}
+		cmd := &DdeCommand{}
+		// paragraphs
+		var desc []string
+		para := ""
+		isExample := false
+		for _, l := range lines[start : end+1] {
+			l = strings.TrimSpace(strings.TrimSuffix(strings.TrimPrefix(strings.TrimSpace(l), "/*"), "*/"))
+			if l == "" && para != "" {
+				desc = append(desc, para)
+				para = ""
+			}
+			lower := strings.ToLower(l)
+			if strings.HasPrefix(lower, "eg") || strings.HasPrefix(lower, "e.g.") {
+				_, rest, _ := strings.Cut(l, ":")
+				rest = strings.TrimSpace(rest)
+				isExample = rest == ""
+				if rest != "" {
+					cmd.Examples = append(cmd.Examples, rest)
+				}
+				continue
+			}
+			if strings.HasPrefix(l, "[") {
+				if isExample {
+					cmd.Examples = append(cmd.Examples, l)
+				} else {
+					cmd.Syntax = append(cmd.Syntax, l)
+				}
+				continue
+			}
+			isExample = false
+			if l != "" {
+				para = strings.TrimSpace(para + " " + l)
+			}
+		}
+		if para != "" {
+			desc = append(desc, para)
+		}
+		cmd.Description = strings.Join(desc, "\n\n")
+		if m[1] == "HandleCmdCommand" {
+			cmd.Syntax = []string{"[<command id>]"}
+		}
+		if len(cmd.Syntax) == 0 {
+			continue
+		}
+		if m[1] == "HandleCmdCommand" {
+			cmd.Name = "<command id>"
+		} else if mn := rxDdeName.FindStringSubmatch(cmd.Syntax[0]); mn != nil {
+			cmd.Name = mn[1]
+		}
+		res = append(res, cmd)
+	}
+	return res
+}
+
+func getCmdLineDocMust() *CmdLineDoc {
+	flags := strings.ReplaceAll(string(readFileMust(flagsCppPath)), "\r\n", "\n")
+	names, order := parseArgsDefs(flags)
+	panicIf(len(order) == 0, "didn't find ARGS() in '%s'", flagsCppPath)
+	dde := parseDdeCommands(strings.ReplaceAll(string(readFileMust(ddeCppPath)), "\r\n", "\n"))
+	panicIf(len(dde) == 0, "didn't find DDE commands in '%s'", ddeCppPath)
+	return &CmdLineDoc{
+		Args: parseArgHandlers(flags, names, order),
+		Dde:  dde,
+	}
+}
+
+func genCmdLineJSONMust() []byte {
+	d, err := json.MarshalIndent(getCmdLineDocMust(), "", "  ")
+	must(err)
+	return append(d, '\n')
+}
+
+func fmtCmdLineArgParams(a *CmdLineArg) string {
+	var parts []string
+	for i := 0; i < a.MaxParams; i++ {
+		p := fmt.Sprintf("<arg%d>", i+1)
+		if a.MaxParams == 1 {
+			p = "<arg>"
+		}
+		if i >= a.MinParams {
+			p = "[" + p + "]"
+		}
+		parts = append(parts, p)
+	}
+	return strings.Join(parts, " ")
+}
+
+func genCmdLineDocMust() []byte {
+	doc := getCmdLineDocMust()
+	lines := []string{
+		"<!-- DO NOT EDIT MANUALLY !!! Generated with .\\doit.bat docs gen from src/Flags.cpp and src/SearchAndDDE.cpp -->",
+		"",
+		"# Command-line arguments",
+		"",
+		"Arguments recognized by `SumatraPDF.exe`. Arguments that are not recognized are file paths to open.",
+		"",
+		"| Argument | Parameters | Aliases | Notes |",
+		"| --- | --- | --- | --- |",
+	}
+	for _, a := range doc.Args {
+		var aliases []string
+		for _, s := range a.Aliases {
+			aliases = append(aliases, "`-"+s+"`")
+		}
+		params := fmtCmdLineArgParams(a)
+		if params != "" {
+			params = "`" + params + "`"
+		}
+		notes := strings.NewReplacer("<", "&lt;", ">", "&gt;").Replace(mdEscape(a.Notes))
+		lines = append(lines, fmt.Sprintf("| `-%s` | %s | %s | %s |", a.Name, params, strings.Join(aliases, ", "), notes))
+	}
+	lines = append(lines, "", "## DDE commands", "", "Sent with `-dde` or via DDE to server `SUMATRA`, topic `control`.", "")
+	for _, c := range doc.Dde {
+		lines = append(lines, "### "+c.Name, "")
+		if c.Description != "" {
+			lines = append(lines, c.Description, "")
+		}
+		lines = append(lines, "```")
+		lines = append(lines, c.Syntax...)
+		lines = append(lines, "```", "")
+		if len(c.Examples) > 0 {
+			lines = append(lines, "Example:", "", "```")
+			lines = append(lines, c.Examples...)
+			lines = append(lines, "```", "")
+		}
+	}
+	lines = append(lines, "Machine-readable version for shell completion is [cmdline.json](cmdline.json).", "")
+	return []byte(strings.Join(lines, "\n"))
+}
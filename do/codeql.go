@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+)
+
+// creates CodeQL database locally and runs the same queries as the hosted
+// codeql workflow (security-and-quality) plus our custom queries from
+// .github/codeql/queries, so that we can see the results before pushing
+// needs CodeQL CLI (https://github.com/github/codeql-cli-binaries) in %PATH%
+// or CODEQL env variable pointing to codeql.exe
+
+const codeqlQuerySuite = "codeql/cpp-queries:codeql-suites/cpp-security-and-quality.qls"
+
+func detectCodeQLPath() string {
+	if path := os.Getenv("CODEQL"); path != "" {
+		panicIf(!fileExists(path), "CODEQL env variable points to '%s' which doesn't exist", path)
+		return path
+	}
+	path, err := exec.LookPath("codeql")
+	panicIf(err != nil, "didn't find codeql in %PATH%, install CodeQL CLI or set CODEQL env variable")
+	return path
+}
+
+func runCodeQL(upload bool) {
+	codeql := detectCodeQLPath()
+	outDir := filepath.Join("out", "codeql")
+	dbDir := filepath.Join(outDir, "db")
+	must(os.RemoveAll(outDir))
+	createDirMust(outDir)
+
+	msbuildPath := detectMsbuildPath()
+	p := fmt.Sprintf(`/p:Configuration=Release;Platform=%s`, kPlatformIntel64)
+	buildCmd := fmt.Sprintf(`"%s" vs2022\SumatraPDF.sln /t:SumatraPDF:Rebuild %s /m`, msbuildPath, p)
+	{
+		cmd := exec.Command(codeql, "database", "create", dbDir, "--language=cpp", "--source-root=.", "--command="+buildCmd, "--codescanning-config=.github/codeql-config.yml", "--overwrite")
+		runCmdLoggedMust(cmd)
+	}
+
+	sarifPath := filepath.Join(outDir, "codeql.sarif")
+	queriesDir := filepath.Join(".github", "codeql", "queries")
+	{
+		cmd := exec.Command(codeql, "pack", "install", queriesDir)
+		runCmdLoggedMust(cmd)
+	}
+	{
+		cmd := exec.Command(codeql, "database", "analyze", dbDir, codeqlQuerySuite, queriesDir, "--format=sarif-latest", "--output="+sarifPath, "--download", "--sarif-category=codeql-local")
+		runCmdLoggedMust(cmd)
+	}
+
+	byRule := map[string]int{}
+	n := 0
+	for _, run := range readSarifMust(sarifPath).Runs {
+		for _, r := range run.Results {
+			byRule[r.RuleID]++
+			n++
+		}
+	}
+	var rules []string
+	for rule := range byRule {
+		rules = append(rules, rule)
+	}
+	sort.Slice(rules, func(i, j int) bool {
+		return byRule[rules[i]] > byRule[rules[j]]
+	})
+	for _, rule := range rules {
+		logf("%5d %s\n", byRule[rule], rule)
+	}
+	logf("%d CodeQL results in '%s'\n", n, sarifPath)
+
+	if upload {
+		uploadSarifToGitHubMust(sarifPath)
+	}
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"os"
+	"os/exec"
+	"path"
+	"sync"
+	"time"
+)
+
+// every external command we run (via runCmdWithTimeout) is recorded in
+// out/command-audit.jsonl, one JSON object per line, with secrets in
+// arguments redacted. CI and release builds start a new audit and upload it
+// next to the build, so that for every official build there's a record of
+// exactly what was executed to create it
+
+const (
+	commandAuditPath     = "out/command-audit.jsonl"
+	commandAuditFileName = "command-audit.jsonl"
+)
+
+// CommandAuditEntry is a line in command-audit.jsonl
+type CommandAuditEntry struct {
+	Time     time.Time
+	Exe      string
+	Args     []string
+	Dir      string
+	Duration time.Duration
+	ExitCode int
+	// set if the command didn't run or didn't exit normally (e.g. timeout)
+	Error string `json:",omitempty"`
+}
+
+// commands run concurrently e.g. when signing
+var commandAuditMu sync.Mutex
+
+// starts a new audit, must be called at the start of a build
+func resetCommandAudit() {
+	os.Remove(commandAuditPath)
+}
+
+func getExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return exitErr.ExitCode()
+	}
+	return -1
+}
+
+func recordCommandAudit(cmd *exec.Cmd, timeStart time.Time, err error) {
+	e := &CommandAuditEntry{
+		Time:     timeStart.UTC(),
+		Exe:      cmd.Path,
+		Dir:      cmd.Dir,
+		Duration: time.Since(timeStart),
+		ExitCode: getExitCode(err),
+	}
+	for _, arg := range cmd.Args[1:] {
+		e.Args = append(e.Args, redactSecrets(arg))
+	}
+	if e.Dir == "" {
+		e.Dir, _ = os.Getwd()
+	}
+	if err != nil && e.ExitCode == -1 {
+		e.Error = redactSecrets(err.Error())
+	}
+	d, err := json.Marshal(e)
+	must(err)
+	d = append(d, '\n')
+
+	commandAuditMu.Lock()
+	defer commandAuditMu.Unlock()
+	// auditing must not fail the build
+	if err = createDirForFile(commandAuditPath); err != nil {
+		return
+	}
+	f, err := openForAppend(commandAuditPath)
+	if err != nil {
+		logf("failed to open '%s': %s\n", commandAuditPath, err)
+		return
+	}
+	defer f.Close()
+	// single write so that lines of processes building in parallel
+	// (do build fanout) don't interleave
+	_, _ = f.Write(d)
+}
+
+// uploads command audit to remote dir of the build
+func uploadCommandAuditMust(mc Storage, dirRemote string, log *TaskLogger) {
+	d, err := os.ReadFile(commandAuditPath)
+	if err != nil {
+		log.Logf("no '%s', not uploading command audit\n", commandAuditPath)
+		return
+	}
+	remotePath := path.Join(dirRemote, commandAuditFileName)
+	_, err = mc.UploadData(remotePath, d, false)
+	must(err)
+	log.Logf("Uploaded '%s' (%d commands)\n", mc.URLForPath(remotePath), len(toTrimmedLines(d)))
+}
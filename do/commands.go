@@ -0,0 +1,1249 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/kjk/common/u"
+)
+
+// do is used as: do <command> [flags] [args] e.g.
+// do build prerel -platform arm64 -upload
+// Commands have one or two words, the first word groups related commands
+// (build, trans, docs etc.). Each command has its own flags. Global flags
+// (timeouts, proxy, logging etc.) are accepted by every command. Flags and
+// arguments can be mixed, arguments after -- are passed as is.
+// To add a capability, add a DoCommand to doCommands.
+// Old style (do -ci, do -build-no-info 15780) still works but is deprecated,
+// see legacyFlagCommands
+
+// DoCommand is a command of do e.g. "build ci"
+type DoCommand struct {
+	// one or two words, the first is the group
+	Name string
+	// usage of positional arguments: <required>, [optional], ... for any
+	// number of arguments
+	Args string
+	Help string
+	// ad-hoc commands, not shown in usage
+	Hidden bool
+	// registers flags of the command
+	Flags func(fs *flag.FlagSet)
+	// runs before reading secrets and detecting versions
+	NoSetup bool
+	// official build: starts a new command audit (see command_audit.go)
+	Audit bool
+	// writes GitHub step summary and failed steps, also when build fails
+	Summary bool
+	Run     func(args []string)
+}
+
+// flags shared by many commands
+var (
+	flgUpload         bool
+	flgPlatform       string
+	flgUpdateBaseline bool
+	flgDryRun         bool
+)
+
+var (
+	flgFix           bool
+	flgCMake         bool
+	flgCppCheckAll   bool
+	flgClangTidyFix  bool
+	flgCrashIssues   bool
+	flgCrashIssueMin int
+	flgInGitHubCI    bool
+	flgCIUploadDaily bool
+)
+
+// flags accepted by all commands
+func addGlobalFlags(fs *flag.FlagSet) {
+	fs.BoolVar(&flgKeepGoing, "keep-going", false, "don't stop after a failure of independent steps (building platforms, uploading to each storage), report all failures at the end")
+	fs.BoolVar(&flgTaskLogs, "task-logs", false, "write output of concurrent tasks (uploads, signing) to out/logs/${task}.log instead of stdout")
+	fs.StringVar(&flgTimeouts, "timeouts", "", "override timeouts of external commands e.g. msbuild=3h,signtool=20m,http=5m,default=1h")
+	fs.BoolVar(&flgNoNotify, "no-notify", false, "don't show desktop notification when a local run takes longer than a few minutes")
+	fs.BoolVar(&flgNotifySound, "notify-sound", false, "play sound with the desktop notification")
+	fs.BoolVar(&flgWaitForLock, "wait", false, "if another do is building in out/, wait for it to finish instead of failing")
+	fs.BoolVar(&flgOutPerBranch, "out-per-branch", false, "keep build output of other branches in out/${dir}-${branch} and restore it when switching back")
+	fs.StringVar(&flgStorageEndpoint, "storage-endpoint", "", "upload to this S3-compatible server instead of R2 and Backblaze e.g. http://localhost:9000 for local MinIO")
+	fs.BoolVar(&flgSkipPreflight, "skip-preflight", false, "skip checking free disk space, long paths support and processes locking out/ before builds")
+	fs.StringVar(&flgHooksDir, "hooks-dir", flgHooksDir, "directory with pre-${step} and post-${step} hook scripts")
+	fs.StringVar(&flgProxy, "proxy", "", "proxy for http requests e.g. http://proxy:3128 (by default from HTTPS_PROXY env variable)")
+}
+
+// flags of commands that build, sign and upload SumatraPDF
+func addBuildFlags(fs *flag.FlagSet) {
+	fs.StringVar(&flgPlatforms, "platforms", "", "build, sign and upload only those platforms e.g. arm64,x64 to respin them in an uploaded build")
+	fs.StringVar(&flgAllowSizeIncrease, "allow-size-increase", "", "allow exceeding size budgets in do/size_budgets.txt, the reason is recorded in the manifest")
+	fs.BoolVar(&flgPgo, "pgo", false, "optimize release build with PGO profiles from out/pgo (see: do pgo pull)")
+	fs.StringVar(&flgPortableFormats, "portable-formats", "zip", "comma-separated formats of portable archive: zip, 7z, sfx (7-Zip self-extracting .exe); zip is required")
+	fs.BoolVar(&flgUpx, "upx", false, "compress SumatraPDF.exe and libmupdf.dll with UPX before signing and record size and startup time change in the manifest")
+	fs.StringVar(&flgUploadLimit, "upload-limit", "", "limit bandwidth of uploads to storage to that much per second e.g. 2MB")
+	fs.StringVar(&flgUploadWindow, "upload-window", "", "upload only in that time of day (local time) e.g. 01:00-07:00, waits for it otherwise")
+	fs.StringVar(&flgEncryptArtifacts, "encrypt-artifacts", "", "before upload, encrypt files in out/final-* matching comma-separated patterns e.g. '*-asan*' with ARTIFACTS_KEY")
+	fs.BoolVar(&flgSkipTransGate, "skip-trans-gate", false, "don't fail release build if translations are not complete enough")
+	fs.IntVar(&flgTransMinComplete, "trans-min-complete", flgTransMinComplete, "translation gate: minimum percent of translated strings in shipping languages")
+}
+
+func addUploadFlag(fs *flag.FlagSet, help string) {
+	fs.BoolVar(&flgUpload, "upload", false, help)
+}
+
+func addPlatformFlag(fs *flag.FlagSet) {
+	fs.StringVar(&flgPlatform, "platform", "", "platform: Win32, x64 or ARM64, x64 by default")
+}
+
+func addUpdateBaselineFlag(fs *flag.FlagSet) {
+	fs.BoolVar(&flgUpdateBaseline, "update-baseline", false, "re-create the baseline instead of comparing with it")
+}
+
+func withFlags(fns ...func(fs *flag.FlagSet)) func(fs *flag.FlagSet) {
+	return func(fs *flag.FlagSet) {
+		for _, fn := range fns {
+			fn(fs)
+		}
+	}
+}
+
+func getPlatformFlagMust() string {
+	if flgPlatform == "" {
+		return kPlatformIntel64
+	}
+	panicIf(!stringInSlice(fanoutPlatforms, flgPlatform), "invalid platform '%s'", flgPlatform)
+	return flgPlatform
+}
+
+// -upload of shortcuts for profiles signs and uploads
+func runProfileCommand(name string, uploadFromFlag bool) {
+	p := getBuildProfileMust(name)
+	if uploadFromFlag {
+		p.Sign = flgUpload
+		p.Upload = flgUpload
+	}
+	setProfilePlatformMust(p, flgPlatform)
+	runBuildProfileMust(p)
+}
+
+func uploadPreReleaseIf(opts *BuildOptions) {
+	if !opts.upload {
+		logf("uploadToStorage: skipping because opts.upload = false\n")
+		return
+	}
+	uploadToStorage(buildTypePreRel)
+}
+
+var doCommands = []*DoCommand{
+	// build
+	{
+		Name:    "build ci",
+		Help:    "CI build on push: check generated docs and build 32-bit and all projects. Signs if this is master of the main repo",
+		Flags:   withFlags(addBuildFlags, func(fs *flag.FlagSet) { addUploadFlag(fs, "sign and upload the build") }),
+		Audit:   true,
+		Summary: true,
+		Run: func(args []string) {
+			// only sign if this is my repo (not a fork), master branch (not
+			// work branches) and on push (not pull requests etc.)
+			opts := &BuildOptions{sign: isMyMasterBranch() || flgUpload, upload: flgUpload}
+			ensureBuildOptionsPreRequesites(opts)
+			buildCi()
+			uploadPreReleaseIf(opts)
+		},
+	},
+	{
+		Name: "build ci-upload",
+		Help: "upload the result of CI build (done in an earlier step) to storage",
+		Flags: withFlags(addBuildFlags, func(fs *flag.FlagSet) {
+			fs.BoolVar(&flgCIUploadDaily, "daily", false, "the build is a daily build: after upload announce it and record its sha so that the next one can be skipped if there are no new commits")
+		}),
+		Run: func(args []string) {
+			// only upload if this is my repo (not a fork), master branch
+			// (not work branches) and on push (not pull requests etc.)
+			ensureBuildOptionsPreRequesites(&BuildOptions{upload: isMyMasterBranch()})
+			defer saveStepFailures()
+			uploaded := false
+			runStepWithRetry("upload", func() {
+				uploaded = uploadToStorage(buildTypePreRel)
+			})
+			if uploaded && flgCIUploadDaily {
+				afterProfileUploadMust(getBuildProfileMust("daily"))
+			}
+		},
+	},
+	{
+		Name:    "build fanout",
+		Help:    "run per-platform builds as separate GitHub workflows, then sign and upload",
+		Flags:   withFlags(addBuildFlags, func(fs *flag.FlagSet) { addUploadFlag(fs, "sign and upload the build") }),
+		Audit:   true,
+		Summary: true,
+		Run: func(args []string) {
+			opts := &BuildOptions{sign: flgUpload, upload: flgUpload}
+			ensureBuildOptionsPreRequesites(opts)
+			ciFanout()
+			uploadPreReleaseIf(opts)
+		},
+	},
+	{
+		Name:  "build platform",
+		Args:  "<platform>",
+		Help:  "build a single platform (Win32, x64, ARM64) without signing, for build fanout",
+		Flags: addBuildFlags,
+		// processes of build fanout add to audit of the parent
+		Summary: true,
+		Run: func(args []string) {
+			ensureBuildOptionsPreRequesites(&BuildOptions{})
+			ciBuildPlatform(args[0])
+		},
+	},
+	{
+		Name: "build daily",
+		Help: "daily pre-release build of all platforms, skipped if there were no commits since the last one",
+		Flags: withFlags(addBuildFlags, addPlatformFlag, func(fs *flag.FlagSet) {
+			addUploadFlag(fs, "sign and upload the build")
+		}),
+		Audit:   true,
+		Summary: true,
+		Run: func(args []string) {
+			runProfileCommand("daily", true)
+		},
+	},
+	{
+		Name: "build prerel",
+		Help: "64-bit pre-release build of all projects",
+		Flags: withFlags(addBuildFlags, addPlatformFlag, func(fs *flag.FlagSet) {
+			addUploadFlag(fs, "sign and upload the build")
+		}),
+		Audit:   true,
+		Summary: true,
+		Run: func(args []string) {
+			runProfileCommand("prerelease", true)
+		},
+	},
+	{
+		Name:    "build release",
+		Help:    "release build of all platforms, from clean release branch",
+		Flags:   withFlags(addBuildFlags, func(fs *flag.FlagSet) { addUploadFlag(fs, "sign and upload the build") }),
+		Audit:   true,
+		Summary: true,
+		Run: func(args []string) {
+			runProfileCommand("release", true)
+		},
+	},
+	{
+		Name:    "build profile",
+		Args:    "<profile>",
+		Help:    "build with named profile: dev, daily, prerelease or release",
+		Flags:   withFlags(addBuildFlags, addPlatformFlag),
+		Audit:   true,
+		Summary: true,
+		Run: func(args []string) {
+			runProfileCommand(args[0], false)
+		},
+	},
+	{
+		Name:    "build smoke",
+		Help:    "smoke build (installer for 64bit release)",
+		Flags:   addBuildFlags,
+		Summary: true,
+		Run: func(args []string) {
+			ensureBuildOptionsPreRequesites(&BuildOptions{})
+			buildSmoke()
+		},
+	},
+	{
+		Name: "build project",
+		Args: "<project>",
+		Help: "build just one project from vs2022 solutions e.g. PdfPreview, MakeLZSA into out/ dir for -platform",
+		Flags: withFlags(addPlatformFlag, func(fs *flag.FlagSet) {
+			fs.BoolVar(&flgSignProject, "sign", false, "sign the output")
+		}),
+		Run: func(args []string) {
+			buildProjectMust(args[0], flgPlatform, flgSignProject)
+		},
+	},
+	{
+		Name: "build unity",
+		Help: "fast debug 64-bit build using unity build files",
+		Run: func(args []string) {
+			buildUnity()
+		},
+	},
+	{
+		Name:  "build logview",
+		Help:  "build logview-win",
+		Flags: func(fs *flag.FlagSet) { addUploadFlag(fs, "also upload it to backblaze") },
+		Run: func(args []string) {
+			buildLogView()
+			if flgUpload {
+				uploadLogView()
+			}
+		},
+	},
+	{
+		Name:   "build lzsa",
+		Help:   "build MakeLZSA.exe",
+		Hidden: true,
+		Run: func(args []string) {
+			buildLzsa()
+		},
+	},
+	{
+		Name:  "run",
+		Args:  "<steps>",
+		Help:  "run comma-separated build steps e.g. sign:rel64,upload:backblaze. 'do run list' shows all steps",
+		Flags: addBuildFlags,
+		Run: func(args []string) {
+			runPipelineStepsMust(args[0])
+		},
+	},
+	{
+		Name: "announce",
+		Help: "generate announcement of pre-release in out/final-prerel and post it to ANNOUNCE_WEBHOOK_URL",
+		Run: func(args []string) {
+			announcePreReleaseMust()
+		},
+	},
+
+	// translations
+	{
+		Name: "trans download",
+		Help: "download latest translations to translations/translations.txt",
+		Run: func(args []string) {
+			downloadTranslations()
+		},
+	},
+	{
+		Name: "trans strings",
+		Help: "print strings to translate extracted from sources, with their locations",
+		Run: func(args []string) {
+			printTranslatableStrings()
+		},
+	},
+	{
+		Name: "trans gate",
+		Help: "check that translations are complete enough for a release",
+		Flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&flgTransMinComplete, "trans-min-complete", flgTransMinComplete, "minimum percent of translated strings in shipping languages")
+		},
+		Run: func(args []string) {
+			checkTranslationGateMust()
+		},
+	},
+	{
+		Name:   "trans gen-info",
+		Help:   "generate src/TranslationLangs.cpp",
+		Hidden: true,
+		Run: func(args []string) {
+			genTranslationInfoCpp()
+		},
+	},
+
+	// docs
+	{
+		Name: "docs gen",
+		Help: "generate docs that are derived from sources (keyboard shortcuts, supported formats etc.)",
+		Run: func(args []string) {
+			genDocs()
+		},
+	},
+	{
+		Name: "docs check",
+		Help: "check that docs generated from sources are up to date",
+		Run: func(args []string) {
+			checkGeneratedDocsMust()
+		},
+	},
+	{
+		Name: "docs download-page",
+		Help: "generate download page data from uploaded builds and push to sumatra-website repo",
+		Run: func(args []string) {
+			genDownloadPage()
+		},
+	},
+	{
+		Name: "docs screenshots",
+		Help: "take screenshots of out/rel64/SumatraPDF.exe in different UI states",
+		Run: func(args []string) {
+			takeScreenshots(rel64Dir)
+		},
+	},
+
+	// generated code and project files
+	{
+		Name: "gen settings",
+		Help: "re-generate src/Settings.h",
+		Run: func(args []string) {
+			genAndSaveSettingsStructs()
+		},
+	},
+	{
+		Name: "gen version-rc",
+		Help: "generate src/**/*.version.rc version resources from src/Version.h",
+		Run: func(args []string) {
+			genVersionRcsMust()
+		},
+	},
+	{
+		Name: "gen projects",
+		Help: "generate premake5.files.gen.lua from do/project_files.go and re-generate vs2022 projects",
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&flgCMake, "cmake", false, "also generate cmake/sources.cmake")
+		},
+		Run: func(args []string) {
+			genProjects(flgCMake)
+		},
+	},
+	{
+		Name: "gen premake",
+		Help: "regenerate vs2022 projects with premake",
+		Run: func(args []string) {
+			regenPremake()
+		},
+	},
+	{
+		Name: "gen compile-db",
+		Help: "generate compile_commands.json from vs2022 projects (for clangd, clang-tidy etc.)",
+		Run: func(args []string) {
+			genCompileDb()
+		},
+	},
+	{
+		Name: "gen unity",
+		Help: "generate unity build files in out/unity",
+		Run: func(args []string) {
+			genUnityFiles()
+		},
+	},
+
+	// checks
+	{
+		Name: "check access-keys",
+		Help: "check access keys for menu items",
+		Run: func(args []string) {
+			checkAccessKeys()
+		},
+	},
+	{
+		Name: "check vcxproj",
+		Help: "check that vs2022 projects match .cpp/.h files on disk",
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&flgFix, "fix", false, "fix the projects")
+		},
+		Run: func(args []string) {
+			checkVcxprojSync(flgFix)
+		},
+	},
+	{
+		Name: "check vulns",
+		Help: "check NVD for CVEs affecting vendored libraries in ext/ and mupdf/",
+		Run: func(args []string) {
+			checkVulnsMust()
+		},
+	},
+	{
+		Name: "check toolchain",
+		Help: "check that installed Visual Studio, Windows SDK and clang-format match do/toolchain.txt",
+		Run: func(args []string) {
+			checkToolchainMust()
+		},
+	},
+	{
+		Name:  "check exports",
+		Help:  "check exports of out/rel64/libmupdf.dll against do/libmupdf_exports.txt and imports of its users",
+		Flags: addUpdateBaselineFlag,
+		Run: func(args []string) {
+			checkLibmupdfExportsMust(rel64Dir, flgUpdateBaseline)
+		},
+	},
+	{
+		Name: "check abi",
+		Help: "report changes in exported functions and their signatures of out/rel64/libmupdf.dll since the latest release",
+		Run: func(args []string) {
+			abiDiff()
+		},
+	},
+	{
+		Name: "check installer-l10n",
+		Help: "verify translations embedded in out/rel64/SumatraPDF-dll.exe and test silent install in sample languages in Windows Sandbox",
+		Run: func(args []string) {
+			verifyInstallerL10n(rel64Dir)
+		},
+	},
+	{
+		Name:  "check uia",
+		Help:  "check UI Automation tree of out/rel64/SumatraPDF.exe against do/uia_baseline.txt",
+		Flags: addUpdateBaselineFlag,
+		Run: func(args []string) {
+			checkUia(rel64Dir, flgUpdateBaseline)
+		},
+	},
+	{
+		Name:  "check leaks",
+		Help:  "run tests under Dr. Memory and compare leaks with do/leaks_baseline.txt",
+		Flags: addUpdateBaselineFlag,
+		Run: func(args []string) {
+			checkLeaks(rel64Dir, flgUpdateBaseline)
+		},
+	},
+
+	// tests
+	{
+		Name: "test installer",
+		Help: "test silent install / uninstall of out/rel64/SumatraPDF-dll.exe in Windows Sandbox",
+		Run: func(args []string) {
+			testInstallerInSandbox(rel64Dir)
+		},
+	},
+	{
+		Name: "test shell-ext",
+		Help: "build and test registration of PdfPreview.dll / PdfFilter.dll in Windows Sandbox",
+		Run: func(args []string) {
+			testShellExtInSandbox(rel64Dir)
+		},
+	},
+	{
+		Name: "test auto-update",
+		Help: "test auto-update from previous release to the build in out/final-rel or out/final-prerel (must run as admin)",
+		Run: func(args []string) {
+			testAutoUpdateMust()
+		},
+	},
+	{
+		Name: "test util",
+		Help: "build and run test_util executable",
+		Run: func(args []string) {
+			ensureBuildOptionsPreRequesites(&BuildOptions{})
+			buildTestUtil()
+			cmd := exec.Command(".\\test_util.exe")
+			cmd.Dir = filepath.Join("out", "rel64")
+			runCmdLoggedMust(cmd)
+		},
+	},
+	{
+		Name: "test self",
+		Help: "test build pipeline logic (naming, upload, retention, promotion) end-to-end against in-memory fakes",
+		Run: func(args []string) {
+			runSelfTestsMust()
+		},
+	},
+	{
+		Name: "test drmem",
+		Help: "build release 64-bit and run it under Dr. Memory",
+		Run: func(args []string) {
+			ensureBuildOptionsPreRequesites(&BuildOptions{})
+			buildJustPortableExe(rel64Dir, "Release", kPlatformIntel64)
+			//cmd := exec.Command("drmemory.exe", "-light", "-check_leaks", "-possible_leaks", "-count_leaks", "-suppress", "drmem-sup.txt", "--", ".\\out\\rel64\\SumatraPDF.exe")
+			cmd := exec.Command(detectDrMemoryMust(), "-leaks_only", "-suppress", "drmem-sup.txt", "--", ".\\out\\rel64\\SumatraPDF.exe")
+			runCmdLoggedMust(cmd)
+		},
+	},
+
+	// static analysis
+	{
+		Name: "analyze msvc",
+		Help: "build with /analyze and compare warnings with do/analyze_baseline.txt",
+		Flags: withFlags(addUpdateBaselineFlag, func(fs *flag.FlagSet) {
+			addUploadFlag(fs, "upload SARIF to GitHub code scanning")
+		}),
+		Run: func(args []string) {
+			runAnalyze(flgUpdateBaseline, flgUpload)
+		},
+	},
+	{
+		Name:  "analyze codeql",
+		Help:  "create CodeQL database locally and run queries (needs CodeQL CLI)",
+		Flags: func(fs *flag.FlagSet) { addUploadFlag(fs, "upload SARIF to GitHub code scanning") },
+		Run: func(args []string) {
+			runCodeQL(flgUpload)
+		},
+	},
+	{
+		Name: "analyze trigger-codeql",
+		Help: "trigger codeql build",
+		Run: func(args []string) {
+			triggerBuildWebHook(ciEventTypeCodeQL)
+		},
+	},
+	{
+		Name:  "analyze merge-sarif",
+		Help:  "merge results of all static analyzers into out/merged.sarif",
+		Flags: func(fs *flag.FlagSet) { addUploadFlag(fs, "upload to GitHub code scanning") },
+		Run: func(args []string) {
+			mergeSarif(flgUpload)
+		},
+	},
+	{
+		Name: "analyze cppcheck",
+		Help: "run cppcheck (must be installed)",
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&flgCppCheckAll, "all", false, "run with more checks")
+		},
+		Run: func(args []string) {
+			runCppCheck(flgCppCheckAll)
+		},
+	},
+	{
+		Name:   "analyze clang-tidy",
+		Help:   "run clang-tidy (must be installed)",
+		Hidden: true,
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&flgClangTidyFix, "fix", false, "apply fixes")
+		},
+		Run: func(args []string) {
+			runClangTidy(flgClangTidyFix)
+		},
+	},
+
+	// reports
+	{
+		Name: "report size",
+		Help: "report size of rel64 SumatraPDF.exe by .obj / .lib and largest functions as csv and html treemap (needs llvm-pdbutil)",
+		Run: func(args []string) {
+			sizeReport()
+		},
+	},
+	{
+		Name: "report map",
+		Help: "build with linker map and /VERBOSE:REF,ICF and report size by object, largest symbols and unreferenced code",
+		Run: func(args []string) {
+			mapReport()
+		},
+	},
+	{
+		Name: "report why-included",
+		Args: "<name>",
+		Help: "show why a .lib, .obj or symbol (substring) is linked into SumatraPDF.exe",
+		Run: func(args []string) {
+			whyIncluded(args[0])
+		},
+	},
+	{
+		Name: "report pch",
+		Help: "build with /showIncludes and report precompiled header usage per project",
+		Run: func(args []string) {
+			pchReport()
+		},
+	},
+	{
+		Name:  "report compile-times",
+		Help:  "build with /Bt+ and report slowest files and headers, compared with do/compile_times_baseline.txt",
+		Flags: addUpdateBaselineFlag,
+		Run: func(args []string) {
+			compileTimesReport(flgUpdateBaseline)
+		},
+	},
+	{
+		Name: "report flakiness",
+		Help: "report CI steps that failed in the last 7 days",
+		Run: func(args []string) {
+			flakinessReport()
+		},
+	},
+	{
+		Name: "report crashes",
+		Help: "download recent crash reports and generate top crashers report",
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&flgCrashIssues, "crash-issues", false, "link crash signatures to GitHub issues and open draft issues for frequent crashes")
+			fs.IntVar(&flgCrashIssueMin, "crash-issue-min", crashIssueMinReports, "with -crash-issues, open an issue for crashes with at least this many reports")
+			fs.BoolVar(&flgDryRun, "dry-run", false, "with -crash-issues, show what issues would be opened without opening them")
+		},
+		Run: func(args []string) {
+			crashesReport(flgCrashIssues, flgCrashIssueMin, !flgDryRun)
+		},
+	},
+	{
+		Name: "report wc",
+		Help: "show loc stats (like wc -l)",
+		Run: func(args []string) {
+			doLineCount()
+		},
+	},
+	{
+		Name:   "report largest-files",
+		Help:   "show largest files by extension",
+		Hidden: true,
+		Run: func(args []string) {
+			findLargestFileByExt()
+		},
+	},
+
+	// formatting and git hooks
+	{
+		Name: "format src",
+		Help: "format source files in src/ with clang-format",
+		Run: func(args []string) {
+			clangFormatFiles()
+		},
+	},
+	{
+		Name: "format all",
+		Help: "format all C/C++ sources in src/ and ext/ not in .clang-format-ignore, in parallel",
+		Run: func(args []string) {
+			clangFormatAllFiles()
+		},
+	},
+	{
+		Name: "hooks install",
+		Help: "install git pre-commit hook that checks formatting, access keys and strings to translate of staged files",
+		Run: func(args []string) {
+			installGitHooksMust()
+		},
+	},
+	{
+		Name: "hooks pre-commit",
+		Help: "run checks of the pre-commit hook on staged files",
+		Run: func(args []string) {
+			runPreCommitChecksMust()
+		},
+	},
+
+	// releases and auto-update
+	{
+		Name:  "release wizard",
+		Help:  "interactive release checklist: build, sign, upload, packages, auto-update. Can be resumed",
+		Flags: addBuildFlags,
+		Run: func(args []string) {
+			releaseWizard()
+		},
+	},
+	{
+		Name: "release verify",
+		Args: "<ver>",
+		Help: "verify that release is on all storages and mirrors with the right content and print go/no-go report",
+		Run: func(args []string) {
+			verifyReleaseMust(args[0])
+		},
+	},
+	{
+		Name: "release mirrors",
+		Help: "upload release build from out/final-rel to SourceForge and FossHub and verify",
+		Run: func(args []string) {
+			uploadToMirrors()
+		},
+	},
+	{
+		Name: "release update-ver",
+		Args: "<ver>",
+		Help: "update version used for auto-update checks",
+		Run: func(args []string) {
+			ensureAllUploadCreds()
+			updateAutoUpdateVer(args[0])
+		},
+	},
+	{
+		Name: "release schedule",
+		Args: "<ver[,time[,rolloutPercent]]>",
+		Help: "schedule auto-update promotion",
+		Run: func(args []string) {
+			schedulePromotion(args[0])
+		},
+	},
+	{
+		Name: "release list-scheduled",
+		Help: "list scheduled auto-update promotions",
+		Run: func(args []string) {
+			printScheduledPromotions()
+		},
+	},
+	{
+		Name: "release tick",
+		Help: "execute pending scheduled promotions (called from cron)",
+		Run: func(args []string) {
+			tickScheduledPromotions()
+		},
+	},
+
+	// storage and uploaded builds
+	{
+		Name: "storage prune-symbols",
+		Help: "delete old pre-release symbols not referenced by recent crash reports",
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&flgDryRun, "dry-run", false, "show what would be deleted without deleting")
+		},
+		Run: func(args []string) {
+			pruneSymbolsStorage(!flgDryRun)
+		},
+	},
+	{
+		Name: "storage upload-file",
+		Args: "<path>",
+		Help: "upload a test file to s3 / spaces",
+		Run: func(args []string) {
+			fileUpload(args[0])
+		},
+	},
+	{
+		Name: "storage list",
+		Help: "list uploaded files in s3 / spaces",
+		Run: func(args []string) {
+			filesList()
+		},
+	},
+	{
+		Name:  "builds get",
+		Args:  "<ver>",
+		Help:  "download and verify files of pre-release build number or release version to out/builds",
+		Flags: addPlatformFlag,
+		Run: func(args []string) {
+			getBuildMust(args[0], getPlatformFlagMust())
+		},
+	},
+	{
+		Name: "builds addr2line",
+		Args: "<ver>",
+		Help: "resolve -offset in -module of a build (pre-release build number or release version) to function, file and line",
+		Flags: withFlags(addPlatformFlag, func(fs *flag.FlagSet) {
+			fs.StringVar(&flgAddr2lineModule, "module", "", "module e.g. libmupdf.dll")
+			fs.StringVar(&flgAddr2lineOffset, "offset", "", "comma-separated offsets (0x1234 or section:offset)")
+		}),
+		Run: func(args []string) {
+			addr2line(args[0], getPlatformFlagMust(), flgAddr2lineModule, flgAddr2lineOffset)
+		},
+	},
+	{
+		Name: "builds bisect",
+		Args: "-- <command>...",
+		Help: "find first bad build between -bisect-good and -bisect-bad by running command, e.g. -- repro.bat ${exe}",
+		Flags: func(fs *flag.FlagSet) {
+			fs.IntVar(&flgBisectGood, "bisect-good", 0, "build number of a good build")
+			fs.IntVar(&flgBisectBad, "bisect-bad", 0, "build number of a bad build")
+		},
+		Run: func(args []string) {
+			bisect(args)
+		},
+	},
+	{
+		Name: "builds info",
+		Args: "<build-no>",
+		Help: "print commit of a pre-release build number",
+		Run: func(args []string) {
+			buildNo, err := strconv.Atoi(args[0])
+			must(err)
+			printBuildNoInfo(buildNo)
+		},
+	},
+
+	// artifacts and installer
+	{
+		Name: "artifacts serve",
+		Help: "serve builds in out/final-* and update info for them over local http, with production url layout",
+		Flags: func(fs *flag.FlagSet) {
+			fs.StringVar(&flgServeAddr, "serve-addr", serveDefaultAddr, "address to listen on")
+			fs.BoolVar(&flgServeTLS, "serve-tls", false, "serve over https with self-signed certificate for testing the app's update check with hosts override")
+		},
+		Run: func(args []string) {
+			serveArtifactsMust()
+		},
+	},
+	{
+		Name: "artifacts decrypt",
+		Args: "<path-or-url>",
+		Help: "decrypt .enc file encrypted with -encrypt-artifacts",
+		Run: func(args []string) {
+			decryptArtifactMust(args[0])
+		},
+	},
+	{
+		Name: "artifacts gen-key",
+		Help: "generate a new ARTIFACTS_KEY for -encrypt-artifacts",
+		Run: func(args []string) {
+			genArtifactsKey()
+		},
+	},
+	{
+		Name: "installer ls",
+		Args: "<installer>",
+		Help: "list files in the payload of a given installer (SumatraPDF-dll.exe)",
+		Run: func(args []string) {
+			installerPayloadList(args[0])
+		},
+	},
+	{
+		Name: "installer extract",
+		Args: "<installer> [dir]",
+		Help: "extract payload of a given installer to out/installer-payload or dir",
+		Run: func(args []string) {
+			dstDir := filepath.Join("out", "installer-payload")
+			if len(args) > 1 {
+				dstDir = args[1]
+			}
+			installerPayloadExtract(args[0], dstDir)
+		},
+	},
+
+	// PGO
+	{
+		Name: "pgo upload",
+		Help: "merge PGO training profiles in out/pgo and upload them to R2",
+		Run: func(args []string) {
+			pgoUpload()
+		},
+	},
+	{
+		Name: "pgo pull",
+		Help: "download latest PGO profiles compatible with installed toolset to out/pgo",
+		Run: func(args []string) {
+			pgoPull()
+		},
+	},
+
+	// the do tool itself and dev environment
+	{
+		Name:    "self update",
+		Help:    "update do.exe to the latest published version",
+		NoSetup: true,
+		Run: func(args []string) {
+			selfUpdate()
+		},
+	},
+	{
+		Name: "self publish",
+		Help: "build, sign and publish do.exe for self update",
+		Run: func(args []string) {
+			ensureAllUploadCreds()
+			selfPublish()
+		},
+	},
+	{
+		Name: "doctor",
+		Help: "check that helper tools (wails, llvm-pdbutil, 7z) are installed and offer to install missing, check toolchain and run preflight checks",
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&flgYes, "yes", false, "install missing tools without asking")
+		},
+		Run: func(args []string) {
+			doctor()
+		},
+	},
+	{
+		Name: "pin-download",
+		Args: "<name=url>",
+		Help: "download url and pin its sha256 in do/downloads.txt",
+		Run: func(args []string) {
+			pinDownloadMust(args[0])
+		},
+	},
+	{
+		Name:    "update-go-deps",
+		Help:    "update go dependencies",
+		NoSetup: true,
+		Run: func(args []string) {
+			defer measureDuration()()
+			u.UpdateGoDeps("do", true)
+			u.UpdateGoDeps(filepath.Join("tools", "regress"), true)
+			u.UpdateGoDeps(filepath.Join("tools", "logview"), true)
+			u.UpdateGoDeps(filepath.Join("tools", "logview-win"), true)
+		},
+	},
+	{
+		Name: "serve",
+		Args: "<addr>",
+		Help: "run build server on a given address (e.g. :8400) that accepts authenticated build requests",
+		Run: func(args []string) {
+			runBuildServer(args[0])
+		},
+	},
+	{
+		Name: "clean",
+		Help: "clean the build (remove out/ files except for settings)",
+		Run: func(args []string) {
+			cleanPreserveSettings()
+		},
+	},
+	{
+		Name: "diff",
+		Help: "preview diff using winmerge",
+		Run: func(args []string) {
+			u.WinmergeDiffPreview()
+		},
+	},
+	{
+		Name: "logview",
+		Help: "run logview",
+		Run: func(args []string) {
+			logView()
+		},
+	},
+	{
+		Name: "extract-utils",
+		Help: "extract utils to ../pdfprint repo",
+		Flags: func(fs *flag.FlagSet) {
+			fs.BoolVar(&flgInGitHubCI, "ci", false, "running in GitHub Actions")
+		},
+		NoSetup: true,
+		Run: func(args []string) {
+			extractUtils(flgInGitHubCI)
+		},
+	},
+}
+
+func init() {
+	// refers to doCommands so can't be in the initializer
+	doCommands = append(doCommands, &DoCommand{
+		Name:    "help",
+		Args:    "[command]...",
+		Help:    "show commands, help of a command or, with global, flags accepted by all commands",
+		NoSetup: true,
+		Run:     runHelpCommand,
+	})
+}
+
+func findDoCommand(name string) *DoCommand {
+	for _, c := range doCommands {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// returns command given by the first one or two words of args and
+// the rest of args. nil if there is no such command
+func findDoCommandInArgs(args []string) (*DoCommand, []string) {
+	if len(args) >= 2 {
+		if c := findDoCommand(args[0] + " " + args[1]); c != nil {
+			return c, args[2:]
+		}
+	}
+	if len(args) >= 1 {
+		if c := findDoCommand(args[0]); c != nil {
+			return c, args[1:]
+		}
+	}
+	return nil, args
+}
+
+func newDoCommandFlagSet(c *DoCommand) *flag.FlagSet {
+	fs := flag.NewFlagSet("do "+c.Name, flag.ExitOnError)
+	if c.Flags != nil {
+		c.Flags(fs)
+	}
+	addGlobalFlags(fs)
+	fs.Usage = func() {
+		printDoCommandUsage(fs.Output(), c)
+	}
+	return fs
+}
+
+// names of DO_* env variables of all flags of all commands. Must be called
+// before parsing flags because registering a flag resets its variable
+func getAllFlagEnvNames() map[string]bool {
+	res := map[string]bool{}
+	for _, c := range doCommands {
+		newDoCommandFlagSet(c).VisitAll(func(f *flag.Flag) {
+			res[flagEnvName(f.Name)] = true
+		})
+	}
+	return res
+}
+
+// like fs.Parse but flags can be mixed with positional arguments.
+// Returns positional arguments, arguments after -- are returned as is
+func parseDoCommandFlags(fs *flag.FlagSet, args []string) []string {
+	var res []string
+	for {
+		must(fs.Parse(args))
+		rest := fs.Args()
+		if len(rest) == 0 {
+			return res
+		}
+		if n := len(args) - len(rest); n > 0 && args[n-1] == "--" {
+			return append(res, rest...)
+		}
+		res = append(res, rest[0])
+		args = rest[1:]
+	}
+}
+
+// "<ver> [dir]" => 1, 2. maxArgs is -1 if any number of arguments
+func getDoCommandNArgs(c *DoCommand) (minArgs int, maxArgs int) {
+	for _, arg := range strings.Fields(c.Args) {
+		if strings.HasPrefix(arg, "<") {
+			minArgs++
+		}
+		if strings.HasPrefix(arg, "<") || strings.HasPrefix(arg, "[") {
+			maxArgs++
+		}
+	}
+	if strings.Contains(c.Args, "...") {
+		maxArgs = -1
+	}
+	return minArgs, maxArgs
+}
+
+func checkDoCommandArgs(c *DoCommand, args []string) {
+	minArgs, maxArgs := getDoCommandNArgs(c)
+	if len(args) >= minArgs && (maxArgs < 0 || len(args) <= maxArgs) {
+		return
+	}
+	fmt.Fprintf(os.Stderr, "'do %s' needs arguments: %s, got: '%s'\n\n", c.Name, c.Args, strings.Join(args, " "))
+	printDoCommandUsage(os.Stderr, c)
+	os.Exit(2)
+}
+
+func printDoCommandUsage(w io.Writer, c *DoCommand) {
+	fmt.Fprintf(w, "Usage: do %s [flags] %s\n\n%s\n", c.Name, c.Args, c.Help)
+	if c.Flags != nil {
+		fs := flag.NewFlagSet("", flag.ContinueOnError)
+		fs.SetOutput(w)
+		c.Flags(fs)
+		fmt.Fprintf(w, "\nFlags:\n")
+		fs.PrintDefaults()
+	}
+	fmt.Fprintf(w, "\nGlobal flags are listed by: do help global\n")
+}
+
+// lists commands in a group or all commands if group is ""
+func printDoUsage(w io.Writer, group string) {
+	if !isDoCommandGroup(group) {
+		group = ""
+	}
+	fmt.Fprintf(w, "Usage: do <command> [flags] [args]\n\nCommands:\n")
+	prevGroup := ""
+	for _, c := range doCommands {
+		g := strings.Fields(c.Name)[0]
+		if c.Hidden || (group != "" && g != group) {
+			continue
+		}
+		if prevGroup != "" && g != prevGroup {
+			fmt.Fprintf(w, "\n")
+		}
+		prevGroup = g
+		fmt.Fprintf(w, "  %-36s %s\n", strings.TrimSpace(c.Name+" "+c.Args), c.Help)
+	}
+	fmt.Fprintf(w, "\n'do help <command>' or 'do <command> -h' shows flags of a command\n\n%s\n%s", fmtFlagsEnvUsage(), fmtExitCodesUsage())
+}
+
+func isDoCommandGroup(group string) bool {
+	for _, c := range doCommands {
+		if strings.Fields(c.Name)[0] == group {
+			return true
+		}
+	}
+	return false
+}
+
+func printGlobalFlagsUsage(w io.Writer) {
+	fs := flag.NewFlagSet("", flag.ContinueOnError)
+	fs.SetOutput(w)
+	addGlobalFlags(fs)
+	fmt.Fprintf(w, "Global flags, accepted by all commands:\n")
+	fs.PrintDefaults()
+}
+
+func runHelpCommand(args []string) {
+	w := os.Stdout
+	if len(args) == 1 && args[0] == "global" {
+		printGlobalFlagsUsage(w)
+		return
+	}
+	c, rest := findDoCommandInArgs(args)
+	if c != nil && len(rest) == 0 {
+		printDoCommandUsage(w, c)
+		return
+	}
+	group := ""
+	if len(args) > 0 {
+		group = args[0]
+	}
+	printDoUsage(w, group)
+}
+
+// old style flags (do -ci) => command, only for flags that existed before
+// commands. Modifier flags (-upload etc.) have the same names. Value of
+// a flag (-build-no-info 15780) becomes argument of the command
+var legacyFlagCommands = map[string]string{
+	"ci":                     "build ci",
+	"ci-upload":              "build ci-upload",
+	"ci-daily":               "build daily",
+	"build-pre-rel":          "build prerel",
+	"build-release":          "build release",
+	"smoke":                  "build smoke",
+	"build-logview":          "build logview",
+	"trans-dl":               "trans download",
+	"gen-settings":           "gen settings",
+	"premake":                "gen premake",
+	"check-access-keys":      "check access-keys",
+	"run-tests":              "test util",
+	"drmem":                  "test drmem",
+	"trigger-codeql":         "analyze trigger-codeql",
+	"cppcheck":               "analyze cppcheck",
+	"cppcheck-all":           "analyze cppcheck -all",
+	"wc":                     "report wc",
+	"format":                 "format src",
+	"update-auto-update-ver": "release update-ver",
+	"file-upload":            "storage upload-file",
+	"files-list":             "storage list",
+	"build-no-info":          "builds info",
+	"update-go-deps":         "update-go-deps",
+	"clean":                  "clean",
+	"diff":                   "diff",
+	"logview":                "logview",
+	"extract-utils":          "extract-utils",
+}
+
+// rewrites old style command line (-ci -upload) to a command line of
+// a command (build ci -upload). Returns nil if args don't have old style
+// command flag
+func rewriteLegacyArgs(args []string) []string {
+	for i, arg := range args {
+		if arg == "--" {
+			return nil
+		}
+		name, val, hasVal := strings.Cut(strings.TrimLeft(arg, "-"), "=")
+		cmdLine, ok := legacyFlagCommands[name]
+		if !strings.HasPrefix(arg, "-") || !ok {
+			continue
+		}
+		words := strings.Fields(cmdLine)
+		c, _ := findDoCommandInArgs(words)
+		rest := append([]string{}, args[:i]...)
+		if minArgs, _ := getDoCommandNArgs(c); minArgs > 0 {
+			// -get-build=15780 or -get-build 15780
+			if !hasVal && i+1 < len(args) {
+				val = args[i+1]
+				i++
+			}
+			words = append(words, val)
+		}
+		rest = append(rest, args[i+1:]...)
+		res := append(words, rest...)
+		logf("'%s' is deprecated, use: do %s\n", arg, strings.Join(res, " "))
+		return res
+	}
+	return nil
+}
+
+// returns command to run and its arguments (flags and positional).
+// Shows usage and exits if there's no command
+func parseDoCommandLine(args []string) (*DoCommand, []string) {
+	if len(args) > 0 && strings.HasPrefix(args[0], "-") {
+		switch strings.TrimLeft(args[0], "-") {
+		case "h", "help":
+			args = []string{"help"}
+		default:
+			if newArgs := rewriteLegacyArgs(args); newArgs != nil {
+				args = newArgs
+			}
+		}
+	}
+	c, rest := findDoCommandInArgs(args)
+	if c != nil {
+		return c, rest
+	}
+	if len(args) > 0 {
+		fmt.Fprintf(os.Stderr, "unknown command '%s'\n\n", strings.Join(args, " "))
+	}
+	group := ""
+	if len(args) > 0 {
+		group = args[0]
+	}
+	printDoUsage(os.Stderr, group)
+	os.Exit(2)
+	return nil, nil
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// generates compile_commands.json from vs2022/*.vcxproj for clangd, clang-tidy,
+// include-what-you-use and cppcheck (do analyze cppcheck uses it if present)
+// https://clang.llvm.org/docs/JSONCompilationDatabase.html
+// we use clang-cl as the compiler so that clang tools understand MSVC flags
+
+const compileDbConfig = "Release|x64"
+
+// CompileCommand is an entry in compile_commands.json
+type CompileCommand struct {
+	Directory string   `json:"directory"`
+	File      string   `json:"file"`
+	Arguments []string `json:"arguments"`
+}
+
+type vcxprojClCompileSettings struct {
+	PreprocessorDefinitions         string
+	UndefinePreprocessorDefinitions string
+	AdditionalIncludeDirectories    string
+	AdditionalOptions               string
+	LanguageStandard                string
+}
+
+type vcxprojConditional struct {
+	Condition string `xml:"Condition,attr"`
+	Value     string `xml:",chardata"`
+}
+
+type vcxprojForCompileDb struct {
+	PropertyGroups []struct {
+		Condition    string `xml:"Condition,attr"`
+		Label        string `xml:"Label,attr"`
+		CharacterSet string
+	} `xml:"PropertyGroup"`
+	ItemDefinitionGroups []struct {
+		Condition string                   `xml:"Condition,attr"`
+		ClCompile vcxprojClCompileSettings `xml:"ClCompile"`
+	} `xml:"ItemDefinitionGroup"`
+	ItemGroups []struct {
+		ClCompile []struct {
+			Include           string               `xml:"Include,attr"`
+			ExcludedFromBuild []vcxprojConditional `xml:"ExcludedFromBuild"`
+		} `xml:"ClCompile"`
+	} `xml:"ItemGroup"`
+}
+
+func vcxprojCondition(config string) string {
+	return fmt.Sprintf("'$(Configuration)|$(Platform)'=='%s'", config)
+}
+
+// splits "a;b;%(PreprocessorDefinitions)" into "a", "b"
+func splitVcxprojList(s string) []string {
+	var res []string
+	for _, v := range strings.Split(s, ";") {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.HasPrefix(v, "%(") {
+			continue
+		}
+		res = append(res, v)
+	}
+	return res
+}
+
+var vcxprojLanguageStandards = map[string]string{
+	"stdcpp14":     "/std:c++14",
+	"stdcpp17":     "/std:c++17",
+	"stdcpp20":     "/std:c++20",
+	"stdcpplatest": "/std:c++latest",
+}
+
+func compileCommandsForVcxproj(projPath string, config string) ([]*CompileCommand, error) {
+	var p vcxprojForCompileDb
+	if err := xml.Unmarshal(readFileMust(projPath), &p); err != nil {
+		return nil, err
+	}
+	cond := vcxprojCondition(config)
+	var settings *vcxprojClCompileSettings
+	for i, g := range p.ItemDefinitionGroups {
+		if g.Condition == cond {
+			settings = &p.ItemDefinitionGroups[i].ClCompile
+		}
+	}
+	if settings == nil {
+		// project doesn't have this configuration
+		return nil, nil
+	}
+
+	projDir := absPathMust(filepath.Dir(projPath))
+	args := []string{"clang-cl.exe", "/c", "/nologo"}
+	for _, g := range p.PropertyGroups {
+		if g.Label == "Configuration" && g.Condition == cond && g.CharacterSet == "Unicode" {
+			args = append(args, "-DUNICODE", "-D_UNICODE")
+		}
+	}
+	for _, s := range splitVcxprojList(settings.PreprocessorDefinitions) {
+		args = append(args, "-D"+s)
+	}
+	for _, s := range splitVcxprojList(settings.UndefinePreprocessorDefinitions) {
+		args = append(args, "-U"+s)
+	}
+	for _, s := range splitVcxprojList(settings.AdditionalIncludeDirectories) {
+		args = append(args, "-I"+s)
+	}
+	for _, s := range strings.Fields(settings.AdditionalOptions) {
+		if !strings.HasPrefix(s, "%(") {
+			args = append(args, s)
+		}
+	}
+	std := vcxprojLanguageStandards[settings.LanguageStandard]
+
+	var res []*CompileCommand
+	for _, g := range p.ItemGroups {
+		for _, it := range g.ClCompile {
+			excluded := false
+			for _, e := range it.ExcludedFromBuild {
+				if e.Condition == cond && e.Value == "true" {
+					excluded = true
+				}
+			}
+			if excluded {
+				continue
+			}
+			path := filepath.Join(projDir, filepath.FromSlash(strings.ReplaceAll(it.Include, `\`, "/")))
+			fileArgs := append([]string{}, args...)
+			isC := strings.ToLower(filepath.Ext(path)) == ".c"
+			if isC {
+				fileArgs = append(fileArgs, "/TC")
+			} else if std != "" {
+				fileArgs = append(fileArgs, std)
+			}
+			fileArgs = append(fileArgs, path)
+			res = append(res, &CompileCommand{
+				Directory: projDir,
+				File:      path,
+				Arguments: fileArgs,
+			})
+		}
+	}
+	return res, nil
+}
+
+func genCompileDb() {
+	projects, err := filepath.Glob(filepath.Join("vs2022", "*.vcxproj"))
+	must(err)
+	// the same file can be compiled by more than one project, we prefer
+	// flags from SumatraPDF.vcxproj
+	sort.Slice(projects, func(i, j int) bool {
+		isMain1 := filepath.Base(projects[i]) == "SumatraPDF.vcxproj"
+		isMain2 := filepath.Base(projects[j]) == "SumatraPDF.vcxproj"
+		if isMain1 != isMain2 {
+			return isMain1
+		}
+		return projects[i] < projects[j]
+	})
+
+	var commands []*CompileCommand
+	seen := map[string]bool{}
+	for _, proj := range projects {
+		cmds, err := compileCommandsForVcxproj(proj, compileDbConfig)
+		panicIf(err != nil, "failed to parse '%s': %s", proj, err)
+		for _, c := range cmds {
+			if seen[c.File] {
+				continue
+			}
+			seen[c.File] = true
+			commands = append(commands, c)
+		}
+	}
+	d, err := json.MarshalIndent(commands, "", "  ")
+	must(err)
+	writeFileMust(compileCommandsPath, d)
+	logf("wrote %d entries for '%s' to '%s'\n", len(commands), compileDbConfig, compileCommandsPath)
+}
@@ -0,0 +1,213 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// builds SumatraPDF with /Bt+ (time of frontend and backend for each file)
+// and /d1reportTime (time spent in each included header), writes a report
+// of the slowest files and headers to out/compile-times/report.txt
+// and compares with do/compile_times_baseline.txt to catch build time
+// regressions (e.g. from adding includes to commonly used headers)
+// times depend on the machine so we only warn about big regressions
+
+const (
+	compileTimesTopN = 30
+	// report files that got this much slower than in the baseline
+	compileTimesRegressionPercent = 30
+	// and at least that many seconds
+	compileTimesRegressionMinSecs = 0.5
+)
+
+const compileTimesTargets = `<?xml version="1.0" encoding="utf-8"?>
+<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <ItemDefinitionGroup>
+    <ClCompile>
+      <AdditionalOptions>%(AdditionalOptions) /Bt+ /d1reportTime</AdditionalOptions>
+      <MultiProcessorCompilation>false</MultiProcessorCompilation>
+    </ClCompile>
+  </ItemDefinitionGroup>
+</Project>
+`
+
+var (
+	// time(C:\Program Files\...\c1xx.dll)=0.51234s < 1234 - 5678 > BB [D:\sumatrapdf\src\Foo.cpp]
+	rxBtTime = regexp.MustCompile(`time\(.*\\(c1xx|c1|c2)\.dll\)=([\d.]+)s .*\[(.+)\]`)
+	// 	D:\sumatrapdf\src\utils\BaseUtil.h: 0.123s
+	rxHeaderTime = regexp.MustCompile(`^\t+(\S.*?): ([\d.]+)s$`)
+	// 12>
+	rxMsbuildNodePrefix = regexp.MustCompile(`^\s*\d+>`)
+)
+
+// CompileTime is time it took to compile a file or include a header
+type CompileTime struct {
+	Path     string
+	Frontend float64
+	Backend  float64
+	// for headers: number of times it was included
+	Count int
+}
+
+func (t *CompileTime) Total() float64 {
+	return t.Frontend + t.Backend
+}
+
+func normalizeCompileTimePath(path string) string {
+	path = strings.TrimSpace(path)
+	if rel, err := filepath.Rel(currDirAbsMust(), path); err == nil && !strings.HasPrefix(rel, "..") {
+		path = rel
+	}
+	return strings.ToLower(filepath.ToSlash(path))
+}
+
+// returns times for files and headers
+func parseCompileTimes(s string) ([]*CompileTime, []*CompileTime) {
+	files := map[string]*CompileTime{}
+	headers := map[string]*CompileTime{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		line = rxMsbuildNodePrefix.ReplaceAllString(line, "")
+		if m := rxBtTime.FindStringSubmatch(line); m != nil {
+			path := normalizeCompileTimePath(m[3])
+			secs, _ := strconv.ParseFloat(m[2], 64)
+			t := files[path]
+			if t == nil {
+				t = &CompileTime{Path: path}
+				files[path] = t
+			}
+			if m[1] == "c2" {
+				t.Backend += secs
+			} else {
+				t.Frontend += secs
+			}
+			continue
+		}
+		if m := rxHeaderTime.FindStringSubmatch(line); m != nil {
+			path := normalizeCompileTimePath(m[1])
+			secs, _ := strconv.ParseFloat(m[2], 64)
+			t := headers[path]
+			if t == nil {
+				t = &CompileTime{Path: path}
+				headers[path] = t
+			}
+			t.Frontend += secs
+			t.Count++
+		}
+	}
+	toSorted := func(m map[string]*CompileTime) []*CompileTime {
+		var res []*CompileTime
+		for _, t := range m {
+			res = append(res, t)
+		}
+		sort.Slice(res, func(i, j int) bool {
+			return res[i].Total() > res[j].Total()
+		})
+		return res
+	}
+	return toSorted(files), toSorted(headers)
+}
+
+func fmtCompileTimesReport(files, headers []*CompileTime) string {
+	total := 0.0
+	for _, t := range files {
+		total += t.Total()
+	}
+	s := fmt.Sprintf("%d files, %.1f s total (frontend + backend, summed over all files)\n", len(files), total)
+	s += fmt.Sprintf("\nslowest files:\n%8s %8s %8s  %s\n", "total", "front", "back", "file")
+	for i, t := range files {
+		if i >= compileTimesTopN {
+			break
+		}
+		s += fmt.Sprintf("%8.2f %8.2f %8.2f  %s\n", t.Total(), t.Frontend, t.Backend, t.Path)
+	}
+	s += fmt.Sprintf("\nslowest headers (time includes nested headers):\n%8s %8s  %s\n", "total", "count", "header")
+	for i, t := range headers {
+		if i >= compileTimesTopN {
+			break
+		}
+		s += fmt.Sprintf("%8.2f %8d  %s\n", t.Total(), t.Count, t.Path)
+	}
+	return s
+}
+
+// each line is: seconds path
+func parseCompileTimesBaseline(s string) map[string]float64 {
+	res := map[string]float64{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		secs, err := strconv.ParseFloat(parts[0], 64)
+		panicIf(err != nil, "invalid line '%s' in compile times baseline", line)
+		res[parts[1]] = secs
+	}
+	return res
+}
+
+func compileTimesRegressions(files []*CompileTime, baseline map[string]float64) []string {
+	var res []string
+	for _, t := range files {
+		prev, ok := baseline[t.Path]
+		if !ok {
+			continue
+		}
+		diff := t.Total() - prev
+		if diff >= compileTimesRegressionMinSecs && diff*100 >= prev*compileTimesRegressionPercent {
+			res = append(res, fmt.Sprintf("%s: %.2f s => %.2f s", t.Path, prev, t.Total()))
+		}
+	}
+	return res
+}
+
+func compileTimesReport(updateBaseline bool) {
+	outDir := filepath.Join("out", "compile-times")
+	must(os.RemoveAll(outDir))
+	createDirMust(outDir)
+	targetsPath := absPathMust(filepath.Join(outDir, "compile-times.targets"))
+	writeFileMust(targetsPath, []byte(compileTimesTargets))
+	logPath := filepath.Join(outDir, "msbuild.log")
+
+	msbuildPath := detectMsbuildPath()
+	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
+	p := fmt.Sprintf(`/p:Configuration=Release;Platform=%s`, kPlatformIntel64)
+	flp := fmt.Sprintf(`/flp:logfile=%s;verbosity=normal`, logPath)
+	runExeLoggedMust(msbuildPath, slnPath, `/t:SumatraPDF:Rebuild`, p, "/p:UseMultiToolTask=true", "/p:ForceImportAfterCppTargets="+targetsPath, `/m`, `/fl`, flp)
+
+	files, headers := parseCompileTimes(string(readFileMust(logPath)))
+	panicIf(len(files) == 0, "didn't find /Bt+ output in '%s'", logPath)
+	report := fmtCompileTimesReport(files, headers)
+	reportPath := filepath.Join(outDir, "report.txt")
+	writeFileMust(reportPath, []byte(report))
+	logf("%s\nwrote '%s'\n", report, reportPath)
+
+	baselinePath := filepath.Join("do", "compile_times_baseline.txt")
+	if updateBaseline {
+		s := "# compile times in seconds of SumatraPDF release 64-bit, re-generate with: do report compile-times -update-baseline\n"
+		for _, t := range files {
+			s += fmt.Sprintf("%.2f %s\n", t.Total(), t.Path)
+		}
+		writeFileMust(baselinePath, []byte(s))
+		logf("wrote '%s'\n", baselinePath)
+		return
+	}
+	if !fileExists(baselinePath) {
+		return
+	}
+	regressions := compileTimesRegressions(files, parseCompileTimesBaseline(string(readFileMust(baselinePath))))
+	if len(regressions) == 0 {
+		logf("no compile time regressions compared to '%s'\n", baselinePath)
+		return
+	}
+	logf("warning: %d files compile slower than in '%s':\n%s\n", len(regressions), baselinePath, strings.Join(regressions, "\n"))
+}
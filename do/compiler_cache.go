@@ -0,0 +1,147 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+// routes msbuild compilations through a compiler cache like sccache or
+// buildcache. Set DO_COMPILER_CACHE to the cache executable (sccache.exe,
+// buildcache.exe or a full path). Both can masquerade as cl.exe: we copy the
+// executable as out/compiler-cache/cl.exe and point msbuild to it with
+// CLToolPath. The real cl.exe is found in %PATH% set by msbuild
+// caching requires:
+// - TrackFileAccess=false because file tracker doesn't work with the wrapper
+// - no /MP (MultiProcessorCompilation) because the wrapper must see one file
+//   per invocation. We use UseMultiToolTask for parallelism instead
+// - /Z7 instead of /Zi because a shared .pdb can't be cached
+
+// CompilerCache describes how to get statistics from a compiler cache
+type CompilerCache struct {
+	Name      string
+	StatsArgs []string
+	ZeroArgs  []string
+}
+
+var knownCompilerCaches = []*CompilerCache{
+	{Name: "sccache", StatsArgs: []string{"--show-stats"}, ZeroArgs: []string{"--zero-stats"}},
+	{Name: "buildcache", StatsArgs: []string{"-s"}, ZeroArgs: []string{"-z"}},
+}
+
+const compilerCacheTargets = `<?xml version="1.0" encoding="utf-8"?>
+<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <ItemDefinitionGroup>
+    <ClCompile>
+      <DebugInformationFormat>OldStyle</DebugInformationFormat>
+      <MultiProcessorCompilation>false</MultiProcessorCompilation>
+    </ClCompile>
+  </ItemDefinitionGroup>
+</Project>
+`
+
+var (
+	compilerCacheDir     = filepath.Join("out", "compiler-cache")
+	compilerCacheZeroed  sync.Once
+	compilerCacheExePath string
+)
+
+func getCompilerCacheExe() string {
+	if compilerCacheExePath != "" {
+		return compilerCacheExePath
+	}
+	exe := os.Getenv("DO_COMPILER_CACHE")
+	if exe == "" {
+		return ""
+	}
+	path, err := exec.LookPath(exe)
+	panicIf(err != nil, "DO_COMPILER_CACHE is '%s' but it's not in %%PATH%%", exe)
+	compilerCacheExePath = path
+	return path
+}
+
+func findCompilerCache(exe string) *CompilerCache {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(exe), filepath.Ext(exe)))
+	for _, c := range knownCompilerCaches {
+		if c.Name == name {
+			return c
+		}
+	}
+	return nil
+}
+
+// returns extra msbuild arguments to use compiler cache, if configured
+func compilerCacheMsbuildArgs() []string {
+	exe := getCompilerCacheExe()
+	if exe == "" {
+		return nil
+	}
+	dir := absPathMust(compilerCacheDir)
+	clPath := filepath.Join(dir, "cl.exe")
+	if !fileExists(clPath) {
+		createDirMust(dir)
+		must(copyFile(clPath, exe))
+	}
+	targetsPath := filepath.Join(dir, "compiler-cache.targets")
+	writeFileMust(targetsPath, []byte(compilerCacheTargets))
+
+	compilerCacheZeroed.Do(func() {
+		if c := findCompilerCache(exe); c != nil {
+			runExeLoggedMust(exe, c.ZeroArgs...)
+		}
+	})
+
+	return []string{
+		"/p:CLToolPath=" + dir,
+		"/p:CLToolExe=cl.exe",
+		"/p:TrackFileAccess=false",
+		"/p:UseMultiToolTask=true",
+		"/p:ForceImportAfterCppTargets=" + targetsPath,
+	}
+}
+
+func logCompilerCacheStats() {
+	exe := getCompilerCacheExe()
+	if exe == "" {
+		return
+	}
+	c := findCompilerCache(exe)
+	if c == nil {
+		logf("don't know how to get statistics from '%s'\n", exe)
+		return
+	}
+	out, err := exec.Command(exe, c.StatsArgs...).CombinedOutput()
+	if err != nil {
+		logf("'%s %s' failed with %s\n", exe, strings.Join(c.StatsArgs, " "), err)
+		return
+	}
+	logf("%s statistics:\n%s\n", c.Name, string(out))
+}
+
+// runs msbuild, using compiler cache if configured
+// on GitHub Actions also annotates warnings and errors
+// full log is saved to out/logs, see msbuildFailure
+func runMsbuildMust(args ...string) {
+	defer failOnPanic(errKindBuild)
+	msbuildPath := detectMsbuildPath()
+	args = append(args, compilerCacheMsbuildArgs()...)
+	if isGitHubActions() {
+		// log warnings and errors to a separate file to annotate them
+		logPath := filepath.Join("out", "msbuild-warnings.log")
+		os.Remove(logPath)
+		args = append(args, "/fl8", "/flp8:logfile="+logPath+";warningsonly;errorsonly")
+		defer emitMsbuildAnnotations(logPath)
+	}
+	// full log, to show excerpt of errors if the build fails
+	fullLogPath := newMsbuildLogPath()
+	args = append(args, "/fl9", "/flp9:logfile="+fullLogPath+";verbosity=normal")
+	defer func() {
+		if r := recover(); r != nil {
+			panic(msbuildFailure(r, fullLogPath))
+		}
+	}()
+	runExeLoggedMust(msbuildPath, args...)
+	logCompilerCacheStats()
+}
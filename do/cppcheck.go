@@ -0,0 +1,124 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+)
+
+const (
+	cppcheckLogFile = "cppcheck.out.txt"
+	// generated by do gen compile-db, if present we use it instead of guessing
+	// include paths and defines
+	compileCommandsPath = "compile_commands.json"
+)
+
+func detectCppcheckExe() string {
+	// TODO: better detection logic
+	path := `c:\Program Files\Cppcheck\cppcheck.exe`
+	if pathExists(path) {
+		return path
+	}
+	return "cppcheck.exe"
+}
+
+func runCppCheck(all bool) {
+	// -q : quiet, doesn't print progress report
+	// -v : prints more info about the error
+	// -j : check files in parallel
+	// --platform=win64 : sets platform to 64 bits
+	// -DWIN32 -D_WIN32 -D_MSC_VER=1990 : set some defines and speeds up
+	//    checking because cppcheck doesn't check all possible combinations
+	// --inline-suppr: honor suppression comments in the code like:
+	// // cppcheck-suppress <type>
+	// ... line with a problem
+	// --template : same format as clang-tidy, for do analyze merge-sarif
+	var cmd *exec.Cmd
+
+	// TODO: not sure if adding Windows SDK include path helps.
+	// It takes a lot of time and doesn't seem to provide value
+	//winSdkIncludeDir := `C:\Program Files (x86)\Windows Kits\10\Include\10.0.18362.0\um`
+	// "-I", winSdkIncludeDir
+	// "-D__RPCNDR_H_VERSION__=440"
+	// STDMETHODIMP_(type)=type
+
+	args := []string{"--platform=win64", "-q", "-v", fmt.Sprintf("-j%d", runtime.NumCPU())}
+	args = append(args, "--template={file}:{line}:{column}: {severity}: {message} [{id}]")
+	if all {
+		args = append(args, "--enable=style")
+	}
+	args = append(args, "--suppressions-list="+filepath.Join("do", "cppcheck_suppressions.txt"))
+	args = append(args, "--inline-suppr")
+	if fileExists(compileCommandsPath) {
+		args = append(args, "--project="+compileCommandsPath, "--file-filter=src/*")
+	} else {
+		args = append(args, "-DWIN32", "-D_WIN32", "-D_MSC_VER=1800", "-D_M_X64", "-DIFACEMETHODIMP_(x)=x", "-DSTDMETHODIMP_(x)=x", "-DSTDAPI_(x)=x", "-DPRE_RELEASE_VER=3.4")
+		args = append(args, "-I", "src", "-I", "src/utils", "src")
+	}
+	cppcheckExe := detectCppcheckExe()
+	cmd = exec.Command(cppcheckExe, args...)
+	os.Remove(cppcheckLogFile)
+	err := runCmdShowProgressAndLog(cmd, cppcheckLogFile)
+	must(err)
+	logf("\nLogged output to '%s'\n", cppcheckLogFile)
+	writeCppCheckReport(cppcheckLogFile, filepath.Join("out", "cppcheck.sarif"))
+
+	runPVSStudioIfLicensed()
+}
+
+// writes SARIF report and prints summary of warnings by id
+func writeCppCheckReport(logPath string, sarifPath string) {
+	results := parseGccStyleWarnings(string(readFileMust(logPath)))
+	byID := map[string]int{}
+	for _, r := range results {
+		byID[r.RuleID]++
+	}
+	var ids []string
+	for id := range byID {
+		ids = append(ids, id)
+	}
+	sort.Slice(ids, func(i, j int) bool {
+		return byID[ids[i]] > byID[ids[j]]
+	})
+	for _, id := range ids {
+		logf("%5d %s\n", byID[id], id)
+	}
+	run := &SarifRun{
+		Tool:    SarifTool{Driver: SarifDriver{Name: "cppcheck", InformationURI: "https://cppcheck.sourceforge.io/"}},
+		Results: results,
+	}
+	must(createDirForFile(sarifPath))
+	writeSarifMust(sarifPath, newSarifLog(run))
+}
+
+const pvsStudioDir = `C:\Program Files (x86)\PVS-Studio`
+
+// PVS-Studio is commercial so we only run it if installed and licensed
+func runPVSStudioIfLicensed() {
+	pvsCmd := filepath.Join(pvsStudioDir, "PVS-Studio_Cmd.exe")
+	licensePath := filepath.Join(os.Getenv("APPDATA"), "PVS-Studio", "Settings.xml")
+	if !fileExists(pvsCmd) || !fileExists(licensePath) {
+		logf("skipping PVS-Studio: not installed or not licensed\n")
+		return
+	}
+	outDir := filepath.Join("out", "pvs-studio")
+	must(os.RemoveAll(outDir))
+	createDirMust(outDir)
+	plogPath := filepath.Join(outDir, "pvs-studio.plog")
+	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
+	cmd := exec.Command(pvsCmd, "--target", slnPath, "--configuration", "Release", "--platform", kPlatformIntel64, "--output", plogPath, "--progress")
+	// PVS-Studio_Cmd.exe returns non-zero exit code when it finds issues
+	logf("> %s\n", cmd.String())
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	_ = cmd.Run()
+	panicIf(!fileExists(plogPath), "PVS-Studio didn't create '%s'", plogPath)
+
+	converter := filepath.Join(pvsStudioDir, "PlogConverter.exe")
+	cmd = exec.Command(converter, "-t", "Sarif", "-o", outDir, "-n", "pvs-studio", plogPath)
+	runCmdLoggedMust(cmd)
+	logf("PVS-Studio report in '%s'\n", outDir)
+}
@@ -0,0 +1,312 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// links crash clusters to GitHub issues (do report crashes -crash-issues):
+// - issues we open have a marker with id of the signature in the body so
+//   that we can find them with search (visible text, search doesn't index
+//   html comments)
+// - issues opened by people usually have the crash report pasted, we match
+//   them if they mention all symbolicated frames of the signature
+// - the first time we link a cluster to an existing issue we add a comment
+//   with recent stats
+// - for clusters with at least -crash-issue-min reports and no issue we open
+//   one labeled "draft", with symbolicated stack and affected builds, to be
+//   triaged and edited by a human
+// Links are remembered in the bucket with crash reports so that we only
+// comment / open once. With -dry-run we only show what we would do
+
+const crashIssuesRemotePath = "crashes/crash-issues.json"
+
+// default for -crash-issue-min
+const crashIssueMinReports = 10
+
+// clusters with fewer reports are noise, we don't search for them
+const crashIssueSearchMinReports = 2
+
+// search API allows 30 requests per minute
+const gitHubSearchDelay = 2 * time.Second
+
+// how many frames of symbolicated stack we put in the issue
+const crashIssueMaxFrames = 32
+
+var crashIssueLabels = []string{"crash", "draft"}
+
+// CrashIssueLink is a GitHub issue linked to a crash signature
+type CrashIssueLink struct {
+	Number int
+	URL    string
+	// true if we opened the issue
+	Created  bool
+	LinkedOn time.Time
+}
+
+type gitHubIssue struct {
+	Number  int    `json:"number"`
+	HTMLURL string `json:"html_url"`
+	Title   string `json:"title"`
+	Body    string `json:"body"`
+	State   string `json:"state"`
+}
+
+// short, stable id of a signature, safe to search for
+func getCrashSignatureID(sig string) string {
+	return sha256Hex([]byte(sig))[:12]
+}
+
+func getCrashIssueMarker(sig string) string {
+	return "crash-signature-" + getCrashSignatureID(sig)
+}
+
+// "sumatrapdf.exe!CrashMe | ntdll.dll" => ["sumatrapdf.exe!CrashMe"]
+// frames without symbols are module names, which are not worth matching
+func getSymbolicatedSignatureFrames(sig string) []string {
+	var res []string
+	for _, f := range strings.Split(sig, " | ") {
+		if strings.Contains(f, "!") {
+			res = append(res, f)
+		}
+	}
+	return res
+}
+
+// does body of an issue (e.g. pasted crash report) mention the signature
+func issueMatchesCrashSignature(body string, sig string) bool {
+	if strings.Contains(body, getCrashIssueMarker(sig)) {
+		return true
+	}
+	frames := getSymbolicatedSignatureFrames(sig)
+	if len(frames) == 0 {
+		return false
+	}
+	for _, f := range frames {
+		if !strings.Contains(body, f) {
+			return false
+		}
+	}
+	return true
+}
+
+func searchGitHubIssuesMust(query string) []*gitHubIssue {
+	var rsp struct {
+		Items []*gitHubIssue `json:"items"`
+	}
+	q := fmt.Sprintf("repo:%s is:issue %s", getGitHubRepo(), query)
+	uri := "https://api.github.com/search/issues?per_page=20&q=" + url.QueryEscape(q)
+	gitHubAPIURLMust(http.MethodGet, uri, nil, &rsp)
+	time.Sleep(gitHubSearchDelay)
+	return rsp.Items
+}
+
+// returns existing issue for the signature or nil
+func findCrashIssueMust(sig string) *gitHubIssue {
+	queries := []string{fmt.Sprintf("%q in:body", getCrashIssueMarker(sig))}
+	if frames := getSymbolicatedSignatureFrames(sig); len(frames) > 0 {
+		// "sumatrapdf.exe!CrashMe" => "CrashMe"
+		_, fn, _ := strings.Cut(frames[0], "!")
+		queries = append(queries, fmt.Sprintf("%q in:title,body", fn))
+	}
+	for _, q := range queries {
+		// search is fuzzy so we check the body ourselves
+		for _, issue := range searchGitHubIssuesMust(q) {
+			if issueMatchesCrashSignature(issue.Body, sig) {
+				return issue
+			}
+		}
+	}
+	return nil
+}
+
+func loadCrashIssueLinksMust(mc Storage) map[string]*CrashIssueLink {
+	res := map[string]*CrashIssueLink{}
+	if !mc.Exists(crashIssuesRemotePath) {
+		return res
+	}
+	d, err := mc.DownloadData(crashIssuesRemotePath)
+	must(err)
+	must(json.Unmarshal(d, &res))
+	return res
+}
+
+func saveCrashIssueLinksMust(mc Storage, links map[string]*CrashIssueLink) {
+	d, err := json.MarshalIndent(links, "", "  ")
+	must(err)
+	_, err = mc.UploadData(crashIssuesRemotePath, d, false)
+	must(err)
+}
+
+// "3.5.15780 pre-release 64-bit (git 1a2b3c4)"
+func getCrashBuildName(r *CrashReport) string {
+	s := r.Ver
+	if r.IsPreRel {
+		s += " pre-release"
+	}
+	if r.Arch != "" {
+		s += " " + r.Arch
+	}
+	if r.GitSha1 != "" {
+		s += fmt.Sprintf(" (git %s)", r.GitSha1[:min(len(r.GitSha1), 7)])
+	}
+	return s
+}
+
+// returns builds with number of crashes, most crashes first
+func getAffectedCrashBuilds(c *CrashCluster) []string {
+	m := map[string]int{}
+	for _, r := range c.Reports {
+		m[getCrashBuildName(r)]++
+	}
+	var builds []string
+	for b := range m {
+		builds = append(builds, b)
+	}
+	sort.Slice(builds, func(i, j int) bool {
+		if m[builds[i]] != m[builds[j]] {
+			return m[builds[i]] > m[builds[j]]
+		}
+		return builds[i] < builds[j]
+	})
+	var res []string
+	for _, b := range builds {
+		res = append(res, fmt.Sprintf("%s: %d", b, m[b]))
+	}
+	return res
+}
+
+// report with the most symbolicated frames of the crashed thread
+func getBestSymbolicatedReport(c *CrashCluster) *CrashReport {
+	var res *CrashReport
+	nBest := -1
+	for _, r := range c.Reports {
+		n := 0
+		for _, f := range r.Frames {
+			if strings.Contains(f, "!") {
+				n++
+			}
+		}
+		if n > nBest {
+			res, nBest = r, n
+		}
+	}
+	return res
+}
+
+func genCrashIssueTitle(c *CrashCluster) string {
+	frames := getSymbolicatedSignatureFrames(c.Signature)
+	return fmt.Sprintf("[draft] crash in %s", frames[0])
+}
+
+func genCrashIssueBody(c *CrashCluster) string {
+	r := getBestSymbolicatedReport(c)
+	var a []string
+	push(&a, "_Opened automatically from crash reports, needs triage._", "")
+	push(&a, fmt.Sprintf("%d crashes in the last %d days with signature:", len(c.Reports), crashesRecentDays), "")
+	push(&a, "```", c.Signature, "```", "")
+	if r.Exception != "" {
+		push(&a, "Exception: `"+r.Exception+"`", "")
+	}
+	push(&a, fmt.Sprintf("Crashed thread of %s (%s):", r.Name, getCrashBuildName(r)), "", "```")
+	for i, f := range r.Frames {
+		if i >= crashIssueMaxFrames {
+			push(&a, fmt.Sprintf("... %d more frames", len(r.Frames)-i))
+			break
+		}
+		push(&a, f)
+	}
+	push(&a, "```", "", "Affected builds:", "")
+	for _, b := range getAffectedCrashBuilds(c) {
+		push(&a, "- "+b)
+	}
+	if len(c.NoSymbols) > 0 {
+		push(&a, "", "No symbols for: "+strings.Join(c.NoSymbols, ", ")+", crashes in those versions might not be symbolicated")
+	}
+	push(&a, "", fmt.Sprintf("Signature id: %s", getCrashIssueMarker(c.Signature)))
+	return strings.Join(a, "\n")
+}
+
+func genCrashIssueComment(c *CrashCluster) string {
+	var a []string
+	push(&a, fmt.Sprintf("Crash reports with signature `%s`: %d in the last %d days.", c.Signature, len(c.Reports), crashesRecentDays), "")
+	push(&a, "Affected builds:", "")
+	for _, b := range getAffectedCrashBuilds(c) {
+		push(&a, "- "+b)
+	}
+	push(&a, "", fmt.Sprintf("Example: %s", c.Reports[0].Name))
+	push(&a, "", fmt.Sprintf("Signature id: %s", getCrashIssueMarker(c.Signature)))
+	return strings.Join(a, "\n")
+}
+
+func linkCrashIssueMust(c *CrashCluster, issue *gitHubIssue, doIt bool) *CrashIssueLink {
+	logf("crash '%s' => existing issue %s\n", c.Signature, issue.HTMLURL)
+	if !doIt {
+		return &CrashIssueLink{Number: issue.Number, URL: issue.HTMLURL}
+	}
+	body := map[string]string{"body": genCrashIssueComment(c)}
+	gitHubAPIMust(http.MethodPost, fmt.Sprintf("/issues/%d/comments", issue.Number), body, nil)
+	return &CrashIssueLink{Number: issue.Number, URL: issue.HTMLURL, LinkedOn: time.Now().UTC()}
+}
+
+func createCrashIssueMust(c *CrashCluster, doIt bool) *CrashIssueLink {
+	title := genCrashIssueTitle(c)
+	if !doIt {
+		logf("would open issue '%s':\n%s\n\n", title, genCrashIssueBody(c))
+		return nil
+	}
+	body := map[string]interface{}{
+		"title":  title,
+		"body":   genCrashIssueBody(c),
+		"labels": crashIssueLabels,
+	}
+	var issue gitHubIssue
+	gitHubAPIMust(http.MethodPost, "/issues", body, &issue)
+	logf("crash '%s' => opened issue %s\n", c.Signature, issue.HTMLURL)
+	return &CrashIssueLink{Number: issue.Number, URL: issue.HTMLURL, Created: true, LinkedOn: time.Now().UTC()}
+}
+
+// sets CrashCluster.Issue, searching for issues of new clusters and opening
+// issues for new clusters with at least minReports. if doIt is false only
+// shows what would be done
+func linkCrashIssuesMust(mc Storage, clusters []*CrashCluster, minReports int, doIt bool) {
+	links := loadCrashIssueLinksMust(mc)
+	nLinked, nCreated := 0, 0
+	for _, c := range clusters {
+		if link := links[c.Signature]; link != nil {
+			c.Issue = link
+			continue
+		}
+		// without symbols signatures are not meaningful
+		if len(getSymbolicatedSignatureFrames(c.Signature)) == 0 || len(c.Reports) < crashIssueSearchMinReports {
+			continue
+		}
+		var link *CrashIssueLink
+		if issue := findCrashIssueMust(c.Signature); issue != nil {
+			link = linkCrashIssueMust(c, issue, doIt)
+			nLinked++
+		} else if len(c.Reports) >= minReports {
+			link = createCrashIssueMust(c, doIt)
+			nCreated++
+		}
+		if link == nil {
+			continue
+		}
+		c.Issue = link
+		if doIt {
+			links[c.Signature] = link
+			// save as we go so that we don't comment / open twice if we fail later
+			saveCrashIssueLinksMust(mc, links)
+		}
+	}
+	if doIt {
+		logf("linked %d crash signatures to existing issues, opened %d issues\n", nLinked, nCreated)
+	} else {
+		logf("dry run: would link %d crash signatures to existing issues, open %d issues\n", nLinked, nCreated)
+	}
+}
@@ -0,0 +1,317 @@
+package main
+
+import (
+	"fmt"
+	"html"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// crash reports are uploaded by SumatraPDF (CrashHandler.cpp) to
+// www.sumatrapdfreader.org/uploadcrash/sumatrapdf-crashes which stores them
+// in the bucket under this prefix
+const crashesRemoteDir = "crashes/sumatrapdf-crashes/"
+
+// only look at crash reports uploaded in the last n days
+const crashesRecentDays = 14
+
+// how many frames of crashed thread we use to create a signature
+const crashSignatureFrames = 3
+
+var crashesDir = filepath.Join("out", "crashes")
+
+type CrashReport struct {
+	Name      string
+	Ver       string // "3.5.15780"
+	IsPreRel  bool
+	Arch      string // "64-bit", "32-bit", "arm64"
+	GitSha1   string
+	Exception string
+	Frames    []string
+	Signature string
+}
+
+type CrashCluster struct {
+	Signature string
+	Reports   []*CrashReport
+	ByVer     map[string]int
+	// symbols for those versions were missing from storage
+	NoSymbols []string
+	// set by linkCrashIssuesMust
+	Issue *CrashIssueLink
+}
+
+// "sumatrapdf.exe!CrashMe+0x12 c:\src\sumatrapdf\src\Tester.cpp+34"
+// =>
+// "sumatrapdf.exe!CrashMe"
+// frames without symbols look like:
+// "00007FFB1B1F4F0C 01:0000000000003F0C ntdll.dll"
+// which we turn into "ntdll.dll"
+func normalizeCrashFrame(s string) string {
+	parts := strings.Fields(s)
+	if len(parts) == 0 {
+		return ""
+	}
+	for _, part := range parts {
+		if idx := strings.Index(part, "!"); idx > 0 {
+			if idx2 := strings.Index(part, "+0x"); idx2 > 0 {
+				part = part[:idx2]
+			}
+			return part
+		}
+	}
+	return parts[len(parts)-1]
+}
+
+func parseCrashReport(name string, s string) *CrashReport {
+	s = strings.ReplaceAll(s, "\r\n", "\n")
+	res := &CrashReport{
+		Name: name,
+	}
+	lines := strings.Split(s, "\n")
+	inCrashedThread := false
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if inCrashedThread {
+			if l == "" {
+				inCrashedThread = false
+				continue
+			}
+			res.Frames = append(res.Frames, l)
+			continue
+		}
+		if v, ok := strings.CutPrefix(l, "Ver: "); ok {
+			parts := strings.Fields(v)
+			if len(parts) > 0 {
+				res.Ver = parts[0]
+			}
+			res.IsPreRel = strings.Contains(v, "pre-release")
+			if strings.Contains(v, "arm64") {
+				res.Arch = "arm64"
+			} else if strings.Contains(v, "64-bit") {
+				res.Arch = "64-bit"
+			} else if strings.Contains(v, "32-bit") {
+				res.Arch = "32-bit"
+			}
+			continue
+		}
+		if v, ok := strings.CutPrefix(l, "Git: "); ok {
+			res.GitSha1 = strings.Fields(v + " ")[0]
+			continue
+		}
+		if v, ok := strings.CutPrefix(l, "Exception: "); ok {
+			res.Exception = v
+			continue
+		}
+		// there are multiple "Crashed thread:" sections, we only want the first
+		if l == "Crashed thread:" && len(res.Frames) == 0 {
+			inCrashedThread = true
+		}
+	}
+	var sig []string
+	for _, f := range res.Frames {
+		if len(sig) >= crashSignatureFrames {
+			break
+		}
+		sig = append(sig, normalizeCrashFrame(f))
+	}
+	res.Signature = strings.Join(sig, " | ")
+	if res.Signature == "" {
+		res.Signature = "unknown"
+	}
+	return res
+}
+
+// downloads crash reports uploaded in the last n days to a local cache
+// and returns paths of the files
+func downloadRecentCrashReportsMust(mc Storage, days int) []string {
+	dir := createDirMust(filepath.Join(crashesDir, "reports"))
+	since := time.Now().Add(-time.Hour * 24 * time.Duration(days))
+	var res []string
+	nDownloaded := 0
+	for obj := range mc.ListObjects(crashesRemoteDir) {
+		must(obj.Err)
+		if obj.LastModified.Before(since) {
+			continue
+		}
+		localPath := filepath.Join(dir, path.Base(obj.Key))
+		if !fileExists(localPath) {
+			err := mc.DownloadFileAtomically(localPath, obj.Key)
+			must(err)
+			nDownloaded++
+		}
+		res = append(res, localPath)
+	}
+	logf("%d crash reports in the last %d days, downloaded %d\n", len(res), days, nDownloaded)
+	return res
+}
+
+func loadRecentCrashReportsMust(days int) []*CrashReport {
+	mc := newMinioBackblazeClient()
+	paths := downloadRecentCrashReportsMust(mc, days)
+	var res []*CrashReport
+	for _, path := range paths {
+		d := readFileMust(path)
+		res = append(res, parseCrashReport(filepath.Base(path), string(d)))
+	}
+	return res
+}
+
+func clusterCrashReports(reports []*CrashReport) []*CrashCluster {
+	m := map[string]*CrashCluster{}
+	for _, r := range reports {
+		c := m[r.Signature]
+		if c == nil {
+			c = &CrashCluster{
+				Signature: r.Signature,
+				ByVer:     map[string]int{},
+			}
+			m[r.Signature] = c
+		}
+		c.Reports = append(c.Reports, r)
+		c.ByVer[r.Ver]++
+	}
+	var res []*CrashCluster
+	for _, c := range m {
+		res = append(res, c)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if len(res[i].Reports) != len(res[j].Reports) {
+			return len(res[i].Reports) > len(res[j].Reports)
+		}
+		return res[i].Signature < res[j].Signature
+	})
+	return res
+}
+
+// remote path of symbols archive uploaded for a given crash report
+// file names must match those created by copyBuiltFiles
+func getSymbolsRemotePath(r *CrashReport) string {
+	platform := kPlatformIntel32
+	switch r.Arch {
+	case "64-bit":
+		platform = kPlatformIntel64
+	case "arm64":
+		platform = kPlatformArm64
+	}
+	dir := "software/sumatrapdf/rel/" + r.Ver + "/"
+	prefix := getPrefixForPlatform("SumatraPDF-"+r.Ver, buildTypeRel, platform)
+	if r.IsPreRel {
+		// "3.5.15780" => "15780"
+		parts := strings.Split(r.Ver, ".")
+		buildNo := parts[len(parts)-1]
+		dir = "software/sumatrapdf/prerel/" + buildNo + "/"
+		prefix = getPrefixForPlatform("SumatraPDF-prerel", buildTypePreRel, platform)
+	}
+	for _, f := range getFileNamesWithPrefix(prefix) {
+		if f[0] == "SumatraPDF.pdb.lzsa" {
+			return dir + f[1]
+		}
+	}
+	panicIf(true, "no .pdb.lzsa in getFileNamesWithPrefix()")
+	return ""
+}
+
+// marks versions for which we don't have symbols because without them
+// the signatures are not meaningful
+func checkCrashSymbols(mc Storage, clusters []*CrashCluster) {
+	hasSymbols := map[string]bool{}
+	for _, c := range clusters {
+		seen := map[string]bool{}
+		for _, r := range c.Reports {
+			remotePath := getSymbolsRemotePath(r)
+			has, ok := hasSymbols[remotePath]
+			if !ok {
+				has = mc.Exists(remotePath)
+				hasSymbols[remotePath] = has
+			}
+			if !has && !seen[r.Ver] {
+				seen[r.Ver] = true
+				c.NoSymbols = append(c.NoSymbols, r.Ver)
+			}
+		}
+	}
+}
+
+func sortedVersCounts(m map[string]int) []string {
+	var vers []string
+	for ver := range m {
+		vers = append(vers, ver)
+	}
+	sort.Slice(vers, func(i, j int) bool {
+		return m[vers[i]] > m[vers[j]]
+	})
+	var res []string
+	for _, ver := range vers {
+		res = append(res, fmt.Sprintf("%s (%d)", ver, m[ver]))
+	}
+	return res
+}
+
+func genCrashesReportText(clusters []*CrashCluster, nReports int) string {
+	var a []string
+	push(&a, fmt.Sprintf("Top crashers, %d reports, %d signatures, last %d days", nReports, len(clusters), crashesRecentDays))
+	push(&a, "")
+	for i, c := range clusters {
+		push(&a, fmt.Sprintf("#%d: %d crashes", i+1, len(c.Reports)))
+		push(&a, "  "+c.Signature)
+		push(&a, "  versions: "+strings.Join(sortedVersCounts(c.ByVer), ", "))
+		if len(c.NoSymbols) > 0 {
+			push(&a, "  no symbols for: "+strings.Join(c.NoSymbols, ", "))
+		}
+		push(&a, "  example: "+c.Reports[0].Name)
+		if c.Issue != nil {
+			push(&a, "  issue: "+c.Issue.URL)
+		}
+		push(&a, "")
+	}
+	return strings.Join(a, "\n")
+}
+
+func genCrashesReportHTML(clusters []*CrashCluster, nReports int) string {
+	var a []string
+	push(&a, "<!doctype html>", "<html><head><meta charset=\"utf-8\"><title>SumatraPDF top crashers</title></head><body>")
+	push(&a, fmt.Sprintf("<p>%d reports, %d signatures, last %d days</p>", nReports, len(clusters), crashesRecentDays))
+	push(&a, "<table border=\"1\" cellspacing=\"0\" cellpadding=\"4\">")
+	push(&a, "<tr><th>#</th><th>crashes</th><th>signature</th><th>versions</th><th>no symbols</th><th>issue</th></tr>")
+	for i, c := range clusters {
+		vers := strings.Join(sortedVersCounts(c.ByVer), ", ")
+		issue := ""
+		if c.Issue != nil {
+			issue = fmt.Sprintf("<a href=\"%s\">#%d</a>", html.EscapeString(c.Issue.URL), c.Issue.Number)
+		}
+		s := fmt.Sprintf("<tr><td>%d</td><td>%d</td><td><code>%s</code></td><td>%s</td><td>%s</td><td>%s</td></tr>", i+1, len(c.Reports), html.EscapeString(c.Signature), vers, strings.Join(c.NoSymbols, ", "), issue)
+		push(&a, s)
+	}
+	push(&a, "</table>", "</body></html>")
+	return strings.Join(a, "\n")
+}
+
+// if linkIssues, links clusters to GitHub issues and opens issues for
+// clusters with at least minReports (see crash_issues.go)
+func crashesReport(linkIssues bool, minReports int, doIt bool) {
+	ensureAllUploadCreds()
+	if linkIssues {
+		panicIf(os.Getenv("GITHUB_TOKEN") == "", "need GITHUB_TOKEN env variable for -crash-issues")
+	}
+	reports := loadRecentCrashReportsMust(crashesRecentDays)
+	clusters := clusterCrashReports(reports)
+	mc := newMinioBackblazeClient()
+	checkCrashSymbols(mc, clusters)
+	if linkIssues {
+		linkCrashIssuesMust(mc, clusters, minReports, doIt)
+	}
+
+	s := genCrashesReportText(clusters, len(reports))
+	fmt.Printf("%s\n", s)
+	path := filepath.Join(crashesDir, "top-crashers.txt")
+	writeFileMust(path, []byte(s))
+	logf("Wrote %s\n", path)
+	path = filepath.Join(crashesDir, "top-crashers.html")
+	writeFileMust(path, []byte(genCrashesReportHTML(clusters, len(reports))))
+	logf("Wrote %s\n", path)
+}
@@ -0,0 +1,110 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime/debug"
+)
+
+// errors are reported with panic (must / panicIf). To let CI tell apart
+// why the build failed we classify failures: failIf panics with *DoError
+// of a given kind and failOnPanic (used with defer) turns any other panic
+// into *DoError. handleFatalError in main() prints a summary and exits
+// with exit code of the kind. Unclassified panics exit with 1 and,
+// because they're most likely bugs, also print the callstack
+
+// ErrorKind is a class of failure
+type ErrorKind int
+
+const (
+	errKindOther ErrorKind = iota
+	errKindToolchain
+	errKindBuild
+	errKindSign
+	errKindUpload
+	errKindVerify
+	errKindTimeout
+	errKindInterrupted
+	errKindPreflight
+)
+
+// exit codes are documented in the usage (-help) so keep them stable
+var errorKinds = []struct {
+	Name     string
+	ExitCode int
+}{
+	errKindOther:     {"error", 1},
+	errKindToolchain: {"toolchain missing", 10},
+	errKindBuild:     {"build failed", 11},
+	errKindSign:      {"signing failed", 12},
+	errKindUpload:    {"upload failed", 13},
+	errKindVerify:    {"verification failed", 14},
+	errKindTimeout:   {"timed out", 15},
+	errKindPreflight: {"preflight failed", 16},
+	// like shells do for SIGINT
+	errKindInterrupted: {"interrupted", 130},
+}
+
+// DoError is a classified failure
+type DoError struct {
+	Kind ErrorKind
+	Msg  string
+}
+
+func (e *DoError) Error() string {
+	return fmt.Sprintf("%s: %s", errorKinds[e.Kind].Name, e.Msg)
+}
+
+func (e *DoError) ExitCode() int {
+	return errorKinds[e.Kind].ExitCode
+}
+
+func failIf(kind ErrorKind, cond bool, format string, args ...interface{}) {
+	if !cond {
+		return
+	}
+	panic(&DoError{Kind: kind, Msg: fmt.Sprintf(format, args...)})
+}
+
+func failIfErr(kind ErrorKind, err error) {
+	if err != nil {
+		panic(&DoError{Kind: kind, Msg: err.Error()})
+	}
+}
+
+// must be called with defer. Panics that are not *DoError already
+// become *DoError of a given kind
+func failOnPanic(kind ErrorKind) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	if _, ok := r.(*DoError); ok {
+		panic(r)
+	}
+	logf("%s\n", debug.Stack())
+	panic(&DoError{Kind: kind, Msg: fmt.Sprint(r)})
+}
+
+// must be the first defer in main()
+func handleFatalError() {
+	r := recover()
+	if r == nil {
+		return
+	}
+	e, ok := r.(*DoError)
+	if !ok {
+		logf("%s\n", debug.Stack())
+		e = &DoError{Kind: errKindOther, Msg: fmt.Sprint(r)}
+	}
+	logf("\n%s\nexit code: %d\n", e.Error(), e.ExitCode())
+	os.Exit(e.ExitCode())
+}
+
+func fmtExitCodesUsage() string {
+	s := "Exit codes:\n"
+	for _, k := range errorKinds {
+		s += fmt.Sprintf("  %2d %s\n", k.ExitCode, k.Name)
+	}
+	return s
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// some documentation (and lists in sources derived from the same data)
+// is generated from sources so that it can't get out of date. Re-generate with: .\doit.bat docs gen
+// CI build fails if checked-in files don't match what would be generated
+
+// GeneratedDoc is a file generated from sources
+type GeneratedDoc struct {
+	Path string
+	Gen  func() []byte
+}
+
+func getGeneratedDocs() []*GeneratedDoc {
+	res := []*GeneratedDoc{
+		{Path: keyboardShortcutsDocPath, Gen: genKeyboardShortcutsDocMust},
+		{Path: commandsJSONPath, Gen: genCommandsJSONMust},
+		{Path: supportedFormatsDocPath, Gen: genSupportedFormatsDocMust},
+		{Path: registryInstallerPath, Gen: genRegistryInstallerMust},
+		{Path: engineMultiPath, Gen: genEngineMultiMust},
+		{Path: premakeGenFilesPath, Gen: func() []byte { return []byte(genPremakeFiles(sourceSets)) }},
+		{Path: cmdLineDocPath, Gen: genCmdLineDocMust},
+		{Path: cmdLineJSONPath, Gen: genCmdLineJSONMust},
+		// index of docs/*.md so must be after generated .md files
+		{Path: docsJSONPath, Gen: genDocsJSONMust},
+		{Path: llmsTxtPath, Gen: genLlmsTxtMust},
+	}
+	for _, rc := range versionRcs {
+		rc := rc
+		res = append(res, &GeneratedDoc{Path: rc.Path, Gen: func() []byte { return []byte(genVersionRc(rc)) }})
+	}
+	return res
+}
+
+func genDocs() {
+	for _, doc := range getGeneratedDocs() {
+		writeFileMust(doc.Path, doc.Gen())
+		logf("generated '%s'\n", doc.Path)
+	}
+}
+
+// returns paths of files that are out of date
+func findStaleGeneratedDocs() []string {
+	var res []string
+	for _, doc := range getGeneratedDocs() {
+		if !fileExists(doc.Path) || !bytes.Equal(readFileMust(doc.Path), doc.Gen()) {
+			res = append(res, doc.Path)
+		}
+	}
+	return res
+}
+
+func checkGeneratedDocsMust() {
+	stale := findStaleGeneratedDocs()
+	panicIf(len(stale) > 0, "generated docs are out of date, re-generate with: do docs gen\n%s\n", strings.Join(stale, "\n"))
+	logf("generated docs are up to date\n")
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// generates docs/docs.json and docs/llms.txt, an index of pages in docs/
+// (title, url, summary, keywords) for in-app help search and external
+// tools. See https://llmstxt.org for llms.txt format
+
+var (
+	docsJSONPath = filepath.Join("docs", "docs.json")
+	llmsTxtPath  = filepath.Join("docs", "llms.txt")
+)
+
+const docsBaseURL = "https://www.sumatrapdfreader.org/docs/"
+
+// max length of summary in characters
+const docSummaryMaxLen = 200
+
+// DocPage is an entry in docs/docs.json
+type DocPage struct {
+	Title    string   `json:"title"`
+	URL      string   `json:"url"`
+	Summary  string   `json:"summary,omitempty"`
+	Keywords []string `json:"keywords,omitempty"`
+}
+
+var docKeywordStopWords = map[string]bool{
+	"and": true, "for": true, "the": true, "with": true, "from": true, "into": true, "your": true, "how": true,
+}
+
+// lower-case words, at least 3 characters, from s
+func docKeywords(s string) []string {
+	var res []string
+	f := func(r rune) bool {
+		return !(r >= 'a' && r <= 'z' || r >= '0' && r <= '9')
+	}
+	for _, w := range strings.FieldsFunc(strings.ToLower(s), f) {
+		if len(w) >= 3 && !docKeywordStopWords[w] {
+			res = append(res, w)
+		}
+	}
+	return res
+}
+
+// "Supported-formats.md" => title from "# " heading, summary is the first
+// paragraph of text, keywords are from title and "## " headings
+func parseDocPage(name string, md string) *DocPage {
+	res := &DocPage{
+		URL: docsBaseURL + strings.TrimSuffix(name, ".md"),
+	}
+	var para []string
+	inComment := false
+	keywords := map[string]bool{}
+	for _, line := range strings.Split(md, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "<!--") {
+			inComment = !strings.Contains(line, "-->")
+			continue
+		}
+		if inComment {
+			inComment = !strings.Contains(line, "-->")
+			continue
+		}
+		if strings.HasPrefix(line, "#") {
+			heading := strings.TrimSpace(strings.TrimLeft(line, "#"))
+			if res.Title == "" && strings.HasPrefix(line, "# ") {
+				res.Title = heading
+			}
+			for _, w := range docKeywords(heading) {
+				keywords[w] = true
+			}
+			if len(para) > 0 && res.Summary == "" {
+				res.Summary = strings.Join(para, " ")
+			}
+			continue
+		}
+		if res.Summary != "" {
+			continue
+		}
+		isText := line != "" && !strings.HasPrefix(line, "|") && !strings.HasPrefix(line, "```") && !strings.HasPrefix(line, "![")
+		if isText {
+			para = append(para, line)
+		} else if len(para) > 0 {
+			res.Summary = strings.Join(para, " ")
+		}
+	}
+	if res.Summary == "" {
+		res.Summary = strings.Join(para, " ")
+	}
+	if len(res.Summary) > docSummaryMaxLen {
+		s := res.Summary[:docSummaryMaxLen]
+		if idx := strings.LastIndexByte(s, ' '); idx > 0 {
+			s = s[:idx]
+		}
+		res.Summary = s + "..."
+	}
+	if res.Title == "" {
+		res.Title = strings.ReplaceAll(strings.TrimSuffix(name, ".md"), "-", " ")
+	}
+	for k := range keywords {
+		res.Keywords = append(res.Keywords, k)
+	}
+	sort.Strings(res.Keywords)
+	return res
+}
+
+func getDocPagesMust() []*DocPage {
+	entries, err := os.ReadDir("docs")
+	must(err)
+	var res []*DocPage
+	for _, e := range entries {
+		name := e.Name()
+		if e.IsDir() || filepath.Ext(name) != ".md" {
+			continue
+		}
+		md := readFileMust(filepath.Join("docs", name))
+		res = append(res, parseDocPage(name, string(md)))
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Title < res[j].Title
+	})
+	return res
+}
+
+func genDocsJSONMust() []byte {
+	d, err := json.MarshalIndent(getDocPagesMust(), "", "  ")
+	must(err)
+	return append(d, '\n')
+}
+
+func genLlmsTxtMust() []byte {
+	s := "# SumatraPDF\n\n"
+	s += "> SumatraPDF is a free PDF, eBook (ePub, Mobi), XPS, DjVu, CHM, Comic Book (CBZ and CBR) viewer for Windows.\n\n"
+	s += "## Docs\n\n"
+	for _, p := range getDocPagesMust() {
+		s += fmt.Sprintf("- [%s](%s)", p.Title, p.URL)
+		if p.Summary != "" {
+			s += ": " + p.Summary
+		}
+		s += "\n"
+	}
+	return []byte(s)
+}
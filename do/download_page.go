@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"html"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// generates data for the download page of the website from uploaded builds:
+// versions, per-arch urls, sizes, sha256 and dates of latest release and
+// pre-release. Writes downloads.json and html fragments to sumatra-website
+// repo and pushes the changes
+
+// DownloadFile is a single downloadable file
+type DownloadFile struct {
+	Arch   string `json:"arch"`
+	Kind   string `json:"kind"`
+	URL    string `json:"url"`
+	Size   int64  `json:"size"`
+	Sha256 string `json:"sha256,omitempty"`
+	// only for releases, see torrent.go
+	Torrent string `json:"torrent,omitempty"`
+	Magnet  string `json:"magnet,omitempty"`
+}
+
+// ChannelDownloads describes the latest build in a channel
+type ChannelDownloads struct {
+	Ver   string          `json:"ver"`
+	Date  string          `json:"date"`
+	Files []*DownloadFile `json:"files"`
+}
+
+// DownloadPageData is written as downloads.json
+type DownloadPageData struct {
+	Stable     *ChannelDownloads `json:"stable"`
+	PreRelease *ChannelDownloads `json:"prerelease"`
+}
+
+// returns arch, kind and url for each download
+func listDownloads(urls *DownloadUrls) [][]string {
+	return [][]string{
+		{"64-bit", "installer", urls.installer64},
+		{"64-bit", "portable", urls.portableExe64},
+		{"64-bit", "portable zip", urls.portableZip64},
+		{"ARM64", "installer", urls.installerArm64},
+		{"ARM64", "portable", urls.portableExeArm64},
+		{"ARM64", "portable zip", urls.portableZipArm64},
+		{"32-bit", "installer", urls.installer32},
+		{"32-bit", "portable", urls.portableExe32},
+		{"32-bit", "portable zip", urls.portableZip32},
+	}
+}
+
+// "[SumatraPDF]\nLatest 3.5.2\n" => "3.5.2"
+func parseLatestVer(s string) string {
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "Latest") {
+			line = strings.TrimPrefix(line, "Latest")
+			return strings.TrimSpace(strings.TrimPrefix(line, ":"))
+		}
+	}
+	return strings.TrimSpace(s)
+}
+
+func getChannelDownloadsMust(mc Storage, buildType BuildType, ver string) *ChannelDownloads {
+	dirRemote := "software/sumatrapdf/" + string(buildType) + "/" + ver + "/"
+	sizes := map[string]int64{}
+	var lastModified time.Time
+	for obj := range mc.ListObjects(dirRemote) {
+		sizes[path.Base(obj.Key)] = obj.Size
+		if obj.LastModified.After(lastModified) {
+			lastModified = obj.LastModified
+		}
+	}
+	panicIf(len(sizes) == 0, "no files in '%s'", dirRemote)
+	// older builds don't have hashes
+	hashes := map[string]string{}
+	if hashesPath := dirRemote + uploadHashesFileName; mc.Exists(hashesPath) {
+		must(json.Unmarshal(minioDownloadDataMust(mc, hashesPath), &hashes))
+	}
+	magnets := map[string]string{}
+	if magnetsPath := dirRemote + "SumatraPDF-" + ver + "-magnets.txt"; buildType == buildTypeRel && mc.Exists(magnetsPath) {
+		for _, line := range strings.Split(string(minioDownloadDataMust(mc, magnetsPath)), "\n") {
+			if name, magnet, ok := strings.Cut(line, ": "); ok {
+				magnets[name] = magnet
+			}
+		}
+	}
+	res := &ChannelDownloads{
+		Ver:  ver,
+		Date: lastModified.UTC().Format("2006-01-02"),
+	}
+	for _, dl := range listDownloads(getDownloadUrlsViaWebsite(buildType, ver)) {
+		name := path.Base(dl[2])
+		size, ok := sizes[name]
+		if !ok {
+			logf("'%s' doesn't exist in '%s'\n", name, dirRemote)
+			continue
+		}
+		f := &DownloadFile{
+			Arch:   dl[0],
+			Kind:   dl[1],
+			URL:    dl[2],
+			Size:   size,
+			Sha256: hashes[name],
+			Magnet: magnets[name],
+		}
+		if _, ok := sizes[name+".torrent"]; ok {
+			f.Torrent = dl[2] + ".torrent"
+		}
+		res.Files = append(res.Files, f)
+	}
+	return res
+}
+
+func fmtDownloadsHTML(title string, cd *ChannelDownloads) string {
+	s := fmt.Sprintf("<h3>%s %s <span class=\"date\">(%s)</span></h3>\n", html.EscapeString(title), html.EscapeString(cd.Ver), cd.Date)
+	s += "<table class=\"downloads\">\n"
+	for _, f := range cd.Files {
+		s += fmt.Sprintf("  <tr><td>%s</td><td><a href=\"%s\">%s</a></td><td>%s</td>", f.Arch, html.EscapeString(f.URL), f.Kind, formatSize(f.Size))
+		if f.Sha256 != "" {
+			s += fmt.Sprintf("<td class=\"sha256\">%s</td>", f.Sha256)
+		}
+		s += "</tr>\n"
+	}
+	return s + "</table>\n"
+}
+
+func genDownloadPage() {
+	mc := newMinioR2Client()
+	relVer := parseLatestVer(string(minioDownloadDataMust(mc, "sumatrapdf/sumpdf-latest.txt")))
+	preRelVer := strings.TrimSpace(string(minioDownloadDataMust(mc, getRemotePaths(buildTypePreRel)[1])))
+	data := &DownloadPageData{
+		Stable:     getChannelDownloadsMust(mc, buildTypeRel, relVer),
+		PreRelease: getChannelDownloadsMust(mc, buildTypePreRel, preRelVer),
+	}
+
+	websiteDir := updateSumatraWebsite()
+	d, err := json.MarshalIndent(data, "", "  ")
+	must(err)
+	files := map[string]string{
+		"downloads.json":            string(d),
+		"downloads-stable.html":     fmtDownloadsHTML("SumatraPDF", data.Stable),
+		"downloads-prerelease.html": fmtDownloadsHTML("Pre-release", data.PreRelease),
+	}
+	for name, s := range files {
+		path := filepath.Join(websiteDir, name)
+		writeFileMust(path, []byte(s))
+		logf("wrote '%s'\n", path)
+	}
+
+	msg := fmt.Sprintf("update download page for %s and pre-release %s", relVer, preRelVer)
+	if !commitAndPushWebsiteMust(websiteDir, msg) {
+		logf("download page data didn't change\n")
+	}
+}
+
+// websiteDir is from updateSumatraWebsite(). The website is deployed on push
+// returns false if nothing changed
+func commitAndPushWebsiteMust(websiteDir string, msg string) bool {
+	repoDir := filepath.Join(websiteDir, "..", "..")
+	if isGitClean(repoDir) {
+		return false
+	}
+	for _, args := range [][]string{{"add", "-A"}, {"commit", "-m", msg}, {"push"}} {
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repoDir
+		runCmdLoggedMust(cmd)
+	}
+	return true
+}
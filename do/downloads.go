@@ -0,0 +1,193 @@
+package main
+
+import (
+	"archive/zip"
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// all http downloads go through httpDownloadMust, which supports proxy
+// (HTTPS_PROXY env variable or -proxy).
+// External tools and data we download are pinned in do/downloads.txt
+// by url and sha256. downloadPinnedMust verifies the hash and caches
+// the file in %LOCALAPPDATA%\sumatrapdf-do\downloads so a tampered or
+// changed upstream file fails the build instead of ending up in it.
+// To pin a new file: do pin-download ${name}=${url}
+// Tools installed by do doctor are pinned by version in install_tools.go
+
+const downloadsPath = "do/downloads.txt"
+
+var flgProxy string
+
+// PinnedDownload is a line in do/downloads.txt: ${name} ${url} ${sha256}
+type PinnedDownload struct {
+	Name   string
+	URL    string
+	Sha256 string
+}
+
+func parsePinnedDownloadsMust(s string) []*PinnedDownload {
+	var res []*PinnedDownload
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(line)
+		panicIf(len(parts) != 3, "invalid line '%s' in '%s', expected: name url sha256", line, downloadsPath)
+		panicIf(len(parts[2]) != 64, "invalid sha256 '%s' in '%s'", parts[2], downloadsPath)
+		res = append(res, &PinnedDownload{Name: parts[0], URL: parts[1], Sha256: strings.ToLower(parts[2])})
+	}
+	return res
+}
+
+// returns nil if name is not pinned
+func findPinnedDownload(name string) *PinnedDownload {
+	if !fileExists(downloadsPath) {
+		return nil
+	}
+	for _, d := range parsePinnedDownloadsMust(string(readFileMust(downloadsPath))) {
+		if d.Name == name {
+			return d
+		}
+	}
+	return nil
+}
+
+// must be called after flags are parsed
+func initHTTPProxyMust() {
+	if flgProxy == "" {
+		return
+	}
+	u, err := url.Parse(flgProxy)
+	panicIf(err != nil, "invalid -proxy '%s': %s", flgProxy, err)
+	http.DefaultTransport.(*http.Transport).Proxy = http.ProxyURL(u)
+}
+
+// does the request and returns body of 200 OK response
+func httpDownloadMust(req *http.Request) []byte {
+	rsp, err := http.DefaultClient.Do(req)
+	must(err)
+	defer rsp.Body.Close()
+	panicIf(rsp.StatusCode != http.StatusOK, "%s '%s' failed with status %d", req.Method, req.URL, rsp.StatusCode)
+	d, err := io.ReadAll(rsp.Body)
+	must(err)
+	return d
+}
+
+func httpGetMust(uri string) []byte {
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	must(err)
+	return httpDownloadMust(req)
+}
+
+func getDownloadsCacheDir() string {
+	return filepath.Join(filepath.Dir(getToolCacheDir()), "downloads")
+}
+
+// returns path of downloaded file, verified against its pinned sha256
+func downloadPinnedMust(name string) string {
+	pin := findPinnedDownload(name)
+	failIf(errKindToolchain, pin == nil, "'%s' is not pinned in '%s', add it with: do pin-download %s=${url}", name, downloadsPath, name)
+	cachePath := filepath.Join(createDirMust(getDownloadsCacheDir()), pin.Sha256[:16]+"-"+path.Base(pin.URL))
+	if fileExists(cachePath) && sha256Hex(readFileMust(cachePath)) == pin.Sha256 {
+		return cachePath
+	}
+	logf("downloading %s from '%s'\n", name, pin.URL)
+	d := httpGetMust(pin.URL)
+	got := sha256Hex(d)
+	failIf(errKindVerify, got != pin.Sha256, "sha256 of '%s' is %s, '%s' pins %s", pin.URL, got, downloadsPath, pin.Sha256)
+	tmpPath := cachePath + ".tmp"
+	writeFileMust(tmpPath, d)
+	must(os.Rename(tmpPath, cachePath))
+	logf("downloaded %s (%s) to '%s'\n", name, formatSize(int64(len(d))), cachePath)
+	return cachePath
+}
+
+// arg is ${name}=${url}. Downloads the file and adds or updates its pin
+func pinDownloadMust(arg string) {
+	name, uri, ok := strings.Cut(arg, "=")
+	panicIf(!ok || name == "" || uri == "", "pin-download expects name=url, got '%s'", arg)
+	sha := sha256Hex(httpGetMust(uri))
+	newLine := fmt.Sprintf("%s %s %s", name, uri, sha)
+
+	var lines []string
+	if fileExists(downloadsPath) {
+		lines = strings.Split(strings.TrimRight(string(readFileMust(downloadsPath)), "\n"), "\n")
+	}
+	replaced := false
+	for i, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) > 0 && fields[0] == name && !strings.HasPrefix(line, "#") {
+			lines[i] = newLine
+			replaced = true
+		}
+	}
+	if !replaced {
+		lines = append(lines, newLine)
+	}
+	writeFileMust(downloadsPath, []byte(strings.Join(lines, "\n")+"\n"))
+	logf("pinned in '%s':\n%s\n", downloadsPath, newLine)
+}
+
+// unlike unzipToDirMust, keeps directory structure of the archive
+func unzipToDirWithPathsMust(d []byte, dstDir string) {
+	zr, err := zip.NewReader(bytes.NewReader(d), int64(len(d)))
+	must(err)
+	createDirMust(dstDir)
+	for _, f := range zr.File {
+		if f.FileInfo().IsDir() {
+			continue
+		}
+		dstPath := filepath.Join(dstDir, filepath.FromSlash(f.Name))
+		// protect against names like ../../foo.exe
+		rel, err := filepath.Rel(dstDir, dstPath)
+		panicIf(err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)), "'%s' in zip would be extracted outside of '%s'", f.Name, dstDir)
+		rc, err := f.Open()
+		must(err)
+		data, err := io.ReadAll(rc)
+		rc.Close()
+		must(err)
+		must(createDirForFile(dstPath))
+		writeFileMust(dstPath, data)
+	}
+}
+
+// version of Dr. Memory pinned in downloads.txt
+const drMemoryURL = "https://github.com/DynamoRIO/drmemory/releases/download/release_2.6.0/DrMemory-Windows-2.6.0.zip"
+
+// returns path of drmemory.exe from PATH or, if not installed, from
+// pinned download
+func detectDrMemoryMust() string {
+	if path, err := exec.LookPath("drmemory.exe"); err == nil {
+		return path
+	}
+	dir := filepath.Join(getToolCacheDir(), "DrMemory")
+	if !dirExists(dir) {
+		pin := findPinnedDownload("drmemory")
+		failIf(errKindToolchain, pin == nil, "drmemory.exe not found in PATH. Install Dr. Memory from https://github.com/DynamoRIO/drmemory/releases or pin its portable .zip with: do pin-download drmemory=%s", drMemoryURL)
+		failIf(errKindToolchain, pin.URL != drMemoryURL, "drmemory in '%s' is '%s', expected '%s'", downloadsPath, pin.URL, drMemoryURL)
+		// extract to a temp dir so that interrupted extraction isn't mistaken for installed
+		tmpDir := dir + ".tmp"
+		must(os.RemoveAll(tmpDir))
+		unzipToDirWithPathsMust(readFileMust(downloadPinnedMust("drmemory")), tmpDir)
+		must(os.Rename(tmpDir, dir))
+	}
+	var res string
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && strings.EqualFold(info.Name(), "drmemory.exe") && filepath.Base(filepath.Dir(path)) == "bin64" {
+			res = path
+		}
+		return nil
+	})
+	failIf(errKindToolchain, res == "", "didn't find bin64\\drmemory.exe in '%s'", dir)
+	return res
+}
@@ -0,0 +1,109 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// after build we compare exports of libmupdf.dll with do/libmupdf_exports.txt
+// and check that everything imported from it by SumatraPDF-dll.exe,
+// PdfPreview.dll and PdfFilter.dll is exported. SumatraPDF-dll.exe
+// delay-loads libmupdf.dll so a missing export would only fail at runtime.
+// This catches breakage from mupdf upgrades (src/libmupdf.def is generated).
+// After intentional changes re-create the list with:
+// do check exports -update-baseline
+
+const libmupdfExportsPath = "do/libmupdf_exports.txt"
+
+var libmupdfConsumers = []string{"SumatraPDF-dll.exe", "PdfPreview.dll", "PdfFilter.dll"}
+
+func parseExportsList(s string) []string {
+	var res []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		res = append(res, line)
+	}
+	return res
+}
+
+// returns errors and names exported but not in expected
+func checkExports(exports []string, expected []string, imports map[string][]string) ([]string, []string) {
+	exported := map[string]bool{}
+	for _, name := range exports {
+		exported[name] = true
+	}
+	var errs []string
+	// consumer names by imported function
+	usedBy := map[string][]string{}
+	var consumers []string
+	for consumer := range imports {
+		consumers = append(consumers, consumer)
+	}
+	sort.Strings(consumers)
+	for _, consumer := range consumers {
+		for _, name := range imports[consumer] {
+			usedBy[name] = append(usedBy[name], consumer)
+			if !exported[name] {
+				errs = append(errs, fmt.Sprintf("%s imports %s which libmupdf.dll doesn't export", consumer, name))
+			}
+		}
+	}
+	isExpected := map[string]bool{}
+	for _, name := range expected {
+		isExpected[name] = true
+		if exported[name] {
+			continue
+		}
+		s := "removed export " + name
+		if len(usedBy[name]) > 0 {
+			s += " used by " + strings.Join(usedBy[name], ", ")
+		}
+		errs = append(errs, s)
+	}
+	var added []string
+	for _, name := range exports {
+		if !isExpected[name] {
+			added = append(added, name)
+		}
+	}
+	sort.Strings(added)
+	return errs, added
+}
+
+func checkLibmupdfExportsMust(dir string, updateBaseline bool) {
+	defer failOnPanic(errKindVerify)
+	dllPath := filepath.Join(dir, "libmupdf.dll")
+	exports, err := peExports(readFileMust(dllPath))
+	panicIf(err != nil, "failed to read exports of '%s': %s", dllPath, err)
+	sort.Strings(exports)
+
+	if updateBaseline {
+		s := "# exports of libmupdf.dll, re-generate with: do check exports -update-baseline\n"
+		s += strings.Join(exports, "\n") + "\n"
+		writeFileMust(libmupdfExportsPath, []byte(s))
+		logf("wrote '%s' with %d exports\n", libmupdfExportsPath, len(exports))
+	}
+
+	imports := map[string][]string{}
+	for _, name := range libmupdfConsumers {
+		path := filepath.Join(dir, name)
+		if !fileExists(path) {
+			continue
+		}
+		a, err := peImportsFromDll(readFileMust(path), "libmupdf.dll")
+		panicIf(err != nil, "failed to read imports of '%s': %s", path, err)
+		imports[name] = a
+	}
+	expected := parseExportsList(string(readFileMust(libmupdfExportsPath)))
+	errs, added := checkExports(exports, expected, imports)
+	if len(added) > 0 {
+		logf("%d new exports in libmupdf.dll not in '%s': %s\n", len(added), libmupdfExportsPath, strings.Join(added, ", "))
+	}
+	panicIf(len(errs) > 0, "libmupdf.dll exports check failed:\n%s\nif intentional, use -update-baseline\n", strings.Join(errs, "\n"))
+	logf("libmupdf.dll: %d exports ok\n", len(exports))
+}
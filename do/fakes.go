@@ -0,0 +1,306 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+)
+
+// fakes of the backends of the build pipeline, so that its logic (naming,
+// manifests, upload dedup, promotion, retention) can be tested without S3,
+// Windows tools and a real build:
+// - MemStorage is in-memory Storage
+// - FakeProcessRunner records commands instead of running them
+// - FakeBackends runs code in a temporary directory, which is where
+//   out/ with fake build files is created (everything in the pipeline uses
+//   paths relative to the current directory), with all storages and the
+//   process runner replaced by fakes
+// Used by unit tests (pipeline_test.go) and do test self
+
+// MemStorage is Storage that keeps files in memory
+type MemStorage struct {
+	Name  string
+	mu    sync.Mutex
+	files map[string]*memObject
+	// newPath of Copy calls, to check what was copied instead of uploaded
+	copied []string
+}
+
+type memObject struct {
+	data    []byte
+	public  bool
+	modTime time.Time
+}
+
+func NewMemStorage(name string) *MemStorage {
+	return &MemStorage{Name: name, files: map[string]*memObject{}}
+}
+
+func (s *MemStorage) URLBase() string {
+	return fmt.Sprintf("https://%s.fake-storage.local/", s.Name)
+}
+
+func (s *MemStorage) URLForPath(remotePath string) string {
+	return s.URLBase() + strings.TrimPrefix(remotePath, "/")
+}
+
+func (s *MemStorage) Exists(remotePath string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.files[remotePath] != nil
+}
+
+func (s *MemStorage) put(remotePath string, data []byte, public bool) minio.UploadInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.files[remotePath] = &memObject{data: append([]byte(nil), data...), public: public, modTime: time.Now()}
+	return minio.UploadInfo{Key: remotePath, Size: int64(len(data))}
+}
+
+// like minioStorage.Copy overwrites newPath if it exists
+func (s *MemStorage) Copy(oldPath, newPath string) (*minio.UploadInfo, error) {
+	d, err := s.DownloadData(oldPath)
+	if err != nil {
+		return nil, err
+	}
+	s.mu.Lock()
+	public := s.files[oldPath].public
+	s.copied = append(s.copied, newPath)
+	s.mu.Unlock()
+	info := s.put(newPath, d, public)
+	return &info, nil
+}
+
+// returns sorted paths of files created with Copy
+func (s *MemStorage) CopiedKeys() []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	res := append([]string{}, s.copied...)
+	sort.Strings(res)
+	return res
+}
+
+func (s *MemStorage) UploadFile(remotePath string, path string, public bool) (minio.UploadInfo, error) {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return minio.UploadInfo{}, err
+	}
+	return s.put(remotePath, d, public), nil
+}
+
+func (s *MemStorage) UploadData(remotePath string, data []byte, public bool) (minio.UploadInfo, error) {
+	return s.put(remotePath, data, public), nil
+}
+
+func (s *MemStorage) DownloadData(remotePath string) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	o := s.files[remotePath]
+	if o == nil {
+		return nil, fmt.Errorf("'%s' doesn't exist in %s", remotePath, s.Name)
+	}
+	return append([]byte(nil), o.data...), nil
+}
+
+func (s *MemStorage) DownloadFileAtomically(dstPath string, remotePath string) error {
+	d, err := s.DownloadData(remotePath)
+	if err != nil {
+		return err
+	}
+	if err = createDirForFile(dstPath); err != nil {
+		return err
+	}
+	return os.WriteFile(dstPath, d, 0644)
+}
+
+// returns sorted keys with a given prefix
+func (s *MemStorage) Keys(prefix string) []string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	var res []string
+	for key := range s.files {
+		if strings.HasPrefix(key, prefix) {
+			res = append(res, key)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func (s *MemStorage) ListObjects(prefix string) <-chan minio.ObjectInfo {
+	keys := s.Keys(prefix)
+	ch := make(chan minio.ObjectInfo, len(keys))
+	s.mu.Lock()
+	for _, key := range keys {
+		o := s.files[key]
+		ch <- minio.ObjectInfo{Key: key, Size: int64(len(o.data)), LastModified: o.modTime}
+	}
+	s.mu.Unlock()
+	close(ch)
+	return ch
+}
+
+// like S3, removing a file that doesn't exist is not an error
+func (s *MemStorage) Remove(remotePath string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.files, remotePath)
+	return nil
+}
+
+// FakeProcessRunner records commands and "runs" them by writing Outputs
+// to stdout. Outputs and Errors are keyed by lower-case name of executable
+// without extension (e.g. "signtool") optionally followed by the first
+// argument (e.g. "git rev-parse"), which takes precedence
+type FakeProcessRunner struct {
+	mu       sync.Mutex
+	Outputs  map[string]string
+	Errors   map[string]error
+	Commands [][]string
+}
+
+func NewFakeProcessRunner() *FakeProcessRunner {
+	return &FakeProcessRunner{Outputs: map[string]string{}, Errors: map[string]error{}}
+}
+
+func getExeName(path string) string {
+	name := filepath.Base(strings.ReplaceAll(path, `\`, "/"))
+	return strings.ToLower(strings.TrimSuffix(name, filepath.Ext(name)))
+}
+
+func (r *FakeProcessRunner) Run(cmd *exec.Cmd) error {
+	name := getExeName(cmd.Path)
+	r.mu.Lock()
+	args := append([]string{name}, cmd.Args[1:]...)
+	r.Commands = append(r.Commands, args)
+	key := name
+	if len(args) > 1 {
+		if _, ok := r.Outputs[name+" "+args[1]]; ok {
+			key = name + " " + args[1]
+		} else if _, ok := r.Errors[name+" "+args[1]]; ok {
+			key = name + " " + args[1]
+		}
+	}
+	out, err := r.Outputs[key], r.Errors[key]
+	r.mu.Unlock()
+	if cmd.Stdout != nil && out != "" {
+		_, _ = cmd.Stdout.Write([]byte(out))
+	}
+	return err
+}
+
+// returns commands that ran executable name
+func (r *FakeProcessRunner) CommandsOf(name string) [][]string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var res [][]string
+	for _, args := range r.Commands {
+		if args[0] == name {
+			res = append(res, args)
+		}
+	}
+	return res
+}
+
+// FakeBackends are fakes installed by withFakeBackends
+type FakeBackends struct {
+	// temporary directory that is the current directory
+	Dir      string
+	Runner   *FakeProcessRunner
+	Storages map[string]*MemStorage
+}
+
+// storage by name of StorageConfig: "r2" or "backblaze"
+func (fb *FakeBackends) Storage(name string) *MemStorage {
+	s := fb.Storages[name]
+	panicIf(s == nil, "no fake storage '%s'", name)
+	return s
+}
+
+// runs fn with current directory being a new temporary directory, storages
+// and process runner replaced with fakes and versions set to
+// preRelVer and relVer
+func withFakeBackends(preRelVer string, relVer string, fn func(fb *FakeBackends)) {
+	dir, err := os.MkdirTemp("", "do-fake-")
+	must(err)
+	defer os.RemoveAll(dir)
+	origDir, err := os.Getwd()
+	must(err)
+	must(os.Chdir(dir))
+	defer func() {
+		must(os.Chdir(origDir))
+	}()
+
+	fb := &FakeBackends{
+		Dir:    dir,
+		Runner: NewFakeProcessRunner(),
+		Storages: map[string]*MemStorage{
+			"r2":        NewMemStorage("r2"),
+			"backblaze": NewMemStorage("backblaze"),
+		},
+	}
+
+	origRunner := processRunner
+	origStorageOverride := newStorageOverride
+	origStorageClients := storageClients
+	origVers := []string{preReleaseVerCached, gitSha1Cached, sumatraVersion}
+	origCreds := []string{r2Access, r2Secret, b2Access, b2Secret}
+	defer func() {
+		processRunner = origRunner
+		newStorageOverride = origStorageOverride
+		storageClients = origStorageClients
+		preReleaseVerCached, gitSha1Cached, sumatraVersion = origVers[0], origVers[1], origVers[2]
+		r2Access, r2Secret, b2Access, b2Secret = origCreds[0], origCreds[1], origCreds[2], origCreds[3]
+	}()
+	processRunner = fb.Runner
+	newStorageOverride = func(c *StorageConfig) Storage {
+		return fb.Storage(c.Name)
+	}
+	storageClients = map[string]Storage{}
+	preReleaseVerCached = preRelVer
+	gitSha1Cached = strings.Repeat("0", 40)
+	sumatraVersion = relVer
+	// so that checks for credentials pass
+	r2Access, r2Secret, b2Access, b2Secret = "fake-access", "fake-secret", "fake-access", "fake-secret"
+
+	fn(fb)
+}
+
+// returns names of files of a build, as they are in out/final-*
+func getFakeBuildFileNames(buildType BuildType, ver string) []string {
+	urls := getDownloadUrlsForPrefix("", buildType, ver)
+	res := []string{
+		urls.installer64, urls.portableExe64, urls.portableZip64,
+		urls.installerArm64, urls.portableExeArm64, urls.portableZipArm64,
+		urls.installer32, urls.portableExe32, urls.portableZip32,
+	}
+	prefix := "SumatraPDF-prerel"
+	if buildType == buildTypeRel {
+		prefix = "SumatraPDF-" + ver
+	}
+	for _, suffix := range []string{"-64.pdb.zip", "-arm64.pdb.zip", ".pdb.zip", "-manifest.txt"} {
+		res = append(res, prefix+suffix)
+	}
+	return res
+}
+
+// creates out/final-* with fake files of a build. content of files depends
+// on seed so that builds can have the same or different files
+func createFakeFinalDirMust(buildType BuildType, seed string) string {
+	dir := getFinalDirForBuildType(buildType)
+	must(os.RemoveAll(dir))
+	createDirMust(dir)
+	ver := getVerForBuildType(buildType)
+	for _, name := range getFakeBuildFileNames(buildType, ver) {
+		d := fmt.Sprintf("fake %s, seed: %s\n", name, seed)
+		writeFileMust(filepath.Join(dir, name), []byte(d))
+	}
+	return dir
+}
@@ -5,8 +5,6 @@ import (
 	"path/filepath"
 	"sync"
 	"time"
-
-	"github.com/kjk/minioutil"
 )
 
 const filesRemoteDir = "sumatraTestFiles/"
@@ -23,7 +21,7 @@ func fileUpload(fpath string) {
 
 	timeStart := time.Now()
 
-	upload := func(mc *minioutil.Client) {
+	upload := func(mc Storage) {
 		uri := mc.URLForPath(remotePath)
 		if mc.Exists(remotePath) {
 			logf("Skipping upload, '%s' already exists\n", uri)
@@ -50,7 +48,7 @@ func fileUpload(fpath string) {
 	wg.Wait()
 }
 
-func minioFilesList(mc *minioutil.Client) {
+func minioFilesList(mc Storage) {
 	uri := mc.URLForPath("")
 	logf("filesList in '%s'\n", uri)
 
@@ -70,7 +68,7 @@ func deleteFilesOneOff() {
 	doDelete := false
 	prefix := "vack/"
 
-	var mc *minioutil.Client
+	var mc Storage
 	//mc = newMinioR2Client()
 	//mc = newMinioBackblazeClient()
 	uri := mc.URLForPath("")
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/kjk/common/u"
+)
+
+// every flag can also be set with DO_* environment variable or in do.env
+// config file (KEY=VALUE lines, not checked in) in the directory we run in,
+// so that CI workflows can configure the tool without long command lines.
+// Name is flag name upper-cased with '-' replaced by '_' e.g.
+// -upload => DO_UPLOAD, -portable-formats => DO_PORTABLE_FORMATS
+// Precedence: flag > env variable > config file > default
+
+const flagsConfigFileName = "do.env"
+
+func flagEnvName(flagName string) string {
+	return "DO_" + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+func loadFlagsConfig(path string) map[string]string {
+	d, err := os.ReadFile(path)
+	if err != nil {
+		return nil
+	}
+	m := u.ParseEnvMust(d)
+	for k := range m {
+		panicIf(!strings.HasPrefix(k, "DO_"), "'%s' in '%s': keys must start with DO_", k, path)
+	}
+	return m
+}
+
+// config file is shared by all commands so options can be for flags of
+// any command
+func checkFlagsConfigMust(config map[string]string, known map[string]bool) {
+	for k := range config {
+		panicIf(!known[k], "unknown option '%s' in '%s'", k, flagsConfigFileName)
+	}
+}
+
+// sets flags of a command not given on command line from env variables
+// and config file. must be called after fs.Parse()
+func applyFlagOverridesMust(fs *flag.FlagSet, config map[string]string) {
+	setOnCmdLine := map[string]bool{}
+	fs.Visit(func(f *flag.Flag) {
+		setOnCmdLine[f.Name] = true
+	})
+	var applied []string
+	fs.VisitAll(func(f *flag.Flag) {
+		if setOnCmdLine[f.Name] {
+			return
+		}
+		key := flagEnvName(f.Name)
+		v, ok := os.LookupEnv(key)
+		src := "env"
+		if !ok {
+			v, ok = config[key]
+			src = flagsConfigFileName
+		}
+		if !ok {
+			return
+		}
+		err := fs.Set(f.Name, v)
+		panicIf(err != nil, "invalid value '%s' of %s (from %s) for -%s: %s", v, key, src, f.Name, err)
+		applied = append(applied, fmt.Sprintf("-%s=%s (%s)", f.Name, v, src))
+	})
+	sort.Strings(applied)
+	for _, s := range applied {
+		logf("option %s\n", s)
+	}
+}
+
+func fmtFlagsEnvUsage() string {
+	s := fmt.Sprintf("Every flag can also be set with DO_${NAME} env variable or in %s file,\n", flagsConfigFileName)
+	s += "e.g. -portable-formats => DO_PORTABLE_FORMATS=zip,7z\n"
+	s += fmt.Sprintf("Precedence: flag > env variable > %s > default\n", flagsConfigFileName)
+	return s
+}
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// CI steps sometimes fail for reasons unrelated to the code (network errors,
+// files locked by anti-virus etc.). runStepWithRetry retries a step if its
+// error or output matches a known transient pattern.
+// All step failures are recorded in a state file in R2 so that we can see
+// which steps are flaky (do report flakiness, weekly in CI)
+
+const (
+	stepFailuresRemotePath = "software/sumatrapdf/ci-step-failures.json"
+	stepMaxAttempts        = 3
+	// keep that many days of history in the state file
+	stepFailuresKeepDays = 90
+	// how much output of a step we keep to match against patterns
+	stepOutputTailSize = 64 * 1024
+)
+
+var transientFailurePatterns = []string{
+	// network
+	"connection reset by peer",
+	"i/o timeout",
+	"TLS handshake timeout",
+	"no such host",
+	"unexpected EOF",
+	"502 Bad Gateway",
+	"503 Service Unavailable",
+	"504 Gateway Time",
+	// file locks, usually by anti-virus or indexer
+	"being used by another process",
+	"cannot open program database",
+	"LNK1104: cannot open file",
+}
+
+// StepFailure is a failed attempt of a CI step
+type StepFailure struct {
+	Step      string    `json:"step"`
+	At        time.Time `json:"at"`
+	RunID     string    `json:"runId,omitempty"`
+	Attempt   int       `json:"attempt"`
+	Transient bool      `json:"transient"`
+	Msg       string    `json:"msg"`
+}
+
+// failures in this run, saved by saveStepFailures
+var (
+	stepFailures   []*StepFailure
+	stepFailuresMu sync.Mutex
+)
+
+func findTransientPattern(s string) string {
+	for _, p := range transientFailurePatterns {
+		if strings.Contains(s, p) {
+			return p
+		}
+	}
+	return ""
+}
+
+// tailWriter remembers last max bytes written to it
+type tailWriter struct {
+	mu  sync.Mutex
+	buf []byte
+	max int
+}
+
+func (w *tailWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.buf = append(w.buf, p...)
+	if len(w.buf) > w.max {
+		w.buf = w.buf[len(w.buf)-w.max:]
+	}
+	return len(p), nil
+}
+
+func (w *tailWriter) String() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return string(w.buf)
+}
+
+// runs fn, recovering from panic and returning the panic value. Output of fn
+// (including output of executed commands) is shown and also returned
+func runStepCapturingOutput(fn func()) (output string, panicVal interface{}) {
+	origStdout, origStderr := os.Stdout, os.Stderr
+	r, w, perr := os.Pipe()
+	if perr != nil {
+		// can't capture but can still run
+		w = nil
+	}
+	tail := &tailWriter{max: stepOutputTailSize}
+	done := make(chan bool)
+	if w != nil {
+		os.Stdout, os.Stderr = w, w
+		go func() {
+			rw := newRedactingWriter(io.MultiWriter(origStdout, tail))
+			_, _ = io.Copy(rw, r)
+			rw.Flush()
+			done <- true
+		}()
+	}
+	defer func() {
+		if w != nil {
+			os.Stdout, os.Stderr = origStdout, origStderr
+			w.Close()
+			<-done
+			r.Close()
+		}
+		output = tail.String()
+		panicVal = recover()
+	}()
+	fn()
+	return
+}
+
+// runs fn and retries it if it failed with a known transient error
+func runStepWithRetry(name string, fn func()) {
+	for attempt := 1; ; attempt++ {
+		output, v := runStepCapturingOutput(fn)
+		if v == nil {
+			if attempt > 1 {
+				logf("step '%s' succeeded on attempt %d\n", name, attempt)
+			}
+			return
+		}
+		msg := fmt.Sprint(v)
+		pattern := findTransientPattern(msg + "\n" + output)
+		stepFailuresMu.Lock()
+		stepFailures = append(stepFailures, &StepFailure{
+			Step:      name,
+			At:        time.Now().UTC(),
+			RunID:     getCIContext().RunID,
+			Attempt:   attempt,
+			Transient: pattern != "",
+			Msg:       msg,
+		})
+		stepFailuresMu.Unlock()
+		if pattern == "" || attempt == stepMaxAttempts {
+			// re-panic with original value so that e.g. *DoError keeps its kind
+			panic(v)
+		}
+		wait := time.Duration(attempt) * 30 * time.Second
+		logf("step '%s' failed with transient error ('%s'), retrying in %s\n", name, pattern, wait)
+		time.Sleep(wait)
+	}
+}
+
+func loadStepFailuresMust(mc Storage) []*StepFailure {
+	var res []*StepFailure
+	if !mc.Exists(stepFailuresRemotePath) {
+		return res
+	}
+	d := minioDownloadDataMust(mc, stepFailuresRemotePath)
+	must(json.Unmarshal(d, &res))
+	return res
+}
+
+// called at the end of CI build, also when it fails
+func saveStepFailures() {
+	stepFailuresMu.Lock()
+	failures := stepFailures
+	stepFailuresMu.Unlock()
+	if len(failures) == 0 || r2Access == "" {
+		return
+	}
+	// failing to save is not a reason to fail the build
+	defer func() {
+		if v := recover(); v != nil {
+			logf("saveStepFailures: %v\n", v)
+		}
+	}()
+	mc := newMinioR2Client()
+	a := loadStepFailuresMust(mc)
+	a = append(a, failures...)
+	cutoff := time.Now().Add(-stepFailuresKeepDays * 24 * time.Hour)
+	var keep []*StepFailure
+	for _, f := range a {
+		if f.At.After(cutoff) {
+			keep = append(keep, f)
+		}
+	}
+	d, err := json.MarshalIndent(keep, "", "  ")
+	must(err)
+	_, err = mc.UploadData(stepFailuresRemotePath, d, false)
+	must(err)
+	logf("saved %d step failures to '%s'\n", len(failures), stepFailuresRemotePath)
+}
+
+func fmtFlakinessReport(failures []*StepFailure, since time.Time) string {
+	type stepStats struct {
+		Name      string
+		Failures  int
+		Transient int
+		Runs      map[string]bool
+		Msgs      map[string]int
+	}
+	byStep := map[string]*stepStats{}
+	for _, f := range failures {
+		if f.At.Before(since) {
+			continue
+		}
+		st := byStep[f.Step]
+		if st == nil {
+			st = &stepStats{Name: f.Step, Runs: map[string]bool{}, Msgs: map[string]int{}}
+			byStep[f.Step] = st
+		}
+		st.Failures++
+		if f.Transient {
+			st.Transient++
+		}
+		st.Runs[f.RunID] = true
+		msg := f.Msg
+		if len(msg) > 120 {
+			msg = msg[:120] + "..."
+		}
+		st.Msgs[msg]++
+	}
+	var steps []*stepStats
+	for _, st := range byStep {
+		steps = append(steps, st)
+	}
+	sort.Slice(steps, func(i, j int) bool {
+		return steps[i].Failures > steps[j].Failures
+	})
+
+	s := fmt.Sprintf("## CI flakiness since %s\n\n", since.Format("2006-01-02"))
+	if len(steps) == 0 {
+		return s + "no failed steps\n"
+	}
+	s += "| step | failures | transient | runs |\n|---|---:|---:|---:|\n"
+	for _, st := range steps {
+		s += fmt.Sprintf("| %s | %d | %d | %d |\n", st.Name, st.Failures, st.Transient, len(st.Runs))
+	}
+	for _, st := range steps {
+		s += fmt.Sprintf("\n### %s\n\n", st.Name)
+		for msg, n := range st.Msgs {
+			s += fmt.Sprintf("- %d x `%s`\n", n, strings.ReplaceAll(msg, "\n", " "))
+		}
+	}
+	return s
+}
+
+func flakinessReport() {
+	panicIf(r2Access == "", "need R2_ACCESS and R2_SECRET env variables")
+	failures := loadStepFailuresMust(newMinioR2Client())
+	report := fmtFlakinessReport(failures, time.Now().Add(-7*24*time.Hour))
+	logf("%s\n", report)
+	if path := os.Getenv("GITHUB_STEP_SUMMARY"); path != "" {
+		f, err := openForAppend(path)
+		must(err)
+		defer f.Close()
+		_, err = f.WriteString(report)
+		must(err)
+	}
+}
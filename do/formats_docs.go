@@ -0,0 +1,170 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// generates docs/Supported-formats.md, the list of extensions the
+// installer associates with SumatraPDF (gSupportedExts in
+// src/RegistryInstaller.cpp) and the list of files opened from a directory
+// (gSupportedExtsForMulti in src/EngineMulti.cpp) from extensions we
+// recognize (DEF_EXT_KIND in src/utils/GuessFileType.cpp) so that they
+// don't disagree
+
+var (
+	supportedFormatsDocPath = filepath.Join("docs", "Supported-formats.md")
+	guessFileTypePath       = filepath.Join("src", "utils", "GuessFileType.cpp")
+	registryInstallerPath   = filepath.Join("src", "RegistryInstaller.cpp")
+	engineMultiPath         = filepath.Join("src", "EngineMulti.cpp")
+)
+
+// DocFormat groups file kinds (kindFile* in GuessFileType.cpp) for docs
+type DocFormat struct {
+	// if empty, not documented
+	Name  string
+	Kinds []string
+	// if true, installer registers SumatraPDF for its extensions
+	Associate bool
+	Note      string
+}
+
+// every kind in DEF_EXT_KIND must be here
+var docFormats = []*DocFormat{
+	{Name: "PDF", Kinds: []string{"kindFilePDF"}, Associate: true},
+	{Name: "XPS", Kinds: []string{"kindFileXps"}, Associate: true},
+	{Name: "DjVu", Kinds: []string{"kindFileDjVu"}, Associate: true},
+	{Name: "EPUB", Kinds: []string{"kindFileEpub"}, Associate: true},
+	{Name: "Mobi", Kinds: []string{"kindFileMobi"}, Associate: true},
+	{Name: "FictionBook", Kinds: []string{"kindFileFb2", "kindFileFb2z"}, Associate: true},
+	{Name: "CHM", Kinds: []string{"kindFileChm"}, Associate: true},
+	{Name: "Comic books", Kinds: []string{"kindFileCbz", "kindFileCbr", "kindFileCb7", "kindFileCbt"}, Associate: true},
+	{Name: "Images", Kinds: []string{"kindFilePng", "kindFileJpeg", "kindFileGif", "kindFileTiff", "kindFileTga", "kindFileWebp", "kindFileJp2", "kindFileHeic", "kindFileAvif"}, Associate: true},
+	{Name: "Images", Kinds: []string{"kindFileBmp", "kindFileJxr", "kindFileHdp", "kindFileWdp"}},
+	{Name: "SVG", Kinds: []string{"kindFileSvg"}},
+	{Name: "PostScript", Kinds: []string{"kindFilePS"}, Note: "requires Ghostscript"},
+	{Name: "PalmDoc", Kinds: []string{"kindFilePalmDoc"}},
+	{Name: "Text", Kinds: []string{"kindFileTxt"}},
+	// only opened as part of other formats
+	{Kinds: []string{"kindFileHTML", "kindFileZip", "kindFileRar", "kindFile7Z", "kindFileTar"}},
+}
+
+// extensions which are recognized but we don't want to associate with
+// e.g. because Windows only looks at the last extension
+var notAssociatedExts = []string{".fbz", ".zfb2", ".fb2.zip", ".azw1"}
+
+// V(".txt", kindFileTxt)
+var rxExtKindDef = regexp.MustCompile(`^\s*V\("([^"]+)",\s*(kindFile\w+)\)`)
+
+// ExtKind is extension and its kind, in order of DEF_EXT_KIND
+type ExtKind struct {
+	Ext  string
+	Kind string
+}
+
+func parseExtKinds(s string) []*ExtKind {
+	var res []*ExtKind
+	for _, line := range strings.Split(s, "\n") {
+		if m := rxExtKindDef.FindStringSubmatch(line); m != nil {
+			res = append(res, &ExtKind{Ext: m[1], Kind: m[2]})
+		}
+	}
+	return res
+}
+
+func findDocFormat(kind string) *DocFormat {
+	for _, f := range docFormats {
+		if stringInSlice(f.Kinds, kind) {
+			return f
+		}
+	}
+	return nil
+}
+
+func getExtKindsMust() []*ExtKind {
+	exts := parseExtKinds(string(readFileMust(guessFileTypePath)))
+	panicIf(len(exts) == 0, "didn't find DEF_EXT_KIND in '%s'", guessFileTypePath)
+	for _, e := range exts {
+		panicIf(findDocFormat(e.Kind) == nil, "%s (%s) from '%s' is not in docFormats in do/formats_docs.go", e.Kind, e.Ext, guessFileTypePath)
+	}
+	return exts
+}
+
+// returns extensions the installer should associate with SumatraPDF
+func getAssociatedExts(exts []*ExtKind) []string {
+	var res []string
+	for _, e := range exts {
+		if !findDocFormat(e.Kind).Associate || stringInSlice(notAssociatedExts, e.Ext) {
+			continue
+		}
+		// file_id.diz, .ps.gz etc.
+		if !strings.HasPrefix(e.Ext, ".") || strings.Count(e.Ext, ".") > 1 {
+			continue
+		}
+		res = append(res, e.Ext)
+	}
+	return res
+}
+
+func genSupportedFormatsDocMust() []byte {
+	exts := getExtKindsMust()
+	lines := []string{
+		"<!-- DO NOT EDIT MANUALLY !!! Generated with .\\doit.bat docs gen from src/utils/GuessFileType.cpp -->",
+		"",
+		"# Supported document formats",
+		"",
+		"| Format | File extensions | Associated by installer |",
+		"| --- | --- | --- |",
+	}
+	for _, f := range docFormats {
+		if f.Name == "" {
+			continue
+		}
+		var fExts []string
+		for _, e := range exts {
+			if stringInSlice(f.Kinds, e.Kind) {
+				fExts = append(fExts, "`"+e.Ext+"`")
+			}
+		}
+		if len(fExts) == 0 {
+			continue
+		}
+		name := f.Name
+		if f.Note != "" {
+			name += fmt.Sprintf(" (%s)", f.Note)
+		}
+		assoc := "no"
+		if f.Associate {
+			assoc = "yes"
+		}
+		lines = append(lines, fmt.Sprintf("| %s | %s | %s |", name, strings.Join(fExts, ", "), assoc))
+	}
+	lines = append(lines, "")
+	return []byte(strings.Join(lines, "\n"))
+}
+
+// returns path with re-generated SeqStrings varName, a list of associated extensions
+func genSupportedExtsDeclMust(path string, varName string) []byte {
+	rx := regexp.MustCompile(`(?s)(static SeqStrings ` + varName + ` = )[^;]*;`)
+	s := string(readFileMust(path))
+	panicIf(!rx.MatchString(s), "didn't find %s in '%s'", varName, path)
+	exts := getAssociatedExts(getExtKindsMust())
+	const perLine = 7
+	var lines []string
+	for i := 0; i < len(exts); i += perLine {
+		end := min(i+perLine, len(exts))
+		lines = append(lines, `    "`+strings.Join(exts[i:end], `\0`)+`\0"`)
+	}
+	decl := "${1}\r\n" + strings.Join(lines, " \\\r\n") + ";"
+	return []byte(rx.ReplaceAllString(s, decl))
+}
+
+func genRegistryInstallerMust() []byte {
+	return genSupportedExtsDeclMust(registryInstallerPath, "gSupportedExts")
+}
+
+func genEngineMultiMust() []byte {
+	return genSupportedExtsDeclMust(engineMultiPath, "gSupportedExtsForMulti")
+}
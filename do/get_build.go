@@ -0,0 +1,114 @@
+package main
+
+import (
+	"encoding/json"
+	"path/filepath"
+	"strings"
+)
+
+// do builds get ${ver} downloads uploaded files of a build to out/builds/${ver}/${platform}
+// so that we can reproduce issues reported for that build.
+// ${ver} is pre-release build number (e.g. 15432) or release version (e.g. 3.5.2)
+// -platform selects Win32, x64 (default) or ARM64.
+// Files are verified against upload-hashes.json. To match .pdb files
+// we also restore binaries under their original names: .pdb files are
+// extracted from .pdb.zip and dlls from the installer
+
+// returns remote dir and file name prefix of a build
+func getBuildRemoteDirAndPrefix(ver string, platform string) (string, string) {
+	suffix := getSuffixForPlatform(platform)
+	if isNum(ver) {
+		return "software/sumatrapdf/" + string(buildTypePreRel) + "/" + ver + "/", "SumatraPDF-prerel-" + suffix
+	}
+	verifyCorrectVersionMust(ver)
+	prefix := "SumatraPDF-" + ver
+	if platform != kPlatformIntel32 {
+		prefix += "-" + suffix
+	}
+	return "software/sumatrapdf/" + string(buildTypeRel) + "/" + ver + "/", prefix
+}
+
+// returns the first client that has the build
+func findBuildStorageMust(remoteDir string) Storage {
+	var clients []Storage
+	if r2Access != "" {
+		clients = append(clients, newMinioR2Client())
+	}
+	if b2Access != "" {
+		clients = append(clients, newMinioBackblazeClient())
+	}
+	panicIf(len(clients) == 0, "R2_ACCESS or BB_ACCESS env variable must be set")
+	for _, mc := range clients {
+		for obj := range mc.ListObjects(remoteDir) {
+			must(obj.Err)
+			return mc
+		}
+	}
+	panicIf(true, "build '%s' not found in storage", remoteDir)
+	return nil
+}
+
+// returns directory with downloaded files
+func getBuildMust(ver string, platform string) string {
+	remoteDir, prefix := getBuildRemoteDirAndPrefix(ver, platform)
+	dir := createDirMust(filepath.Join("out", "builds", ver, getSuffixForPlatform(platform)))
+	mc := findBuildStorageMust(remoteDir)
+	logf("getBuildMust: downloading from '%s%s' to '%s'\n", mc.URLBase(), remoteDir, dir)
+
+	var hashes map[string]string
+	if hashesPath := remoteDir + uploadHashesFileName; mc.Exists(hashesPath) {
+		must(json.Unmarshal(minioDownloadDataMust(mc, hashesPath), &hashes))
+	} else {
+		logf("no '%s', can't verify hashes\n", hashesPath)
+	}
+
+	nFiles := 0
+	for _, f := range getFileNamesWithPrefix(prefix) {
+		name := f[1]
+		remotePath := remoteDir + name
+		path := filepath.Join(dir, name)
+		expectedHash := hashes[name]
+		if !fileExists(path) || (expectedHash != "" && sha256Hex(readFileMust(path)) != expectedHash) {
+			if !mc.Exists(remotePath) {
+				continue
+			}
+			must(mc.DownloadFileAtomically(path, remotePath))
+		}
+		if expectedHash != "" {
+			gotHash := sha256Hex(readFileMust(path))
+			failIf(errKindVerify, gotHash != expectedHash, "'%s': sha256 is %s, expected %s", path, gotHash, expectedHash)
+		}
+		logf("  %s %s\n", name, formatSize(fileSizeMust(path)))
+		nFiles++
+		if strings.HasSuffix(name, ".pdb.zip") {
+			for _, pdbName := range pdbFiles {
+				extractFileFromZipMust(path, pdbName, filepath.Join(dir, pdbName))
+			}
+		}
+	}
+	panicIf(nFiles == 0, "no files for platform %s in '%s'", platform, remoteDir)
+	restoreBuiltFileNamesMust(dir, prefix)
+	logf("downloaded %d files of build %s to '%s'\n", nFiles, ver, dir)
+	return dir
+}
+
+// re-creates files with names as in out/rel* directory from release files
+func restoreBuiltFileNamesMust(dir string, prefix string) {
+	for _, f := range getFileNamesWithPrefix(prefix) {
+		srcPath := filepath.Join(dir, f[1])
+		dstPath := filepath.Join(dir, f[0])
+		if !stringInSlice([]string{"SumatraPDF.exe", "SumatraPDF-dll.exe"}, f[0]) || !fileExists(srcPath) {
+			continue
+		}
+		must(copyFile(dstPath, srcPath))
+	}
+	installerPath := filepath.Join(dir, "SumatraPDF-dll.exe")
+	if !fileExists(installerPath) {
+		return
+	}
+	for _, f := range loadInstallerPayloadMust(installerPath) {
+		d, err := f.Uncompress()
+		must(err)
+		writeFileMust(filepath.Join(dir, filepath.Base(f.Name)), d)
+	}
+}
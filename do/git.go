@@ -1,7 +1,8 @@
 package main
 
 import (
-	"os"
+	"os/exec"
+	"strconv"
 	"strings"
 )
 
@@ -15,6 +16,19 @@ func getGitLinearVersionMust() int {
 	return n
 }
 
+// linear version of an older commit, see getGitLinearVersionMust
+func getGitLinearVersionOfMust(sha string) int {
+	out := runExeMust("git", "rev-list", "--count", sha)
+	n, err := strconv.Atoi(strings.TrimSpace(string(out)))
+	must(err)
+	return n + 1000
+}
+
+func isGitCommit(sha string) bool {
+	cmd := exec.Command("git", "cat-file", "-e", sha+"^{commit}")
+	return cmd.Run() == nil
+}
+
 func getGitSha1Must() string {
 	out := runExeMust("git", "rev-parse", "HEAD")
 	s := strings.TrimSpace(string(out))
@@ -72,22 +86,3 @@ func verifyOnReleaseBranchMust() {
 
 	panicIf(!strings.HasPrefix(sumatraVersion, ver), "version mismatch, sumatra: '%s', branch: '%s'\n", sumatraVersion, ver)
 }
-
-// we should only sign and upload to s3 if this is my repo and a push event
-// or building locally
-// don't sign if it's a fork or pull requests
-func isGithubMyMasterBranch() bool {
-	// https://help.github.com/en/actions/automating-your-workflow-with-github-actions/using-environment-variables
-	repo := os.Getenv("GITHUB_REPOSITORY")
-	if repo != "sumatrapdfreader/sumatrapdf" {
-		return false
-	}
-	ref := os.Getenv("GITHUB_REF")
-	if ref != "refs/heads/master" {
-		logf("GITHUB_REF: '%s'\n", ref)
-		return false
-	}
-	event := os.Getenv("GITHUB_EVENT_NAME")
-	// other event is "pull_request"
-	return event == "push" || event == "repository_dispatch"
-}
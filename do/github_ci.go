@@ -1,7 +1,6 @@
 package main
 
 import (
-	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"net/http"
@@ -26,38 +25,6 @@ func triggerBuildWebHook(typ string) {
 	panicIf(rsp.StatusCode >= 400)
 }
 
-const (
-	githubEventTypeCodeQL = "codeql"
-	githubEventPush       = "push"
-	githubEventCron       = "schedule"
-)
-
-//  "action": "build-pre-rel"
-type gitHubEventJSON struct {
-	Action string `json:"action"`
-}
-
-func getGitHubEventType() string {
-	v := os.Getenv("GITHUB_EVENT_NAME")
-	isWebhookDispatch := v == "repository_dispatch"
-	if !isWebhookDispatch {
-		return githubEventPush
-	}
-	path := os.Getenv("GITHUB_EVENT_PATH")
-	d, err := ioutil.ReadFile(path)
-	must(err)
-	var js gitHubEventJSON
-	err = json.Unmarshal(d, &js)
-	must(err)
-	// validate this is an action we understand
-	switch js.Action {
-	case githubEventTypeCodeQL:
-		return js.Action
-	}
-	panicIf(true, "invalid js.Action of '%s'", js.Action)
-	return ""
-}
-
 // https://help.github.com/en/actions/configuring-and-managing-workflows/using-environment-variables#default-environment-variables
 func dumpWebHookEventPayload() {
 	v := os.Getenv("GITHUB_EVENT_PATH")
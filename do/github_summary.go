@@ -0,0 +1,190 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// when running in GitHub Actions:
+// - writes a markdown summary of the build (durations, test results,
+//   sizes of artifacts) to $GITHUB_STEP_SUMMARY
+// - emits annotations for compiler warnings / errors and test failures
+//   so that they show up in the UI of the workflow run and in PR diffs
+// https://docs.github.com/en/actions/using-workflows/workflow-commands-for-github-actions
+
+func isGitHubActions() bool {
+	return os.Getenv("GITHUB_ACTIONS") == "true"
+}
+
+// StepDuration is recorded by makePrintDuration
+type StepDuration struct {
+	Name string
+	Dur  time.Duration
+}
+
+// TestResult is result of running test_util.exe
+type TestResult struct {
+	Dir    string
+	Passed bool
+	Output string
+}
+
+var (
+	stepDurations   []*StepDuration
+	stepDurationsMu sync.Mutex
+	testResults     []*TestResult
+)
+
+// for msbuild log created with /flp:warningsonly;errorsonly
+// src\Foo.cpp(123,5): warning C4100: 'hwnd': unreferenced formal parameter [D:\sumatrapdf\vs2022\SumatraPDF.vcxproj]
+var rxMsvcDiag = regexp.MustCompile(`^(?:\d+>)?\s*(.+?)\((\d+)(?:,(\d+))?\): (?:fatal )?(warning|error) (\w+): (.*?)(?: \[[^\]]+\])?$`)
+
+// 'str::Eq(s, "foo")' D:\sumatrapdf\src\utils\tests\StrUtil_ut.cpp@123
+var rxTestFailure = regexp.MustCompile(`^'(.*)' (.+)@(\d+)$`)
+
+func escapeGitHubAnnotation(s string) string {
+	s = strings.ReplaceAll(s, "%", "%25")
+	s = strings.ReplaceAll(s, "\r", "%0D")
+	return strings.ReplaceAll(s, "\n", "%0A")
+}
+
+func escapeGitHubAnnotationProp(s string) string {
+	s = escapeGitHubAnnotation(s)
+	s = strings.ReplaceAll(s, ":", "%3A")
+	return strings.ReplaceAll(s, ",", "%2C")
+}
+
+// level is "error", "warning" or "notice"
+func emitGitHubAnnotation(level, path string, line, col int, msg string) {
+	if !isGitHubActions() {
+		return
+	}
+	var props []string
+	if path != "" {
+		props = append(props, "file="+escapeGitHubAnnotationProp(sarifURI(path)))
+	}
+	if line > 0 {
+		props = append(props, fmt.Sprintf("line=%d", line))
+	}
+	if col > 0 {
+		props = append(props, fmt.Sprintf("col=%d", col))
+	}
+	s := "::" + level
+	if len(props) > 0 {
+		s += " " + strings.Join(props, ",")
+	}
+	fmt.Printf("%s::%s\n", s, escapeGitHubAnnotation(msg))
+}
+
+// emits annotations for warnings and errors in msbuild log
+func emitMsbuildAnnotations(logPath string) {
+	if !isGitHubActions() || !fileExists(logPath) {
+		return
+	}
+	seen := map[string]bool{}
+	for _, line := range strings.Split(string(readFileMust(logPath)), "\n") {
+		line = strings.TrimSpace(line)
+		m := rxMsvcDiag.FindStringSubmatch(line)
+		if m == nil || seen[line] {
+			continue
+		}
+		// msbuild prints each warning twice
+		seen[line] = true
+		lineNo, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		emitGitHubAnnotation(m[4], m[1], lineNo, col, m[5]+": "+m[6])
+	}
+}
+
+func recordTestResult(dir string, passed bool, output string) {
+	testResults = append(testResults, &TestResult{Dir: dir, Passed: passed, Output: output})
+	for _, line := range strings.Split(output, "\n") {
+		m := rxTestFailure.FindStringSubmatch(strings.TrimSpace(line))
+		if m == nil {
+			continue
+		}
+		lineNo, _ := strconv.Atoi(m[3])
+		emitGitHubAnnotation("error", m[2], lineNo, 0, "test failed: "+m[1])
+	}
+}
+
+func fmtArtifactsSummary() string {
+	s := ""
+	for _, dir := range []string{rel32Dir, rel64Dir, relArm64Dir} {
+		files, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		rows := ""
+		for _, f := range files {
+			ext := strings.ToLower(filepath.Ext(f.Name()))
+			if f.IsDir() || !stringInSlice([]string{".exe", ".dll", ".zip", ".lzsa"}, ext) {
+				continue
+			}
+			fi, err := f.Info()
+			if err != nil {
+				continue
+			}
+			rows += fmt.Sprintf("| %s | %s |\n", f.Name(), formatSize(fi.Size()))
+		}
+		if rows != "" {
+			s += fmt.Sprintf("\n#### %s\n\n| file | size |\n|---|---:|\n%s", filepath.ToSlash(dir), rows)
+		}
+	}
+	return s
+}
+
+func fmtGitHubStepSummary() string {
+	s := ""
+	if len(testResults) > 0 {
+		s += "\n### Tests\n\n| dir | result |\n|---|---|\n"
+		for _, r := range testResults {
+			res := "passed"
+			if !r.Passed {
+				res = "**failed**"
+			}
+			s += fmt.Sprintf("| %s | %s |\n", filepath.ToSlash(r.Dir), res)
+		}
+	}
+	stepDurationsMu.Lock()
+	durations := stepDurations
+	stepDurationsMu.Unlock()
+	if len(durations) > 0 {
+		s += "\n### Durations\n\n| step | duration |\n|---|---:|\n"
+		for _, d := range durations {
+			s += fmt.Sprintf("| %s | %s |\n", d.Name, formatDuration(d.Dur))
+		}
+	}
+	if artifacts := fmtArtifactsSummary(); artifacts != "" {
+		s += "\n### Artifacts\n" + artifacts
+	}
+	if s == "" {
+		return ""
+	}
+	return fmt.Sprintf("## SumatraPDF build %s\n", getGitSha1Must()[:8]) + s
+}
+
+// called at the end of CI build, also when it fails
+func writeGitHubStepSummary() {
+	path := os.Getenv("GITHUB_STEP_SUMMARY")
+	if path == "" {
+		return
+	}
+	s := fmtGitHubStepSummary()
+	if s == "" {
+		return
+	}
+	f, err := openForAppend(path)
+	if err != nil {
+		logf("writeGitHubStepSummary: failed to open '%s': %s\n", path, err)
+		return
+	}
+	defer f.Close()
+	_, _ = f.WriteString(s + "\n")
+}
@@ -8,6 +8,7 @@ require (
 	github.com/kjk/common v0.0.0-20240426141304-c217812bf00b
 	github.com/kjk/minioutil v0.0.0-20230422073834-96945ac7e481
 	github.com/kjk/u v0.0.0-20220410204605-ce4a95db4475
+	github.com/minio/minio-go/v7 v7.0.70
 	golang.org/x/exp v0.0.0-20240416160154-fe59bbe5cc7f
 )
 
@@ -22,7 +23,6 @@ require (
 	github.com/klauspost/cpuid/v2 v2.2.7 // indirect
 	github.com/minio/md5-simd v1.1.2 // indirect
 	github.com/minio/minio-go/v6 v6.0.57 // indirect
-	github.com/minio/minio-go/v7 v7.0.70 // indirect
 	github.com/minio/sha256-simd v1.0.1 // indirect
 	github.com/mitchellh/go-homedir v1.1.0 // indirect
 	github.com/modern-go/concurrent v0.0.0-20180306012644-bacd9c7ef1dd // indirect
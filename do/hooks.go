@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// forks can run their own scripts before / after named steps (custom
+// signing, uploading to their storage, notifications) without patching
+// do/. Hooks are files in -hooks-dir (do/hooks by default) named
+// ${when}-${step}.${ext} e.g. pre-sign.bat, post-upload.ps1, where when
+// is pre or post and ext is .bat, .cmd, .ps1 or .exe.
+// Steps with hooks are build, sign, package (arg is out dir e.g. rel64)
+// and upload (arg is r2 or backblaze), both when run as part of do build ci,
+// do build prerel etc. and with do run (see steps.go)
+// Hook gets build context in env variables DO_HOOK_WHEN, DO_HOOK_STEP,
+// DO_HOOK_ARG, DO_HOOK_STATUS (post only: ok or failed) and as JSON in
+// file whose path is in DO_HOOK_CONTEXT.
+// Failure of a hook fails the build.
+// Go plugins are not supported because they don't work on Windows
+
+var flgHooksDir = filepath.Join("do", "hooks")
+
+var hookExts = []string{".bat", ".cmd", ".ps1", ".exe"}
+
+// HookContext is build context passed to hooks
+type HookContext struct {
+	When string
+	Step string
+	// e.g. out dir (rel64) for build and sign, storage for upload
+	Arg string
+	// for post hooks: ok or failed
+	Status     string
+	Version    string `json:",omitempty"`
+	PreRelease string `json:",omitempty"`
+	GitSha1    string `json:",omitempty"`
+	CI         *CIContext
+}
+
+// returns hooks for when and step, sorted by name
+func findHooks(when string, step string) []string {
+	var res []string
+	for _, ext := range hookExts {
+		path := filepath.Join(flgHooksDir, when+"-"+step+ext)
+		if fileExists(path) {
+			res = append(res, path)
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+func hookCmd(path string) *exec.Cmd {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".ps1":
+		return exec.Command("powershell.exe", "-NoProfile", "-ExecutionPolicy", "Bypass", "-File", path)
+	case ".bat", ".cmd":
+		return exec.Command("cmd.exe", "/c", path)
+	}
+	return exec.Command(path)
+}
+
+func runHooksMust(ctx *HookContext) {
+	hooks := findHooks(ctx.When, ctx.Step)
+	if len(hooks) == 0 {
+		return
+	}
+	ctx.Version = sumatraVersion
+	ctx.PreRelease = preReleaseVerCached
+	ctx.GitSha1 = gitSha1Cached
+	ctx.CI = getCIContext()
+	d, err := json.MarshalIndent(ctx, "", "  ")
+	must(err)
+	// steps can run concurrently (upload to r2 and backblaze)
+	name := fmt.Sprintf("hook-context-%s-%s-%s.json", ctx.When, ctx.Step, urlify(ctx.Arg))
+	ctxPath := absPathMust(filepath.Join(createDirMust("out"), name))
+	writeFileMust(ctxPath, d)
+	defer os.Remove(ctxPath)
+
+	for _, path := range hooks {
+		cmd := hookCmd(absPathMust(path))
+		cmd.Env = append(os.Environ(),
+			"DO_HOOK_WHEN="+ctx.When,
+			"DO_HOOK_STEP="+ctx.Step,
+			"DO_HOOK_ARG="+ctx.Arg,
+			"DO_HOOK_STATUS="+ctx.Status,
+			"DO_HOOK_CONTEXT="+ctxPath,
+		)
+		func() {
+			defer func() {
+				if r := recover(); r != nil {
+					panic(fmt.Sprintf("hook '%s' failed: %v", path, r))
+				}
+			}()
+			runCmdLoggedMust(cmd)
+		}()
+	}
+}
+
+// runs pre hooks of a step and returns function that runs post hooks.
+// Must be used as: defer stepHooks("sign", "rel64")()
+func stepHooks(step string, arg string) func() {
+	runHooksMust(&HookContext{When: "pre", Step: step, Arg: arg})
+	return func() {
+		r := recover()
+		status := "ok"
+		if r != nil {
+			status = "failed"
+		}
+		func() {
+			// failure of step is more important than failure of its hook
+			if r != nil {
+				defer func() {
+					if r2 := recover(); r2 != nil {
+						logf("post hook of failed step '%s' failed: %v\n", step, r2)
+					}
+				}()
+			}
+			runHooksMust(&HookContext{When: "post", Step: step, Arg: arg, Status: status})
+		}()
+		if r != nil {
+			panic(r)
+		}
+	}
+}
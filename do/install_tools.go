@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// do doctor checks that helper tools needed by some commands are installed
+// and offers to install the missing ones (with -yes installs without
+// asking), then checks the toolchain (see toolchain.go) and runs preflight
+// checks (see preflight.go). Setting up a new build machine is:
+// do doctor -yes
+// Tools installed with go install go to the tool cache
+// (%LOCALAPPDATA%\sumatrapdf-do\bin), which we add to PATH at startup.
+// winget installs to default locations, which we already search
+// Tools are installed at pinned versions (update them here): go install
+// verifies the module against sum.golang.org and winget verifies the
+// installer against sha256 in its manifest
+
+var flgYes bool
+
+// HelperTool is a tool we can install if missing
+type HelperTool struct {
+	Name string
+	// what needs it
+	UsedBy string
+	// returns path of the tool, panics if not found
+	Detect func() string
+	// command that installs it
+	Install []string
+}
+
+var helperTools = []*HelperTool{
+	{
+		Name:    "wails",
+		UsedBy:  "build logview",
+		Detect:  func() string { return lookPathMust("wails") },
+		Install: []string{"go", "install", "github.com/wailsapp/wails/v2/cmd/wails@v2.9.2"},
+	},
+	{
+		Name:    "llvm-pdbutil",
+		UsedBy:  "report size, check abi, builds addr2line",
+		Detect:  detectLlvmPdbutilMust,
+		Install: []string{"winget", "install", "--id", "LLVM.LLVM", "--version", "18.1.8", "--exact", "--silent", "--accept-package-agreements", "--accept-source-agreements"},
+	},
+	{
+		Name:    "7z",
+		UsedBy:  "-portable-formats 7z,sfx",
+		Detect:  detect7zPathMust,
+		Install: []string{"winget", "install", "--id", "7zip.7zip", "--version", "24.08", "--exact", "--silent", "--accept-package-agreements", "--accept-source-agreements"},
+	},
+}
+
+func getToolCacheDir() string {
+	dir := os.Getenv("LOCALAPPDATA")
+	if dir == "" {
+		dir = os.TempDir()
+	}
+	return filepath.Join(dir, "sumatrapdf-do", "bin")
+}
+
+// adds tool cache to PATH so that tools installed there are found
+func initToolCache() {
+	path := os.Getenv("PATH")
+	os.Setenv("PATH", getToolCacheDir()+string(os.PathListSeparator)+path)
+}
+
+func lookPathMust(name string) string {
+	path, err := exec.LookPath(name)
+	failIf(errKindToolchain, err != nil, "%s not found in PATH, install it with: do doctor", name)
+	return path
+}
+
+// returns path of the tool or "" if not installed
+func detectHelperTool(t *HelperTool) (path string) {
+	defer func() {
+		if r := recover(); r != nil {
+			path = ""
+		}
+	}()
+	return t.Detect()
+}
+
+func installHelperToolMust(t *HelperTool) {
+	cmd := exec.Command(t.Install[0], t.Install[1:]...)
+	if t.Install[0] == "go" {
+		cacheDir := createDirMust(getToolCacheDir())
+		cmd.Env = append(os.Environ(), "GOBIN="+cacheDir)
+	}
+	runCmdLoggedMust(cmd)
+	path := detectHelperTool(t)
+	failIf(errKindToolchain, path == "", "installed %s but still can't find it", t.Name)
+	logf("installed %s: %s\n", t.Name, path)
+}
+
+func doctor() {
+	var missing []*HelperTool
+	for _, t := range helperTools {
+		if path := detectHelperTool(t); path != "" {
+			logf("%s: %s\n", t.Name, path)
+			continue
+		}
+		logf("%s: not installed (needed by %s)\n", t.Name, t.UsedBy)
+		missing = append(missing, t)
+	}
+	var notInstalled []string
+	for _, t := range missing {
+		if !flgYes {
+			answer := askUser(fmt.Sprintf("install %s with '%s'? [y/N]", t.Name, strings.Join(t.Install, " ")))
+			if answer != "y" {
+				notInstalled = append(notInstalled, t.Name)
+				continue
+			}
+		}
+		installHelperToolMust(t)
+	}
+	if len(notInstalled) > 0 {
+		logf("not installed: %s\n", strings.Join(notInstalled, ", "))
+	}
+	checkToolchainMust()
+	preflightMust()
+}
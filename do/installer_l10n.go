@@ -0,0 +1,150 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// do check installer-l10n checks localization of the installer:
+// - translations embedded in the installer (RCDATA 2, translations-good.txt)
+//   contain every translation of installer UI strings that translations.txt
+//   has for embedded languages
+// - every embedded language can be selected i.e. is in gLangs
+// - silent install with -lang in Windows Sandbox for a sample of languages
+//   picks that language (checked in installer log)
+
+// translations are loaded from this RCDATA resource, see SumatraPDF.rc
+const translationsResourceID = 2
+
+// sources with installer / uninstaller UI strings
+var installerSourceFiles = []string{
+	"Installer.cpp",
+	"InstallerCommon.cpp",
+	"RegistryInstaller.cpp",
+	"Uninstaller.cpp",
+}
+
+// sample of languages tested with silent install: ltr, rtl, cjk and
+// one with sub-language code
+var installerTestLangs = []string{"de", "ar", "ja", "pt", "sr-rs"}
+
+func getInstallerStringsMust() []string {
+	var res []string
+	for _, name := range installerSourceFiles {
+		res = append(res, extractStringsFromCFile(filepath.Join("src", name))...)
+	}
+	res = uniquifyStrings(res)
+	sort.Strings(res)
+	return res
+}
+
+// lang => english => translation
+func translationsByLang(m map[string][]*Translation) map[string]map[string]string {
+	res := map[string]map[string]string{}
+	for s, trs := range m {
+		for _, tr := range trs {
+			if res[tr.Lang] == nil {
+				res[tr.Lang] = map[string]string{}
+			}
+			res[tr.Lang][s] = tr.Translation
+		}
+	}
+	return res
+}
+
+// returns problems and coverage report
+func checkInstallerTranslations(strs []string, embedded, all map[string]map[string]string, langCodes map[string]bool) ([]string, string) {
+	var problems []string
+	var langs []string
+	for lang := range embedded {
+		langs = append(langs, lang)
+	}
+	sort.Strings(langs)
+	report := fmt.Sprintf("%d installer strings, %d embedded languages\n", len(strs), len(langs))
+	for _, lang := range langs {
+		if !langCodes[lang] {
+			problems = append(problems, fmt.Sprintf("%s: embedded but not in gLangs so can't be selected", lang))
+		}
+		nTranslated := 0
+		for _, s := range strs {
+			if embedded[lang][s] != "" {
+				nTranslated++
+				continue
+			}
+			if all[lang][s] != "" {
+				problems = append(problems, fmt.Sprintf("%s: translation of '%s' in translations.txt but not in installer", lang, s))
+			}
+		}
+		report += fmt.Sprintf("  %-6s %d / %d\n", lang, nTranslated, len(strs))
+	}
+	return problems, report
+}
+
+func getEmbeddedTranslationsMust(exePath string) map[string]map[string]string {
+	d, err := peFindResource(readFileMust(exePath), peResourceTypeRCData, translationsResourceID)
+	panicIf(err != nil, "failed to find translations in '%s': %s", exePath, err)
+	s := strings.ReplaceAll(string(d), "\r\n", "\n")
+	return translationsByLang(parseTranslations(s))
+}
+
+const testInstallLangsPs1Tmpl = `$ErrorActionPreference = "Continue"
+$errors = @()
+$installDir = "C:\SumatraTest"
+$log = Join-Path $env:TEMP "sumatra-install-log.txt"
+
+foreach ($lang in @({{.Langs}})) {
+    $p = Start-Process -FilePath "C:\test\SumatraPDF-dll.exe" -ArgumentList "-install","-silent","-log","-lang",$lang,"-install-dir",$installDir -Wait -PassThru
+    if ($p.ExitCode -ne 0) { $errors += "${lang}: install exit code: $($p.ExitCode)" }
+    $s = Get-Content -Raw -ErrorAction SilentlyContinue $log
+    if (-not ($s -like "*installer language: '$lang'*")) { $errors += "${lang}: language not selected, log: $log" }
+    $p = Start-Process -FilePath (Join-Path $installDir "SumatraPDF.exe") -ArgumentList "-uninstall","-silent" -Wait -PassThru
+    Start-Sleep -Seconds 5
+}
+
+if ($errors.Count -eq 0) {
+    Set-Content -Path "C:\test\result.txt" -Value "OK"
+} else {
+    Set-Content -Path "C:\test\result.txt" -Value ($errors -join "` + "`" + `n")
+}
+shutdown /s /t 0
+`
+
+func testInstallerLangsInSandbox(installerPath string) {
+	testDir := absPathMust(filepath.Join("out", "installer-l10n-test"))
+	must(os.RemoveAll(testDir))
+	createDirMust(testDir)
+	must(copyFile(filepath.Join(testDir, "SumatraPDF-dll.exe"), installerPath))
+	var quoted []string
+	for _, lang := range installerTestLangs {
+		quoted = append(quoted, `"`+lang+`"`)
+	}
+	ps1 := execTextTemplate(testInstallLangsPs1Tmpl, map[string]string{"Langs": strings.Join(quoted, ",")})
+	writeFileMust(filepath.Join(testDir, "test-install-langs.ps1"), []byte(ps1))
+	runTestScriptInSandboxMust(testDir, "test-install-langs.ps1")
+}
+
+func verifyInstallerL10n(dir string) {
+	installerPath := filepath.Join(dir, "SumatraPDF-dll.exe")
+	panicIf(!fileExists(installerPath), "'%s' doesn't exist", installerPath)
+
+	strs := getInstallerStringsMust()
+	embedded := getEmbeddedTranslationsMust(installerPath)
+	all := translationsByLang(parseTranslations(string(readFileMust(translationsTxtPath))))
+	langCodes := map[string]bool{}
+	for _, lang := range gLangs {
+		langCodes[lang[0]] = true
+	}
+	problems, report := checkInstallerTranslations(strs, embedded, all, langCodes)
+	logf("%s", report)
+	for _, lang := range installerTestLangs {
+		if embedded[lang] == nil {
+			problems = append(problems, fmt.Sprintf("%s: tested language is not embedded in installer", lang))
+		}
+	}
+	panicIf(len(problems) > 0, "installer localization problems:\n%s\n", strings.Join(problems, "\n"))
+	logf("installer translations ok\n")
+	testInstallerLangsInSandbox(installerPath)
+}
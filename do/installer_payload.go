@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/crc32"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// SumatraPDF-dll.exe is an installer that has libmupdf.dll, PdfFilter.dll
+// and PdfPreview.dll in .lzsa archive embedded as RCDATA resource with id 1
+// (see INSTALL_PAYLOAD_ZIP in SumatraPDF.rc)
+// the format is described in src/utils/LzmaSimpleArchive.cpp
+
+const (
+	lzsaMagicID              = 0x41537a4c // 'LzSA'
+	lzsaFileEntryMinSize     = 4*4 + 8 + 1
+	installerPayloadResource = 1
+)
+
+// LzsaFile is a file in .lzsa archive
+type LzsaFile struct {
+	Name             string
+	CompressedSize   uint32
+	UncompressedSize uint32
+	Crc32            uint32
+	Modified         time.Time
+	compressed       []byte
+}
+
+// converts Windows FILETIME (100-nanosecond intervals since 1601-01-01)
+func fileTimeToTime(low, high uint32) time.Time {
+	ft := int64(high)<<32 | int64(low)
+	// difference between 1601 and 1970 in 100-nanosecond intervals
+	const epochDiff = 116444736000000000
+	return time.Unix(0, (ft-epochDiff)*100).UTC()
+}
+
+func parseLzsaArchive(d []byte) ([]*LzsaFile, error) {
+	if len(d) < 8 || binary.LittleEndian.Uint32(d) != lzsaMagicID {
+		return nil, fmt.Errorf("not an lzsa archive")
+	}
+	nFiles := int(binary.LittleEndian.Uint32(d[4:]))
+	off := 8
+	var res []*LzsaFile
+	for i := 0; i < nFiles; i++ {
+		if off+lzsaFileEntryMinSize > len(d) {
+			return nil, fmt.Errorf("truncated header of file %d", i)
+		}
+		headerSize := int(binary.LittleEndian.Uint32(d[off:]))
+		if headerSize < lzsaFileEntryMinSize || headerSize > 1024 || off+headerSize > len(d) {
+			return nil, fmt.Errorf("invalid header size %d of file %d", headerSize, i)
+		}
+		if d[off+headerSize-1] != 0 {
+			return nil, fmt.Errorf("name of file %d is not 0-terminated", i)
+		}
+		f := &LzsaFile{
+			CompressedSize:   binary.LittleEndian.Uint32(d[off+4:]),
+			UncompressedSize: binary.LittleEndian.Uint32(d[off+8:]),
+			Crc32:            binary.LittleEndian.Uint32(d[off+12:]),
+			Modified:         fileTimeToTime(binary.LittleEndian.Uint32(d[off+16:]), binary.LittleEndian.Uint32(d[off+20:])),
+			Name:             string(d[off+24 : off+headerSize-1]),
+		}
+		res = append(res, f)
+		off += headerSize
+	}
+	if off+4 > len(d) {
+		return nil, fmt.Errorf("truncated header")
+	}
+	headerCrc := binary.LittleEndian.Uint32(d[off:])
+	if realCrc := crc32.ChecksumIEEE(d[:off]); realCrc != headerCrc {
+		return nil, fmt.Errorf("header crc32 mismatch: 0x%08x vs. 0x%08x", realCrc, headerCrc)
+	}
+	off += 4
+	for _, f := range res {
+		end := off + int(f.CompressedSize)
+		if end > len(d) {
+			return nil, fmt.Errorf("truncated data of '%s'", f.Name)
+		}
+		f.compressed = d[off:end]
+		off = end
+	}
+	if off != len(d) {
+		return nil, fmt.Errorf("%d bytes of unexpected data at the end", len(d)-off)
+	}
+	return res, nil
+}
+
+// first byte of compressed data is compression method:
+// 0 is lzma, 1 is lzma + x86 (BCJ) filter, 0xff is stored
+func (f *LzsaFile) Uncompress() ([]byte, error) {
+	d := f.compressed
+	if len(d) < 1 {
+		return nil, fmt.Errorf("'%s': no data", f.Name)
+	}
+	var res []byte
+	switch method := d[0]; method {
+	case 0xff:
+		res = d[1:]
+	case 0, 1:
+		var err error
+		res, err = lzmaDecode(d[1:], int(f.UncompressedSize))
+		if err != nil {
+			return nil, fmt.Errorf("'%s': %w", f.Name, err)
+		}
+		if method == 1 {
+			x86BcjDecode(res)
+		}
+	default:
+		return nil, fmt.Errorf("'%s': unknown compression method %d", f.Name, method)
+	}
+	if len(res) != int(f.UncompressedSize) {
+		return nil, fmt.Errorf("'%s': size is %d, expected %d", f.Name, len(res), f.UncompressedSize)
+	}
+	if realCrc := crc32.ChecksumIEEE(res); realCrc != f.Crc32 {
+		return nil, fmt.Errorf("'%s': crc32 mismatch: 0x%08x vs. 0x%08x", f.Name, realCrc, f.Crc32)
+	}
+	return res, nil
+}
+
+// path can be the installer or .lzsa archive itself (e.g. InstallerData.dat)
+func loadInstallerPayloadMust(path string) []*LzsaFile {
+	d := readFileMust(path)
+	if len(d) < 4 || binary.LittleEndian.Uint32(d) != lzsaMagicID {
+		var err error
+		d, err = peFindResource(d, peResourceTypeRCData, installerPayloadResource)
+		panicIf(err != nil, "'%s': no installer payload: %s", path, err)
+	}
+	files, err := parseLzsaArchive(d)
+	panicIf(err != nil, "'%s': failed to parse installer payload: %s", path, err)
+	return files
+}
+
+func installerPayloadList(path string) {
+	files := loadInstallerPayloadMust(path)
+	logf("%d files in '%s':\n", len(files), path)
+	var total, totalCompressed int64
+	for _, f := range files {
+		d, err := f.Uncompress()
+		must(err)
+		logf("%-16s %10s %10s %s %s\n", f.Name, formatSize(int64(f.UncompressedSize)), formatSize(int64(f.CompressedSize)), f.Modified.Format(time.RFC3339), sha256Hex(d))
+		total += int64(f.UncompressedSize)
+		totalCompressed += int64(f.CompressedSize)
+	}
+	logf("%-16s %10s %10s\n", "total", formatSize(total), formatSize(totalCompressed))
+}
+
+func installerPayloadExtract(path string, dstDir string) {
+	files := loadInstallerPayloadMust(path)
+	createDirMust(dstDir)
+	for _, f := range files {
+		d, err := f.Uncompress()
+		must(err)
+		dstPath := filepath.Join(dstDir, filepath.Base(f.Name))
+		writeFileMust(dstPath, d)
+		must(os.Chtimes(dstPath, f.Modified, f.Modified))
+		logf("extracted %s (%s)\n", dstPath, formatSize(int64(len(d))))
+	}
+}
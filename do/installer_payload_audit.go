@@ -0,0 +1,118 @@
+package main
+
+import (
+	"bytes"
+	"debug/pe"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// we want to fail the build if unexpected files (debug dlls, test data)
+// get into the installer. the list of expected files is in
+// do/installer_payload_expected.txt
+
+// ExpectedPayloadFile is a line in installer_payload_expected.txt
+type ExpectedPayloadFile struct {
+	Name    string
+	MinSize int64
+	MaxSize int64
+	Kind    string
+}
+
+func parseExpectedPayloadMust(s string) []*ExpectedPayloadFile {
+	var res []*ExpectedPayloadFile
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(line)
+		panicIf(len(parts) != 4, "invalid line '%s' in expected payload", line)
+		minKB, err := strconv.ParseInt(parts[1], 10, 64)
+		must(err)
+		maxKB, err := strconv.ParseInt(parts[2], 10, 64)
+		must(err)
+		panicIf(parts[3] != "pe", "unknown kind '%s' in line '%s'", parts[3], line)
+		f := &ExpectedPayloadFile{
+			Name:    parts[0],
+			MinSize: minKB * 1024,
+			MaxSize: maxKB * 1024,
+			Kind:    parts[3],
+		}
+		res = append(res, f)
+	}
+	return res
+}
+
+func peMachineForPlatform(platform string) uint16 {
+	switch platform {
+	case kPlatformIntel32:
+		return pe.IMAGE_FILE_MACHINE_I386
+	case kPlatformIntel64:
+		return pe.IMAGE_FILE_MACHINE_AMD64
+	case kPlatformArm64:
+		return pe.IMAGE_FILE_MACHINE_ARM64
+	}
+	panicIf(true, "unsupported platform '%s'", platform)
+	return 0
+}
+
+func auditPayloadFile(f *LzsaFile, exp *ExpectedPayloadFile, machine uint16) []string {
+	var errs []string
+	size := int64(f.UncompressedSize)
+	if size < exp.MinSize || size > exp.MaxSize {
+		errs = append(errs, fmt.Sprintf("%s: size %s outside of expected range %s - %s", f.Name, formatSize(size), formatSize(exp.MinSize), formatSize(exp.MaxSize)))
+	}
+	d, err := f.Uncompress()
+	if err != nil {
+		return append(errs, err.Error())
+	}
+	pf, err := pe.NewFile(bytes.NewReader(d))
+	if err != nil {
+		return append(errs, fmt.Sprintf("%s: not a PE file: %s", f.Name, err))
+	}
+	defer pf.Close()
+	if pf.Machine != machine {
+		errs = append(errs, fmt.Sprintf("%s: machine is 0x%x, expected 0x%x", f.Name, pf.Machine, machine))
+	}
+	return errs
+}
+
+func auditInstallerPayload(installerPath string, platform string, expected []*ExpectedPayloadFile) []string {
+	files := loadInstallerPayloadMust(installerPath)
+	machine := peMachineForPlatform(platform)
+	var errs []string
+	seen := map[string]bool{}
+	for _, f := range files {
+		seen[strings.ToLower(f.Name)] = true
+		var exp *ExpectedPayloadFile
+		for _, e := range expected {
+			if strings.EqualFold(e.Name, f.Name) {
+				exp = e
+			}
+		}
+		if exp == nil {
+			errs = append(errs, fmt.Sprintf("%s: unexpected file (%s)", f.Name, formatSize(int64(f.UncompressedSize))))
+			continue
+		}
+		errs = append(errs, auditPayloadFile(f, exp, machine)...)
+	}
+	for _, e := range expected {
+		if !seen[strings.ToLower(e.Name)] {
+			errs = append(errs, fmt.Sprintf("%s: missing", e.Name))
+		}
+	}
+	return errs
+}
+
+func auditInstallerPayloadMust(dir string, platform string) {
+	defer failOnPanic(errKindVerify)
+	installerPath := filepath.Join(dir, "SumatraPDF-dll.exe")
+	expectedPath := filepath.Join("do", "installer_payload_expected.txt")
+	expected := parseExpectedPayloadMust(string(readFileMust(expectedPath)))
+	errs := auditInstallerPayload(installerPath, platform, expected)
+	panicIf(len(errs) > 0, "installer payload of '%s' doesn't match '%s':\n%s\n", installerPath, expectedPath, strings.Join(errs, "\n"))
+	logf("installer payload of '%s' matches '%s'\n", installerPath, expectedPath)
+}
@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// by default the first failure aborts everything. With -keep-going
+// independent steps (building each platform, uploading to each storage)
+// run to completion even if one of them fails. Failures are collected and
+// reported together by failIfStepsFailed, which is called before steps
+// that depend on all of them (e.g. signing needs all platforms built)
+
+var flgKeepGoing bool
+
+var (
+	failedSteps   []*DoError
+	failedStepsMu sync.Mutex
+)
+
+// runs fn. With -keep-going failure of fn is recorded instead of aborting
+// safe to call from multiple goroutines
+func runIndependentStep(name string, fn func()) {
+	if !flgKeepGoing {
+		fn()
+		return
+	}
+	defer func() {
+		r := recover()
+		if r == nil {
+			return
+		}
+		e, ok := r.(*DoError)
+		if !ok {
+			e = &DoError{Kind: errKindOther, Msg: fmt.Sprint(r)}
+		}
+		e = &DoError{Kind: e.Kind, Msg: name + ": " + e.Msg}
+		logf("step '%s' failed, continuing because of -keep-going: %s\n", name, e.Msg)
+		failedStepsMu.Lock()
+		failedSteps = append(failedSteps, e)
+		failedStepsMu.Unlock()
+	}()
+	fn()
+}
+
+// panics if any independent step failed. Exit code is that of the
+// first failure
+func failIfStepsFailed() {
+	failedStepsMu.Lock()
+	failed := failedSteps
+	failedSteps = nil
+	failedStepsMu.Unlock()
+	if len(failed) == 0 {
+		return
+	}
+	var msgs []string
+	for _, e := range failed {
+		msgs = append(msgs, "  "+e.Error())
+	}
+	msg := fmt.Sprintf("%d steps failed:\n%s", len(failed), strings.Join(msgs, "\n"))
+	panic(&DoError{Kind: failed[0].Kind, Msg: msg})
+}
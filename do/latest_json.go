@@ -0,0 +1,58 @@
+package main
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// software/sumatrapdf/latest.json describes the latest build in each channel
+// for third-party updaters and package maintainers. It's updated with every
+// upload of a release or pre-release build.
+// Don't remove or rename fields, only add new ones. Breaking changes
+// require bumping latestJSONSchemaVersion
+
+const (
+	latestJSONRemotePath    = "software/sumatrapdf/latest.json"
+	latestJSONSchemaVersion = 1
+	releaseNotesURL         = "https://www.sumatrapdfreader.org/docs/Version-history"
+)
+
+// LatestJSON is the content of latest.json
+type LatestJSON struct {
+	SchemaVersion   int                          `json:"schemaVersion"`
+	Updated         time.Time                    `json:"updated"`
+	ReleaseNotesURL string                       `json:"releaseNotesUrl"`
+	Channels        map[string]*ChannelDownloads `json:"channels"`
+}
+
+func latestJSONChannel(buildType BuildType) string {
+	if buildType == buildTypeRel {
+		return "stable"
+	}
+	return "prerelease"
+}
+
+// updates the channel of buildType, keeps the other channels
+func publishLatestJSONMust(buildType BuildType) {
+	mcR2 := newMinioR2Client()
+	doc := &LatestJSON{}
+	if mcR2.Exists(latestJSONRemotePath) {
+		must(json.Unmarshal(minioDownloadDataMust(mcR2, latestJSONRemotePath), doc))
+	}
+	if doc.Channels == nil {
+		doc.Channels = map[string]*ChannelDownloads{}
+	}
+	doc.SchemaVersion = latestJSONSchemaVersion
+	doc.Updated = time.Now().UTC()
+	doc.ReleaseNotesURL = releaseNotesURL
+	ver := getVerForBuildType(buildType)
+	doc.Channels[latestJSONChannel(buildType)] = getChannelDownloadsMust(mcR2, buildType, ver)
+
+	d, err := json.MarshalIndent(doc, "", "  ")
+	must(err)
+	for _, mc := range []Storage{mcR2, newMinioBackblazeClient()} {
+		_, err := mc.UploadData(latestJSONRemotePath, d, true)
+		must(err)
+		logf("Uploaded '%s'\n", mc.URLForPath(latestJSONRemotePath))
+	}
+}
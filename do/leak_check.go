@@ -0,0 +1,196 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// runs test scenarios under Dr. Memory, parses leak reports and compares
+// them with do/leaks_baseline.txt. Fails if there are new definite leaks
+// use -update-baseline to re-create the baseline
+// Dr. Memory must be installed and drmemory.exe in %PATH% or pinned
+// in do/downloads.txt (see downloadPinnedMust)
+
+const leakSignatureFrames = 3
+
+// LeakScenario is a program we run under Dr. Memory
+type LeakScenario struct {
+	Name string
+	Exe  string
+	Args []string
+}
+
+// DrMemLeak is a leak reported by Dr. Memory
+type DrMemLeak struct {
+	// "LEAK" or "POSSIBLE LEAK"
+	Kind      string
+	Bytes     int
+	Frames    []string
+	Signature string
+}
+
+var (
+	// Error #1: LEAK 40 direct bytes 0x00f1c4a8-0x00f1c4d0 + 0 indirect bytes
+	rxDrMemLeak = regexp.MustCompile(`^Error #\d+: (LEAK|POSSIBLE LEAK) (\d+) direct bytes`)
+	// # 1 SumatraPDF.exe!str::Dup   [C:\...\StrUtil.cpp:100]
+	rxDrMemFrame = regexp.MustCompile(`^#\s*\d+\s+(\S+)`)
+)
+
+// frames inside allocators are the same for all leaks
+func isAllocatorFrame(frame string) bool {
+	for _, s := range []string{"replace_", "!operator new", "!malloc", "!calloc", "!realloc", "Allocator::", "AllocArray", "AllocStruct"} {
+		if strings.Contains(frame, s) {
+			return true
+		}
+	}
+	return false
+}
+
+func parseDrMemResults(s string) []*DrMemLeak {
+	var res []*DrMemLeak
+	var curr *DrMemLeak
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if m := rxDrMemLeak.FindStringSubmatch(line); m != nil {
+			n, _ := strconv.Atoi(m[2])
+			curr = &DrMemLeak{Kind: m[1], Bytes: n}
+			res = append(res, curr)
+			continue
+		}
+		if curr == nil {
+			continue
+		}
+		if line == "" {
+			curr = nil
+			continue
+		}
+		if m := rxDrMemFrame.FindStringSubmatch(line); m != nil {
+			curr.Frames = append(curr.Frames, m[1])
+		}
+	}
+	for _, l := range res {
+		var sig []string
+		for _, f := range l.Frames {
+			if isAllocatorFrame(f) {
+				continue
+			}
+			sig = append(sig, f)
+			if len(sig) == leakSignatureFrames {
+				break
+			}
+		}
+		l.Signature = strings.Join(sig, " <- ")
+	}
+	return res
+}
+
+func runUnderDrMemoryMust(sc *LeakScenario, logDir string) []*DrMemLeak {
+	must(os.RemoveAll(logDir))
+	createDirMust(logDir)
+	args := []string{"-batch", "-leaks_only", "-suppress", "drmem-sup.txt", "-logdir", logDir, "--", sc.Exe}
+	args = append(args, sc.Args...)
+	cmd := exec.Command(detectDrMemoryMust(), args...)
+	// the program might exit with non-zero code, we only care about the leaks
+	logf("> %s\n", cmd.String())
+	_ = cmd.Run()
+
+	var res []*DrMemLeak
+	err := filepath.Walk(logDir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Name() == "results.txt" {
+			res = append(res, parseDrMemResults(string(readFileMust(path)))...)
+		}
+		return err
+	})
+	must(err)
+	return res
+}
+
+func parseLeaksBaseline(s string) map[string]int {
+	res := map[string]int{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		// count scenario: signature
+		parts := strings.SplitN(line, " ", 2)
+		if len(parts) != 2 {
+			continue
+		}
+		n, err := strconv.Atoi(parts[0])
+		panicIf(err != nil, "invalid line '%s' in leaks baseline", line)
+		res[parts[1]] = n
+	}
+	return res
+}
+
+func checkLeaks(dir string, updateBaseline bool) {
+	outDir := absPathMust(filepath.Join("out", "leak-check"))
+	must(os.RemoveAll(outDir))
+	createDirMust(outDir)
+	samplePath := filepath.Join(outDir, "sample.pdf")
+	writeFileMust(samplePath, genTestPdfPages([]string{"page 1", "page 2", "page 3"}))
+
+	scenarios := []*LeakScenario{
+		{Name: "test_util", Exe: filepath.Join(dir, "test_util.exe")},
+		{Name: "bench", Exe: filepath.Join(dir, "SumatraPDF.exe"), Args: []string{"-bench", samplePath}},
+	}
+
+	// "scenario: signature" => number of definite leaks
+	current := map[string]int{}
+	nPossible := 0
+	for _, sc := range scenarios {
+		panicIf(!fileExists(sc.Exe), "'%s' doesn't exist", sc.Exe)
+		leaks := runUnderDrMemoryMust(sc, filepath.Join(outDir, sc.Name))
+		for _, l := range leaks {
+			if l.Kind != "LEAK" {
+				nPossible++
+				continue
+			}
+			current[sc.Name+": "+l.Signature]++
+		}
+		logf("%s: %d leaks\n", sc.Name, len(leaks))
+	}
+	logf("ignoring %d possible leaks\n", nPossible)
+
+	var keys []string
+	for k := range current {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	baselinePath := filepath.Join("do", "leaks_baseline.txt")
+	if updateBaseline {
+		s := "# known definite leaks as reported by Dr. Memory, re-generate with: do check leaks -update-baseline\n"
+		for _, k := range keys {
+			s += fmt.Sprintf("%d %s\n", current[k], k)
+		}
+		writeFileMust(baselinePath, []byte(s))
+		logf("wrote '%s' with %d leak signatures\n", baselinePath, len(keys))
+		return
+	}
+
+	var baseline map[string]int
+	if fileExists(baselinePath) {
+		baseline = parseLeaksBaseline(string(readFileMust(baselinePath)))
+	}
+	var errs []string
+	for _, k := range keys {
+		if n := current[k]; n > baseline[k] {
+			errs = append(errs, fmt.Sprintf("%d new: %s", n-baseline[k], k))
+		}
+	}
+	for k := range baseline {
+		if current[k] == 0 {
+			logf("fixed: %s (update the baseline)\n", k)
+		}
+	}
+	panicIf(len(errs) > 0, "new leaks (reports in '%s'):\n%s\n", outDir, strings.Join(errs, "\n"))
+	logf("no new leaks, reports in '%s'\n", outDir)
+}
@@ -0,0 +1,413 @@
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+)
+
+// minimal LZMA decoder, ported from LzmaSpec.cpp in lzma sdk
+// we only need it to decompress files from .lzsa archives so it decodes
+// the whole stream into memory and requires the end marker
+
+const (
+	lzmaNumBitModelTotalBits = 11
+	lzmaBitModelTotal        = 1 << lzmaNumBitModelTotalBits
+	lzmaNumMoveBits          = 5
+	lzmaProbInit             = lzmaBitModelTotal / 2
+
+	lzmaNumStates          = 12
+	lzmaNumPosBitsMax      = 4
+	lzmaNumLenToPosStates  = 4
+	lzmaNumAlignBits       = 4
+	lzmaStartPosModelIndex = 4
+	lzmaEndPosModelIndex   = 14
+	lzmaNumFullDistances   = 1 << (lzmaEndPosModelIndex >> 1)
+	lzmaMatchMinLen        = 2
+)
+
+var errLzmaCorrupted = errors.New("lzma: corrupted data")
+
+type lzmaRangeDecoder struct {
+	d         []byte
+	pos       int
+	rng       uint32
+	code      uint32
+	corrupted bool
+}
+
+func (rc *lzmaRangeDecoder) nextByte() byte {
+	if rc.pos >= len(rc.d) {
+		rc.corrupted = true
+		return 0
+	}
+	b := rc.d[rc.pos]
+	rc.pos++
+	return b
+}
+
+func (rc *lzmaRangeDecoder) init() bool {
+	rc.rng = 0xFFFFFFFF
+	b := rc.nextByte()
+	for i := 0; i < 4; i++ {
+		rc.code = (rc.code << 8) | uint32(rc.nextByte())
+	}
+	return b == 0 && rc.code != rc.rng
+}
+
+func (rc *lzmaRangeDecoder) isFinishedOK() bool {
+	return rc.code == 0
+}
+
+func (rc *lzmaRangeDecoder) normalize() {
+	if rc.rng < 1<<24 {
+		rc.rng <<= 8
+		rc.code = (rc.code << 8) | uint32(rc.nextByte())
+	}
+}
+
+func (rc *lzmaRangeDecoder) decodeDirectBits(numBits int) uint32 {
+	var res uint32
+	for ; numBits > 0; numBits-- {
+		rc.rng >>= 1
+		rc.code -= rc.rng
+		t := 0 - (rc.code >> 31)
+		rc.code += rc.rng & t
+		if rc.code == rc.rng {
+			rc.corrupted = true
+		}
+		rc.normalize()
+		res = (res << 1) + (t + 1)
+	}
+	return res
+}
+
+func (rc *lzmaRangeDecoder) decodeBit(prob *uint16) uint32 {
+	v := uint32(*prob)
+	bound := (rc.rng >> lzmaNumBitModelTotalBits) * v
+	var symbol uint32
+	if rc.code < bound {
+		v += (lzmaBitModelTotal - v) >> lzmaNumMoveBits
+		rc.rng = bound
+		symbol = 0
+	} else {
+		v -= v >> lzmaNumMoveBits
+		rc.code -= bound
+		rc.rng -= bound
+		symbol = 1
+	}
+	*prob = uint16(v)
+	rc.normalize()
+	return symbol
+}
+
+func newLzmaProbs(n int) []uint16 {
+	res := make([]uint16, n)
+	for i := range res {
+		res[i] = lzmaProbInit
+	}
+	return res
+}
+
+func lzmaBitTreeDecode(probs []uint16, numBits int, rc *lzmaRangeDecoder) uint32 {
+	m := uint32(1)
+	for i := 0; i < numBits; i++ {
+		m = (m << 1) + rc.decodeBit(&probs[m])
+	}
+	return m - (uint32(1) << numBits)
+}
+
+func lzmaBitTreeReverseDecode(probs []uint16, numBits int, rc *lzmaRangeDecoder) uint32 {
+	m := uint32(1)
+	var symbol uint32
+	for i := 0; i < numBits; i++ {
+		bit := rc.decodeBit(&probs[m])
+		m = (m << 1) + bit
+		symbol |= bit << i
+	}
+	return symbol
+}
+
+type lzmaLenDecoder struct {
+	choice  uint16
+	choice2 uint16
+	low     [1 << lzmaNumPosBitsMax][]uint16
+	mid     [1 << lzmaNumPosBitsMax][]uint16
+	high    []uint16
+}
+
+func newLzmaLenDecoder() *lzmaLenDecoder {
+	ld := &lzmaLenDecoder{
+		choice:  lzmaProbInit,
+		choice2: lzmaProbInit,
+		high:    newLzmaProbs(1 << 8),
+	}
+	for i := range ld.low {
+		ld.low[i] = newLzmaProbs(1 << 3)
+		ld.mid[i] = newLzmaProbs(1 << 3)
+	}
+	return ld
+}
+
+func (ld *lzmaLenDecoder) decode(rc *lzmaRangeDecoder, posState uint32) uint32 {
+	if rc.decodeBit(&ld.choice) == 0 {
+		return lzmaBitTreeDecode(ld.low[posState], 3, rc)
+	}
+	if rc.decodeBit(&ld.choice2) == 0 {
+		return 8 + lzmaBitTreeDecode(ld.mid[posState], 3, rc)
+	}
+	return 16 + lzmaBitTreeDecode(ld.high, 8, rc)
+}
+
+// lzmaDecode decodes LZMA stream with 5 byte properties header followed
+// by compressed data terminated with end marker
+func lzmaDecode(d []byte, uncompressedSize int) ([]byte, error) {
+	if len(d) < 5 {
+		return nil, fmt.Errorf("lzma: data too short")
+	}
+	props := uint32(d[0])
+	if props >= 9*5*5 {
+		return nil, fmt.Errorf("lzma: invalid properties 0x%x", props)
+	}
+	lc := props % 9
+	props /= 9
+	lp := props % 5
+	pb := props / 5
+	dictSize := binary.LittleEndian.Uint32(d[1:5])
+
+	rc := &lzmaRangeDecoder{d: d[5:]}
+	if !rc.init() {
+		return nil, errLzmaCorrupted
+	}
+
+	out := make([]byte, 0, uncompressedSize)
+	literalProbs := newLzmaProbs(0x300 << (lc + lp))
+	posSlot := make([][]uint16, lzmaNumLenToPosStates)
+	for i := range posSlot {
+		posSlot[i] = newLzmaProbs(1 << 6)
+	}
+	posDecoders := newLzmaProbs(1 + lzmaNumFullDistances - lzmaEndPosModelIndex)
+	alignDecoder := newLzmaProbs(1 << lzmaNumAlignBits)
+	isMatch := newLzmaProbs(lzmaNumStates << lzmaNumPosBitsMax)
+	isRep := newLzmaProbs(lzmaNumStates)
+	isRepG0 := newLzmaProbs(lzmaNumStates)
+	isRepG1 := newLzmaProbs(lzmaNumStates)
+	isRepG2 := newLzmaProbs(lzmaNumStates)
+	isRep0Long := newLzmaProbs(lzmaNumStates << lzmaNumPosBitsMax)
+	lenDecoder := newLzmaLenDecoder()
+	repLenDecoder := newLzmaLenDecoder()
+
+	decodeLiteral := func(state uint32, rep0 uint32) {
+		var prevByte uint32
+		if len(out) > 0 {
+			prevByte = uint32(out[len(out)-1])
+		}
+		totalPos := uint32(len(out))
+		litState := ((totalPos & ((1 << lp) - 1)) << lc) + (prevByte >> (8 - lc))
+		probs := literalProbs[0x300*litState:]
+		symbol := uint32(1)
+		if state >= 7 {
+			matchByte := uint32(out[len(out)-int(rep0)-1])
+			for symbol < 0x100 {
+				matchBit := (matchByte >> 7) & 1
+				matchByte <<= 1
+				bit := rc.decodeBit(&probs[((1+matchBit)<<8)+symbol])
+				symbol = (symbol << 1) | bit
+				if matchBit != bit {
+					break
+				}
+			}
+		}
+		for symbol < 0x100 {
+			symbol = (symbol << 1) | rc.decodeBit(&probs[symbol])
+		}
+		out = append(out, byte(symbol-0x100))
+	}
+
+	decodeDistance := func(length uint32) uint32 {
+		lenState := length
+		if lenState > lzmaNumLenToPosStates-1 {
+			lenState = lzmaNumLenToPosStates - 1
+		}
+		slot := lzmaBitTreeDecode(posSlot[lenState], 6, rc)
+		if slot < 4 {
+			return slot
+		}
+		numDirectBits := int((slot >> 1) - 1)
+		dist := (2 | (slot & 1)) << numDirectBits
+		if slot < lzmaEndPosModelIndex {
+			dist += lzmaBitTreeReverseDecode(posDecoders[dist-slot:], numDirectBits, rc)
+		} else {
+			dist += rc.decodeDirectBits(numDirectBits-lzmaNumAlignBits) << lzmaNumAlignBits
+			dist += lzmaBitTreeReverseDecode(alignDecoder, lzmaNumAlignBits, rc)
+		}
+		return dist
+	}
+
+	var rep0, rep1, rep2, rep3 uint32
+	var state uint32
+	for {
+		if rc.corrupted {
+			return nil, errLzmaCorrupted
+		}
+		posState := uint32(len(out)) & ((1 << pb) - 1)
+		if rc.decodeBit(&isMatch[(state<<lzmaNumPosBitsMax)+posState]) == 0 {
+			if len(out) >= uncompressedSize {
+				return nil, errLzmaCorrupted
+			}
+			decodeLiteral(state, rep0)
+			switch {
+			case state < 4:
+				state = 0
+			case state < 10:
+				state -= 3
+			default:
+				state -= 6
+			}
+			continue
+		}
+
+		var length uint32
+		if rc.decodeBit(&isRep[state]) != 0 {
+			if len(out) == 0 || len(out) >= uncompressedSize {
+				return nil, errLzmaCorrupted
+			}
+			if rc.decodeBit(&isRepG0[state]) == 0 {
+				if rc.decodeBit(&isRep0Long[(state<<lzmaNumPosBitsMax)+posState]) == 0 {
+					// short rep
+					if state < 7 {
+						state = 9
+					} else {
+						state = 11
+					}
+					out = append(out, out[len(out)-int(rep0)-1])
+					continue
+				}
+			} else {
+				var dist uint32
+				if rc.decodeBit(&isRepG1[state]) == 0 {
+					dist = rep1
+				} else {
+					if rc.decodeBit(&isRepG2[state]) == 0 {
+						dist = rep2
+					} else {
+						dist = rep3
+						rep3 = rep2
+					}
+					rep2 = rep1
+				}
+				rep1 = rep0
+				rep0 = dist
+			}
+			length = repLenDecoder.decode(rc, posState)
+			if state < 7 {
+				state = 8
+			} else {
+				state = 11
+			}
+		} else {
+			rep3 = rep2
+			rep2 = rep1
+			rep1 = rep0
+			length = lenDecoder.decode(rc, posState)
+			if state < 7 {
+				state = 7
+			} else {
+				state = 10
+			}
+			rep0 = decodeDistance(length)
+			if rep0 == 0xFFFFFFFF {
+				// end marker
+				if !rc.isFinishedOK() || rc.corrupted {
+					return nil, errLzmaCorrupted
+				}
+				break
+			}
+			if len(out) >= uncompressedSize || rep0 >= dictSize || int(rep0) >= len(out) {
+				return nil, errLzmaCorrupted
+			}
+		}
+
+		length += lzmaMatchMinLen
+		if len(out)+int(length) > uncompressedSize {
+			return nil, errLzmaCorrupted
+		}
+		for i := uint32(0); i < length; i++ {
+			out = append(out, out[len(out)-int(rep0)-1])
+		}
+	}
+	if len(out) != uncompressedSize {
+		return nil, fmt.Errorf("lzma: uncompressed size is %d, expected %d", len(out), uncompressedSize)
+	}
+	return out, nil
+}
+
+// x86BcjDecode reverses x86 (BCJ) filter, ported from Bra86.c
+func x86BcjDecode(data []byte) {
+	maskToAllowedStatus := [8]bool{true, true, true, false, true, false, false, false}
+	maskToBitNumber := [8]uint32{0, 1, 2, 2, 3, 3, 3, 3}
+	test86MSByte := func(b byte) bool {
+		return b == 0 || b == 0xFF
+	}
+
+	size := len(data)
+	if size < 5 {
+		return
+	}
+	ip := uint32(5)
+	bufferPos := 0
+	prevPosT := -1
+	var prevMask uint32
+	for {
+		limit := size - 4
+		p := bufferPos
+		for p < limit && (data[p]&0xFE) != 0xE8 {
+			p++
+		}
+		bufferPos = p
+		if p >= limit {
+			break
+		}
+		d := bufferPos - prevPosT
+		if d > 3 {
+			prevMask = 0
+		} else {
+			prevMask = (prevMask << (d - 1)) & 0x7
+			if prevMask != 0 {
+				b := data[p+4-int(maskToBitNumber[prevMask])]
+				if !maskToAllowedStatus[prevMask] || test86MSByte(b) {
+					prevPosT = bufferPos
+					prevMask = ((prevMask << 1) & 0x7) | 1
+					bufferPos++
+					continue
+				}
+			}
+		}
+		prevPosT = bufferPos
+
+		if !test86MSByte(data[p+4]) {
+			prevMask = ((prevMask << 1) & 0x7) | 1
+			bufferPos++
+			continue
+		}
+		src := binary.LittleEndian.Uint32(data[p+1:])
+		var dest uint32
+		for {
+			dest = src - (ip + uint32(bufferPos))
+			if prevMask == 0 {
+				break
+			}
+			index := maskToBitNumber[prevMask] * 8
+			b := byte(dest >> (24 - index))
+			if !test86MSByte(b) {
+				break
+			}
+			src = dest ^ ((1 << (32 - index)) - 1)
+		}
+		data[p+4] = ^byte(((dest >> 24) & 1) - 1)
+		data[p+3] = byte(dest >> 16)
+		data[p+2] = byte(dest >> 8)
+		data[p+1] = byte(dest)
+		bufferPos += 5
+	}
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"flag"
 	"io"
 	"os"
 	"os/exec"
@@ -21,6 +20,10 @@ var (
 	b2Secret          string
 	transUploadSecret string
 	certPwd           string
+	// thumbprint of the cert on hardware token, if we sign with one
+	certSha1 string
+	// for do serve
+	buildServerToken string
 )
 
 func loadSecrets() bool {
@@ -50,6 +53,13 @@ func loadSecrets() bool {
 	getEnv("BB_SECRET", &b2Secret, 0)
 	getEnv("TRANS_UPLOAD_SECRET", &transUploadSecret, 0)
 	getEnv("CERT_PWD", &certPwd, 0)
+	getEnv("CERT_SHA1", &certSha1, 0)
+	getEnv("BUILD_SERVER_TOKEN", &buildServerToken, 0)
+	getEnv("SOURCEFORGE_USER", &sourceForgeUser, 0)
+	getEnv("FOSSHUB_API_KEY", &fossHubAPIKey, 0)
+	getEnv("ARTIFACTS_KEY", &artifactsKey, 0)
+	getEnv("SOURCE_GPG_KEY", &sourceGpgKey, 0)
+	getEnv("ANNOUNCE_WEBHOOK_URL", &announceWebhookURL, 0)
 	return true
 }
 
@@ -70,6 +80,25 @@ func getSecrets() {
 	b2Secret = os.Getenv("BB_SECRET")
 	transUploadSecret = os.Getenv("TRANS_UPLOAD_SECRET")
 	certPwd = os.Getenv("CERT_PWD")
+	certSha1 = os.Getenv("CERT_SHA1")
+	buildServerToken = os.Getenv("BUILD_SERVER_TOKEN")
+	sourceForgeUser = os.Getenv("SOURCEFORGE_USER")
+	fossHubAPIKey = os.Getenv("FOSSHUB_API_KEY")
+	artifactsKey = os.Getenv("ARTIFACTS_KEY")
+	sourceGpgKey = os.Getenv("SOURCE_GPG_KEY")
+	announceWebhookURL = os.Getenv("ANNOUNCE_WEBHOOK_URL")
+}
+
+// values of secrets that are set, to redact them from logs
+func getSecretValues() []string {
+	var res []string
+	for _, s := range []string{r2Access, r2Secret, b2Access, b2Secret, transUploadSecret, certPwd, certSha1, buildServerToken, fossHubAPIKey, artifactsKey, sourceGpgKey, announceWebhookURL} {
+		// very short values would redact unrelated text
+		if len(s) >= 4 {
+			res = append(res, s)
+		}
+	}
+	return res
 }
 
 func regenPremake() {
@@ -95,91 +124,19 @@ func runCmdShowProgressAndLog(cmd *exec.Cmd, path string) error {
 	must(err)
 	defer f.Close()
 
-	cmd.Stdout = io.MultiWriter(f, os.Stdout)
-	cmd.Stderr = io.MultiWriter(f, os.Stderr)
+	flush := redactCmdOutput(cmd, io.MultiWriter(f, os.Stdout), io.MultiWriter(f, os.Stderr))
+	defer flush()
 	logf("> %s\n", fmtCmdShort(*cmd))
 	return cmd.Run()
 }
 
-const (
-	cppcheckLogFile = "cppcheck.out.txt"
-)
-
-func detectCppcheckExe() string {
-	// TODO: better detection logic
-	path := `c:\Program Files\Cppcheck\cppcheck.exe`
-	if pathExists(path) {
-		return path
-	}
-	return "cppcheck.exe"
-}
-
-func runCppCheck(all bool) {
-	// -q : quiet, doesn't print progress report
-	// -v : prints more info about the error
-	// --platform=win64 : sets platform to 64 bits
-	// -DWIN32 -D_WIN32 -D_MSC_VER=1990 : set some defines and speeds up
-	//    checking because cppcheck doesn't check all possible combinations
-	// --inline-suppr: honor suppression comments in the code like:
-	// // cppcheck-suppress <type>
-	// ... line with a problem
-	var cmd *exec.Cmd
-
-	// TODO: not sure if adding Windows SDK include path helps.
-	// It takes a lot of time and doesn't seem to provide value
-	//winSdkIncludeDir := `C:\Program Files (x86)\Windows Kits\10\Include\10.0.18362.0\um`
-	// "-I", winSdkIncludeDir
-	// "-D__RPCNDR_H_VERSION__=440"
-	// STDMETHODIMP_(type)=type
-
-	args := []string{"--platform=win64", "-DWIN32", "-D_WIN32", "-D_MSC_VER=1800", "-D_M_X64", "-DIFACEMETHODIMP_(x)=x", "-DSTDMETHODIMP_(x)=x", "-DSTDAPI_(x)=x", "-DPRE_RELEASE_VER=3.4", "-q", "-v"}
-	if all {
-		args = append(args, "--enable=style")
-		args = append(args, "--suppress=constParameter")
-		// they are just fine
-		args = append(args, "--suppress=cstyleCast")
-		// we minimize use of STL
-		args = append(args, "--suppress=useStlAlgorithm")
-		// trying to make them explicit has cascading side-effects
-		args = append(args, "--suppress=noExplicitConstructor")
-		args = append(args, "--suppress=variableScope")
-		args = append(args, "--suppress=memsetClassFloat")
-		// mostly from log() calls
-		args = append(args, "--suppress=ignoredReturnValue")
-		// complains about: char* x; float* y = (float*)x;
-		// all false positives, can't write in a way that doesn't trigger warning
-		args = append(args, "--suppress=invalidPointerCast")
-		// all false postives, gets confused by MAKEINTRESOURCEW() and wcschr
-		// using auto can fix MAKEINTRESOURCEW(), casting wcschr but it's just silly
-		args = append(args, "--suppress=AssignmentIntegerToAddress")
-		// I often use global variables set at compile time to
-		// control paths to take
-		args = append(args, "--suppress=knownConditionTrueFalse")
-		args = append(args, "--suppress=constParameterPointer")
-		args = append(args, "--suppress=constVariablePointer")
-		args = append(args, "--suppress=constVariableReference")
-		args = append(args, "--suppress=constParameterReference")
-		args = append(args, "--suppress=useInitializationList")
-		args = append(args, "--suppress=duplInheritedMember")
-		args = append(args, "--suppress=unusedStructMember")
-		args = append(args, "--suppress=CastIntegerToAddressAtReturn")
-		args = append(args, "--suppress=uselessOverride") // false positive
-	}
-	args = append(args, "--inline-suppr", "-I", "src", "-I", "src/utils", "src")
-	cppcheckExe := detectCppcheckExe()
-	cmd = exec.Command(cppcheckExe, args...)
-	os.Remove(cppcheckLogFile)
-	err := runCmdShowProgressAndLog(cmd, cppcheckLogFile)
-	must(err)
-	logf("\nLogged output to '%s'\n", cppcheckLogFile)
-}
-
 type BuildOptions struct {
 	sign                      bool
 	upload                    bool
 	verifyTranslationUpToDate bool
 	doCleanCheck              bool
 	releaseBuild              bool
+	translationGate           bool
 }
 
 func ensureBuildOptionsPreRequesites(opts *BuildOptions) {
@@ -197,12 +154,16 @@ func ensureBuildOptionsPreRequesites(opts *BuildOptions) {
 	if opts.verifyTranslationUpToDate {
 		verifyTranslationsMust()
 	}
+	if opts.translationGate {
+		checkTranslationGateMust()
+	}
 	if opts.doCleanCheck {
 		panicIf(!isGitClean(""), "git has unsaved changes\n")
 	}
 	if opts.releaseBuild {
 		verifyOnReleaseBranchMust()
-		os.RemoveAll("out")
+		acquireBuildLockMust()
+		removeOutDirMust()
 	}
 
 	if !opts.sign {
@@ -211,106 +172,43 @@ func ensureBuildOptionsPreRequesites(opts *BuildOptions) {
 }
 
 func main() {
+	defer handleFatalError()
+	logf("do tool version: %s\n", getDoVersion())
 	logf("Current directory: %s\n", currDirAbsMust())
+	removeOldSelf()
 	timeStart := time.Now()
 	defer func() {
 		logf("Finished in %s\n", time.Since(timeStart))
 	}()
+	defer notifyWhenFinished(timeStart)
+	defer releaseBuildLock()
 
-	// ad-hoc flags to be set manually (to show less options)
-	var (
-		flgGenTranslationsInfoCpp = false
-		flgCppCheck               = false
-		flgCppCheckAll            = false
-		flgClangTidy              = false
-		flgClangTidyFix           = false
-		flgPrintBuildNo           = false
-		flgBuildLzsa              = false
-		flgFindLargestFilesByExt  = false
-	)
-
-	var (
-		flgRegenPremake    bool
-		flgUpload          bool
-		flgCIBuild         bool
-		flgCIDailyBuild    bool
-		flgUploadCiBuild   bool
-		flgBuildPreRelease bool
-		flgBuildRelease    bool
-		flgWc              bool
-		flgTransDownload   bool
-		flgClean           bool
-		flgCheckAccessKeys bool
-		flgTriggerCodeQL   bool
-		flgClangFormat     bool
-		flgDiff            bool
-		flgGenSettings     bool
-		flgUpdateVer       string
-		flgDrMem           bool
-		flgLogView         bool
-		flgRunTests        bool
-		flgSmoke           bool
-		flgFileUpload      string
-		flgFilesList       bool
-		flgExtractUtils    bool
-		flgBuildLogview    bool
-		flgBuildNo         int
-		flgUpdateGoDeps    bool
-	)
-
+	cmd, args := parseDoCommandLine(os.Args[1:])
 	{
-		flag.StringVar(&flgFileUpload, "file-upload", "", "upload a test file to s3 / spaces")
-		flag.BoolVar(&flgFilesList, "files-list", false, "list uploaded files in s3 / spaces")
-		flag.BoolVar(&flgRegenPremake, "premake", false, "regenerate premake*.lua files")
-		flag.BoolVar(&flgCIBuild, "ci", false, "run CI steps")
-		flag.BoolVar(&flgCIDailyBuild, "ci-daily", false, "run CI daily steps")
-		flag.BoolVar(&flgUploadCiBuild, "ci-upload", false, "upload the result of ci build to s3 and do spaces")
-		flag.BoolVar(&flgSmoke, "smoke", false, "run smoke build (installer for 64bit release)")
-		flag.BoolVar(&flgBuildPreRelease, "build-pre-rel", false, "build pre-release")
-		flag.BoolVar(&flgBuildRelease, "build-release", false, "build release")
-		//flag.BoolVar(&flgBuildLzsa, "build-lzsa", false, "build MakeLZSA.exe")
-		flag.BoolVar(&flgUpload, "upload", false, "upload the build to s3 and do spaces")
-		flag.BoolVar(&flgClangFormat, "format", false, "format source files with clang-format")
-		flag.BoolVar(&flgWc, "wc", false, "show loc stats (like wc -l)")
-		flag.BoolVar(&flgTransDownload, "trans-dl", false, "download latest translations to translations/translations.txt")
-		//flag.BoolVar(&flgGenTranslationsInfoCpp, "trans-gen-info", false, "generate src/TranslationLangs.cpp")
-		flag.BoolVar(&flgClean, "clean", false, "clean the build (remove out/ files except for settings)")
-		flag.BoolVar(&flgCheckAccessKeys, "check-access-keys", false, "check access keys for menu items")
-		//flag.BoolVar(&flgPrintBuildNo, "build-no", false, "print build number")
-		flag.BoolVar(&flgTriggerCodeQL, "trigger-codeql", false, "trigger codeql build")
-		flag.BoolVar(&flgCppCheck, "cppcheck", false, "run cppcheck (must be installed)")
-		flag.BoolVar(&flgCppCheckAll, "cppcheck-all", false, "run cppcheck with more checks (must be installed)")
-		//flag.BoolVar(&flgClangTidy, "clang-tidy", false, "run clang-tidy (must be installed)")
-		//flag.BoolVar(&flgClangTidyFix, "clang-tidy-fix", false, "run clang-tidy (must be installed)")
-		flag.BoolVar(&flgDiff, "diff", false, "preview diff using winmerge")
-		flag.BoolVar(&flgGenSettings, "gen-settings", false, "re-generate src/Settings.h")
-		flag.StringVar(&flgUpdateVer, "update-auto-update-ver", "", "update version used for auto-update checks")
-		flag.BoolVar(&flgDrMem, "drmem", false, "run drmemory of rel 64")
-		flag.BoolVar(&flgLogView, "logview", false, "run logview")
-		flag.BoolVar(&flgRunTests, "run-tests", false, "run test_util executable")
-		flag.BoolVar(&flgExtractUtils, "extract-utils", false, "extract utils")
-		flag.BoolVar(&flgBuildLogview, "build-logview", false, "build logview-win. Use -upload to also upload it to backblaze")
-		flag.IntVar(&flgBuildNo, "build-no-info", 0, "print build number info for given build number")
-		flag.BoolVar(&flgUpdateGoDeps, "update-go-deps", false, "update go dependencies")
-
-		flag.Parse()
-	}
-
-	if flgExtractUtils {
-		extractUtils(flgCIBuild)
-		return
+		config := loadFlagsConfig(flagsConfigFileName)
+		checkFlagsConfigMust(config, getAllFlagEnvNames())
+		fs := newDoCommandFlagSet(cmd)
+		args = parseDoCommandFlags(fs, args)
+		checkDoCommandArgs(cmd, args)
+		applyFlagOverridesMust(fs, config)
+		initTimeoutsAndCancellation()
+		initToolCache()
+		initHTTPProxyMust()
+		panicIf(flgPlatform != "" && flgPlatforms != "", "use -platform or -platforms, not both")
+		initPlatformsFilterMust()
+		initUploadThrottleMust()
+		if flgOutPerBranch {
+			switchOutDirsToCurrentBranchMust()
+		}
 	}
 
-	if flgUpdateGoDeps {
-		defer measureDuration()()
-		u.UpdateGoDeps("do", true)
-		u.UpdateGoDeps(filepath.Join("tools", "regress"), true)
-		u.UpdateGoDeps(filepath.Join("tools", "logview"), true)
-		u.UpdateGoDeps(filepath.Join("tools", "logview-win"), true)
+	if cmd.NoSetup {
+		cmd.Run(args)
 		return
 	}
 
 	getSecrets()
+	applyStorageEndpointCreds()
 	detectVersions()
 
 	if false {
@@ -328,217 +226,15 @@ func main() {
 		return
 	}
 
-	if flgBuildNo > 0 {
-		printBuildNoInfo(flgBuildNo)
-		return
+	if cmd.Audit {
+		resetCommandAudit()
 	}
-
-	if flgFindLargestFilesByExt {
-		findLargestFileByExt()
-		return
+	if cmd.Summary {
+		// also called when the build fails
+		defer writeGitHubStepSummary()
+		defer saveStepFailures()
 	}
-
-	if flgFileUpload != "" {
-		fileUpload(flgFileUpload)
-		return
-	}
-
-	if flgBuildLogview {
-		buildLogView()
-		if flgUpload {
-			uploadLogView()
-		}
-		return
-	}
-
-	if flgFilesList {
-		filesList()
-		return
-	}
-
-	opts := &BuildOptions{}
-	if flgUploadCiBuild {
-		// triggered via -ci-upload from .github workflow file
-		// only upload if this is my repo (not a fork)
-		// master branch (not work branches) and on push (not pull requests etc.)
-		opts.upload = isGithubMyMasterBranch()
-	}
-
-	if flgCIBuild {
-		// triggered via -ci from .github workflow file
-		// only sign if this is my repo (not a fork)
-		// master branch (not work branches) and on push (not pull requests etc.)
-		opts.sign = isGithubMyMasterBranch()
-	}
-
-	if flgUpload {
-		// given by me from cmd-line
-		opts.sign = true
-		opts.upload = true
-	}
-
-	if flgBuildRelease {
-		// only when building locally, not on GitHub CI
-		opts.verifyTranslationUpToDate = true
-		opts.doCleanCheck = true
-		opts.releaseBuild = true
-	}
-	//opts.doCleanCheck = false // for ad-hoc testing
-
-	ensureBuildOptionsPreRequesites(opts)
-
-	if flgDiff {
-		u.WinmergeDiffPreview()
-		return
-	}
-
-	if flgGenSettings {
-		genAndSaveSettingsStructs()
-		return
-	}
-
-	if flgWc {
-		doLineCount()
-		return
-	}
-
-	if flgTriggerCodeQL {
-		triggerBuildWebHook(githubEventTypeCodeQL)
-		return
-	}
-
-	if flgClean {
-		cleanPreserveSettings()
-		return
-	}
-
-	if flgClangFormat {
-		clangFormatFiles()
-		return
-	}
-
-	if flgCppCheck || flgCppCheckAll {
-		runCppCheck(flgCppCheckAll)
-		return
-	}
-
-	if flgClangTidy || flgClangTidyFix {
-		runClangTidy(flgClangTidyFix)
-		return
-	}
-
-	if flgRegenPremake {
-		regenPremake()
-		return
-	}
-
-	if flgCheckAccessKeys {
-		checkAccessKeys()
-		return
-	}
-
-	if flgTransDownload {
-		downloadTranslations()
-		return
-	}
-
-	if flgGenTranslationsInfoCpp {
-		genTranslationInfoCpp()
-		return
-	}
-
-	if flgBuildLzsa {
-		buildLzsa()
-		return
-	}
-
-	if flgSmoke {
-		buildSmoke()
-		return
-	}
-
-	if flgPrintBuildNo {
-		return
-	}
-
-	if flgCIDailyBuild {
-		buildCiDaily()
-		if opts.upload {
-			uploadToStorage(buildTypePreRel)
-		} else {
-			logf("uploadToStorage: skipping because opts.upload = false\n")
-		}
-		return
-	}
-
-	// on GitHub Actions the build happens in an earlier step
-	if flgUploadCiBuild {
-		// pre-release build on push
-		uploadToStorage(buildTypePreRel)
-		return
-	}
-
-	if flgCIBuild {
-		buildCi()
-		if opts.upload {
-			uploadToStorage(buildTypePreRel)
-		} else {
-			logf("uploadToStorage: skipping because opts.upload = false\n")
-		}
-		return
-	}
-
-	if flgBuildRelease {
-		buildRelease()
-		if opts.upload {
-			uploadToStorage(buildTypeRel)
-		} else {
-			logf("uploadToStorage: skipping because opts.upload = false\n")
-		}
-		return
-	}
-
-	// this one is typically for me to build locally, so build all projects
-	if flgBuildPreRelease {
-		cleanReleaseBuilds()
-		buildPreRelease(kPlatformIntel64, true)
-		if opts.upload {
-			uploadToStorage(buildTypePreRel)
-		} else {
-			logf("uploadToStorage: skipping because opts.upload = false\n")
-		}
-		return
-	}
-
-	if flgUpdateVer != "" {
-		ensureAllUploadCreds()
-		updateAutoUpdateVer(flgUpdateVer)
-		return
-	}
-
-	if flgDrMem {
-		buildJustPortableExe(rel64Dir, "Release", kPlatformIntel64)
-		//cmd := exec.Command("drmemory.exe", "-light", "-check_leaks", "-possible_leaks", "-count_leaks", "-suppress", "drmem-sup.txt", "--", ".\\out\\rel64\\SumatraPDF.exe")
-		cmd := exec.Command("drmemory.exe", "-leaks_only", "-suppress", "drmem-sup.txt", "--", ".\\out\\rel64\\SumatraPDF.exe")
-		runCmdLoggedMust(cmd)
-		return
-	}
-
-	if flgLogView {
-		logView()
-		return
-	}
-
-	if flgRunTests {
-		buildTestUtil()
-		dir := filepath.Join("out", "rel64")
-		cmd := exec.Command(".\\test_util.exe")
-		cmd.Dir = dir
-		runCmdLoggedMust(cmd)
-		return
-	}
-
-	flag.Usage()
+	cmd.Run(args)
 }
 
 func logView() {
@@ -559,7 +255,7 @@ func buildLogView() {
 	logf("biuldLogView: ver: %s\n", ver)
 	os.RemoveAll(filepath.Join(logViewWinDir, "build", "bin"))
 	//cmdRunLoggedInDir(".", "wails", "build", "-clean", "-f", "-upx")
-	cmdRunLoggedInDir(logViewWinDir, "wails", "build", "-clean", "-f", "-upx")
+	cmdRunLoggedInDir(logViewWinDir, lookPathMust("wails"), "build", "-clean", "-f", "-upx")
 
 	path := filepath.Join(logViewWinDir, "build", "bin", "logview.exe")
 	panicIf(!u.FileExists(path))
@@ -0,0 +1,168 @@
+package main
+
+import (
+	"bytes"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"path/filepath"
+	"strings"
+)
+
+// after build we check application manifests embedded in executables.
+// Mistakes there (e.g. lost DPI awareness or supportedOS) only show up
+// on specific versions of Windows or specific DPI settings.
+// dlls don't have manifests but if they get one, we check the parts that
+// apply to dlls
+
+// resource type for application manifest
+const peResourceTypeManifest = 24
+
+// executables must have a manifest
+var exesWithManifest = []string{"SumatraPDF.exe", "SumatraPDF-dll.exe"}
+
+// supportedOS ids we must declare, otherwise e.g. GetVersionEx() lies
+// and we get compatibility shims
+var requiredSupportedOS = map[string]string{
+	"{35138b9a-5d96-4fbd-8e2d-a2440225f93a}": "Windows 7",
+	"{4a2f28e3-53b9-4441-ba9c-d69d4a4a6e38}": "Windows 8",
+	"{1f676c76-80e1-4239-95bb-83d0f6d0da78}": "Windows 8.1",
+	"{8e0f7a12-bfb3-4fe8-b9a5-48fd50a15a9a}": "Windows 10 / 11",
+}
+
+// AppManifest has the parts of application manifest we check
+type AppManifest struct {
+	DpiAware          string
+	DpiAwareness      string
+	SupportedOS       []string
+	ExecutionLevel    string
+	UIAccess          string
+	CommonControlsVer string
+}
+
+// we only care about element names, not namespaces
+func parseAppManifest(d []byte) (*AppManifest, error) {
+	res := &AppManifest{}
+	dec := xml.NewDecoder(bytes.NewReader(d))
+	attr := func(el xml.StartElement, name string) string {
+		for _, a := range el.Attr {
+			if a.Name.Local == name {
+				return a.Value
+			}
+		}
+		return ""
+	}
+	text := func(el xml.StartElement) string {
+		var s string
+		err := dec.DecodeElement(&s, &el)
+		if err != nil {
+			return ""
+		}
+		return strings.TrimSpace(s)
+	}
+	for {
+		tok, err := dec.Token()
+		if err == io.EOF {
+			return res, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		el, ok := tok.(xml.StartElement)
+		if !ok {
+			continue
+		}
+		switch el.Name.Local {
+		case "dpiAware":
+			res.DpiAware = text(el)
+		case "dpiAwareness":
+			res.DpiAwareness = text(el)
+		case "supportedOS":
+			res.SupportedOS = append(res.SupportedOS, strings.ToLower(attr(el, "Id")))
+		case "requestedExecutionLevel":
+			res.ExecutionLevel = attr(el, "level")
+			res.UIAccess = attr(el, "uiAccess")
+		case "assemblyIdentity":
+			if attr(el, "name") == "Microsoft.Windows.Common-Controls" {
+				res.CommonControlsVer = attr(el, "version")
+			}
+		}
+	}
+}
+
+// returns problems
+func checkAppManifest(m *AppManifest, isExe bool) []string {
+	var res []string
+	if !isExe {
+		if m.ExecutionLevel != "" && m.ExecutionLevel != "asInvoker" {
+			res = append(res, fmt.Sprintf("requestedExecutionLevel is '%s', expected 'asInvoker'", m.ExecutionLevel))
+		}
+		if m.CommonControlsVer != "" && m.CommonControlsVer != "6.0.0.0" {
+			res = append(res, fmt.Sprintf("Microsoft.Windows.Common-Controls version is '%s', expected '6.0.0.0'", m.CommonControlsVer))
+		}
+		return res
+	}
+	if m.DpiAwareness != "PerMonitorV2" {
+		res = append(res, fmt.Sprintf("dpiAwareness is '%s', expected 'PerMonitorV2'", m.DpiAwareness))
+	}
+	// used by Windows before 10 1607 which ignore dpiAwareness
+	if m.DpiAware != "true/pm" {
+		res = append(res, fmt.Sprintf("dpiAware is '%s', expected 'true/pm'", m.DpiAware))
+	}
+	for id, name := range requiredSupportedOS {
+		if !stringInSlice(m.SupportedOS, id) {
+			res = append(res, fmt.Sprintf("missing supportedOS %s for %s", id, name))
+		}
+	}
+	if m.ExecutionLevel != "asInvoker" {
+		res = append(res, fmt.Sprintf("requestedExecutionLevel is '%s', expected 'asInvoker'", m.ExecutionLevel))
+	}
+	if m.UIAccess != "" && m.UIAccess != "false" {
+		res = append(res, fmt.Sprintf("uiAccess is '%s', expected 'false'", m.UIAccess))
+	}
+	if m.CommonControlsVer != "6.0.0.0" {
+		res = append(res, fmt.Sprintf("Microsoft.Windows.Common-Controls version is '%s', expected '6.0.0.0'", m.CommonControlsVer))
+	}
+	return res
+}
+
+// returns nil if there's no manifest
+func peAppManifest(d []byte) []byte {
+	// CREATEPROCESS_MANIFEST_RESOURCE_ID for exes,
+	// ISOLATIONAWARE_MANIFEST_RESOURCE_ID for dlls
+	for _, id := range []uint32{1, 2} {
+		if res, err := peFindResource(d, peResourceTypeManifest, id); err == nil {
+			return res
+		}
+	}
+	return nil
+}
+
+func auditManifestsMust(dir string) {
+	defer failOnPanic(errKindVerify)
+	var problems []string
+	for _, name := range signedFileNames {
+		path := filepath.Join(dir, name)
+		if !fileExists(path) {
+			continue
+		}
+		isExe := stringInSlice(exesWithManifest, name)
+		d := peAppManifest(readFileMust(path))
+		if d == nil {
+			if isExe {
+				problems = append(problems, path+": no application manifest")
+			}
+			continue
+		}
+		m, err := parseAppManifest(d)
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: invalid manifest: %s", path, err))
+			continue
+		}
+		for _, p := range checkAppManifest(m, isExe) {
+			problems = append(problems, path+": "+p)
+		}
+	}
+	panicIf(len(problems) > 0, "application manifest problems:\n%s\n", strings.Join(problems, "\n"))
+	logf("auditManifestsMust: manifests in '%s' ok\n", dir)
+}
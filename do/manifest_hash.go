@@ -0,0 +1,99 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// manifest has size and hashes of every artifact. Files are hashed by a
+// pool of workers, each file is read once and streamed to all hashes,
+// so adding artifacts or hash types doesn't make it much slower
+
+// ManifestHash is a hash type in the manifest
+type ManifestHash struct {
+	Name string
+	New  func() hash.Hash
+}
+
+var manifestHashes = []*ManifestHash{
+	{Name: "sha256", New: sha256.New},
+}
+
+// ArtifactInfo is a line in the manifest
+type ArtifactInfo struct {
+	Path string
+	Size int64
+	// hash name => hex digest, for hashes in manifestHashes
+	Hashes map[string]string
+}
+
+// "out\rel64\SumatraPDF.exe: 1234 sha256:ab12..."
+func (a *ArtifactInfo) ManifestLine() string {
+	s := fmt.Sprintf("%s: %d", a.Path, a.Size)
+	for _, h := range manifestHashes {
+		s += fmt.Sprintf(" %s:%s", h.Name, a.Hashes[h.Name])
+	}
+	return s
+}
+
+func hashFile(path string) (*ArtifactInfo, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var hashes []hash.Hash
+	var writers []io.Writer
+	for _, mh := range manifestHashes {
+		h := mh.New()
+		hashes = append(hashes, h)
+		writers = append(writers, h)
+	}
+	n, err := io.Copy(io.MultiWriter(writers...), f)
+	if err != nil {
+		return nil, err
+	}
+	res := &ArtifactInfo{Path: path, Size: n, Hashes: map[string]string{}}
+	for i, h := range hashes {
+		res.Hashes[manifestHashes[i].Name] = hex.EncodeToString(h.Sum(nil))
+	}
+	return res, nil
+}
+
+// returns info about files, in the same order
+func hashFilesMust(paths []string) []*ArtifactInfo {
+	res := make([]*ArtifactInfo, len(paths))
+	errs := make([]error, len(paths))
+	work := make(chan int)
+	var wg sync.WaitGroup
+	nWorkers := min(runtime.NumCPU(), len(paths))
+	for i := 0; i < nWorkers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range work {
+				res[i], errs[i] = hashFile(paths[i])
+			}
+		}()
+	}
+	for i := range paths {
+		work <- i
+	}
+	close(work)
+	wg.Wait()
+	var failed []string
+	for _, err := range errs {
+		if err != nil {
+			failed = append(failed, err.Error())
+		}
+	}
+	panicIf(len(failed) > 0, "failed to hash files:\n%s", strings.Join(failed, "\n"))
+	return res
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// analyzes linker map file of SumatraPDF.exe and linker's /VERBOSE:REF,ICF
+// output from a Release x64 build:
+// - objects and libraries ranked by size of code and data they contribute
+// - largest symbols
+// - objects that were pulled in but most of their code was never referenced
+//   (discarded by /OPT:REF). Those are candidates for removing dead code or
+//   splitting files
+// - functions folded by /OPT:ICF i.e. identical code, usually from templates
+// report is written to out/map-report/report.txt
+// release builds also create SumatraPDF.map ("Maps" flag in premake5.lua)
+// but without the verbose linker output, so we do our own build
+
+const (
+	mapReportTopN   = 40
+	mapReportConfig = "Release|x64"
+)
+
+// verbose output of the linker goes to msbuild log
+const mapReportTargets = `<?xml version="1.0" encoding="utf-8"?>
+<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <ItemDefinitionGroup>
+    <Link>
+      <GenerateMapFile>true</GenerateMapFile>
+      <AdditionalOptions>%(AdditionalOptions) /VERBOSE:REF /VERBOSE:ICF</AdditionalOptions>
+    </Link>
+  </ItemDefinitionGroup>
+</Project>
+`
+
+var (
+	//  0001:00000010       ?foo@@YAXXZ       0000000140001010 f i   libcmt:xyz.obj
+	rxMapSymbol = regexp.MustCompile(`^\s*([0-9a-fA-F]{4}):([0-9a-fA-F]{8})\s+(\S+)\s+[0-9a-fA-F]{8,16}\s+(?:f\s+)?(?:i\s+)?(\S+)\s*$`)
+	//      Discarded "?foo@@YAXXZ" from Foo.obj
+	rxLinkDiscarded = regexp.MustCompile(`Discarded "(.+)" from (\S+)`)
+	//        ?bar@@YAXXZ from Bar.obj (after "Replaced:")
+	rxLinkICFReplaced = regexp.MustCompile(`^\s*(\S+) from (\S+)\s*$`)
+)
+
+// MapSymbol is a symbol from map file
+type MapSymbol struct {
+	Section int
+	Offset  int64
+	Name    string
+	// lib:obj or obj
+	Object string
+	Size   int64
+}
+
+func parseLinkerMap(s string) []*MapSymbol {
+	var res []*MapSymbol
+	for _, line := range strings.Split(s, "\n") {
+		m := rxMapSymbol.FindStringSubmatch(line)
+		if m == nil || m[4] == "<absolute>" || m[4] == "<linker-defined>" {
+			continue
+		}
+		sec, _ := strconv.ParseInt(m[1], 16, 32)
+		off, _ := strconv.ParseInt(m[2], 16, 64)
+		res = append(res, &MapSymbol{Section: int(sec), Offset: off, Name: m[3], Object: m[4]})
+	}
+	// symbol size is distance to the next symbol in the same section
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Section != res[j].Section {
+			return res[i].Section < res[j].Section
+		}
+		return res[i].Offset < res[j].Offset
+	})
+	for i := 0; i+1 < len(res); i++ {
+		if res[i+1].Section == res[i].Section {
+			res[i].Size = res[i+1].Offset - res[i].Offset
+		}
+	}
+	return res
+}
+
+// LinkVerboseInfo is what we get from /VERBOSE:REF /VERBOSE:ICF
+type LinkVerboseInfo struct {
+	// object => number of discarded symbols
+	Discarded map[string]int
+	// object => number of functions replaced by identical function
+	Folded map[string]int
+}
+
+func parseLinkVerbose(s string) *LinkVerboseInfo {
+	res := &LinkVerboseInfo{Discarded: map[string]int{}, Folded: map[string]int{}}
+	inReplaced := false
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := rxLinkDiscarded.FindStringSubmatch(line); m != nil {
+			res.Discarded[m[2]]++
+			inReplaced = false
+			continue
+		}
+		trimmed := strings.TrimSpace(line)
+		if strings.HasSuffix(trimmed, "Replaced:") {
+			inReplaced = true
+			continue
+		}
+		if strings.HasSuffix(trimmed, "Selected symbol:") {
+			inReplaced = false
+			continue
+		}
+		if inReplaced {
+			if m := rxLinkICFReplaced.FindStringSubmatch(line); m != nil {
+				res.Folded[m[2]]++
+				continue
+			}
+			inReplaced = false
+		}
+	}
+	return res
+}
+
+// "libmupdf:pdf-lex.obj" => "pdf-lex.obj"
+func mapObjectName(s string) string {
+	if idx := strings.LastIndex(s, ":"); idx >= 0 {
+		return s[idx+1:]
+	}
+	return s
+}
+
+type mapObjectStats struct {
+	Object    string
+	Size      int64
+	NSymbols  int
+	Discarded int
+	Folded    int
+}
+
+func fmtMapReport(syms []*MapSymbol, verbose *LinkVerboseInfo) string {
+	byObj := map[string]*mapObjectStats{}
+	byLib := map[string]int64{}
+	var total int64
+	for _, sym := range syms {
+		st := byObj[sym.Object]
+		if st == nil {
+			st = &mapObjectStats{Object: sym.Object}
+			byObj[sym.Object] = st
+		}
+		st.Size += sym.Size
+		st.NSymbols++
+		total += sym.Size
+		lib := "(main)"
+		if idx := strings.LastIndex(sym.Object, ":"); idx >= 0 {
+			lib = sym.Object[:idx]
+		}
+		byLib[lib] += sym.Size
+	}
+	var objs []*mapObjectStats
+	for _, st := range byObj {
+		st.Discarded = verbose.Discarded[mapObjectName(st.Object)]
+		st.Folded = verbose.Folded[mapObjectName(st.Object)]
+		objs = append(objs, st)
+	}
+
+	s := fmt.Sprintf("total size of symbols: %s in %d objects\n", formatSize(total), len(objs))
+
+	s += "\nlibraries by size:\n"
+	var libs []string
+	for lib := range byLib {
+		libs = append(libs, lib)
+	}
+	sort.Slice(libs, func(i, j int) bool { return byLib[libs[i]] > byLib[libs[j]] })
+	for _, lib := range libs {
+		s += fmt.Sprintf("  %10s  %s\n", formatSize(byLib[lib]), lib)
+	}
+
+	s += "\nobjects by size:\n"
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Size > objs[j].Size })
+	for i, st := range objs {
+		if i >= mapReportTopN {
+			break
+		}
+		s += fmt.Sprintf("  %10s  %5d symbols  %s\n", formatSize(st.Size), st.NSymbols, st.Object)
+	}
+
+	s += "\nlargest symbols:\n"
+	bySize := append([]*MapSymbol(nil), syms...)
+	sort.Slice(bySize, func(i, j int) bool { return bySize[i].Size > bySize[j].Size })
+	for i, sym := range bySize {
+		if i >= mapReportTopN {
+			break
+		}
+		s += fmt.Sprintf("  %10s  %s  (%s)\n", formatSize(sym.Size), sym.Name, sym.Object)
+	}
+
+	s += "\nobjects pulled in with most symbols unreferenced (discarded by /OPT:REF):\n"
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Discarded > objs[j].Discarded })
+	for i, st := range objs {
+		if i >= mapReportTopN || st.Discarded == 0 {
+			break
+		}
+		s += fmt.Sprintf("  %5d discarded  %5d kept  %10s  %s\n", st.Discarded, st.NSymbols, formatSize(st.Size), st.Object)
+	}
+
+	s += "\nobjects with most identical functions folded by /OPT:ICF:\n"
+	sort.Slice(objs, func(i, j int) bool { return objs[i].Folded > objs[j].Folded })
+	for i, st := range objs {
+		if i >= mapReportTopN || st.Folded == 0 {
+			break
+		}
+		s += fmt.Sprintf("  %5d folded  %s\n", st.Folded, st.Object)
+	}
+	return s
+}
+
+func mapReport() {
+	outDir := filepath.Join("out", "map-report")
+	must(os.RemoveAll(outDir))
+	createDirMust(outDir)
+	targetsPath := absPathMust(filepath.Join(outDir, "map-report.targets"))
+	writeFileMust(targetsPath, []byte(mapReportTargets))
+
+	logPath := filepath.Join(outDir, "msbuild.log")
+	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
+	parts := strings.Split(mapReportConfig, "|")
+	p := fmt.Sprintf(`/p:Configuration=%s;Platform=%s`, parts[0], parts[1])
+	flp := fmt.Sprintf(`/flp:logfile=%s;verbosity=normal`, logPath)
+	runExeLoggedMust(detectMsbuildPath(), slnPath, `/t:SumatraPDF:Rebuild`, p, "/p:ForceImportBeforeCppTargets="+targetsPath, `/m`, `/fl`, flp)
+
+	mapPath := filepath.Join("out", "rel64", "SumatraPDF.map")
+	syms := parseLinkerMap(string(readFileMust(mapPath)))
+	verbose := parseLinkVerbose(string(readFileMust(logPath)))
+	report := fmt.Sprintf("linker map report for %s (%s)\n\n", mapPath, mapReportConfig) + fmtMapReport(syms, verbose)
+	reportPath := filepath.Join(outDir, "report.txt")
+	writeFileMust(reportPath, []byte(report))
+	logf("%s\nwrote '%s'\n", report, reportPath)
+}
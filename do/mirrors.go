@@ -0,0 +1,166 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// publishes release files to official mirrors:
+// - SourceForge: files are uploaded with scp (OpenSSH, key of SOURCEFORGE_USER
+//   must be registered with SourceForge), then downloaded back and verified
+// - FossHub: we create a release via their API with links to our files, FossHub
+//   downloads them. We verify that files FossHub serves match local files
+// https://sourceforge.net/p/forge/documentation/Release%20Files%20for%20Download/
+// https://www.fosshub.com/api-docs
+
+const (
+	sourceForgeProject = "sumatrapdf"
+	fossHubProject     = "SumatraPDF"
+	// SourceForge mirrors and FossHub need time to pick up new files
+	mirrorVerifyTries = 10
+	mirrorVerifyDelay = time.Minute
+)
+
+var (
+	sourceForgeUser string
+	fossHubAPIKey   string
+)
+
+// files we publish on mirrors, from out/final-rel
+func getMirrorFilesMust(ver string) []string {
+	dir := getFinalDirForBuildType(buildTypeRel)
+	files, err := os.ReadDir(dir)
+	must(err)
+	var res []string
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !isMirrorFile(name) {
+			continue
+		}
+		res = append(res, name)
+	}
+	sort.Strings(res)
+	panicIf(len(res) == 0, "no files for version '%s' in '%s'", ver, dir)
+	return res
+}
+
+// downloads uri and compares with local file, retrying to give mirrors time
+func verifyMirrorFileMust(uri string, localPath string) {
+	want := sha256Hex(readFileMust(localPath))
+	for i := 1; ; i++ {
+		rsp, err := http.Get(uri)
+		var got string
+		if err == nil {
+			d, _ := io.ReadAll(rsp.Body)
+			rsp.Body.Close()
+			if rsp.StatusCode == http.StatusOK {
+				got = sha256Hex(d)
+			}
+		}
+		if got == want {
+			logf("verified '%s'\n", uri)
+			return
+		}
+		panicIf(i == mirrorVerifyTries, "'%s' doesn't match '%s' (sha256 '%s', expected '%s')", uri, localPath, got, want)
+		logf("'%s' not available yet, retrying in %s\n", uri, mirrorVerifyDelay)
+		time.Sleep(mirrorVerifyDelay)
+	}
+}
+
+func uploadToSourceForgeMust(ver string) {
+	panicIf(sourceForgeUser == "", "need SOURCEFORGE_USER env variable")
+	dir := getFinalDirForBuildType(buildTypeRel)
+	files := getMirrorFilesMust(ver)
+	// /home/frs/project/sumatrapdf/3.5/
+	remoteDir := fmt.Sprintf("/home/frs/project/%s/%s/", sourceForgeProject, ver)
+	host := sourceForgeUser + "@frs.sourceforge.net"
+	runExeLoggedMust("ssh", host, "mkdir", "-p", remoteDir)
+	args := []string{}
+	for _, name := range files {
+		args = append(args, filepath.Join(dir, name))
+	}
+	args = append(args, host+":"+remoteDir)
+	runExeLoggedMust("scp", args...)
+
+	for _, name := range files {
+		uri := fmt.Sprintf("https://downloads.sourceforge.net/project/%s/%s/%s", sourceForgeProject, ver, name)
+		verifyMirrorFileMust(uri, filepath.Join(dir, name))
+	}
+}
+
+type fossHubFile struct {
+	FileURL string `json:"fileUrl"`
+	Type    string `json:"type"`
+	Version string `json:"version"`
+}
+
+type fossHubRelease struct {
+	Version string         `json:"version"`
+	Files   []*fossHubFile `json:"files"`
+	Publish bool           `json:"publish"`
+}
+
+// FossHub file type shown on download page
+func fossHubFileType(name string) string {
+	arch := "32-bit"
+	if strings.Contains(name, "-64") {
+		arch = "64-bit"
+	} else if strings.Contains(name, "-arm64") {
+		arch = "ARM64"
+	}
+	switch {
+	case strings.HasSuffix(name, "-install.exe"):
+		return "Windows Installer " + arch
+	case strings.HasSuffix(name, ".zip"):
+		return "Windows Portable " + arch + " (zip)"
+	}
+	return "Windows Portable " + arch
+}
+
+// FossHub serves the file from its own servers (redirects to a mirror)
+func fossHubDownloadURL(name string) string {
+	return fmt.Sprintf("https://www.fosshub.com/%s.html?dwl=%s", fossHubProject, name)
+}
+
+func uploadToFossHubMust(ver string) {
+	panicIf(fossHubAPIKey == "", "need FOSSHUB_API_KEY env variable")
+	dir := getFinalDirForBuildType(buildTypeRel)
+	rel := &fossHubRelease{Version: ver, Publish: true}
+	files := getMirrorFilesMust(ver)
+	for _, name := range files {
+		uri := "https://www.sumatrapdfreader.org/dl/rel/" + ver + "/" + name
+		rel.Files = append(rel.Files, &fossHubFile{FileURL: uri, Type: fossHubFileType(name), Version: ver})
+	}
+	d, err := json.Marshal(rel)
+	must(err)
+	uri := fmt.Sprintf("https://api.fosshub.com/rest/projects/%s/releases/", fossHubProject)
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewReader(d))
+	must(err)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Auth-Key", fossHubAPIKey)
+	rsp, err := http.DefaultClient.Do(req)
+	must(err)
+	defer rsp.Body.Close()
+	body, _ := io.ReadAll(rsp.Body)
+	panicIf(rsp.StatusCode >= 400, "POST %s failed with %s:\n%s\n", uri, rsp.Status, string(body))
+	logf("created FossHub release %s with %d files\n", ver, len(rel.Files))
+
+	// FossHub needs time to download the files from us
+	for _, name := range files {
+		verifyMirrorFileMust(fossHubDownloadURL(name), filepath.Join(dir, name))
+	}
+}
+
+func uploadToMirrors() {
+	ver := sumatraVersion
+	uploadToSourceForgeMust(ver)
+	uploadToFossHubMust(ver)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// msbuild output is thousands of lines and the error that broke the build
+// is somewhere in the middle. We save full log of each msbuild invocation
+// to out/logs/msbuild-${n}.log and when it fails, the first error with
+// a few lines that follow it (notes, template instantiation context) is
+// shown in the error summary at the end of the run
+
+// max lines of excerpt, including the error line
+const msbuildExcerptMaxLines = 12
+
+// matches:
+// 1>D:\sumatrapdf\src\Foo.cpp(12,5): error C2065: 'x': undeclared identifier [...]
+// LINK : fatal error LNK1104: cannot open file 'foo.lib'
+var rxMsbuildError = regexp.MustCompile(`(?:: |^)(?:fatal )?error [A-Z]+\d+\s*:`)
+
+var msbuildLogNo int
+
+func newMsbuildLogPath() string {
+	msbuildLogNo++
+	dir := createDirMust(filepath.Join("out", "logs"))
+	return filepath.Join(dir, fmt.Sprintf("msbuild-%d.log", msbuildLogNo))
+}
+
+// returns first error block and number of distinct errors
+func extractMsbuildErrorExcerpt(log string) (string, int) {
+	var lines []string
+	seen := map[string]bool{}
+	nErrors := 0
+	first := -1
+	for _, line := range strings.Split(log, "\n") {
+		line = strings.TrimRight(line, "\r")
+		lines = append(lines, line)
+		s := strings.TrimSpace(line)
+		if !rxMsbuildError.MatchString(s) || seen[s] {
+			continue
+		}
+		// msbuild repeats errors in the summary at the end
+		seen[s] = true
+		nErrors++
+		if first == -1 {
+			first = len(lines) - 1
+		}
+	}
+	if first == -1 {
+		return "", 0
+	}
+	excerpt := []string{lines[first]}
+	for _, line := range lines[first+1:] {
+		if len(excerpt) == msbuildExcerptMaxLines || strings.TrimSpace(line) == "" {
+			break
+		}
+		excerpt = append(excerpt, line)
+	}
+	return strings.Join(excerpt, "\n"), nErrors
+}
+
+// converts panic r of a failed msbuild into *DoError with error excerpt
+func msbuildFailure(r interface{}, logPath string) *DoError {
+	if e, ok := r.(*DoError); ok && (e.Kind == errKindTimeout || e.Kind == errKindInterrupted) {
+		return e
+	}
+	msg := fmt.Sprint(r)
+	d, err := os.ReadFile(logPath)
+	if err != nil {
+		return &DoError{Kind: errKindBuild, Msg: msg}
+	}
+	excerpt, nErrors := extractMsbuildErrorExcerpt(string(d))
+	if nErrors == 0 {
+		msg += fmt.Sprintf("\nno errors found in msbuild log, see '%s'", logPath)
+	} else {
+		msg += fmt.Sprintf("\n%d errors, the first one:\n%s\nfull log: '%s'", nErrors, excerpt, logPath)
+	}
+	return &DoError{Kind: errKindBuild, Msg: msg}
+}
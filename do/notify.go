@@ -0,0 +1,74 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+)
+
+// when a local (not CI) run takes longer than notifyMinDuration we show
+// a Windows toast notification with the result and duration, so that we
+// can do something else while full release build runs.
+// -notify-sound plays the notification sound, -no-notify disables it
+
+const notifyMinDuration = 3 * time.Minute
+
+var (
+	flgNoNotify    bool
+	flgNotifySound bool
+)
+
+// text is passed in env variables to avoid quoting issues
+const toastScript = `
+[Windows.UI.Notifications.ToastNotificationManager, Windows.UI.Notifications, ContentType = WindowsRuntime] | Out-Null
+$xml = [Windows.UI.Notifications.ToastNotificationManager]::GetTemplateContent([Windows.UI.Notifications.ToastTemplateType]::ToastText02)
+$text = $xml.GetElementsByTagName('text')
+$text.Item(0).AppendChild($xml.CreateTextNode($env:DO_TOAST_TITLE)) | Out-Null
+$text.Item(1).AppendChild($xml.CreateTextNode($env:DO_TOAST_MSG)) | Out-Null
+if ($env:DO_TOAST_SILENT -eq '1') {
+  $audio = $xml.CreateElement('audio')
+  $audio.SetAttribute('silent', 'true')
+  $xml.DocumentElement.AppendChild($audio) | Out-Null
+}
+$toast = [Windows.UI.Notifications.ToastNotification]::new($xml)
+$appID = '{1AC14E77-02E7-4E5D-B744-2EB1AE5198B7}\WindowsPowerShell\v1.0\powershell.exe'
+[Windows.UI.Notifications.ToastNotificationManager]::CreateToastNotifier($appID).Show($toast)
+`
+
+// failing to show notification is not an error
+func showToast(title string, msg string, sound bool) {
+	silent := "1"
+	if sound {
+		silent = "0"
+	}
+	cmd := exec.Command("powershell.exe", "-NoProfile", "-NonInteractive", "-Command", toastScript)
+	cmd.Env = append(os.Environ(),
+		"DO_TOAST_TITLE="+title,
+		"DO_TOAST_MSG="+msg,
+		"DO_TOAST_SILENT="+silent,
+	)
+	if out, err := combinedOutputWithTimeout(cmd); err != nil {
+		logf("showToast: failed with '%s'\n%s\n", err, out)
+	}
+}
+
+// must be used as: defer notifyWhenFinished(timeStart)
+func notifyWhenFinished(timeStart time.Time) {
+	r := recover()
+	dur := time.Since(timeStart)
+	shouldNotify := !flgNoNotify && runtime.GOOS == "windows" && getCIContext().Provider == "" && dur >= notifyMinDuration
+	if shouldNotify {
+		title := "do: finished"
+		msg := fmt.Sprintf("finished in %s", dur.Round(time.Second))
+		if r != nil {
+			title = "do: failed"
+			msg = fmt.Sprintf("failed after %s: %v", dur.Round(time.Second), r)
+		}
+		showToast(title, msg, flgNotifySound)
+	}
+	if r != nil {
+		panic(r)
+	}
+}
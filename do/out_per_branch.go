@@ -0,0 +1,123 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// with -out-per-branch we keep build output of each branch so that
+// switching between e.g. master and rel3.5working doesn't require
+// rebuilding everything.
+// Output dirs are hard-coded in vs2022 projects generated by premake
+// (out/rel64 etc.) so we can't build directly into out/rel64-${branch}.
+// Instead out/rel64 etc. always have files of the current branch
+// (recorded in out/branch.txt). At startup, if the branch changed, we
+// park them as out/rel64-${old branch} and move out/rel64-${branch}
+// (if exists) back to out/rel64. Manifest, signing and upload only
+// look at out/rel64 etc. so they never see files of other branches.
+// Worktrees have their own out/ so they don't need this
+
+var flgOutPerBranch bool
+
+var outBranchPath = filepath.Join("out", "branch.txt")
+
+// dirs in out/ that are specific to a branch
+var branchOutDirs = []string{"rel32", "rel64", "arm64", "dbg32", "dbg64", "dbgarm64", "final-prerel", "final-rel"}
+
+// "feature/foo" => "feature-foo"
+func getOutBranchSuffix(branch string) string {
+	return urlify(branch)
+}
+
+func getOutDirsBranch() string {
+	d, err := os.ReadFile(outBranchPath)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(d))
+}
+
+// moves out/${dir} => out/${dir}-${branch}, replacing existing
+func parkOutDirMust(dir string, branch string) {
+	src := filepath.Join("out", dir)
+	if !dirExists(src) {
+		return
+	}
+	dst := src + "-" + getOutBranchSuffix(branch)
+	must(os.RemoveAll(dst))
+	must(os.Rename(src, dst))
+}
+
+// moves out/${dir}-${branch} => out/${dir}
+func unparkOutDirMust(dir string, branch string) {
+	src := filepath.Join("out", dir+"-"+getOutBranchSuffix(branch))
+	if !dirExists(src) {
+		return
+	}
+	dst := filepath.Join("out", dir)
+	must(os.RemoveAll(dst))
+	must(os.Rename(src, dst))
+}
+
+func switchOutDirsToCurrentBranchMust() {
+	branch := getCurrentBranchMust()
+	panicIf(branch == "", "-out-per-branch: not on a branch")
+	prev := getOutDirsBranch()
+	if prev == branch {
+		return
+	}
+	acquireBuildLockMust()
+	// without out/branch.txt we don't know whose files are in out/ so we
+	// assume they're ours
+	if prev != "" {
+		logf("switching out/ from branch '%s' to '%s'\n", prev, branch)
+		for _, dir := range branchOutDirs {
+			parkOutDirMust(dir, prev)
+		}
+		for _, dir := range branchOutDirs {
+			unparkOutDirMust(dir, branch)
+		}
+	}
+	writeFileMust(filepath.Join(createDirMust("out"), "branch.txt"), []byte(branch+"\n"))
+}
+
+// returns true if name is a dir in out/ parked for another branch
+func isParkedOutDir(name string) bool {
+	for _, dir := range branchOutDirs {
+		if strings.HasPrefix(name, dir+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// deletes out/ except, with -out-per-branch, dirs of other branches
+func removeOutDirMust() {
+	if !flgOutPerBranch {
+		must(os.RemoveAll("out"))
+		return
+	}
+	entries, err := os.ReadDir("out")
+	if err != nil {
+		return
+	}
+	for _, e := range entries {
+		if e.IsDir() && isParkedOutDir(e.Name()) {
+			continue
+		}
+		must(os.RemoveAll(filepath.Join("out", e.Name())))
+	}
+	writeFileMust(outBranchPath, []byte(getCurrentBranchMust()+"\n"))
+}
+
+// upload must not pick up files built on another branch if someone
+// switched branches while do was running
+func verifyOutDirsBranchMust() {
+	if !flgOutPerBranch {
+		return
+	}
+	branch := getCurrentBranchMust()
+	got := getOutDirsBranch()
+	panicIf(got != branch, "files in out/ were built on branch '%s' but current branch is '%s'", got, branch)
+}
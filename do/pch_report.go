@@ -0,0 +1,320 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// precompiled header health report, generated from a build with /showIncludes.
+// For each project reports:
+// - how many files use the PCH (hit rate)
+// - size of .pch file and time it takes to create it
+// - headers included by most files but not in the PCH (should be in PCH)
+// - headers in the PCH used by few files (should be removed from PCH)
+// projects that don't use a PCH get a list of candidate headers
+// the build is serial (no /m) so that the output of compiler isn't interleaved
+
+const (
+	// headers included by at least this % of files should be in PCH
+	pchCandidatePercent = 50
+	// headers in PCH used by less than this % of files should not be in PCH
+	pchUnusedPercent = 20
+	pchReportTopN    = 20
+)
+
+const pchReportTargets = `<?xml version="1.0" encoding="utf-8"?>
+<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <ItemDefinitionGroup>
+    <ClCompile>
+      <AdditionalOptions>%(AdditionalOptions) /showIncludes /Bt+</AdditionalOptions>
+      <MultiProcessorCompilation>false</MultiProcessorCompilation>
+    </ClCompile>
+  </ItemDefinitionGroup>
+</Project>
+`
+
+var (
+	// Project "D:\sumatrapdf\vs2022\SumatraPDF.sln" (1) is building "D:\sumatrapdf\vs2022\utils.vcxproj" (3) on node 1 (Rebuild target(s)).
+	rxMsbuildProject = regexp.MustCompile(`is building "(.+?\.vcxproj)"`)
+	// Note: including file:   D:\sumatrapdf\src\utils\BaseUtil.h
+	rxShowIncludes = regexp.MustCompile(`Note: including file:( +)(.+)$`)
+	// cl.exe prints name of the file it compiles
+	rxCompiledFile = regexp.MustCompile(`^\s*([\w.\-]+\.(?:c|cpp|cc|cxx))$`)
+)
+
+// PchProject is PCH info for one project
+type PchProject struct {
+	Name string
+	// Use, Create or NotUsing, per file
+	FileModes map[string]string
+	PchHeader string
+	// files that were compiled => headers they included
+	Includes map[string]map[string]bool
+	// headers included by the file that creates the PCH
+	PchIncludes map[string]bool
+	PchSize     int64
+	PchSecs     float64
+}
+
+type vcxprojForPch struct {
+	ItemDefinitionGroups []struct {
+		Condition string `xml:"Condition,attr"`
+		ClCompile struct {
+			PrecompiledHeader     string
+			PrecompiledHeaderFile string
+		} `xml:"ClCompile"`
+	} `xml:"ItemDefinitionGroup"`
+	ItemGroups []struct {
+		ClCompile []struct {
+			Include           string               `xml:"Include,attr"`
+			PrecompiledHeader []vcxprojConditional `xml:"PrecompiledHeader"`
+		} `xml:"ClCompile"`
+	} `xml:"ItemGroup"`
+}
+
+func readPchSettingsMust(projPath string, config string) *PchProject {
+	var p vcxprojForPch
+	err := xml.Unmarshal(readFileMust(projPath), &p)
+	panicIf(err != nil, "failed to parse '%s': %s", projPath, err)
+	res := &PchProject{
+		Name:        strings.TrimSuffix(filepath.Base(projPath), ".vcxproj"),
+		FileModes:   map[string]string{},
+		Includes:    map[string]map[string]bool{},
+		PchIncludes: map[string]bool{},
+	}
+	cond := vcxprojCondition(config)
+	defMode := "NotUsing"
+	for _, g := range p.ItemDefinitionGroups {
+		if g.Condition == cond {
+			if g.ClCompile.PrecompiledHeader != "" {
+				defMode = g.ClCompile.PrecompiledHeader
+			}
+			res.PchHeader = g.ClCompile.PrecompiledHeaderFile
+		}
+	}
+	for _, g := range p.ItemGroups {
+		for _, it := range g.ClCompile {
+			mode := defMode
+			for _, c := range it.PrecompiledHeader {
+				if c.Condition == "" || c.Condition == cond {
+					mode = c.Value
+				}
+			}
+			name := strings.ToLower(filepath.Base(strings.ReplaceAll(it.Include, `\`, "/")))
+			res.FileModes[name] = mode
+		}
+	}
+	return res
+}
+
+// parses msbuild log of a serial build with /showIncludes and /Bt+
+func parsePchBuildLog(s string, projects map[string]*PchProject) {
+	var proj *PchProject
+	var currIncludes map[string]bool
+	currFile := ""
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := rxMsbuildProject.FindStringSubmatch(line); m != nil {
+			name := strings.TrimSuffix(filepath.Base(strings.ReplaceAll(m[1], `\`, "/")), ".vcxproj")
+			proj = projects[name]
+			currIncludes = nil
+			continue
+		}
+		if proj == nil {
+			continue
+		}
+		if m := rxCompiledFile.FindStringSubmatch(line); m != nil {
+			currFile = strings.ToLower(m[1])
+			currIncludes = map[string]bool{}
+			proj.Includes[currFile] = currIncludes
+			continue
+		}
+		if m := rxShowIncludes.FindStringSubmatch(line); m != nil && currIncludes != nil {
+			header := normalizeCompileTimePath(m[2])
+			currIncludes[header] = true
+			if proj.FileModes[currFile] == "Create" {
+				proj.PchIncludes[header] = true
+			}
+			continue
+		}
+		if m := rxBtTime.FindStringSubmatch(line); m != nil {
+			name := strings.ToLower(filepath.Base(strings.ReplaceAll(m[3], `\`, "/")))
+			if proj.FileModes[name] == "Create" {
+				var secs float64
+				fmt.Sscanf(m[2], "%f", &secs)
+				proj.PchSecs += secs
+			}
+		}
+	}
+}
+
+// .pch files are in IntDir which ends with project name
+func findPchSizes(dir string, projects map[string]*PchProject) {
+	_ = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !strings.EqualFold(filepath.Ext(path), ".pch") {
+			return nil
+		}
+		proj := projects[filepath.Base(filepath.Dir(path))]
+		if proj == nil {
+			return nil
+		}
+		if fi, err := d.Info(); err == nil {
+			proj.PchSize += fi.Size()
+		}
+		return nil
+	})
+}
+
+type headerCount struct {
+	Header string
+	Count  int
+}
+
+func countHeaders(includes map[string]map[string]bool) []*headerCount {
+	counts := map[string]int{}
+	for _, headers := range includes {
+		for h := range headers {
+			counts[h]++
+		}
+	}
+	var res []*headerCount
+	for h, n := range counts {
+		res = append(res, &headerCount{Header: h, Count: n})
+	}
+	sort.Slice(res, func(i, j int) bool {
+		if res[i].Count != res[j].Count {
+			return res[i].Count > res[j].Count
+		}
+		return res[i].Header < res[j].Header
+	})
+	return res
+}
+
+// headers in PCH are not reported by /showIncludes in files that use the PCH
+// so to see which files need them we look at #include directives in sources
+func countDirectIncludes(files []string, header string) int {
+	name := strings.ToLower(filepath.Base(strings.ReplaceAll(header, `\`, "/")))
+	n := 0
+	for _, path := range files {
+		d, err := os.ReadFile(path)
+		if err != nil {
+			continue
+		}
+		for _, line := range strings.Split(strings.ToLower(string(d)), "\n") {
+			line = strings.TrimSpace(line)
+			if strings.HasPrefix(line, "#include") && strings.Contains(line, name) {
+				n++
+				break
+			}
+		}
+	}
+	return n
+}
+
+func fmtPchProjectReport(proj *PchProject, sources []string) string {
+	nUse, nCreate := 0, 0
+	for _, mode := range proj.FileModes {
+		switch mode {
+		case "Use":
+			nUse++
+		case "Create":
+			nCreate++
+		}
+	}
+	nFiles := len(proj.FileModes)
+	s := fmt.Sprintf("\n%s: %d files\n", proj.Name, nFiles)
+	if nCreate == 0 {
+		s += "  doesn't use precompiled header. Headers included by most files (candidates for PCH):\n"
+		for i, hc := range countHeaders(proj.Includes) {
+			if i >= pchReportTopN || hc.Count*100 < len(proj.Includes)*pchCandidatePercent {
+				break
+			}
+			s += fmt.Sprintf("  %5d  %s\n", hc.Count, hc.Header)
+		}
+		return s
+	}
+
+	s += fmt.Sprintf("  PCH: %s, %d of %d files use it (%.0f%%)\n", proj.PchHeader, nUse, nFiles-nCreate, float64(nUse*100)/float64(nFiles-nCreate))
+	s += fmt.Sprintf("  PCH size: %s, creation time: %.2f s, %d headers\n", formatSize(proj.PchSize), proj.PchSecs, len(proj.PchIncludes))
+	s += "  headers not in PCH included by most files (should be in PCH):\n"
+	for _, hc := range countHeaders(proj.Includes) {
+		if hc.Count*100 < len(proj.Includes)*pchCandidatePercent {
+			break
+		}
+		if !proj.PchIncludes[hc.Header] {
+			s += fmt.Sprintf("  %5d  %s\n", hc.Count, hc.Header)
+		}
+	}
+	s += "  headers in PCH used by few files (should not be in PCH):\n"
+	var pchHeaders []string
+	for h := range proj.PchIncludes {
+		pchHeaders = append(pchHeaders, h)
+	}
+	sort.Strings(pchHeaders)
+	for _, h := range pchHeaders {
+		// system headers are cheap to keep in PCH
+		if !strings.HasPrefix(h, "src/") {
+			continue
+		}
+		n := countDirectIncludes(sources, h)
+		if n*100 < len(sources)*pchUnusedPercent {
+			s += fmt.Sprintf("  %5d  %s\n", n, h)
+		}
+	}
+	return s
+}
+
+func pchReport() {
+	const config = "Release|x64"
+	outDir := filepath.Join("out", "pch-report")
+	must(os.RemoveAll(outDir))
+	createDirMust(outDir)
+	targetsPath := absPathMust(filepath.Join(outDir, "pch-report.targets"))
+	writeFileMust(targetsPath, []byte(pchReportTargets))
+
+	projPaths, err := filepath.Glob(filepath.Join("vs2022", "*.vcxproj"))
+	must(err)
+	projects := map[string]*PchProject{}
+	sources := map[string][]string{}
+	for _, path := range projPaths {
+		proj := readPchSettingsMust(path, config)
+		projects[proj.Name] = proj
+		cmds, err := compileCommandsForVcxproj(path, config)
+		must(err)
+		for _, c := range cmds {
+			sources[proj.Name] = append(sources[proj.Name], c.File)
+		}
+	}
+
+	logPath := filepath.Join(outDir, "msbuild.log")
+	msbuildPath := detectMsbuildPath()
+	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
+	parts := strings.Split(config, "|")
+	p := fmt.Sprintf(`/p:Configuration=%s;Platform=%s`, parts[0], parts[1])
+	flp := fmt.Sprintf(`/flp:logfile=%s;verbosity=normal`, logPath)
+	runExeLoggedMust(msbuildPath, slnPath, `/t:SumatraPDF:Rebuild`, p, "/p:ForceImportAfterCppTargets="+targetsPath, `/m:1`, `/fl`, flp)
+
+	parsePchBuildLog(string(readFileMust(logPath)), projects)
+	findPchSizes(filepath.Join("out", "rel64"), projects)
+
+	var names []string
+	for name, proj := range projects {
+		if len(proj.Includes) > 0 {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	report := "precompiled header report for " + config + "\n"
+	for _, name := range names {
+		report += fmtPchProjectReport(projects[name], sources[name])
+	}
+	reportPath := filepath.Join(outDir, "report.txt")
+	writeFileMust(reportPath, []byte(report))
+	logf("%s\nwrote '%s'\n", report, reportPath)
+}
@@ -0,0 +1,283 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+)
+
+// minimal reader of .pdb files i.e. MSF (multi-stream file) container
+// https://llvm.org/docs/PDB/MsfFile.html
+
+var msfMagic = []byte("Microsoft C/C++ MSF 7.00\r\n\x1aDS\x00\x00\x00")
+
+const (
+	pdbStreamInfo = 1
+	pdbStreamDbi  = 3
+)
+
+// MsfFile is a parsed MSF container
+type MsfFile struct {
+	d            []byte
+	blockSize    uint32
+	streamSizes  []uint32
+	streamBlocks [][]uint32
+}
+
+func (m *MsfFile) block(idx uint32) ([]byte, error) {
+	off := uint64(idx) * uint64(m.blockSize)
+	if off+uint64(m.blockSize) > uint64(len(m.d)) {
+		return nil, fmt.Errorf("block %d out of range", idx)
+	}
+	return m.d[off : off+uint64(m.blockSize)], nil
+}
+
+func (m *MsfFile) readBlocks(blocks []uint32, size uint32) ([]byte, error) {
+	res := make([]byte, 0, size)
+	for _, idx := range blocks {
+		b, err := m.block(idx)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, b...)
+	}
+	if uint32(len(res)) < size {
+		return nil, fmt.Errorf("stream is truncated")
+	}
+	return res[:size], nil
+}
+
+func numBlocks(size uint32, blockSize uint32) uint32 {
+	return (size + blockSize - 1) / blockSize
+}
+
+func parseMsf(d []byte) (*MsfFile, error) {
+	if len(d) < 56 || !bytes.HasPrefix(d, msfMagic) {
+		return nil, fmt.Errorf("not a .pdb file")
+	}
+	m := &MsfFile{
+		d:         d,
+		blockSize: binary.LittleEndian.Uint32(d[32:]),
+	}
+	switch m.blockSize {
+	case 512, 1024, 2048, 4096:
+	default:
+		return nil, fmt.Errorf("invalid block size %d", m.blockSize)
+	}
+	nDirBytes := binary.LittleEndian.Uint32(d[44:])
+	blockMapAddr := binary.LittleEndian.Uint32(d[52:])
+	blockMap, err := m.block(blockMapAddr)
+	if err != nil {
+		return nil, err
+	}
+	nDirBlocks := numBlocks(nDirBytes, m.blockSize)
+	if nDirBlocks*4 > m.blockSize {
+		return nil, fmt.Errorf("stream directory too big")
+	}
+	var dirBlocks []uint32
+	for i := uint32(0); i < nDirBlocks; i++ {
+		dirBlocks = append(dirBlocks, binary.LittleEndian.Uint32(blockMap[i*4:]))
+	}
+	dir, err := m.readBlocks(dirBlocks, nDirBytes)
+	if err != nil {
+		return nil, fmt.Errorf("stream directory: %w", err)
+	}
+	if len(dir) < 4 {
+		return nil, fmt.Errorf("stream directory is truncated")
+	}
+	nStreams := binary.LittleEndian.Uint32(dir)
+	off := uint32(4)
+	if uint64(off)+uint64(nStreams)*4 > uint64(len(dir)) {
+		return nil, fmt.Errorf("stream directory is truncated")
+	}
+	for i := uint32(0); i < nStreams; i++ {
+		size := binary.LittleEndian.Uint32(dir[off:])
+		// deleted stream
+		if size == 0xffffffff {
+			size = 0
+		}
+		m.streamSizes = append(m.streamSizes, size)
+		off += 4
+	}
+	for _, size := range m.streamSizes {
+		n := numBlocks(size, m.blockSize)
+		if uint64(off)+uint64(n)*4 > uint64(len(dir)) {
+			return nil, fmt.Errorf("stream directory is truncated")
+		}
+		var blocks []uint32
+		for i := uint32(0); i < n; i++ {
+			blocks = append(blocks, binary.LittleEndian.Uint32(dir[off:]))
+			off += 4
+		}
+		m.streamBlocks = append(m.streamBlocks, blocks)
+	}
+	return m, nil
+}
+
+// returns data of stream idx
+func (m *MsfFile) Stream(idx int) ([]byte, error) {
+	if idx < 0 || idx >= len(m.streamSizes) {
+		return nil, fmt.Errorf("no stream %d", idx)
+	}
+	return m.readBlocks(m.streamBlocks[idx], m.streamSizes[idx])
+}
+
+// PdbInfo identifies a .pdb. Must match PeCodeView of the binary
+type PdbInfo struct {
+	GUID string
+	Age  uint32
+}
+
+func pdbReadInfo(d []byte) (*PdbInfo, error) {
+	m, err := parseMsf(d)
+	if err != nil {
+		return nil, err
+	}
+	// PDB info stream: version, signature, age, guid
+	info, err := m.Stream(pdbStreamInfo)
+	if err != nil {
+		return nil, err
+	}
+	if len(info) < 28 {
+		return nil, fmt.Errorf("pdb info stream is truncated")
+	}
+	res := &PdbInfo{
+		GUID: fmtGUID(info[12:28]),
+		Age:  binary.LittleEndian.Uint32(info[8:]),
+	}
+	// debuggers compare with age in DBI stream header: signature, version, age
+	if dbi, err := m.Stream(pdbStreamDbi); err == nil && len(dbi) >= 12 {
+		res.Age = binary.LittleEndian.Uint32(dbi[8:])
+	}
+	return res, nil
+}
+
+// checks that all stream blocks are within the file and that no block
+// belongs to more than one stream
+func (m *MsfFile) CheckIntegrity() error {
+	nBlocks := binary.LittleEndian.Uint32(m.d[40:])
+	if uint64(nBlocks)*uint64(m.blockSize) > uint64(len(m.d)) {
+		return fmt.Errorf("file has %d bytes, expected %d blocks of %d bytes", len(m.d), nBlocks, m.blockSize)
+	}
+	owner := map[uint32]int{}
+	for i, blocks := range m.streamBlocks {
+		for _, b := range blocks {
+			if b == 0 || b >= nBlocks {
+				return fmt.Errorf("stream %d: block %d out of range", i, b)
+			}
+			if prev, ok := owner[b]; ok {
+				return fmt.Errorf("block %d is used by streams %d and %d", b, prev, i)
+			}
+			owner[b] = i
+		}
+	}
+	return nil
+}
+
+// PdbStats is a rough summary of .pdb contents
+type PdbStats struct {
+	Info      *PdbInfo
+	NStreams  int
+	NModules  int
+	NSymbols  int
+	NPublics  int
+	SizeBytes int
+}
+
+const (
+	// size of fixed part of module info record in DBI stream
+	pdbModInfoSize = 64
+	symPub32       = 0x110e
+)
+
+// counts module info records in module info substream of DBI stream
+func pdbCountModules(modInfo []byte) (int, error) {
+	n := 0
+	for off := 0; off < len(modInfo); {
+		if off+pdbModInfoSize > len(modInfo) {
+			return 0, fmt.Errorf("module info %d is truncated", n)
+		}
+		off += pdbModInfoSize
+		// module name and object file name, zero-terminated
+		for i := 0; i < 2; i++ {
+			idx := bytes.IndexByte(modInfo[off:], 0)
+			if idx < 0 {
+				return 0, fmt.Errorf("module info %d is truncated", n)
+			}
+			off += idx + 1
+		}
+		off = (off + 3) &^ 3
+		n++
+	}
+	return n, nil
+}
+
+// returns number of all symbol records and of public symbols
+func pdbCountSymbols(d []byte) (int, int, error) {
+	nSyms, nPublics := 0, 0
+	for off := 0; off < len(d); {
+		if off+4 > len(d) {
+			return 0, 0, fmt.Errorf("symbol record at %d is truncated", off)
+		}
+		size := int(binary.LittleEndian.Uint16(d[off:]))
+		kind := binary.LittleEndian.Uint16(d[off+2:])
+		if size < 2 || off+2+size > len(d) {
+			return 0, 0, fmt.Errorf("symbol record at %d has invalid size %d", off, size)
+		}
+		nSyms++
+		if kind == symPub32 {
+			nPublics++
+		}
+		off += 2 + size
+	}
+	return nSyms, nPublics, nil
+}
+
+// validates structure of .pdb and returns its summary
+func pdbReadStats(d []byte) (*PdbStats, error) {
+	m, err := parseMsf(d)
+	if err != nil {
+		return nil, err
+	}
+	if err = m.CheckIntegrity(); err != nil {
+		return nil, err
+	}
+	for i := range m.streamSizes {
+		if _, err = m.Stream(i); err != nil {
+			return nil, fmt.Errorf("stream %d: %w", i, err)
+		}
+	}
+	info, err := pdbReadInfo(d)
+	if err != nil {
+		return nil, err
+	}
+	res := &PdbStats{
+		Info:      info,
+		NStreams:  len(m.streamSizes),
+		SizeBytes: len(d),
+	}
+	dbi, err := m.Stream(pdbStreamDbi)
+	if err != nil {
+		return nil, err
+	}
+	// DBI stream header is 64 bytes, followed by module info substream
+	if len(dbi) < 64 {
+		return nil, fmt.Errorf("DBI stream is truncated")
+	}
+	symRecordStream := int(binary.LittleEndian.Uint16(dbi[20:]))
+	modInfoSize := int(binary.LittleEndian.Uint32(dbi[24:]))
+	if 64+modInfoSize > len(dbi) {
+		return nil, fmt.Errorf("DBI module info substream is truncated")
+	}
+	if res.NModules, err = pdbCountModules(dbi[64 : 64+modInfoSize]); err != nil {
+		return nil, err
+	}
+	syms, err := m.Stream(symRecordStream)
+	if err != nil {
+		return nil, fmt.Errorf("symbol records stream: %w", err)
+	}
+	if res.NSymbols, res.NPublics, err = pdbCountSymbols(syms); err != nil {
+		return nil, err
+	}
+	return res, nil
+}
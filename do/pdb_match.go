@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// before archiving .pdb files we check that GUID and age of each .pdb
+// match CodeView info in the debug directory of the binary built with it.
+// After partial rebuilds they can get out of sync and mismatched symbols
+// in the symbol store are worse than none
+
+// returns problems
+func checkPdbMatch(binName string, cv *PeCodeView, pdbName string, info *PdbInfo) []string {
+	var res []string
+	if !strings.EqualFold(filepath.Base(strings.ReplaceAll(cv.PdbPath, `\`, "/")), pdbName) {
+		res = append(res, fmt.Sprintf("%s: refers to '%s', expected %s", binName, cv.PdbPath, pdbName))
+	}
+	if cv.GUID != info.GUID || cv.Age != info.Age {
+		res = append(res, fmt.Sprintf("%s: GUID %s age %d doesn't match %s: GUID %s age %d", binName, cv.GUID, cv.Age, pdbName, info.GUID, info.Age))
+	}
+	return res
+}
+
+// a .pdb with fewer public symbols is most likely broken
+const pdbMinPublics = 100
+
+// returns path of llvm-pdbutil or "" if not installed
+func findLlvmPdbutil() (path string) {
+	defer func() {
+		if r := recover(); r != nil {
+			path = ""
+		}
+	}()
+	return detectLlvmPdbutilMust()
+}
+
+// checks integrity of .pdb and that it has symbols. Structure is checked
+// with our parser (see pdb.go) so that it works on any machine and,
+// if installed, also with llvm-pdbutil. Returns problems
+func validatePdb(pdbPath string) []string {
+	stats, err := pdbReadStats(readFileMust(pdbPath))
+	if err != nil {
+		return []string{fmt.Sprintf("%s: %s", pdbPath, err)}
+	}
+	logf("%s: %s, %d streams, %d modules, %d symbols (%d public)\n", pdbPath, formatSize(int64(stats.SizeBytes)), stats.NStreams, stats.NModules, stats.NSymbols, stats.NPublics)
+	var res []string
+	if stats.NModules == 0 || stats.NPublics < pdbMinPublics {
+		res = append(res, fmt.Sprintf("%s: only %d modules and %d public symbols", pdbPath, stats.NModules, stats.NPublics))
+	}
+	if pdbutil := findLlvmPdbutil(); pdbutil != "" {
+		out, err := exec.Command(pdbutil, "dump", "-summary", pdbPath).CombinedOutput()
+		if err != nil {
+			res = append(res, fmt.Sprintf("%s: llvm-pdbutil dump -summary failed with '%s':\n%s", pdbPath, err, out))
+		}
+	}
+	return res
+}
+
+// checks pdbFiles in dir against binaries built with them
+func verifyPdbsMatchMust(dir string) {
+	defer failOnPanic(errKindVerify)
+	var problems []string
+	for _, pdbName := range pdbFiles {
+		binName := strings.TrimSuffix(pdbName, ".pdb") + ".exe"
+		if pdbName == "libmupdf.pdb" {
+			binName = "libmupdf.dll"
+		}
+		binPath := filepath.Join(dir, binName)
+		pdbPath := filepath.Join(dir, pdbName)
+		cv, err := peCodeView(readFileMust(binPath))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", binPath, err))
+			continue
+		}
+		info, err := pdbReadInfo(readFileMust(pdbPath))
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: %s", pdbPath, err))
+			continue
+		}
+		problems = append(problems, checkPdbMatch(binName, cv, pdbName, info)...)
+		problems = append(problems, validatePdb(pdbPath)...)
+	}
+	panicIf(len(problems) > 0, "pdb files in '%s' don't match binaries:\n%s\n", dir, strings.Join(problems, "\n"))
+	logf("verifyPdbsMatchMust: %d pdb files in '%s' match\n", len(pdbFiles), dir)
+}
@@ -0,0 +1,696 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"debug/pe"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"math/big"
+	"strings"
+	"time"
+	"unicode/utf16"
+)
+
+// VS_FIXEDFILEINFO starts with this signature
+const vsFixedFileInfoSig = 0xFEEF04BD
+
+// reads the version from VS_FIXEDFILEINFO of the first version resource
+// returns version as "3.5.2.0"
+func peFileVersion(d []byte) (string, error) {
+	f, err := pe.NewFile(bytes.NewReader(d))
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+	sec := f.Section(".rsrc")
+	if sec == nil {
+		return "", fmt.Errorf("no .rsrc section")
+	}
+	rsrc, err := sec.Data()
+	if err != nil {
+		return "", err
+	}
+	var sig [4]byte
+	binary.LittleEndian.PutUint32(sig[:], vsFixedFileInfoSig)
+	idx := bytes.Index(rsrc, sig[:])
+	// signature, struct version, file version ms, file version ls
+	if idx < 0 || idx+16 > len(rsrc) {
+		return "", fmt.Errorf("no VS_FIXEDFILEINFO in .rsrc")
+	}
+	ms := binary.LittleEndian.Uint32(rsrc[idx+8:])
+	ls := binary.LittleEndian.Uint32(rsrc[idx+12:])
+	ver := fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xffff, ls>>16, ls&0xffff)
+	return ver, nil
+}
+
+var (
+	oidSignedData       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidSigningTime      = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 5}
+	oidCounterSignature = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 6}
+	oidRFC3161Timestamp = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 3, 3, 1}
+	oidNestedSignature  = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 311, 2, 4, 1}
+	oidTSTInfo          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidDigestSha1       = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidDigestSha256     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidDigestSha384     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidDigestSha512     = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+)
+
+const imageDirectoryEntrySecurity = 4
+
+type pkcs7ContentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type pkcs7SignedData struct {
+	Version          int
+	DigestAlgorithms asn1.RawValue
+	ContentInfo      pkcs7ContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      asn1.RawValue
+}
+
+type pkcs7IssuerAndSerial struct {
+	Issuer asn1.RawValue
+	Serial *big.Int
+}
+
+type pkcs7SignerInfo struct {
+	Version                   int
+	IssuerAndSerial           pkcs7IssuerAndSerial
+	DigestAlgorithm           pkix.AlgorithmIdentifier
+	AuthenticatedAttributes   asn1.RawValue `asn1:"optional,tag:0"`
+	DigestEncryptionAlgorithm pkix.AlgorithmIdentifier
+	EncryptedDigest           []byte
+	UnauthenticatedAttributes asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type pkcs7Attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint asn1.RawValue
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+// AuthenticodeSig describes one Authenticode signature of a PE file
+type AuthenticodeSig struct {
+	DigestAlg  string // "sha1", "sha256"
+	Signer     *x509.Certificate
+	Thumbprint string // sha1 of signer certificate, as shown by Windows
+	// "rfc3161" or "authenticode" (legacy) or "" if not timestamped
+	TimestampKind string
+	TimestampTime time.Time
+	TSA           *x509.Certificate
+}
+
+func digestAlgName(oid asn1.ObjectIdentifier) string {
+	switch {
+	case oid.Equal(oidDigestSha1):
+		return "sha1"
+	case oid.Equal(oidDigestSha256):
+		return "sha256"
+	case oid.Equal(oidDigestSha384):
+		return "sha384"
+	case oid.Equal(oidDigestSha512):
+		return "sha512"
+	}
+	return oid.String()
+}
+
+func certThumbprint(cert *x509.Certificate) string {
+	h := sha1.Sum(cert.Raw)
+	return strings.ToUpper(hex.EncodeToString(h[:]))
+}
+
+// parses a sequence of DER encoded values
+func asn1ParseAll[T any](d []byte) ([]*T, error) {
+	var res []*T
+	for len(d) > 0 {
+		var v T
+		rest, err := asn1.Unmarshal(d, &v)
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, &v)
+		d = rest
+	}
+	return res, nil
+}
+
+func findCertBySerial(certs []*x509.Certificate, serial *big.Int) *x509.Certificate {
+	for _, c := range certs {
+		if serial != nil && c.SerialNumber.Cmp(serial) == 0 {
+			return c
+		}
+	}
+	return nil
+}
+
+func parseSignedData(d []byte) (*pkcs7SignedData, []*x509.Certificate, error) {
+	var ci pkcs7ContentInfo
+	_, err := asn1.Unmarshal(d, &ci)
+	if err != nil {
+		return nil, nil, err
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, nil, fmt.Errorf("not SignedData but %s", ci.ContentType)
+	}
+	var sd pkcs7SignedData
+	_, err = asn1.Unmarshal(ci.Content.Bytes, &sd)
+	if err != nil {
+		return nil, nil, err
+	}
+	var certs []*x509.Certificate
+	if len(sd.Certificates.Bytes) > 0 {
+		certs, err = x509.ParseCertificates(sd.Certificates.Bytes)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	return &sd, certs, nil
+}
+
+func parseRFC3161Timestamp(d []byte, sig *AuthenticodeSig) error {
+	sd, certs, err := parseSignedData(d)
+	if err != nil {
+		return err
+	}
+	if !sd.ContentInfo.ContentType.Equal(oidTSTInfo) {
+		return fmt.Errorf("timestamp content is %s, not TSTInfo", sd.ContentInfo.ContentType)
+	}
+	// eContent is OCTET STRING with DER encoded TSTInfo
+	var octets []byte
+	_, err = asn1.Unmarshal(sd.ContentInfo.Content.Bytes, &octets)
+	if err != nil {
+		return err
+	}
+	var tst tstInfo
+	_, err = asn1.Unmarshal(octets, &tst)
+	if err != nil {
+		return err
+	}
+	sig.TimestampKind = "rfc3161"
+	sig.TimestampTime = tst.GenTime
+	signers, err := asn1ParseAll[pkcs7SignerInfo](sd.SignerInfos.Bytes)
+	if err == nil && len(signers) > 0 {
+		sig.TSA = findCertBySerial(certs, signers[0].IssuerAndSerial.Serial)
+	}
+	return nil
+}
+
+func parseAuthenticodeSignedData(d []byte) ([]*AuthenticodeSig, error) {
+	sd, certs, err := parseSignedData(d)
+	if err != nil {
+		return nil, err
+	}
+	signers, err := asn1ParseAll[pkcs7SignerInfo](sd.SignerInfos.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	var res []*AuthenticodeSig
+	for _, si := range signers {
+		sig := &AuthenticodeSig{
+			DigestAlg: digestAlgName(si.DigestAlgorithm.Algorithm),
+			Signer:    findCertBySerial(certs, si.IssuerAndSerial.Serial),
+		}
+		if sig.Signer != nil {
+			sig.Thumbprint = certThumbprint(sig.Signer)
+		}
+		res = append(res, sig)
+
+		attrs, err := asn1ParseAll[pkcs7Attribute](si.UnauthenticatedAttributes.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		for _, attr := range attrs {
+			v := attr.Values.Bytes
+			switch {
+			case attr.Type.Equal(oidRFC3161Timestamp):
+				if err = parseRFC3161Timestamp(v, sig); err != nil {
+					return nil, err
+				}
+			case attr.Type.Equal(oidCounterSignature):
+				var cs pkcs7SignerInfo
+				if _, err = asn1.Unmarshal(v, &cs); err != nil {
+					return nil, err
+				}
+				sig.TimestampKind = "authenticode"
+				sig.TSA = findCertBySerial(certs, cs.IssuerAndSerial.Serial)
+				authAttrs, _ := asn1ParseAll[pkcs7Attribute](cs.AuthenticatedAttributes.Bytes)
+				for _, a := range authAttrs {
+					if a.Type.Equal(oidSigningTime) {
+						_, _ = asn1.Unmarshal(a.Values.Bytes, &sig.TimestampTime)
+					}
+				}
+			case attr.Type.Equal(oidNestedSignature):
+				// signatures appended with signtool /as
+				nested, err := parseAuthenticodeSignedData(v)
+				if err != nil {
+					return nil, err
+				}
+				res = append(res, nested...)
+			}
+		}
+	}
+	return res, nil
+}
+
+// returns raw content of certificate table (IMAGE_DIRECTORY_ENTRY_SECURITY)
+func peCertificateTable(d []byte) ([]byte, error) {
+	f, err := pe.NewFile(bytes.NewReader(d))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var dir pe.DataDirectory
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		dir = oh.DataDirectory[imageDirectoryEntrySecurity]
+	case *pe.OptionalHeader64:
+		dir = oh.DataDirectory[imageDirectoryEntrySecurity]
+	default:
+		return nil, fmt.Errorf("no optional header")
+	}
+	// for security directory VirtualAddress is a file offset
+	start := int(dir.VirtualAddress)
+	end := start + int(dir.Size)
+	if dir.Size == 0 {
+		return nil, nil
+	}
+	if start < 0 || end > len(d) {
+		return nil, fmt.Errorf("invalid certificate table at %d, size %d", start, dir.Size)
+	}
+	return d[start:end], nil
+}
+
+// returns all Authenticode signatures (including nested) of a PE file
+// returns empty slice if file is not signed
+func peAuthenticodeSigs(d []byte) ([]*AuthenticodeSig, error) {
+	table, err := peCertificateTable(d)
+	if err != nil {
+		return nil, err
+	}
+	var res []*AuthenticodeSig
+	// WIN_CERTIFICATE entries, each 8-byte aligned
+	for len(table) >= 8 {
+		n := int(binary.LittleEndian.Uint32(table))
+		certType := binary.LittleEndian.Uint16(table[6:])
+		if n < 8 || n > len(table) {
+			return nil, fmt.Errorf("invalid WIN_CERTIFICATE length %d", n)
+		}
+		// WIN_CERT_TYPE_PKCS_SIGNED_DATA
+		if certType == 2 {
+			sigs, err := parseAuthenticodeSignedData(table[8:n])
+			if err != nil {
+				return nil, err
+			}
+			res = append(res, sigs...)
+		}
+		n = (n + 7) &^ 7
+		if n > len(table) {
+			break
+		}
+		table = table[n:]
+	}
+	return res, nil
+}
+
+func peAuthenticodeSigsMust(path string) []*AuthenticodeSig {
+	d := readFileMust(path)
+	sigs, err := peAuthenticodeSigs(d)
+	panicIf(err != nil, "failed to parse signatures of '%s': %s", path, err)
+	return sigs
+}
+
+// resource type for RCDATA
+const peResourceTypeRCData = 10
+
+// returns data of the resource with a given type and integer id (first language)
+func peFindResource(d []byte, typ uint32, id uint32) ([]byte, error) {
+	f, err := pe.NewFile(bytes.NewReader(d))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	sec := f.Section(".rsrc")
+	if sec == nil {
+		return nil, fmt.Errorf("no .rsrc section")
+	}
+	rsrc, err := sec.Data()
+	if err != nil {
+		return nil, err
+	}
+	// IMAGE_RESOURCE_DIRECTORY is followed by IMAGE_RESOURCE_DIRECTORY_ENTRY
+	// entries. high bit of offset means it points to another directory
+	findEntry := func(off uint32, id uint32, anyID bool) (uint32, bool) {
+		if int(off)+16 > len(rsrc) {
+			return 0, false
+		}
+		nNamed := uint32(binary.LittleEndian.Uint16(rsrc[off+12:]))
+		nIDs := uint32(binary.LittleEndian.Uint16(rsrc[off+14:]))
+		for i := uint32(0); i < nNamed+nIDs; i++ {
+			e := off + 16 + i*8
+			if int(e)+8 > len(rsrc) {
+				return 0, false
+			}
+			name := binary.LittleEndian.Uint32(rsrc[e:])
+			if anyID || (name&0x80000000 == 0 && name == id) {
+				return binary.LittleEndian.Uint32(rsrc[e+4:]), true
+			}
+		}
+		return 0, false
+	}
+	off, ok := findEntry(0, typ, false)
+	if !ok || off&0x80000000 == 0 {
+		return nil, fmt.Errorf("no resources of type %d", typ)
+	}
+	off, ok = findEntry(off&0x7fffffff, id, false)
+	if !ok || off&0x80000000 == 0 {
+		return nil, fmt.Errorf("no resource of type %d with id %d", typ, id)
+	}
+	off, ok = findEntry(off&0x7fffffff, 0, true)
+	if !ok || off&0x80000000 != 0 || int(off)+16 > len(rsrc) {
+		return nil, fmt.Errorf("invalid resource of type %d with id %d", typ, id)
+	}
+	// IMAGE_RESOURCE_DATA_ENTRY: rva, size
+	rva := binary.LittleEndian.Uint32(rsrc[off:])
+	size := binary.LittleEndian.Uint32(rsrc[off+4:])
+	start := int(rva) - int(sec.VirtualAddress)
+	end := start + int(size)
+	if start < 0 || end > len(rsrc) {
+		return nil, fmt.Errorf("invalid resource data at rva 0x%x, size %d", rva, size)
+	}
+	return rsrc[start:end], nil
+}
+
+// resource type and id of VS_VERSIONINFO
+const (
+	peResourceTypeVersion = 16
+	peVersionInfoID       = 1
+)
+
+// PeVersionInfo is parsed VS_VERSIONINFO resource
+type PeVersionInfo struct {
+	// from VS_FIXEDFILEINFO, "3.5.2.0"
+	FileVersion    string
+	ProductVersion string
+	// values from the first string table of StringFileInfo
+	// e.g. "CompanyName", "LegalCopyright"
+	Strings map[string]string
+}
+
+func utf16BytesToString(d []byte) string {
+	var a []uint16
+	for i := 0; i+1 < len(d); i += 2 {
+		c := binary.LittleEndian.Uint16(d[i:])
+		if c == 0 {
+			break
+		}
+		a = append(a, c)
+	}
+	return string(utf16.Decode(a))
+}
+
+// VS_VERSIONINFO, StringFileInfo, StringTable and String have the same layout:
+// wLength, wValueLength, wType, szKey, padding, Value, padding, Children
+type peVersionNode struct {
+	Key      string
+	Value    []byte
+	Children []*peVersionNode
+}
+
+func parsePeVersionNode(d []byte, off int) (*peVersionNode, int, error) {
+	if off+6 > len(d) {
+		return nil, 0, fmt.Errorf("truncated version node at %d", off)
+	}
+	length := int(binary.LittleEndian.Uint16(d[off:]))
+	valueLen := int(binary.LittleEndian.Uint16(d[off+2:]))
+	isText := binary.LittleEndian.Uint16(d[off+4:]) == 1
+	end := off + length
+	if length < 6 || end > len(d) {
+		return nil, 0, fmt.Errorf("invalid length %d of version node at %d", length, off)
+	}
+	align4 := func(n int) int { return (n + 3) &^ 3 }
+	pos := off + 6
+	keyStart := pos
+	for pos+1 < end && (d[pos] != 0 || d[pos+1] != 0) {
+		pos += 2
+	}
+	node := &peVersionNode{Key: utf16BytesToString(d[keyStart:pos])}
+	pos = align4(pos + 2)
+	if isText {
+		// in WORDs
+		valueLen *= 2
+	}
+	if pos+valueLen > end {
+		valueLen = end - pos
+	}
+	if valueLen > 0 {
+		node.Value = d[pos : pos+valueLen]
+	}
+	pos = align4(pos + valueLen)
+	for pos < end {
+		child, next, err := parsePeVersionNode(d, pos)
+		if err != nil {
+			return nil, 0, err
+		}
+		node.Children = append(node.Children, child)
+		pos = align4(next)
+	}
+	return node, end, nil
+}
+
+func peVersionInfo(d []byte) (*PeVersionInfo, error) {
+	rsrc, err := peFindResource(d, peResourceTypeVersion, peVersionInfoID)
+	if err != nil {
+		return nil, err
+	}
+	root, _, err := parsePeVersionNode(rsrc, 0)
+	if err != nil {
+		return nil, err
+	}
+	if root.Key != "VS_VERSION_INFO" || len(root.Value) < 24 || binary.LittleEndian.Uint32(root.Value) != vsFixedFileInfoSig {
+		return nil, fmt.Errorf("invalid VS_VERSIONINFO")
+	}
+	fmtVer := func(ms, ls uint32) string {
+		return fmt.Sprintf("%d.%d.%d.%d", ms>>16, ms&0xffff, ls>>16, ls&0xffff)
+	}
+	v := root.Value
+	res := &PeVersionInfo{
+		FileVersion:    fmtVer(binary.LittleEndian.Uint32(v[8:]), binary.LittleEndian.Uint32(v[12:])),
+		ProductVersion: fmtVer(binary.LittleEndian.Uint32(v[16:]), binary.LittleEndian.Uint32(v[20:])),
+		Strings:        map[string]string{},
+	}
+	for _, c := range root.Children {
+		if c.Key != "StringFileInfo" || len(c.Children) == 0 {
+			continue
+		}
+		for _, s := range c.Children[0].Children {
+			res.Strings[s.Key] = utf16BytesToString(s.Value)
+		}
+	}
+	return res, nil
+}
+
+const (
+	imageDirectoryEntryExport      = 0
+	imageDirectoryEntryDelayImport = 13
+)
+
+func peDataDirectory(f *pe.File, idx int) pe.DataDirectory {
+	switch oh := f.OptionalHeader.(type) {
+	case *pe.OptionalHeader32:
+		return oh.DataDirectory[idx]
+	case *pe.OptionalHeader64:
+		return oh.DataDirectory[idx]
+	}
+	return pe.DataDirectory{}
+}
+
+// returns data from rva until the end of the section that contains it
+func peDataAtRVA(f *pe.File, rva uint32) []byte {
+	for _, sec := range f.Sections {
+		if rva < sec.VirtualAddress || rva >= sec.VirtualAddress+sec.VirtualSize {
+			continue
+		}
+		d, err := sec.Data()
+		off := rva - sec.VirtualAddress
+		if err != nil || int(off) >= len(d) {
+			return nil
+		}
+		return d[off:]
+	}
+	return nil
+}
+
+func peCStringAtRVA(f *pe.File, rva uint32) string {
+	d := peDataAtRVA(f, rva)
+	if idx := bytes.IndexByte(d, 0); idx >= 0 {
+		return string(d[:idx])
+	}
+	return ""
+}
+
+// returns names of exported functions
+func peExports(d []byte) ([]string, error) {
+	f, err := pe.NewFile(bytes.NewReader(d))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dir := peDataDirectory(f, imageDirectoryEntryExport)
+	if dir.VirtualAddress == 0 {
+		return nil, nil
+	}
+	ed := peDataAtRVA(f, dir.VirtualAddress)
+	if len(ed) < 40 {
+		return nil, fmt.Errorf("invalid export directory")
+	}
+	nNames := binary.LittleEndian.Uint32(ed[24:])
+	names := peDataAtRVA(f, binary.LittleEndian.Uint32(ed[32:]))
+	if len(names) < int(nNames)*4 {
+		return nil, fmt.Errorf("invalid export names table")
+	}
+	var res []string
+	for i := uint32(0); i < nNames; i++ {
+		res = append(res, peCStringAtRVA(f, binary.LittleEndian.Uint32(names[i*4:])))
+	}
+	return res, nil
+}
+
+// returns names of functions imported from dllName, both regular
+// and delay-loaded (/DELAYLOAD) imports
+func peImportsFromDll(d []byte, dllName string) ([]string, error) {
+	f, err := pe.NewFile(bytes.NewReader(d))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	var res []string
+	syms, err := f.ImportedSymbols()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range syms {
+		// "fz_open_document:libmupdf.dll"
+		name, dll, ok := strings.Cut(s, ":")
+		if ok && strings.EqualFold(dll, dllName) {
+			res = append(res, name)
+		}
+	}
+
+	dir := peDataDirectory(f, imageDirectoryEntryDelayImport)
+	if dir.VirtualAddress == 0 {
+		return res, nil
+	}
+	_, is64 := f.OptionalHeader.(*pe.OptionalHeader64)
+	thunkSize := uint32(4)
+	if is64 {
+		thunkSize = 8
+	}
+	descs := peDataAtRVA(f, dir.VirtualAddress)
+	// IMAGE_DELAYLOAD_DESCRIPTOR is 32 bytes, terminated by all zeros
+	for off := 0; off+32 <= len(descs); off += 32 {
+		dllNameRVA := binary.LittleEndian.Uint32(descs[off+4:])
+		intRVA := binary.LittleEndian.Uint32(descs[off+16:])
+		if dllNameRVA == 0 {
+			break
+		}
+		if !strings.EqualFold(peCStringAtRVA(f, dllNameRVA), dllName) {
+			continue
+		}
+		thunks := peDataAtRVA(f, intRVA)
+		for i := uint32(0); (i+1)*thunkSize <= uint32(len(thunks)); i++ {
+			var v uint64
+			var isOrdinal bool
+			if is64 {
+				v = binary.LittleEndian.Uint64(thunks[i*8:])
+				isOrdinal = v&(1<<63) != 0
+			} else {
+				v = uint64(binary.LittleEndian.Uint32(thunks[i*4:]))
+				isOrdinal = v&(1<<31) != 0
+			}
+			if v == 0 {
+				break
+			}
+			if isOrdinal {
+				res = append(res, fmt.Sprintf("#%d", v&0xffff))
+				continue
+			}
+			// IMAGE_IMPORT_BY_NAME: hint, name
+			res = append(res, peCStringAtRVA(f, uint32(v)+2))
+		}
+	}
+	return res, nil
+}
+
+const (
+	imageDirectoryEntryDebug = 6
+	imageDebugTypeCodeView   = 2
+)
+
+// PeCodeView is CodeView debug info (RSDS) which identifies matching .pdb
+type PeCodeView struct {
+	GUID    string
+	Age     uint32
+	PdbPath string
+}
+
+// formats GUID stored as in Windows GUID struct (first 3 fields little-endian)
+func fmtGUID(d []byte) string {
+	return fmt.Sprintf("%08X-%04X-%04X-%X-%X", binary.LittleEndian.Uint32(d), binary.LittleEndian.Uint16(d[4:]), binary.LittleEndian.Uint16(d[6:]), d[8:10], d[10:16])
+}
+
+// returns CodeView info from debug directory
+func peCodeView(d []byte) (*PeCodeView, error) {
+	f, err := pe.NewFile(bytes.NewReader(d))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	dir := peDataDirectory(f, imageDirectoryEntryDebug)
+	if dir.VirtualAddress == 0 {
+		return nil, fmt.Errorf("no debug directory")
+	}
+	entries := peDataAtRVA(f, dir.VirtualAddress)
+	// IMAGE_DEBUG_DIRECTORY is 28 bytes
+	for off := uint32(0); off+28 <= dir.Size && int(off+28) <= len(entries); off += 28 {
+		e := entries[off:]
+		if binary.LittleEndian.Uint32(e[12:]) != imageDebugTypeCodeView {
+			continue
+		}
+		size := binary.LittleEndian.Uint32(e[16:])
+		ptr := binary.LittleEndian.Uint32(e[24:])
+		if size < 24 || int(ptr)+int(size) > len(d) {
+			return nil, fmt.Errorf("invalid CodeView debug entry")
+		}
+		cv := d[ptr : ptr+size]
+		if string(cv[:4]) != "RSDS" {
+			return nil, fmt.Errorf("unsupported CodeView format '%s'", cv[:4])
+		}
+		res := &PeCodeView{
+			GUID: fmtGUID(cv[4:20]),
+			Age:  binary.LittleEndian.Uint32(cv[20:]),
+		}
+		path := cv[24:]
+		if idx := bytes.IndexByte(path, 0); idx >= 0 {
+			path = path[:idx]
+		}
+		res.PdbPath = string(path)
+		return res, nil
+	}
+	return nil, fmt.Errorf("no CodeView debug entry")
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// PGO (profile guided optimization) training profiles are stored in R2 so
+// that release builds don't have to re-run training on every machine.
+// A profile is only valid for the same version of MSVC toolset that produced
+// it so they're stored by toolset version and by source era (major.minor
+// of SumatraPDF version, i.e. release cycle):
+//   software/sumatrapdf/pgo/${toolset}/${era}/${platform}/SumatraPDF.pgd
+// do pgo upload : merges out/pgo/${platform}/*.pgc from training runs of an
+//               instrumented build into SumatraPDF.pgd and uploads it
+// do pgo pull   : downloads latest compatible profile to out/pgo/${platform}
+// -pgo        : release build uses profiles in out/pgo/${platform}
+// Profiles from older era are compatible but stale: functions changed since
+// then are optimized without profile data
+
+var flgPgo bool
+
+const (
+	pgoRemoteDir = "software/sumatrapdf/pgo/"
+	pgoFileName  = "SumatraPDF.pgd"
+)
+
+var pgoPlatforms = []string{kPlatformIntel32, kPlatformIntel64, kPlatformArm64}
+
+// msbuild properties for a build optimized with the profile
+const pgoTargetsTmpl = `<?xml version="1.0" encoding="utf-8"?>
+<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <PropertyGroup>
+    <WholeProgramOptimization>true</WholeProgramOptimization>
+  </PropertyGroup>
+  <ItemDefinitionGroup>
+    <Link>
+      <LinkTimeCodeGeneration>PGOptimization</LinkTimeCodeGeneration>
+      <ProfileGuidedDatabase>%s</ProfileGuidedDatabase>
+    </Link>
+  </ItemDefinitionGroup>
+</Project>
+`
+
+// e.g. "14.38.33130", from the installed Visual Studio
+func detectVCToolsVersionMust() string {
+	path := detectPath(vsBasePaths, `VC\Auxiliary\Build\Microsoft.VCToolsVersion.default.txt`)
+	panicIf(path == "", "didn't find Microsoft.VCToolsVersion.default.txt")
+	return strings.TrimSpace(string(readFileMust(path)))
+}
+
+func detectPgomgrPathMust(toolset string) string {
+	name := filepath.Join("VC", "Tools", "MSVC", toolset, "bin", "Hostx64", "x64", "pgomgr.exe")
+	path := detectPath(vsBasePaths, name)
+	panicIf(path == "", "didn't find %s", name)
+	return path
+}
+
+// "3.6.15432" => "3.6"
+func getPgoEra(ver string) string {
+	parts := strings.Split(ver, ".")
+	if len(parts) > 2 {
+		parts = parts[:2]
+	}
+	return strings.Join(parts, ".")
+}
+
+// returns true if era a is older than era b, "3.5" < "3.10"
+func pgoEraLess(a, b string) bool {
+	pa := strings.Split(a, ".")
+	pb := strings.Split(b, ".")
+	for i := 0; i < len(pa) && i < len(pb); i++ {
+		na, _ := strconv.Atoi(pa[i])
+		nb, _ := strconv.Atoi(pb[i])
+		if na != nb {
+			return na < nb
+		}
+	}
+	return len(pa) < len(pb)
+}
+
+func pgoLocalDir(platform string) string {
+	return filepath.Join("out", "pgo", platform)
+}
+
+func pgoRemotePath(toolset, era, platform string) string {
+	return pgoRemoteDir + path.Join(toolset, era, platform, pgoFileName)
+}
+
+// returns the newest era not newer than era that has a profile for
+// toolset and platform or "" if none
+func findCompatiblePgoEra(keys []string, toolset, era, platform string) string {
+	res := ""
+	for _, key := range keys {
+		// ${toolset}/${era}/${platform}/SumatraPDF.pgd
+		parts := strings.Split(strings.TrimPrefix(key, pgoRemoteDir), "/")
+		if len(parts) != 4 || parts[0] != toolset || parts[2] != platform || parts[3] != pgoFileName {
+			continue
+		}
+		e := parts[1]
+		if pgoEraLess(era, e) {
+			continue
+		}
+		if res == "" || pgoEraLess(res, e) {
+			res = e
+		}
+	}
+	return res
+}
+
+func pgoUpload() {
+	panicIf(r2Access == "", "R2_ACCESS env variable not set")
+	toolset := detectVCToolsVersionMust()
+	era := getPgoEra(sumatraVersion)
+	pgomgr := detectPgomgrPathMust(toolset)
+	mc := newMinioR2Client()
+	nUploaded := 0
+	for _, platform := range pgoPlatforms {
+		dir := pgoLocalDir(platform)
+		pgdPath := filepath.Join(dir, pgoFileName)
+		pgcs, _ := filepath.Glob(filepath.Join(dir, "*.pgc"))
+		if len(pgcs) > 0 {
+			// pgomgr merges all SumatraPDF!*.pgc next to the .pgd
+			runExeLoggedMust(pgomgr, "/merge", pgdPath)
+			for _, pgc := range pgcs {
+				must(os.Remove(pgc))
+			}
+		}
+		if !fileExists(pgdPath) {
+			logf("pgoUpload: no '%s', skipping %s\n", pgdPath, platform)
+			continue
+		}
+		remotePath := pgoRemotePath(toolset, era, platform)
+		_, err := mc.UploadFile(remotePath, pgdPath, false)
+		must(err)
+		info := fmt.Sprintf("git: %s\nuploaded: %s\nmerged .pgc files: %d\n", getGitSha1Must(), time.Now().UTC().Format(time.RFC3339), len(pgcs))
+		_, err = mc.UploadData(remotePath+".txt", []byte(info), false)
+		must(err)
+		logf("uploaded '%s' (%s) to '%s'\n", pgdPath, formatSize(fileSizeMust(pgdPath)), remotePath)
+		nUploaded++
+	}
+	panicIf(nUploaded == 0, "no PGO profiles in '%s'", filepath.Join("out", "pgo"))
+}
+
+// downloads latest compatible profiles
+func pgoPull() {
+	panicIf(r2Access == "", "R2_ACCESS env variable not set")
+	toolset := detectVCToolsVersionMust()
+	era := getPgoEra(sumatraVersion)
+	mc := newMinioR2Client()
+	var keys []string
+	for obj := range mc.ListObjects(pgoRemoteDir + toolset + "/") {
+		must(obj.Err)
+		keys = append(keys, obj.Key)
+	}
+	n := 0
+	for _, platform := range pgoPlatforms {
+		e := findCompatiblePgoEra(keys, toolset, era, platform)
+		if e == "" {
+			logf("pgoPull: no profile for toolset %s, era %s, %s\n", toolset, era, platform)
+			continue
+		}
+		if e != era {
+			logf("pgoPull: using stale profile from era %s for %s\n", e, platform)
+		}
+		remotePath := pgoRemotePath(toolset, e, platform)
+		dir := createDirMust(pgoLocalDir(platform))
+		localPath := filepath.Join(dir, pgoFileName)
+		must(os.RemoveAll(localPath))
+		must(mc.DownloadFileAtomically(localPath, remotePath))
+		logf("downloaded '%s' to '%s'\n", remotePath, localPath)
+		n++
+	}
+	logf("pgoPull: downloaded profiles for %d of %d platforms\n", n, len(pgoPlatforms))
+}
+
+// returns extra msbuild argument for building with PGO profile
+// or "" if -pgo not given or we don't have a profile
+func getPgoMsbuildArg(platform string) string {
+	if !flgPgo {
+		return ""
+	}
+	pgdPath := absPathMust(filepath.Join(pgoLocalDir(platform), pgoFileName))
+	if !fileExists(pgdPath) {
+		logf("building %s without PGO because '%s' doesn't exist, run: do pgo pull\n", platform, pgdPath)
+		return ""
+	}
+	targetsPath := absPathMust(filepath.Join(pgoLocalDir(platform), "pgo.targets"))
+	writeFileMust(targetsPath, []byte(fmt.Sprintf(pgoTargetsTmpl, pgdPath)))
+	logf("building %s with PGO profile '%s'\n", platform, pgdPath)
+	return "/p:ForceImportBeforeCppTargets=" + targetsPath
+}
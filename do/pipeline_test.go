@@ -0,0 +1,128 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+// scenarios of do test self, see selftest.go
+func TestSelfTests(t *testing.T) {
+	for _, st := range selfTests {
+		t.Run(st.Name, func(t *testing.T) {
+			if err := runSelfTest(st); err != nil {
+				t.Fatal(err)
+			}
+		})
+	}
+}
+
+func TestRemoteNaming(t *testing.T) {
+	withFakeBackends("16123", "3.6", func(fb *FakeBackends) {
+		if got := getRemoteDir(buildTypePreRel); got != "software/sumatrapdf/prerel/16123/" {
+			t.Errorf("getRemoteDir(prerel) = '%s'", got)
+		}
+		if got := getRemoteDir(buildTypeRel); got != "software/sumatrapdf/rel/3.6/" {
+			t.Errorf("getRemoteDir(rel) = '%s'", got)
+		}
+		if got := getSourceArchiveName(buildTypeRel); got != "SumatraPDF-3.6-src.tar.gz" {
+			t.Errorf("getSourceArchiveName(rel) = '%s'", got)
+		}
+		urls := getDownloadUrlsViaWebsite(buildTypeRel, "3.6")
+		if urls.installerArm64 != "https://www.sumatrapdfreader.org/dl/rel/3.6/SumatraPDF-3.6-arm64-install.exe" {
+			t.Errorf("installerArm64 = '%s'", urls.installerArm64)
+		}
+	})
+}
+
+func TestGroupFilesByVersion(t *testing.T) {
+	files := []string{
+		"software/sumatrapdf/prerel/14028/SumatraPDF-prerel.exe",
+		"software/sumatrapdf/prerel/14100/SumatraPDF-prerel.exe",
+		"software/sumatrapdf/prerel/14028/SumatraPDF-prerel-64.exe",
+	}
+	byVer := groupFilesByVersion(files)
+	if len(byVer) != 2 || byVer[0].ver != 14100 || byVer[1].ver != 14028 || len(byVer[1].files) != 2 {
+		t.Fatalf("unexpected groups: %+v %+v", byVer[0], byVer[1])
+	}
+}
+
+func TestPlatformOfManifestLine(t *testing.T) {
+	tests := map[string]string{
+		`out\rel64\SumatraPDF.exe: 1 abc`:          kPlatformIntel64,
+		"out/arm64/libmupdf.dll: 1 abc":            kPlatformArm64,
+		"out/rel32/SumatraPDF.exe: 1 abc":          kPlatformIntel32,
+		"SumatraPDF-3.6-64.zip: magnet:?xt":        kPlatformIntel64,
+		"SumatraPDF-prerel-arm64-install.exe: sig": kPlatformArm64,
+		"  size: ok":      "",
+		"total size: 123": "",
+	}
+	for line, want := range tests {
+		if got := getPlatformOfManifestLine(line); got != want {
+			t.Errorf("getPlatformOfManifestLine('%s') = '%s', want '%s'", line, got, want)
+		}
+	}
+}
+
+func TestParseScheduledPromotion(t *testing.T) {
+	p := parseScheduledPromotionMust("3.5.2,2024-05-03T10:00:00Z,100%")
+	if p.Ver != "3.5.2" || p.RolloutPercent != 100 || p.At.Hour() != 10 {
+		t.Fatalf("unexpected promotion: %+v", p)
+	}
+	p = parseScheduledPromotionMust("3.6")
+	if p.RolloutPercent != 100 {
+		t.Fatalf("default rollout is %d", p.RolloutPercent)
+	}
+	p = parseScheduledPromotionMust("3.5.2,2024-05-03T10:00:00Z,25%")
+	if p.RolloutPercent != 25 {
+		t.Fatalf("rollout is %d, expected 25", p.RolloutPercent)
+	}
+	defer func() {
+		if recover() == nil {
+			t.Fatalf("rollout of 0%% should be rejected")
+		}
+	}()
+	parseScheduledPromotionMust("3.5.2,2024-05-03T10:00:00Z,0%")
+}
+
+func TestMemStorage(t *testing.T) {
+	s := NewMemStorage("test")
+	_, _ = s.UploadData("a/1.txt", []byte("1"), true)
+	_, _ = s.UploadData("a/2.txt", []byte("2"), true)
+	_, _ = s.UploadData("b/3.txt", []byte("3"), true)
+	if _, err := s.Copy("b/3.txt", "a/2.txt"); err != nil {
+		t.Errorf("Copy to existing file: %v", err)
+	}
+	if d, _ := s.DownloadData("a/2.txt"); string(d) != "3" {
+		t.Errorf("Copy didn't overwrite 'a/2.txt', got '%s'", d)
+	}
+	if _, err := s.Copy("a/1.txt", "c/1.txt"); err != nil {
+		t.Errorf("Copy: %v", err)
+	}
+	if got := strings.Join(s.CopiedKeys(), ","); got != "a/2.txt,c/1.txt" {
+		t.Errorf("CopiedKeys() = %s", got)
+	}
+	var keys []string
+	for obj := range s.ListObjects("a/") {
+		keys = append(keys, obj.Key)
+	}
+	if strings.Join(keys, ",") != "a/1.txt,a/2.txt" {
+		t.Errorf("ListObjects('a/') = %v", keys)
+	}
+	must(s.Remove("a/1.txt"))
+	if s.Exists("a/1.txt") || !s.Exists("c/1.txt") {
+		t.Errorf("unexpected files after Remove: %v", s.Keys(""))
+	}
+}
+
+func TestFakeProcessRunner(t *testing.T) {
+	withFakeBackends("16000", "3.6", func(fb *FakeBackends) {
+		fb.Runner.Outputs["git rev-parse"] = strings.Repeat("a", 40) + "\n"
+		if got := getGitSha1Must(); got != strings.Repeat("a", 40) {
+			t.Errorf("getGitSha1Must() = '%s'", got)
+		}
+		cmds := fb.Runner.CommandsOf("git")
+		if len(cmds) != 1 || strings.Join(cmds[0], " ") != "git rev-parse HEAD" {
+			t.Errorf("unexpected commands: %v", cmds)
+		}
+	})
+}
@@ -0,0 +1,201 @@
+package main
+
+import (
+	"encoding/json"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// -platforms=arm64,x64 limits building, signing, packaging and uploading
+// (do build release, do build prerel, do build profile, do build fanout) to those platforms
+// so that a respin of one platform doesn't rebuild and re-upload all of
+// them. Files of other platforms are those of the prior build of the same
+// version already in storage: we merge manifest, signatures, magnets and
+// upload-hashes.json of the prior build for platforms we didn't rebuild.
+// Partial build can only be uploaded if the prior build was uploaded
+
+var flgPlatforms string
+
+// nil means all platforms
+var selectedPlatforms []string
+
+var platformAliases = map[string]string{
+	"arm64": kPlatformArm64,
+	"x64":   kPlatformIntel64,
+	"64":    kPlatformIntel64,
+	"win32": kPlatformIntel32,
+	"x86":   kPlatformIntel32,
+	"32":    kPlatformIntel32,
+}
+
+// "arm64,x64" => ["x64", "ARM64"], in the order we build them
+func parsePlatformsMust(s string) []string {
+	want := map[string]bool{}
+	for _, p := range strings.Split(s, ",") {
+		p = strings.ToLower(strings.TrimSpace(p))
+		platform, ok := platformAliases[p]
+		panicIf(!ok, "invalid platform '%s' in -platforms, must be one of: arm64, x64, x86", p)
+		want[platform] = true
+	}
+	var res []string
+	for _, platform := range []string{kPlatformIntel32, kPlatformIntel64, kPlatformArm64} {
+		if want[platform] {
+			res = append(res, platform)
+		}
+	}
+	return res
+}
+
+// must be called after flags are parsed
+func initPlatformsFilterMust() {
+	if flgPlatforms == "" {
+		return
+	}
+	selectedPlatforms = parsePlatformsMust(flgPlatforms)
+	logf("building only platforms: %s\n", strings.Join(selectedPlatforms, ", "))
+}
+
+func isPartialPlatformsBuild() bool {
+	return len(selectedPlatforms) > 0 && len(selectedPlatforms) < len(fanoutPlatforms)
+}
+
+func isPlatformSelected(platform string) bool {
+	return len(selectedPlatforms) == 0 || stringInSlice(selectedPlatforms, platform)
+}
+
+// returns platforms from the list that are selected with -platforms
+func filterSelectedPlatforms(platforms []string) []string {
+	var res []string
+	for _, platform := range platforms {
+		if isPlatformSelected(platform) {
+			res = append(res, platform)
+		}
+	}
+	return res
+}
+
+// returns out dirs (rel32, rel64, arm64) of selected platforms
+func getSelectedOutDirs() []string {
+	var res []string
+	for _, platform := range filterSelectedPlatforms([]string{kPlatformIntel32, kPlatformIntel64, kPlatformArm64}) {
+		res = append(res, getOutDirForPlatform(platform))
+	}
+	return res
+}
+
+// "SumatraPDF-3.5-arm64.zip" => "ARM64", "SumatraPDF-prerel.exe" => "Win32"
+func getPlatformOfFileName(name string) string {
+	switch {
+	case strings.Contains(name, "-arm64"):
+		return kPlatformArm64
+	case strings.Contains(name, "-64"):
+		return kPlatformIntel64
+	}
+	return kPlatformIntel32
+}
+
+// returns platform of a line in manifest, signatures or magnets file, which
+// start with "out/rel64/SumatraPDF.exe: " or "SumatraPDF-3.5-64.zip: ".
+// "" if the line is not about a file of a platform
+func getPlatformOfManifestLine(line string) string {
+	s, _, ok := strings.Cut(line, ": ")
+	if !ok || strings.HasPrefix(line, " ") {
+		return ""
+	}
+	// manifest written on Windows has out\rel64\SumatraPDF.exe
+	s = strings.ReplaceAll(s, `\`, "/")
+	if !strings.HasPrefix(s, "out/") {
+		if !strings.HasPrefix(s, "SumatraPDF") {
+			return ""
+		}
+		return getPlatformOfFileName(s)
+	}
+	parts := strings.Split(s, "/")
+	for _, platform := range fanoutPlatforms {
+		if filepath.Base(getOutDirForPlatform(platform)) == parts[1] {
+			return platform
+		}
+	}
+	return ""
+}
+
+// appends to cur lines of prior about platforms we didn't build.
+// Indented lines belong to the line above them
+func mergePriorManifestLines(cur string, prior string) string {
+	lines := toTrimmedRightLines(cur)
+	keep := false
+	for _, line := range toTrimmedRightLines(prior) {
+		if !strings.HasPrefix(line, " ") {
+			platform := getPlatformOfManifestLine(line)
+			keep = platform != "" && !isPlatformSelected(platform)
+		}
+		if keep {
+			lines = append(lines, line)
+		}
+	}
+	return strings.Join(lines, "\n") + "\n"
+}
+
+func toTrimmedRightLines(s string) []string {
+	var res []string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r ")
+		if line != "" {
+			res = append(res, line)
+		}
+	}
+	return res
+}
+
+// returns client for storage with the prior build of buildType, fails if
+// the prior build was not uploaded
+func getPriorBuildStorageMust(buildType BuildType, name string) Storage {
+	failIf(errKindPreflight, r2Access == "" || r2Secret == "", "-platforms needs R2_ACCESS and R2_SECRET to merge with the prior build")
+	mc := newMinioR2Client()
+	remotePath := getRemoteDir(buildType) + name
+	failIf(errKindPreflight, !mc.Exists(remotePath), "'%s' doesn't exist, -platforms can only respin platforms of an uploaded build", remotePath)
+	return mc
+}
+
+// merges manifest, signatures and magnets in dstDir with those of the prior
+// build of the same version
+func mergePriorBuildFilesMust(buildType BuildType, dstDir string, prefix string) {
+	if !isPartialPlatformsBuild() {
+		return
+	}
+	manifestName := prefix + "-manifest.txt"
+	mc := getPriorBuildStorageMust(buildType, manifestName)
+	for _, name := range []string{manifestName, prefix + "-signatures.txt", prefix + "-magnets.txt"} {
+		localPath := filepath.Join(dstDir, name)
+		remotePath := getRemoteDir(buildType) + name
+		if !fileExists(localPath) || !mc.Exists(remotePath) {
+			continue
+		}
+		prior := string(minioDownloadDataMust(mc, remotePath))
+		s := mergePriorManifestLines(string(readFileMust(localPath)), prior)
+		writeFileMust(localPath, []byte(s))
+		logf("merged '%s' with '%s' of the prior build\n", localPath, remotePath)
+	}
+}
+
+// adds hashes of files of the prior build in dirRemote that we didn't rebuild
+func mergePriorUploadHashes(mc Storage, dirRemote string, hashes map[string]string) {
+	if !isPartialPlatformsBuild() {
+		return
+	}
+	remotePath := path.Join(dirRemote, uploadHashesFileName)
+	if !mc.Exists(remotePath) {
+		return
+	}
+	var prior map[string]string
+	if err := json.Unmarshal(minioDownloadDataMust(mc, remotePath), &prior); err != nil {
+		logf("failed to parse '%s': %s\n", remotePath, err)
+		return
+	}
+	for name, sha := range prior {
+		if _, ok := hashes[name]; !ok {
+			hashes[name] = sha
+		}
+	}
+}
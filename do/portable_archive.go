@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// portable version is SumatraPDF.exe packaged in an archive. Some
+// distribution channels require specific formats so with
+// -portable-formats we can create, in addition to .zip:
+// - 7z  : .7z archive
+// - sfx : 7-Zip self-extracting .exe
+// 7z and sfx need 7z.exe (7-Zip) in PATH or in default install location.
+// Size of each format is recorded in the manifest
+// .zip is always created because download page and update checks link to it
+
+var flgPortableFormats = "zip"
+
+// PortableFormat describes a format of portable archive
+type PortableFormat struct {
+	Name string
+	// name of the file in out/rel* directory
+	FileName string
+	// suffix of the file name in release directory, after prefix
+	ReleaseSuffix string
+}
+
+var portableFormats = []*PortableFormat{
+	{Name: "zip", FileName: "SumatraPDF.zip", ReleaseSuffix: ".zip"},
+	{Name: "7z", FileName: "SumatraPDF.7z", ReleaseSuffix: ".7z"},
+	{Name: "sfx", FileName: "SumatraPDF-sfx.exe", ReleaseSuffix: "-sfx.exe"},
+}
+
+// PortableArchiveSizes is recorded in the manifest
+type PortableArchiveSizes struct {
+	Dir     string
+	ExeSize int64
+	// in the order of portableFormats
+	Formats []string
+	Sizes   []int64
+}
+
+var portableArchiveSizes []*PortableArchiveSizes
+
+func getPortableFormatsMust() []*PortableFormat {
+	var res []*PortableFormat
+	for _, s := range strings.Split(flgPortableFormats, ",") {
+		s = strings.TrimSpace(strings.ToLower(s))
+		if s == "" {
+			continue
+		}
+		var format *PortableFormat
+		for _, f := range portableFormats {
+			if f.Name == s {
+				format = f
+			}
+		}
+		panicIf(format == nil, "unknown portable format '%s' in -portable-formats, valid: zip, 7z, sfx", s)
+		if !stringInSlice(getPortableFormatNames(res), s) {
+			res = append(res, format)
+		}
+	}
+	panicIf(!stringInSlice(getPortableFormatNames(res), "zip"), "-portable-formats must include zip")
+	return res
+}
+
+func getPortableFormatNames(formats []*PortableFormat) []string {
+	var res []string
+	for _, f := range formats {
+		res = append(res, f.Name)
+	}
+	return res
+}
+
+func detect7zPathMust() string {
+	if path, err := exec.LookPath("7z"); err == nil {
+		return path
+	}
+	for _, dir := range []string{os.Getenv("ProgramFiles"), os.Getenv("ProgramFiles(x86)")} {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, "7-Zip", "7z.exe")
+		if fileExists(path) {
+			return path
+		}
+	}
+	failIf(errKindToolchain, true, "7z.exe not found in PATH or in Program Files\\7-Zip, install it or run: do doctor")
+	return ""
+}
+
+// creates .7z or self-extracting .exe with SumatraPDF.exe named nameInArchive
+// we don't store file times so that archives of identical files are identical
+func create7zArchiveMust(dir string, nameInArchive string, sfx bool, dstName string) {
+	tmpDir := filepath.Join("out", "portable-tmp")
+	must(os.RemoveAll(tmpDir))
+	createDirMust(tmpDir)
+	defer os.RemoveAll(tmpDir)
+	must(copyFile(filepath.Join(tmpDir, nameInArchive), filepath.Join(dir, "SumatraPDF.exe")))
+
+	dstPath := absPathMust(filepath.Join(dir, dstName))
+	os.Remove(dstPath) // 7z would add to existing archive
+	args := []string{"a", "-t7z", "-mx=9", "-mtm-", "-mtc-", "-mta-"}
+	if sfx {
+		args = append(args, "-sfx7z.sfx")
+	}
+	args = append(args, dstPath, nameInArchive)
+	cmd := exec.Command(detect7zPathMust(), args...)
+	cmd.Dir = tmpDir
+	runCmdLoggedMust(cmd)
+}
+
+// creates portable archives in formats selected with -portable-formats
+// SumatraPDF.exe in dir must be already signed
+func createPortableArchivesMust(dir string, nameInArchive string) {
+	formats := getPortableFormatsMust()
+	res := &PortableArchiveSizes{
+		Dir:     strings.TrimPrefix(filepath.ToSlash(dir), "out/"),
+		ExeSize: fileSizeMust(filepath.Join(dir, "SumatraPDF.exe")),
+	}
+	for _, f := range formats {
+		switch f.Name {
+		case "zip":
+			createExeZipWithGoWithNameMust(dir, nameInArchive)
+		case "7z":
+			create7zArchiveMust(dir, nameInArchive, false, f.FileName)
+		case "sfx":
+			create7zArchiveMust(dir, nameInArchive, true, f.FileName)
+			signMust(filepath.Join(dir, f.FileName))
+		}
+		res.Formats = append(res.Formats, f.Name)
+		res.Sizes = append(res.Sizes, fileSizeMust(filepath.Join(dir, f.FileName)))
+	}
+	logf("%s\n", fmtPortableArchiveSizes(res))
+	portableArchiveSizes = append(portableArchiveSizes, res)
+}
+
+// names of portable archives in out/rel* directory, for the manifest
+func getPortableArchiveFileNames() []string {
+	var res []string
+	for _, f := range getPortableFormatsMust() {
+		res = append(res, f.FileName)
+	}
+	return res
+}
+
+func fmtPortableArchiveSizes(r *PortableArchiveSizes) string {
+	var parts []string
+	for i, name := range r.Formats {
+		size := r.Sizes[i]
+		parts = append(parts, fmt.Sprintf("%s %d (%.0f%%)", name, size, float64(size*100)/float64(r.ExeSize)))
+	}
+	return fmt.Sprintf("portable: %s/SumatraPDF.exe %d: %s", r.Dir, r.ExeSize, strings.Join(parts, ", "))
+}
+
+// lines to add to the manifest
+func fmtPortableArchivesForManifest() []string {
+	var res []string
+	for _, r := range portableArchiveSizes {
+		res = append(res, fmtPortableArchiveSizes(r))
+	}
+	return res
+}
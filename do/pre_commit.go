@@ -0,0 +1,167 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// do hooks install installs git pre-commit hook that runs do hooks pre-commit
+// which checks files staged for commit (their staged content, not the
+// working tree):
+// - C/C++ files are formatted with clang-format (unless in .clang-format-ignore)
+// - ACCESSKEY_GROUP markers in .cpp files are well formed
+// - strings to translate (_TR() etc.) can be extracted and are not empty
+// Only staged files are checked so it's fast.
+// To skip: SUMATRA_SKIP_HOOKS=1 git commit ... (or git commit --no-verify)
+
+const skipHooksEnvVar = "SUMATRA_SKIP_HOOKS"
+
+// marks hooks installed by us so that we don't overwrite other hooks
+const preCommitHookMarker = "# installed by: do hooks install"
+
+var preCommitHookScript = `#!/bin/sh
+` + preCommitHookMarker + `
+# skip with: ` + skipHooksEnvVar + `=1 git commit ... (or git commit --no-verify)
+if [ -n "$` + skipHooksEnvVar + `" ]; then
+	exit 0
+fi
+exec go run ./do hooks pre-commit
+`
+
+func installGitHooksMust() {
+	out := runExeMust("git", "rev-parse", "--git-path", "hooks")
+	dir := createDirMust(strings.TrimSpace(string(out)))
+	path := filepath.Join(dir, "pre-commit")
+	if fileExists(path) {
+		d := readFileMust(path)
+		panicIf(!bytes.Contains(d, []byte(preCommitHookMarker)), "'%s' already exists and was not installed by do, delete it or add 'go run ./do hooks pre-commit' to it", path)
+	}
+	must(os.WriteFile(path, []byte(preCommitHookScript), 0755))
+	logf("installed '%s', skip it with %s=1 git commit\n", path, skipHooksEnvVar)
+}
+
+// returns paths of files added, copied, modified or renamed in the index
+func getStagedFilesMust() []string {
+	out := runExeMust("git", "diff", "--cached", "--name-only", "--diff-filter=ACMR")
+	return toTrimmedLines(out)
+}
+
+func getStagedContentMust(path string) []byte {
+	cmd := exec.Command("git", "show", ":"+path)
+	var out bytes.Buffer
+	cmd.Stdout = &out
+	cmd.Stderr = os.Stderr
+	must(runCmdWithTimeout(cmd))
+	return out.Bytes()
+}
+
+// "" if content of path is formatted
+func checkClangFormatted(clangFormatPath string, path string, d []byte) string {
+	cmd := exec.Command(clangFormatPath, "--dry-run", "-Werror", "-style=file", "--assume-filename="+path)
+	cmd.Stdin = bytes.NewReader(d)
+	out, err := combinedOutputWithTimeout(cmd)
+	if err != nil {
+		return fmt.Sprintf("%s: not formatted, run: do format all\n%s", path, out)
+	}
+	return ""
+}
+
+// returns detected clang-format or "" if it's not installed
+func detectClangFormatOptional() (path string) {
+	defer func() {
+		if r := recover(); r != nil {
+			path = ""
+		}
+	}()
+	return detectClangFormat()
+}
+
+// checks that access key groups are well formed and strings to
+// translate can be extracted. "" if ok
+func checkTranslatableSource(path string, d []byte) (problem string) {
+	defer func() {
+		if r := recover(); r != nil {
+			problem = fmt.Sprint(r)
+			if !strings.HasPrefix(problem, path) {
+				problem = path + ": " + problem
+			}
+		}
+	}()
+	extractAccesskeyGroupsFromLines(strings.Split(string(d), "\n"))
+	for _, ts := range extractTranslatableStringsFromSource(string(d), path) {
+		if strings.TrimSpace(ts.Text) == "" {
+			return fmt.Sprintf("%s:%d: empty string in %s()", ts.Path, ts.Line, ts.Macro)
+		}
+	}
+	return ""
+}
+
+// do hooks pre-commit
+func runPreCommitChecksMust() {
+	if os.Getenv(skipHooksEnvVar) != "" {
+		logf("%s is set, skipping pre-commit checks\n", skipHooksEnvVar)
+		return
+	}
+	var ignorePatterns []*ClangFormatIgnorePattern
+	if fileExists(clangFormatIgnorePath) {
+		ignorePatterns = parseClangFormatIgnore(string(readFileMust(clangFormatIgnorePath)))
+	}
+	translatable := map[string]bool{}
+	for _, path := range getFilesToProcess() {
+		translatable[filepath.ToSlash(path)] = true
+	}
+	clangFormatPath := detectClangFormatOptional()
+	if clangFormatPath == "" {
+		logf("clang-format not found, skipping format check\n")
+	}
+
+	var mu sync.Mutex
+	var problems []string
+	addProblem := func(s string) {
+		if s == "" {
+			return
+		}
+		mu.Lock()
+		problems = append(problems, s)
+		mu.Unlock()
+	}
+	sem := make(chan bool, runtime.NumCPU())
+	var wg sync.WaitGroup
+	nChecked := 0
+	for _, path := range getStagedFilesMust() {
+		ext := strings.ToLower(filepath.Ext(path))
+		checkFormat := clangFormatPath != "" && stringInSlice(clangFormatExts, ext) && !isClangFormatIgnored(ignorePatterns, path)
+		checkTranslations := translatable[path]
+		if !checkFormat && !checkTranslations {
+			continue
+		}
+		nChecked++
+		sem <- true
+		wg.Add(1)
+		go func(path string) {
+			defer func() {
+				wg.Done()
+				<-sem
+			}()
+			d := getStagedContentMust(path)
+			if checkFormat {
+				addProblem(checkClangFormatted(clangFormatPath, path, d))
+			}
+			if checkTranslations {
+				addProblem(checkTranslatableSource(path, d))
+			}
+		}(path)
+	}
+	wg.Wait()
+	for _, s := range problems {
+		logf("%s\n", s)
+	}
+	failIf(errKindVerify, len(problems) > 0, "pre-commit checks failed for %d of %d files, fix them or skip with %s=1", len(problems), nChecked, skipHooksEnvVar)
+	logf("pre-commit checks of %d files ok\n", nChecked)
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strconv"
+	"strings"
+)
+
+// before CI / release builds we check things that otherwise fail the build
+// half-way through, with confusing errors:
+// - free disk space: out/ and temp need tens of GB for all platforms
+// - long path support: deeply nested paths in out/ and ext/ exceed MAX_PATH
+// - SumatraPDF.exe etc. from a previous run still running from out/ lock
+//   files we're about to delete or overwrite
+// -skip-preflight skips the checks
+
+var flgSkipPreflight bool
+
+const (
+	preflightMinFreeOut  = 30 * 1024 * 1024 * 1024
+	preflightMinFreeTemp = 5 * 1024 * 1024 * 1024
+)
+
+// returns free bytes on the drive of dir
+func getDiskFreeBytes(dir string) (int64, error) {
+	script := fmt.Sprintf("(Get-Item -LiteralPath '%s').PSDrive.Free", absPathMust(dir))
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-Command", script).Output()
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64)
+}
+
+func isLongPathsEnabled() bool {
+	out, err := exec.Command("reg", "query", `HKLM\SYSTEM\CurrentControlSet\Control\FileSystem`, "/v", "LongPathsEnabled").Output()
+	if err != nil {
+		return false
+	}
+	// LongPathsEnabled    REG_DWORD    0x1
+	fields := strings.Fields(string(out))
+	return len(fields) > 0 && fields[len(fields)-1] == "0x1"
+}
+
+// returns "${pid} ${path}" of processes started from dir
+func getProcessesRunningFromDir(dir string) []string {
+	script := fmt.Sprintf(`Get-Process | Where-Object { $_.Path -like '%s\*' } | ForEach-Object { "$($_.Id) $($_.Path)" }`, absPathMust(dir))
+	out, err := exec.Command("powershell.exe", "-NoProfile", "-Command", script).Output()
+	if err != nil {
+		logf("preflight: failed to list processes: %s\n", err)
+		return nil
+	}
+	return toTrimmedLines(out)
+}
+
+func checkFreeSpace(what string, dir string, minFree int64) string {
+	free, err := getDiskFreeBytes(dir)
+	if err != nil {
+		logf("preflight: failed to get free space for '%s': %s\n", dir, err)
+		return ""
+	}
+	logf("preflight: %s free space: %s\n", what, formatSize(free))
+	if free < minFree {
+		return fmt.Sprintf("only %s free on the drive of %s '%s', need at least %s", formatSize(free), what, dir, formatSize(minFree))
+	}
+	return ""
+}
+
+func preflightMust() {
+	if flgSkipPreflight {
+		logf("preflight: skipping because -skip-preflight\n")
+		return
+	}
+	if runtime.GOOS != "windows" {
+		logf("preflight: skipping, only works on Windows\n")
+		return
+	}
+	outDir := createDirMust("out")
+	var problems []string
+	if s := checkFreeSpace("out", outDir, preflightMinFreeOut); s != "" {
+		problems = append(problems, s)
+	}
+	if s := checkFreeSpace("temp", os.TempDir(), preflightMinFreeTemp); s != "" {
+		problems = append(problems, s)
+	}
+	if !isLongPathsEnabled() {
+		problems = append(problems, `long paths are not enabled, set HKLM\SYSTEM\CurrentControlSet\Control\FileSystem\LongPathsEnabled to 1`)
+	}
+	for _, p := range getProcessesRunningFromDir(outDir) {
+		problems = append(problems, fmt.Sprintf("process running from out/ locks its files, close it: %s", p))
+	}
+	failIf(errKindPreflight, len(problems) > 0, "preflight checks failed:\n  %s", strings.Join(problems, "\n  "))
+	logf("preflight: ok\n")
+}
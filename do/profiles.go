@@ -0,0 +1,182 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// a profile is a named set of build options: which platforms to build,
+// whether to sign and upload, whether to clean out/ first and which
+// verification gates to run. do build profile release
+// do build prerel, do build release and do build daily are shortcuts for
+// prerelease, release and daily profiles, except they only sign and
+// upload with -upload
+
+// BuildProfile is a named set of build options
+type BuildProfile struct {
+	Name      string
+	Help      string
+	BuildType BuildType
+	// release build always builds all platforms
+	Platforms []string
+	// build all projects (tests, utilities), not just the ones we ship
+	AllProjects bool
+	Sign        bool
+	Upload      bool
+	// delete release directories in out/ before building
+	Clean bool
+
+	// gates, checked before building
+	CheckVulns         bool
+	VerifyTranslations bool
+	TranslationGate    bool
+	CleanCheck         bool
+	// must be on release branch, deletes out/
+	ReleaseBranch bool
+	// skip if there were no commits since last daily build
+	SkipUnchanged bool
+	// create source archive, uploaded with the build
+	SourceArchive bool
+	// after upload, generate and post announcement of the pre-release
+	Announce bool
+	// after upload, delete old pre-release symbols, see pruneSymbolsStorage()
+	PruneSymbols bool
+}
+
+var buildProfiles = []*BuildProfile{
+	{
+		Name:        "dev",
+		Help:        "local 64-bit pre-release build of all projects, no signing or upload",
+		BuildType:   buildTypePreRel,
+		Platforms:   []string{kPlatformIntel64},
+		AllProjects: true,
+		Clean:       true,
+	},
+	{
+		Name:          "daily",
+		Help:          "signed pre-release of all platforms, uploaded if there were new commits",
+		BuildType:     buildTypePreRel,
+		Platforms:     []string{kPlatformArm64, kPlatformIntel32, kPlatformIntel64},
+		Sign:          true,
+		Upload:        true,
+		Clean:         true,
+		CheckVulns:    true,
+		SkipUnchanged: true,
+		SourceArchive: true,
+		Announce:      true,
+		PruneSymbols:  true,
+	},
+	{
+		Name:        "prerelease",
+		Help:        "signed and uploaded 64-bit pre-release of all projects",
+		BuildType:   buildTypePreRel,
+		Platforms:   []string{kPlatformIntel64},
+		AllProjects: true,
+		Sign:        true,
+		Upload:      true,
+		Clean:       true,
+	},
+	{
+		Name:               "release",
+		Help:               "signed and uploaded release of all platforms, from clean release branch",
+		BuildType:          buildTypeRel,
+		Platforms:          []string{kPlatformIntel32, kPlatformIntel64, kPlatformArm64},
+		Sign:               true,
+		Upload:             true,
+		Clean:              true,
+		VerifyTranslations: true,
+		TranslationGate:    true,
+		CleanCheck:         true,
+		ReleaseBranch:      true,
+		SourceArchive:      true,
+	},
+}
+
+func fmtBuildProfiles() string {
+	s := "profiles for: do build profile ${name}\n"
+	for _, p := range buildProfiles {
+		s += fmt.Sprintf("  %-12s %s\n", p.Name, p.Help)
+	}
+	return s
+}
+
+// returns a copy so that caller can change it
+func getBuildProfileMust(name string) *BuildProfile {
+	for _, p := range buildProfiles {
+		if p.Name == name {
+			res := *p
+			return &res
+		}
+	}
+	panicIf(true, "unknown profile '%s'\n%s", name, fmtBuildProfiles())
+	return nil
+}
+
+// -platform overrides platforms of pre-release profiles
+func setProfilePlatformMust(p *BuildProfile, platform string) {
+	if platform == "" {
+		return
+	}
+	panicIf(!stringInSlice(fanoutPlatforms, platform), "invalid platform '%s'", platform)
+	panicIf(p.BuildType == buildTypeRel, "-platform can't be used with profile '%s', release build always builds all platforms", p.Name)
+	p.Platforms = []string{platform}
+}
+
+func runBuildProfileMust(p *BuildProfile) {
+	logf("profile: %s, platforms: %s, sign: %v, upload: %v\n", p.Name, strings.Join(p.Platforms, ","), p.Sign, p.Upload)
+	opts := &BuildOptions{
+		sign:                      p.Sign,
+		upload:                    p.Upload,
+		verifyTranslationUpToDate: p.VerifyTranslations,
+		translationGate:           p.TranslationGate,
+		doCleanCheck:              p.CleanCheck,
+		releaseBuild:              p.ReleaseBranch,
+	}
+	ensureBuildOptionsPreRequesites(opts)
+
+	if p.SkipUnchanged && !shouldBuildDaily() {
+		return
+	}
+	if p.CheckVulns {
+		// don't ship builds with known critical vulnerabilities
+		checkVulnsMust()
+	}
+
+	if p.BuildType == buildTypeRel {
+		// cleans and builds all platforms
+		buildRelease()
+	} else {
+		if p.Clean {
+			cleanReleaseBuilds()
+		}
+		for _, platform := range filterSelectedPlatforms(p.Platforms) {
+			buildPreRelease(platform, p.AllProjects)
+		}
+	}
+	if p.SourceArchive {
+		createSourceArchiveMust(p.BuildType, p.Sign)
+	}
+
+	if !p.Upload {
+		logf("uploadToStorage: skipping because profile '%s' doesn't upload\n", p.Name)
+		return
+	}
+	if uploadToStorage(p.BuildType) {
+		afterProfileUploadMust(p)
+	}
+}
+
+// in CI upload is a separate step so this is also called by:
+// do build ci-upload -daily
+func afterProfileUploadMust(p *BuildProfile) {
+	// before recording the sha because changes are since the previous one
+	if p.Announce {
+		announcePreReleaseMust()
+	}
+	if p.SkipUnchanged {
+		setDailyLastShaMust(getGitSha1Must())
+	}
+	if p.PruneSymbols {
+		pruneSymbolsStorage(true)
+	}
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"sort"
+	"strings"
+)
+
+// source files of projects, declared in sourceSets (source_sets.go) instead
+// of editing premake files or vs2022/*.vcxproj by hand. do gen projects
+// generates premake5.files.gen.lua from sourceSets, runs premake to
+// re-generate vs2022 projects and with -cmake also writes cmake/sources.cmake
+// to add a file to a project, add it to a SourceGroup and run: do gen projects
+
+// SourceGroup is a list of files (or patterns like "Foo.*") in a directory
+type SourceGroup struct {
+	// if empty, Files are relative to the root of the repo
+	Dir   string
+	Files []string
+	// premake filter e.g. "configurations:Debug" or "platforms:arm64"
+	Filter string
+	// if set, the group is files of SourceSet with that name
+	Set string
+}
+
+// SourceSet becomes <Name>_files() function in premake5.files.gen.lua
+type SourceSet struct {
+	Name   string
+	Groups []*SourceGroup
+}
+
+const premakeGenFilesPath = "premake5.files.gen.lua"
+
+func genPremakeFiles(sets []*SourceSet) string {
+	var lines []string
+	add := func(format string, args ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, args...))
+	}
+	add("-- auto-generated by do/project_files.go, edit sourceSets in do/source_sets.go and run: do gen projects")
+	add("")
+	add("function files_in_dir(dir, files_in_dir)")
+	add("  local paths = {}")
+	add("  for _, file in ipairs(files_in_dir) do")
+	add(`    local path = dir .. "/" .. file`)
+	add("    table.insert(paths, path)")
+	add("  end")
+	add("  files(paths)")
+	add("end")
+	for _, set := range sets {
+		add("")
+		add("function %s_files()", set.Name)
+		filter := ""
+		for _, g := range set.Groups {
+			if g.Filter != filter {
+				add(`  filter {"%s"}`, g.Filter)
+				filter = g.Filter
+			}
+			indent := "  "
+			if filter != "" {
+				indent = "    "
+			}
+			if g.Set != "" {
+				add("%s%s_files()", indent, g.Set)
+				continue
+			}
+			if g.Dir == "" {
+				add("%sfiles {", indent)
+			} else {
+				add(`%sfiles_in_dir("%s", {`, indent, g.Dir)
+			}
+			for _, f := range g.Files {
+				add(`%s  "%s",`, indent, f)
+			}
+			if g.Dir == "" {
+				add("%s}", indent)
+			} else {
+				add("%s})", indent)
+			}
+		}
+		if filter != "" {
+			add("  filter {}")
+		}
+		add("end")
+	}
+	// premake files use CRLF line endings
+	return strings.Join(lines, "\r\n") + "\r\n"
+}
+
+func findSourceSet(name string) *SourceSet {
+	for _, set := range sourceSets {
+		if set.Name == name {
+			return set
+		}
+	}
+	return nil
+}
+
+// cmake/sources.cmake is for x64 builds so it doesn't have files of other
+// platforms
+func isCMakeSourceGroup(g *SourceGroup) bool {
+	return !strings.HasPrefix(g.Filter, "platforms:") || strings.Contains(g.Filter, "x64")
+}
+
+// expands patterns like "Foo.*" to files that exist on disk, including files
+// of other sets (SourceGroup.Set). Also returns patterns that don't match any
+// files, which premake ignores
+func expandSourceSet(set *SourceSet, forCMake bool) ([]string, []string) {
+	var res, missing []string
+	seen := map[string]bool{}
+	var expand func(set *SourceSet)
+	expand = func(set *SourceSet) {
+		for _, g := range set.Groups {
+			if forCMake && !isCMakeSourceGroup(g) {
+				continue
+			}
+			if g.Set != "" {
+				other := findSourceSet(g.Set)
+				panicIf(other == nil, "%s_files() includes %s_files(), which doesn't exist", set.Name, g.Set)
+				expand(other)
+				continue
+			}
+			for _, f := range g.Files {
+				pattern := filepath.Join(filepath.FromSlash(g.Dir), filepath.FromSlash(f))
+				matches, err := filepath.Glob(pattern)
+				must(err)
+				sort.Strings(matches)
+				n := 0
+				for _, m := range matches {
+					if !fileExists(m) {
+						// "*" also matches directories
+						continue
+					}
+					n++
+					m = filepath.ToSlash(m)
+					if !seen[m] {
+						seen[m] = true
+						res = append(res, m)
+					}
+				}
+				if n == 0 {
+					missing = append(missing, fmt.Sprintf("'%s' in %s_files()", pattern, set.Name))
+				}
+			}
+		}
+	}
+	expand(set)
+	return res, missing
+}
+
+func genCMakeSources(sets []*SourceSet) string {
+	s := "# auto-generated by do/project_files.go, edit sourceSets in do/source_sets.go and run: do gen projects -cmake\n"
+	for _, set := range sets {
+		s += fmt.Sprintf("\nset(%s_FILES\n", strings.ToUpper(set.Name))
+		files, _ := expandSourceSet(set, true)
+		for _, path := range files {
+			s += fmt.Sprintf("  ${CMAKE_CURRENT_LIST_DIR}/../%s\n", path)
+		}
+		s += ")\n"
+	}
+	return s
+}
+
+func genProjects(cmake bool) {
+	// sets included in other sets would report the same patterns
+	reported := map[string]bool{}
+	for _, set := range sourceSets {
+		files, missing := expandSourceSet(set, false)
+		logf("%s_files(): %d files\n", set.Name, len(files))
+		for _, m := range missing {
+			if !reported[m] {
+				reported[m] = true
+				logf("warning: %s doesn't match any files\n", m)
+			}
+		}
+	}
+	writeFileMust(premakeGenFilesPath, []byte(genPremakeFiles(sourceSets)))
+	logf("wrote '%s'\n", premakeGenFilesPath)
+	if cmake {
+		path := filepath.Join("cmake", "sources.cmake")
+		must(createDirForFile(path))
+		writeFileMust(path, []byte(genCMakeSources(sourceSets)))
+		logf("wrote '%s'\n", path)
+	}
+	if runtime.GOOS != "windows" {
+		logf("premake5.exe only runs on Windows, run 'do gen premake' there to re-generate vs2022 projects\n")
+		return
+	}
+	regenPremake()
+	cmd := exec.Command("git", "status", "--short", "vs2022")
+	runCmdLoggedMust(cmd)
+}
@@ -0,0 +1,242 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// do release wizard walks through all steps of making a release. Before each step
+// it asks for confirmation. Completed steps are recorded in a state file
+// so that after fixing a problem we can re-run do release wizard and it continues
+// where it stopped
+
+// ReleaseStep is one step of the release process
+type ReleaseStep struct {
+	Name string
+	Desc string
+	// nil means a manual step, we only ask if it was done
+	Run func()
+}
+
+// ReleaseState is saved after each step
+type ReleaseState struct {
+	Ver  string               `json:"ver"`
+	Done map[string]time.Time `json:"done"`
+	// steps user chose to skip
+	Skipped map[string]bool `json:"skipped"`
+}
+
+func releaseStatePath(ver string) string {
+	return filepath.Join("out", fmt.Sprintf("release-state-%s.json", ver))
+}
+
+func loadReleaseState(ver string) *ReleaseState {
+	res := &ReleaseState{Ver: ver, Done: map[string]time.Time{}, Skipped: map[string]bool{}}
+	d, err := os.ReadFile(releaseStatePath(ver))
+	if err != nil {
+		return res
+	}
+	must(json.Unmarshal(d, res))
+	if res.Skipped == nil {
+		res.Skipped = map[string]bool{}
+	}
+	return res
+}
+
+func saveReleaseState(st *ReleaseState) {
+	d, err := json.MarshalIndent(st, "", "  ")
+	must(err)
+	must(createDirForFile(releaseStatePath(st.Ver)))
+	writeFileMust(releaseStatePath(st.Ver), d)
+}
+
+var stdinReader = bufio.NewReader(os.Stdin)
+
+// returns first letter of the answer, lower-cased
+func askUser(prompt string) string {
+	fmt.Printf("%s ", prompt)
+	s, _ := stdinReader.ReadString('\n')
+	s = strings.ToLower(strings.TrimSpace(s))
+	if s == "" {
+		return ""
+	}
+	return s[:1]
+}
+
+// release notes must have a section for the version with at least one entry
+func verifyReleaseNotesMust(ver string) {
+	path := filepath.Join("docs", "releasenotes.txt")
+	lines, err := readLinesFromFile(path)
+	must(err)
+	inSection := false
+	for _, l := range lines {
+		l = strings.TrimSpace(l)
+		if l == ver || strings.HasPrefix(l, ver+" (") {
+			inSection = true
+			continue
+		}
+		if inSection && strings.HasPrefix(l, "* ") {
+			logf("found release notes for %s in '%s'\n", ver, path)
+			return
+		}
+	}
+	panicIf(true, "no release notes for '%s' in '%s'", ver, path)
+}
+
+// CURR_VERSION_COMMA must match CURR_VERSION
+func verifyVersionHeaderMust(ver string) {
+	path := filepath.Join("src", "Version.h")
+	s := string(readFileMust(path))
+	parts := strings.Split(ver, ".")
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	comma := "#define CURR_VERSION_COMMA " + strings.Join(parts, ",")
+	panicIf(!strings.Contains(s, comma), "'%s' doesn't have '%s'", path, comma)
+}
+
+// writes files for winget and chocolatey packages with hashes of
+// the release installers
+func genReleasePackagesMust(ver string) {
+	urls := getDownloadUrlsViaWebsite(buildTypeRel, ver)
+	finalDir := getFinalDirForBuildType(buildTypeRel)
+	sha := func(url string) string {
+		path := filepath.Join(finalDir, url[strings.LastIndex(url, "/")+1:])
+		return strings.ToUpper(sha256Hex(readFileMust(path)))
+	}
+	outDir := filepath.Join("out", "release-packages")
+	createDirMust(outDir)
+
+	winget := fmt.Sprintf(`PackageIdentifier: SumatraPDF.SumatraPDF
+PackageVersion: %s
+InstallerType: nullsoft
+Scope: user
+InstallerSwitches:
+  Silent: -s
+  SilentWithProgress: -s
+Installers:
+- Architecture: x86
+  InstallerUrl: %s
+  InstallerSha256: %s
+- Architecture: x64
+  InstallerUrl: %s
+  InstallerSha256: %s
+- Architecture: arm64
+  InstallerUrl: %s
+  InstallerSha256: %s
+ManifestType: installer
+ManifestVersion: 1.4.0
+`, ver, urls.installer32, sha(urls.installer32), urls.installer64, sha(urls.installer64), urls.installerArm64, sha(urls.installerArm64))
+	path := filepath.Join(outDir, "SumatraPDF.SumatraPDF.installer.yaml")
+	writeFileMust(path, []byte(winget))
+	logf("wrote '%s'\n", path)
+
+	choco := fmt.Sprintf(`$ErrorActionPreference = 'Stop'
+$packageArgs = @{
+  packageName    = 'sumatrapdf.install'
+  fileType       = 'exe'
+  url            = '%s'
+  checksum       = '%s'
+  checksumType   = 'sha256'
+  url64bit       = '%s'
+  checksum64     = '%s'
+  checksumType64 = 'sha256'
+  silentArgs     = '-s -all-users'
+}
+Install-ChocolateyPackage @packageArgs
+`, urls.installer32, sha(urls.installer32), urls.installer64, sha(urls.installer64))
+	path = filepath.Join(outDir, "chocolateyinstall.ps1")
+	writeFileMust(path, []byte(choco))
+	logf("wrote '%s'\n", path)
+	logf("submit winget manifest to https://github.com/microsoft/winget-pkgs and update chocolatey package\n")
+}
+
+func getReleaseSteps(ver string) []*ReleaseStep {
+	return []*ReleaseStep{
+		{"branch", "verify we're on rel" + ver + "working branch with no changes", func() {
+			verifyOnReleaseBranchMust()
+			panicIf(!isGitClean(""), "git has unsaved changes\n")
+		}},
+		{"translations", "verify translations are up to date", verifyTranslationsMust},
+		{"translation-gate", "verify shipping languages are translated enough", checkTranslationGateMust},
+		{"release-notes", "verify docs/releasenotes.txt has notes for " + ver, func() {
+			verifyReleaseNotesMust(ver)
+		}},
+		{"version", "verify version in src/Version.h", func() {
+			verifyVersionHeaderMust(ver)
+		}},
+		{"build", "clean build and sign all platforms", func() {
+			ensureAllUploadCreds()
+			panicIf(!hasCertPwd(), "CERT_PWD env variable is not set")
+			buildRelease()
+		}},
+		{"verify-signatures", "verify signatures of built files", func() {
+			verifySignatureTimestampsMust(buildTypeRel)
+		}},
+		{"upload", "upload to storage", func() {
+			uploadToStorage(buildTypeRel)
+		}},
+		{"mirrors", "upload to SourceForge and FossHub", uploadToMirrors},
+		{"packages", "generate winget and chocolatey packages", func() {
+			genReleasePackagesMust(ver)
+		}},
+		{"update-check", "make " + ver + " the latest version for auto-update", func() {
+			updateAutoUpdateVer(ver)
+		}},
+		{"download-page", "update download page data in sumatra-website repo", genDownloadPage},
+		{"website", "update release notes on the website", nil},
+	}
+}
+
+func fmtReleaseSummary(st *ReleaseState, steps []*ReleaseStep) string {
+	s := fmt.Sprintf("release %s:\n", st.Ver)
+	for _, step := range steps {
+		status := "not done"
+		if t, ok := st.Done[step.Name]; ok {
+			status = "done " + t.Local().Format("2006-01-02 15:04")
+		} else if st.Skipped[step.Name] {
+			status = "skipped"
+		}
+		s += fmt.Sprintf("  %-18s %s\n", step.Name, status)
+	}
+	return s
+}
+
+func releaseWizard() {
+	ver := sumatraVersion
+	st := loadReleaseState(ver)
+	steps := getReleaseSteps(ver)
+	defer func() {
+		logf("\n%s", fmtReleaseSummary(st, steps))
+	}()
+	for i, step := range steps {
+		if _, ok := st.Done[step.Name]; ok {
+			logf("step %d/%d %s: already done\n", i+1, len(steps), step.Name)
+			continue
+		}
+		delete(st.Skipped, step.Name)
+		prompt := fmt.Sprintf("\nstep %d/%d %s: %s\nrun? [y]es / [s]kip / [q]uit:", i+1, len(steps), step.Name, step.Desc)
+		if step.Run == nil {
+			prompt = fmt.Sprintf("\nstep %d/%d %s: %s\ndone manually? [y]es / [s]kip / [q]uit:", i+1, len(steps), step.Name, step.Desc)
+		}
+		switch askUser(prompt) {
+		case "y":
+			if step.Run != nil {
+				step.Run()
+			}
+			st.Done[step.Name] = time.Now()
+		case "s":
+			st.Skipped[step.Name] = true
+		default:
+			logf("stopping, re-run: do release wizard to continue\n")
+			saveReleaseState(st)
+			return
+		}
+		saveReleaseState(st)
+	}
+}
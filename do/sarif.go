@@ -0,0 +1,195 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// minimal subset of SARIF 2.1.0 we need to report static analysis results
+// and upload them to GitHub code scanning
+// https://docs.oasis-open.org/sarif/sarif/v2.1.0/sarif-v2.1.0.html
+
+const sarifSchema = "https://json.schemastore.org/sarif-2.1.0.json"
+
+type SarifLog struct {
+	Schema  string      `json:"$schema"`
+	Version string      `json:"version"`
+	Runs    []*SarifRun `json:"runs"`
+}
+
+type SarifRun struct {
+	Tool    SarifTool      `json:"tool"`
+	Results []*SarifResult `json:"results"`
+}
+
+type SarifTool struct {
+	Driver SarifDriver `json:"driver"`
+}
+
+type SarifDriver struct {
+	Name           string `json:"name"`
+	InformationURI string `json:"informationUri,omitempty"`
+}
+
+type SarifMessage struct {
+	Text string `json:"text"`
+}
+
+type SarifResult struct {
+	RuleID    string           `json:"ruleId"`
+	Level     string           `json:"level,omitempty"`
+	Message   SarifMessage     `json:"message"`
+	Locations []*SarifLocation `json:"locations,omitempty"`
+	// used for deduplication by GitHub code scanning
+	PartialFingerprints map[string]string `json:"partialFingerprints,omitempty"`
+}
+
+type SarifLocation struct {
+	PhysicalLocation SarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type SarifPhysicalLocation struct {
+	ArtifactLocation SarifArtifactLocation `json:"artifactLocation"`
+	Region           *SarifRegion          `json:"region,omitempty"`
+}
+
+type SarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+type SarifRegion struct {
+	StartLine   int `json:"startLine,omitempty"`
+	StartColumn int `json:"startColumn,omitempty"`
+}
+
+func newSarifLog(runs ...*SarifRun) *SarifLog {
+	return &SarifLog{
+		Schema:  sarifSchema,
+		Version: "2.1.0",
+		Runs:    runs,
+	}
+}
+
+func newSarifResult(ruleID, level, msg, path string, line, col int) *SarifResult {
+	res := &SarifResult{
+		RuleID:  ruleID,
+		Level:   level,
+		Message: SarifMessage{Text: msg},
+	}
+	if path != "" {
+		loc := &SarifLocation{}
+		loc.PhysicalLocation.ArtifactLocation.URI = sarifURI(path)
+		if line > 0 {
+			loc.PhysicalLocation.Region = &SarifRegion{StartLine: line, StartColumn: col}
+		}
+		res.Locations = []*SarifLocation{loc}
+	}
+	return res
+}
+
+// code scanning wants paths relative to the root of the repository
+func sarifURI(path string) string {
+	if filepath.IsAbs(path) {
+		if rel, err := filepath.Rel(currDirAbsMust(), path); err == nil {
+			path = rel
+		}
+	}
+	return filepath.ToSlash(path)
+}
+
+// returns "path:line" of the first location of the result
+func (r *SarifResult) Location() (string, int) {
+	if len(r.Locations) == 0 {
+		return "", 0
+	}
+	pl := r.Locations[0].PhysicalLocation
+	line := 0
+	if pl.Region != nil {
+		line = pl.Region.StartLine
+	}
+	return pl.ArtifactLocation.URI, line
+}
+
+// Key identifies a finding independent of line numbers, so that it doesn't
+// change when unrelated code is added above it
+func (r *SarifResult) Key() string {
+	path, _ := r.Location()
+	return fmt.Sprintf("%s %s %s", r.RuleID, path, r.Message.Text)
+}
+
+func writeSarifMust(path string, l *SarifLog) {
+	d, err := json.MarshalIndent(l, "", "  ")
+	must(err)
+	writeFileMust(path, d)
+	n := 0
+	for _, run := range l.Runs {
+		n += len(run.Results)
+	}
+	logf("wrote %d results to '%s'\n", n, path)
+}
+
+func readSarifMust(path string) *SarifLog {
+	var res SarifLog
+	err := json.Unmarshal(readFileMust(path), &res)
+	panicIf(err != nil, "failed to parse '%s': %s", path, err)
+	return &res
+}
+
+// reads a file with keys of known findings, one per line
+func readSarifBaseline(path string) map[string]bool {
+	res := map[string]bool{}
+	if !fileExists(path) {
+		return res
+	}
+	for _, line := range strings.Split(string(readFileMust(path)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		res[line] = true
+	}
+	return res
+}
+
+// https://docs.github.com/en/rest/code-scanning/code-scanning#upload-an-analysis-as-sarif-data
+func uploadSarifToGitHubMust(path string) {
+	ghtoken := os.Getenv("GITHUB_TOKEN")
+	panicIf(ghtoken == "", "need GITHUB_TOKEN env variable")
+
+	var buf bytes.Buffer
+	w := gzip.NewWriter(&buf)
+	_, err := w.Write(readFileMust(path))
+	must(err)
+	must(w.Close())
+
+	ref := os.Getenv("GITHUB_REF")
+	if ref == "" {
+		ref = "refs/heads/" + getCurrentBranchMust()
+	}
+	v := map[string]string{
+		"commit_sha": getGitSha1Must(),
+		"ref":        ref,
+		"sarif":      base64.StdEncoding.EncodeToString(buf.Bytes()),
+	}
+	data, err := json.Marshal(v)
+	must(err)
+	uri := "https://api.github.com/repos/sumatrapdfreader/sumatrapdf/code-scanning/sarifs"
+	req, err := http.NewRequest(http.MethodPost, uri, bytes.NewReader(data))
+	must(err)
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Authorization", fmt.Sprintf("token %s", ghtoken))
+	rsp, err := http.DefaultClient.Do(req)
+	must(err)
+	defer rsp.Body.Close()
+	body, _ := io.ReadAll(rsp.Body)
+	panicIf(rsp.StatusCode >= 400, "uploading '%s' failed with %s:\n%s\n", path, rsp.Status, string(body))
+	logf("uploaded '%s' to GitHub code scanning for %s\n", path, ref)
+}
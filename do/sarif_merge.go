@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// merges results of all static analyzers into a single SARIF file:
+// - MSVC /analyze (do analyze msvc) : out/analyze/analyze.sarif
+// - CodeQL (do analyze codeql) : out/codeql/codeql.sarif
+// - clang-tidy (-clang-tidy) : clangtidy.out.txt
+// - cppcheck (do analyze cppcheck) : cppcheck.out.txt
+// - PVS-Studio (do analyze cppcheck, if licensed) : out/pvs-studio/pvs-studio.sarif
+// findings reported more than once are de-duplicated and findings matching
+// do/sarif_suppressions.txt are removed
+
+// clang-tidy and cppcheck use the same format:
+// src\Foo.cpp:123:5: warning: message [check-id]
+var rxGccStyleWarning = regexp.MustCompile(`^(.+?):(\d+):(\d+): (\w+): (.*) \[([^\]]+)\]$`)
+
+// SarifSource is output of one analyzer
+type SarifSource struct {
+	Tool string
+	Path string
+	// if true, Path is a text log we need to convert to SARIF
+	IsTextLog bool
+}
+
+var sarifSources = []*SarifSource{
+	{Tool: "MSVC /analyze", Path: filepath.Join("out", "analyze", "analyze.sarif")},
+	{Tool: "CodeQL", Path: filepath.Join("out", "codeql", "codeql.sarif")},
+	{Tool: "clang-tidy", Path: clangTidyLogFile, IsTextLog: true},
+	{Tool: "cppcheck", Path: cppcheckLogFile, IsTextLog: true},
+	{Tool: "PVS-Studio", Path: filepath.Join("out", "pvs-studio", "pvs-studio.sarif")},
+}
+
+func gccSeverityToSarifLevel(s string) string {
+	switch s {
+	case "error":
+		return "error"
+	case "warning":
+		return "warning"
+	}
+	// cppcheck: style, performance, portability, information
+	return "note"
+}
+
+func parseGccStyleWarnings(s string) []*SarifResult {
+	var res []*SarifResult
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		m := rxGccStyleWarning.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		lineNo, _ := strconv.Atoi(m[2])
+		col, _ := strconv.Atoi(m[3])
+		r := newSarifResult(m[6], gccSeverityToSarifLevel(m[4]), m[5], m[1], lineNo, col)
+		res = append(res, r)
+	}
+	return res
+}
+
+// SarifSuppression is a line in sarif_suppressions.txt
+type SarifSuppression struct {
+	Rule string
+	Path string
+}
+
+// each line is: rule path
+// both can be "*" and path can end with "*" to match files in a directory
+func parseSarifSuppressions(s string) []*SarifSuppression {
+	var res []*SarifSuppression
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		parts := strings.Fields(line)
+		panicIf(len(parts) != 2, "invalid suppression '%s', expected 'rule path'", line)
+		res = append(res, &SarifSuppression{Rule: parts[0], Path: parts[1]})
+	}
+	return res
+}
+
+func matchSuppressionPattern(pattern, s string) bool {
+	if pattern == "*" || pattern == s {
+		return true
+	}
+	if strings.HasSuffix(pattern, "*") && !strings.ContainsAny(pattern[:len(pattern)-1], "*?[") {
+		return strings.HasPrefix(s, pattern[:len(pattern)-1])
+	}
+	ok, _ := path.Match(pattern, s)
+	return ok
+}
+
+func isSuppressed(r *SarifResult, suppressions []*SarifSuppression) bool {
+	uri, _ := r.Location()
+	for _, s := range suppressions {
+		if matchSuppressionPattern(s.Rule, r.RuleID) && matchSuppressionPattern(s.Path, uri) {
+			return true
+		}
+	}
+	return false
+}
+
+func loadSarifSourceMust(src *SarifSource) []*SarifResult {
+	if src.IsTextLog {
+		return parseGccStyleWarnings(string(readFileMust(src.Path)))
+	}
+	var res []*SarifResult
+	for _, run := range readSarifMust(src.Path).Runs {
+		res = append(res, run.Results...)
+	}
+	return res
+}
+
+func mergeSarif(upload bool) {
+	suppressionsPath := filepath.Join("do", "sarif_suppressions.txt")
+	var suppressions []*SarifSuppression
+	if fileExists(suppressionsPath) {
+		suppressions = parseSarifSuppressions(string(readFileMust(suppressionsPath)))
+	}
+
+	// the same issue is often reported by more than one tool
+	seen := map[string]bool{}
+	var runs []*SarifRun
+	for _, src := range sarifSources {
+		if !fileExists(src.Path) {
+			logf("skipping %s: '%s' doesn't exist\n", src.Tool, src.Path)
+			continue
+		}
+		results := loadSarifSourceMust(src)
+		run := &SarifRun{Tool: SarifTool{Driver: SarifDriver{Name: src.Tool}}}
+		nDup, nSuppressed := 0, 0
+		for _, r := range results {
+			uri, line := r.Location()
+			key := fmt.Sprintf("%s:%d %s", uri, line, r.Message.Text)
+			ruleKey := fmt.Sprintf("%s:%d %s %s", uri, line, src.Tool, r.RuleID)
+			if seen[key] || seen[ruleKey] {
+				nDup++
+				continue
+			}
+			seen[key] = true
+			seen[ruleKey] = true
+			if isSuppressed(r, suppressions) {
+				nSuppressed++
+				continue
+			}
+			run.Results = append(run.Results, r)
+		}
+		logf("%s: %d results, %d duplicate, %d suppressed\n", src.Tool, len(run.Results), nDup, nSuppressed)
+		runs = append(runs, run)
+	}
+	panicIf(len(runs) == 0, "no analyzer results found, run do analyze msvc, codeql, clang-tidy or cppcheck first")
+
+	mergedPath := filepath.Join("out", "merged.sarif")
+	writeSarifMust(mergedPath, newSarifLog(runs...))
+	if upload {
+		uploadSarifToGitHubMust(mergedPath)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// takes screenshots of SumatraPDF in representative UI states, for the
+// website and release notes. SumatraPDF is per-monitor DPI aware so we
+// take a screenshot on each monitor with a distinct DPI
+// the screenshots are saved in out/screenshots
+
+const screenshotsPs1 = `param([string]$cmd, [int]$procId, [string]$out)
+Add-Type -AssemblyName System.Drawing
+Add-Type @"
+using System;
+using System.Collections.Generic;
+using System.Runtime.InteropServices;
+public class Shot {
+    [StructLayout(LayoutKind.Sequential)] public struct RECT { public int Left, Top, Right, Bottom; }
+    public delegate bool MonitorEnumProc(IntPtr hMon, IntPtr hdc, ref RECT rc, IntPtr data);
+    [DllImport("user32.dll")] public static extern bool SetProcessDpiAwarenessContext(IntPtr v);
+    [DllImport("user32.dll")] public static extern bool EnumDisplayMonitors(IntPtr hdc, IntPtr clip, MonitorEnumProc cb, IntPtr data);
+    [DllImport("shcore.dll")] public static extern int GetDpiForMonitor(IntPtr hMon, int type, out uint dpiX, out uint dpiY);
+    [DllImport("dwmapi.dll")] public static extern int DwmGetWindowAttribute(IntPtr hwnd, int attr, out RECT rc, int size);
+    [DllImport("user32.dll")] public static extern bool SetForegroundWindow(IntPtr hwnd);
+    public static List<string> Monitors() {
+        var res = new List<string>();
+        EnumDisplayMonitors(IntPtr.Zero, IntPtr.Zero, (IntPtr h, IntPtr dc, ref RECT rc, IntPtr d) => {
+            uint dx, dy;
+            GetDpiForMonitor(h, 0, out dx, out dy);
+            res.Add(String.Format("{0} {1} {2} {3} {4}", rc.Left, rc.Top, rc.Right - rc.Left, rc.Bottom - rc.Top, dx));
+            return true;
+        }, IntPtr.Zero);
+        return res;
+    }
+}
+"@
+# DPI_AWARENESS_CONTEXT_PER_MONITOR_AWARE_V2 so that we get physical pixels
+[Shot]::SetProcessDpiAwarenessContext([IntPtr]-4) | Out-Null
+if ($cmd -eq "monitors") {
+    [Shot]::Monitors() | ForEach-Object { Write-Output $_ }
+    exit 0
+}
+$p = Get-Process -Id $procId
+[Shot]::SetForegroundWindow($p.MainWindowHandle) | Out-Null
+Start-Sleep -Milliseconds 500
+$rc = New-Object Shot+RECT
+# DWMWA_EXTENDED_FRAME_BOUNDS excludes invisible resize borders
+[Shot]::DwmGetWindowAttribute($p.MainWindowHandle, 9, [ref]$rc, 16) | Out-Null
+$w = $rc.Right - $rc.Left
+$h = $rc.Bottom - $rc.Top
+$bmp = New-Object System.Drawing.Bitmap $w, $h
+$g = [System.Drawing.Graphics]::FromImage($bmp)
+$g.CopyFromScreen($rc.Left, $rc.Top, 0, 0, $bmp.Size)
+$bmp.Save($out, [System.Drawing.Imaging.ImageFormat]::Png)
+$g.Dispose()
+$bmp.Dispose()
+`
+
+// ScreenshotScenario is a UI state we take screenshot of
+type ScreenshotScenario struct {
+	Name string
+	// added to SumatraPDF-settings.txt
+	Settings string
+	// how many of the sample documents to open
+	NumDocs int
+}
+
+var screenshotScenarios = []*ScreenshotScenario{
+	{Name: "single", Settings: "ShowToc = false", NumDocs: 1},
+	{Name: "tabs", Settings: "UseTabs = true\nShowToc = false", NumDocs: 3},
+	{Name: "bookmarks", Settings: "ShowToc = true", NumDocs: 1},
+	{Name: "dark", Settings: "Theme = dark\nShowToc = true", NumDocs: 2},
+	{Name: "home", Settings: "", NumDocs: 0},
+}
+
+// Monitor is a display and its DPI
+type Monitor struct {
+	X, Y, Dx, Dy int
+	Dpi          int
+}
+
+func runScreenshotsPs1Must(ps1Path string, args ...string) string {
+	args = append([]string{"-ExecutionPolicy", "Bypass", "-File", ps1Path}, args...)
+	cmd := exec.Command("powershell.exe", args...)
+	out, err := cmd.CombinedOutput()
+	panicIf(err != nil, "%s failed with '%s', output:\n%s\n", cmd.String(), err, string(out))
+	return string(out)
+}
+
+// returns one monitor for each distinct DPI
+func getMonitorsWithDistinctDpiMust(ps1Path string) []*Monitor {
+	out := runScreenshotsPs1Must(ps1Path, "monitors")
+	var res []*Monitor
+	seen := map[int]bool{}
+	for _, line := range strings.Split(out, "\n") {
+		parts := strings.Fields(line)
+		if len(parts) != 5 {
+			continue
+		}
+		var n [5]int
+		for i, s := range parts {
+			v, err := strconv.Atoi(s)
+			must(err)
+			n[i] = v
+		}
+		m := &Monitor{X: n[0], Y: n[1], Dx: n[2], Dy: n[3], Dpi: n[4]}
+		if seen[m.Dpi] {
+			continue
+		}
+		seen[m.Dpi] = true
+		res = append(res, m)
+	}
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].Dpi < res[j].Dpi
+	})
+	panicIf(len(res) == 0, "didn't find any monitors, output:\n%s\n", out)
+	return res
+}
+
+// generates sample documents in dir, with table of contents so that
+// bookmarks sidebar is shown
+func genScreenshotDocsMust(dir string) []string {
+	var res []string
+	for i := 1; i <= 3; i++ {
+		var pages []string
+		for j := 1; j <= 5; j++ {
+			pages = append(pages, fmt.Sprintf("Document %d, chapter %d", i, j))
+		}
+		path := filepath.Join(dir, fmt.Sprintf("sample-%d.pdf", i))
+		writeFileMust(path, genTestPdfPages(pages))
+		res = append(res, path)
+	}
+	return res
+}
+
+// starts SumatraPDF with a fresh SumatraPDF-settings.txt in appDataDir
+func startSumatraWithSettingsMust(exe string, appDataDir string, settings string, docs []string) *exec.Cmd {
+	must(os.RemoveAll(appDataDir))
+	createDirMust(appDataDir)
+	settings = "CheckForUpdates = false\n" + settings
+	writeFileMust(filepath.Join(appDataDir, "SumatraPDF-settings.txt"), []byte(settings))
+
+	args := []string{"-appdata", appDataDir, "-new-window"}
+	args = append(args, docs...)
+	cmd := exec.Command(exe, args...)
+	logf("> %s\n", cmd.String())
+	must(cmd.Start())
+	return cmd
+}
+
+func killCmd(cmd *exec.Cmd) {
+	_ = cmd.Process.Kill()
+	_ = cmd.Wait()
+}
+
+func takeScreenshot(exe string, ps1Path string, docs []string, sc *ScreenshotScenario, m *Monitor, dstPath string) {
+	appDataDir := filepath.Join(filepath.Dir(dstPath), "appdata-"+sc.Name)
+	// leave margin so that the window fits on the monitor
+	dx := min(1280*m.Dpi/96, m.Dx-64)
+	dy := min(800*m.Dpi/96, m.Dy-64)
+	settings := fmt.Sprintf("%s\nWindowState = 1\nWindowPos = %d %d %d %d\n", sc.Settings, m.X+32, m.Y+32, dx, dy)
+	cmd := startSumatraWithSettingsMust(exe, appDataDir, settings, docs[:sc.NumDocs])
+	defer killCmd(cmd)
+	// wait for the window to show up and render the pages
+	time.Sleep(time.Second * 3)
+	runScreenshotsPs1Must(ps1Path, "capture", strconv.Itoa(cmd.Process.Pid), dstPath)
+	logf("saved %s\n", dstPath)
+}
+
+func takeScreenshots(dir string) {
+	exe := absPathMust(filepath.Join(dir, "SumatraPDF.exe"))
+	panicIf(!fileExists(exe), "'%s' doesn't exist", exe)
+
+	outDir := absPathMust(filepath.Join("out", "screenshots"))
+	must(os.RemoveAll(outDir))
+	docsDir := filepath.Join(outDir, "docs")
+	createDirMust(docsDir)
+	docs := genScreenshotDocsMust(docsDir)
+	ps1Path := filepath.Join(outDir, "screenshots.ps1")
+	writeFileMust(ps1Path, []byte(screenshotsPs1))
+
+	monitors := getMonitorsWithDistinctDpiMust(ps1Path)
+	for _, m := range monitors {
+		logf("monitor at %d,%d (%dx%d), dpi: %d\n", m.X, m.Y, m.Dx, m.Dy, m.Dpi)
+	}
+	n := 0
+	for _, sc := range screenshotScenarios {
+		for _, m := range monitors {
+			name := fmt.Sprintf("%s-%ddpi.png", sc.Name, m.Dpi)
+			takeScreenshot(exe, ps1Path, docs, sc, m, filepath.Join(outDir, name))
+			n++
+		}
+	}
+	logf("took %d screenshots in '%s'\n", n, outDir)
+}
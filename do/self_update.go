@@ -0,0 +1,163 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"time"
+)
+
+// maintainers run the do tool from source, build machines use do.exe.
+// do self publish builds do.exe (with git sha as version), signs it and
+// uploads it with latest.json to the channel in storage.
+// do self update on a build machine downloads latest do.exe, verifies its
+// sha256 and signature and replaces the running executable (we can't
+// overwrite running .exe on Windows but we can rename it, the old one is
+// deleted on next start).
+// sha256 comes from the same server as do.exe so it only protects against
+// corrupted downloads. What protects against a swapped do.exe is that it
+// must be signed with the same certificate as the running do.exe. After
+// renewing the certificate, build machines must be updated manually.
+// Version is printed at startup
+
+// set with -ldflags "-X main.doVersion=${sha}" by do self publish
+var doVersion = "dev"
+
+const (
+	doToolRemoteDir = "software/sumatrapdf/do-tool/"
+	// public url of doToolRemoteDir
+	doToolChannelURL = "https://www.sumatrapdfreader.org/dl/do-tool/"
+)
+
+// DoToolRelease is latest.json in the channel
+type DoToolRelease struct {
+	Version string
+	// file name, relative to the channel
+	File      string
+	Sha256    string
+	Published time.Time
+}
+
+func getDoVersion() string {
+	if doVersion != "dev" {
+		return doVersion
+	}
+	info, ok := debug.ReadBuildInfo()
+	if !ok {
+		return doVersion
+	}
+	rev, modified := "", ""
+	for _, s := range info.Settings {
+		switch s.Key {
+		case "vcs.revision":
+			rev = s.Value
+		case "vcs.modified":
+			if s.Value == "true" {
+				modified = "-dirty"
+			}
+		}
+	}
+	if rev == "" {
+		return doVersion
+	}
+	return fmt.Sprintf("%s (%s%s)", doVersion, rev, modified)
+}
+
+func selfPublish() {
+	sha := getGitSha1()
+	dir := createDirMust(filepath.Join("out", "do-tool"))
+	exePath := absPathMust(filepath.Join(dir, "do.exe"))
+	cmd := exec.Command("go", "build", "-ldflags", "-X main.doVersion="+sha, "-o", exePath, ".")
+	cmd.Dir = "do"
+	// build machines are x64 Windows, we might publish from elsewhere
+	cmd.Env = append(os.Environ(), "GOOS=windows", "GOARCH=amd64")
+	runCmdLoggedMust(cmd)
+	signMust(exePath)
+
+	rel := &DoToolRelease{
+		Version:   sha,
+		File:      sha + "/do.exe",
+		Sha256:    sha256Hex(readFileMust(exePath)),
+		Published: time.Now().UTC(),
+	}
+	d, err := json.MarshalIndent(rel, "", "  ")
+	must(err)
+	mc := newMinioR2Client()
+	_, err = mc.UploadFile(doToolRemoteDir+rel.File, exePath, true)
+	must(err)
+	// latest.json last so that clients never see a version that isn't uploaded
+	_, err = mc.UploadData(doToolRemoteDir+"latest.json", d, true)
+	must(err)
+	logf("published do.exe %s to '%s'\n", sha, mc.URLForPath(doToolRemoteDir))
+}
+
+// deletes executable replaced by previous self-update
+func removeOldSelf() {
+	exePath, err := os.Executable()
+	if err != nil {
+		return
+	}
+	os.Remove(exePath + ".old")
+}
+
+// thumbprints of certificates that signed a PE file
+func getSignerThumbprintsMust(path string) []string {
+	var res []string
+	for _, sig := range peAuthenticodeSigsMust(path) {
+		res = append(res, sig.Thumbprint)
+	}
+	return res
+}
+
+// verifies that path is signed with one of the certificates that signed
+// the running executable
+func verifySameSignerMust(path string, exePath string) {
+	want := getSignerThumbprintsMust(exePath)
+	panicIf(len(want) == 0, "'%s' is not signed, can't verify the signer of the update", exePath)
+	got := getSignerThumbprintsMust(path)
+	for _, s := range got {
+		if stringInSlice(want, s) {
+			return
+		}
+	}
+	panicIf(true, "'%s' is signed by '%s', expected one of '%s'", path, strings.Join(got, ", "), strings.Join(want, ", "))
+}
+
+func selfUpdate() {
+	panicIf(doVersion == "dev", "running from source (version %s), update with git pull", getDoVersion())
+	var rel DoToolRelease
+	must(json.Unmarshal(httpGetMust(doToolChannelURL+"latest.json"), &rel))
+	if rel.Version == doVersion {
+		logf("do.exe is up to date (%s)\n", doVersion)
+		return
+	}
+	logf("updating do.exe from %s to %s (published %s)\n", doVersion, rel.Version, rel.Published.Format(time.RFC3339))
+
+	exePath, err := os.Executable()
+	must(err)
+	newPath := exePath + ".new"
+	d := httpGetMust(doToolChannelURL + rel.File)
+	got := sha256Hex(d)
+	failIf(errKindVerify, got != rel.Sha256, "sha256 of downloaded do.exe is %s, expected %s", got, rel.Sha256)
+	writeFileMust(newPath, d)
+	defer os.Remove(newPath)
+	func() {
+		defer failOnPanic(errKindVerify)
+		verifySignatureMust(newPath)
+		verifySameSignerMust(newPath, exePath)
+	}()
+
+	oldPath := exePath + ".old"
+	os.Remove(oldPath)
+	must(os.Rename(exePath, oldPath))
+	if err = os.Rename(newPath, exePath); err != nil {
+		// put the old one back
+		_ = os.Rename(oldPath, exePath)
+		must(err)
+	}
+	logf("updated '%s' to %s\n", exePath, rel.Version)
+}
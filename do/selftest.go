@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// do test self runs the pipeline end-to-end against fakes (see fakes.go):
+// uploads builds to in-memory storage and checks naming and update info,
+// dedup of unchanged files, deleting old builds and symbols, scheduled
+// promotions, merging manifests of a partial (-platforms) build and
+// creating source archive with a fake git. The same scenarios run as unit
+// tests in pipeline_test.go
+
+// SelfTest is a scenario run by do test self and by unit tests. Fails by
+// panicking
+type SelfTest struct {
+	Name string
+	Run  func(fb *FakeBackends)
+}
+
+var selfTests = []*SelfTest{
+	{"upload-prerel", selfTestUploadPreRel},
+	{"upload-dedup", selfTestUploadDedup},
+	{"upload-rel", selfTestUploadRel},
+	{"retention", selfTestRetention},
+	{"promotion", selfTestPromotion},
+	{"partial-manifest", selfTestPartialManifest},
+	{"source-archive", selfTestSourceArchive},
+}
+
+// checks that all files in dirLocal are in dirRemote of mc with same content
+func checkUploadedDirMust(mc *MemStorage, dirRemote string, dirLocal string) {
+	for name, sha := range calcDirHashesMust(dirLocal) {
+		remotePath := path.Join(dirRemote, name)
+		d, err := mc.DownloadData(remotePath)
+		panicIf(err != nil, "'%s' was not uploaded to %s", remotePath, mc.Name)
+		panicIf(sha256Hex(d) != sha, "'%s' in %s has different content", remotePath, mc.Name)
+	}
+	panicIf(!mc.Exists(path.Join(dirRemote, uploadHashesFileName)), "no %s in '%s'", uploadHashesFileName, dirRemote)
+}
+
+// checks that all urls in update info point to files that were uploaded
+func checkUpdateInfoMust(mc *MemStorage, buildType BuildType) {
+	ver := getVerForBuildType(buildType)
+	remotePaths := getRemotePaths(buildType)
+	d, err := mc.DownloadData(remotePaths[1])
+	panicIf(err != nil, "'%s' was not uploaded", remotePaths[1])
+	panicIf(string(d) != ver, "'%s' is '%s', expected '%s'", remotePaths[1], d, ver)
+	d, err = mc.DownloadData(remotePaths[2])
+	panicIf(err != nil, "'%s' was not uploaded", remotePaths[2])
+	info := parseUpdateInfoTxt(string(d))
+	panicIf(info["Latest"] != ver, "Latest in '%s' is '%s', expected '%s'", remotePaths[2], info["Latest"], ver)
+	const websitePrefix = "https://www.sumatrapdfreader.org/dl/"
+	for k, uri := range info {
+		if !strings.HasPrefix(uri, "http") {
+			continue
+		}
+		panicIf(!strings.HasPrefix(uri, websitePrefix), "%s '%s' is not a website url", k, uri)
+		remotePath := "software/sumatrapdf/" + strings.TrimPrefix(uri, websitePrefix)
+		panicIf(!mc.Exists(remotePath), "%s '%s' points to '%s' which was not uploaded", k, uri, remotePath)
+	}
+}
+
+func selfTestUploadPreRel(fb *FakeBackends) {
+	dir := createFakeFinalDirMust(buildTypePreRel, "a")
+	for _, mc := range fb.Storages {
+		minioUploadBuildMust(mc, buildTypePreRel, nil)
+		checkUploadedDirMust(mc, getRemoteDir(buildTypePreRel), dir)
+		checkUpdateInfoMust(mc, buildTypePreRel)
+		panicIf(!isBuildAlreadyUploaded(mc, buildTypePreRel), "build is not considered uploaded to %s", mc.Name)
+	}
+}
+
+func selfTestUploadDedup(fb *FakeBackends) {
+	mc := fb.Storage("r2")
+	createFakeFinalDirMust(buildTypePreRel, "a")
+	minioUploadBuildMust(mc, buildTypePreRel, nil)
+
+	// next build changes only SumatraPDF-prerel-64.exe
+	ver, err := strconv.Atoi(preReleaseVerCached)
+	must(err)
+	preReleaseVerCached = strconv.Itoa(ver + 1)
+	dir := createFakeFinalDirMust(buildTypePreRel, "a")
+	changedName := "SumatraPDF-prerel-64.exe"
+	writeFileMust(filepath.Join(dir, changedName), []byte("changed"))
+	minioUploadBuildMust(mc, buildTypePreRel, nil)
+	dirRemote := getRemoteDir(buildTypePreRel)
+	checkUploadedDirMust(mc, dirRemote, dir)
+	checkUpdateInfoMust(mc, buildTypePreRel)
+
+	// unchanged files must be copied from the previous build, not uploaded
+	copied := mc.CopiedKeys()
+	for name := range calcDirHashesMust(dir) {
+		remotePath := path.Join(dirRemote, name)
+		wasCopied := stringInSlice(copied, remotePath)
+		if name == changedName {
+			panicIf(wasCopied, "changed '%s' was copied from the previous build", remotePath)
+			continue
+		}
+		panicIf(!wasCopied, "unchanged '%s' was uploaded instead of copied", remotePath)
+	}
+}
+
+func selfTestUploadRel(fb *FakeBackends) {
+	dir := createFakeFinalDirMust(buildTypeRel, "a")
+	mc := fb.Storage("backblaze")
+	minioUploadBuildMust(mc, buildTypeRel, nil)
+	checkUploadedDirMust(mc, getRemoteDir(buildTypeRel), dir)
+	panicIf(!strings.Contains(getRemoteDir(buildTypeRel), "/rel/"+sumatraVersion+"/"), "unexpected remote dir '%s'", getRemoteDir(buildTypeRel))
+	// release build doesn't change update info, that's done by promotion
+	panicIf(mc.Exists(getRemotePaths(buildTypeRel)[2]), "release upload changed update info")
+}
+
+func selfTestRetention(fb *FakeBackends) {
+	mc := fb.Storage("r2")
+	nBuilds := nPreRelSymbolsToRetain + 5
+	firstVer := 16000
+	for i := 0; i < nBuilds; i++ {
+		dir := fmt.Sprintf("software/sumatrapdf/prerel/%d/", firstVer+i)
+		_, _ = mc.UploadData(dir+"SumatraPDF-prerel-64.exe", []byte("exe"), true)
+		_, _ = mc.UploadData(dir+"SumatraPDF-prerel-64.pdb.zip", []byte("pdb"), true)
+	}
+	minioDeleteOldBuildsPrefix(mc, buildTypePreRel, nil)
+	exes := 0
+	for _, key := range mc.Keys("software/sumatrapdf/prerel/") {
+		if strings.HasSuffix(key, ".exe") {
+			exes++
+		}
+	}
+	panicIf(exes != nBuildsToRetainPreRel, "%d builds after deleting old builds, expected %d", exes, nBuildsToRetainPreRel)
+	panicIf(!mc.Exists(fmt.Sprintf("software/sumatrapdf/prerel/%d/SumatraPDF-prerel-64.exe", firstVer+nBuilds-1)), "the newest build was deleted")
+
+	inCrashes := map[int]bool{firstVer: true}
+	pruneSymbols(mc, inCrashes, true)
+	var pdbs []string
+	for _, key := range mc.Keys("software/sumatrapdf/prerel/") {
+		if isSymbolsFile(key) {
+			pdbs = append(pdbs, key)
+		}
+	}
+	panicIf(len(pdbs) != nPreRelSymbolsToRetain+1, "%d symbol files after pruning, expected %d", len(pdbs), nPreRelSymbolsToRetain+1)
+	panicIf(!mc.Exists(fmt.Sprintf("software/sumatrapdf/prerel/%d/SumatraPDF-prerel-64.pdb.zip", firstVer)), "symbols of a build in crash reports were deleted")
+}
+
+func selfTestPromotion(fb *FakeBackends) {
+	mc := fb.Storage("backblaze")
+	now := time.Date(2024, 5, 3, 10, 0, 0, 0, time.UTC)
+	due := parseScheduledPromotionMust("3.5.2,2024-05-03T09:00:00Z,25%")
+	later := parseScheduledPromotionMust("3.6,2024-05-10T09:00:00Z")
+	saveScheduledPromotionsMust(mc, []*ScheduledPromotion{later, due})
+
+	var promoted []string
+	promote := func(ver string, rolloutPercent int) {
+		promoted = append(promoted, fmt.Sprintf("%s:%d", ver, rolloutPercent))
+	}
+	runDuePromotionsMust(mc, now, promote)
+	panicIf(strings.Join(promoted, ",") != "3.5.2:25", "promoted '%s', expected '3.5.2:25'", strings.Join(promoted, ","))
+	// promotions are done once
+	runDuePromotionsMust(mc, now.Add(time.Hour), promote)
+	panicIf(len(promoted) != 1, "promoted %d times, expected once", len(promoted))
+	for _, p := range loadScheduledPromotionsMust(mc) {
+		panicIf(p.Done != (p.Ver == "3.5.2"), "promotion of %s has Done: %v", p.Ver, p.Done)
+	}
+	runDuePromotionsMust(mc, later.At, promote)
+	panicIf(strings.Join(promoted, ",") != "3.5.2:25,3.6:100", "promoted '%s'", strings.Join(promoted, ","))
+
+	// if a promotion fails, those done before it are not repeated
+	first := parseScheduledPromotionMust("3.6.1,2024-05-11T09:00:00Z")
+	second := parseScheduledPromotionMust("3.6.2,2024-05-12T09:00:00Z")
+	saveScheduledPromotionsMust(mc, []*ScheduledPromotion{first, second})
+	func() {
+		defer func() {
+			_ = recover()
+		}()
+		runDuePromotionsMust(mc, second.At, func(ver string, rolloutPercent int) {
+			panicIf(ver == second.Ver, "failed to promote %s", ver)
+		})
+	}()
+	for _, p := range loadScheduledPromotionsMust(mc) {
+		panicIf(p.Done != (p.Ver == first.Ver), "after failed promotion, promotion of %s has Done: %v", p.Ver, p.Done)
+	}
+}
+
+func selfTestPartialManifest(fb *FakeBackends) {
+	mc := fb.Storage("r2")
+	prefix := "SumatraPDF-prerel"
+	// prior build of all platforms is in storage
+	prior := []string{
+		"out/rel32/SumatraPDF.exe: 100 sha-32-old",
+		"out/rel64/SumatraPDF.exe: 200 sha-64-old",
+		// belongs to the line above
+		"  size budget ok",
+		"out/arm64/SumatraPDF.exe: 300 sha-arm64-old",
+	}
+	remotePath := getRemoteDir(buildTypePreRel) + prefix + "-manifest.txt"
+	_, _ = mc.UploadData(remotePath, []byte(strings.Join(prior, "\n")), true)
+
+	origSelected := selectedPlatforms
+	defer func() {
+		selectedPlatforms = origSelected
+	}()
+	selectedPlatforms = parsePlatformsMust("arm64")
+	dir := createDirMust(getFinalDirForBuildType(buildTypePreRel))
+	localPath := filepath.Join(dir, prefix+"-manifest.txt")
+	writeFileMust(localPath, []byte("out/arm64/SumatraPDF.exe: 301 sha-arm64-new\n"))
+	mergePriorBuildFilesMust(buildTypePreRel, dir, prefix)
+
+	got := string(readFileMust(localPath))
+	for _, s := range []string{"sha-arm64-new", "sha-32-old", "sha-64-old", "size budget ok"} {
+		panicIf(!strings.Contains(got, s), "merged manifest doesn't have '%s':\n%s", s, got)
+	}
+	panicIf(strings.Contains(got, "sha-arm64-old"), "merged manifest has rebuilt platform of the prior build:\n%s", got)
+
+	// upload hashes of platforms we didn't rebuild come from the prior build
+	dirRemote := getRemoteDir(buildTypePreRel)
+	priorHashes := map[string]string{"SumatraPDF-prerel.exe": "h32", "SumatraPDF-prerel-arm64.exe": "h-arm64-old"}
+	d, err := json.Marshal(priorHashes)
+	must(err)
+	_, _ = mc.UploadData(path.Join(dirRemote, uploadHashesFileName), d, false)
+	hashes := map[string]string{"SumatraPDF-prerel-arm64.exe": "h-arm64-new"}
+	mergePriorUploadHashes(mc, dirRemote, hashes)
+	panicIf(hashes["SumatraPDF-prerel.exe"] != "h32" || hashes["SumatraPDF-prerel-arm64.exe"] != "h-arm64-new", "unexpected merged hashes: %v", hashes)
+}
+
+func selfTestSourceArchive(fb *FakeBackends) {
+	fb.Runner.Outputs["git rev-parse"] = gitSha1Cached + "\n"
+	fb.Runner.Outputs["git archive"] = "fake tar"
+	createSourceArchiveMust(buildTypePreRel, false)
+
+	name := getSourceArchiveName(buildTypePreRel)
+	archivePath := filepath.Join(getFinalDirForBuildType(buildTypePreRel), name)
+	d := readFileMust(archivePath)
+	sums := string(readFileMust(archivePath + ".sha256"))
+	panicIf(sums != sha256Hex(d)+"  "+name+"\n", "unexpected '%s.sha256': '%s'", archivePath, sums)
+	panicIf(fileExists(archivePath+".asc"), "unsigned archive has a signature")
+	// must be reproducible
+	createSourceArchiveMust(buildTypePreRel, false)
+	panicIf(sha256Hex(readFileMust(archivePath)) != sha256Hex(d), "source archive is not reproducible")
+
+	cmds := fb.Runner.CommandsOf("git")
+	args := strings.Join(cmds[len(cmds)-1], " ")
+	panicIf(!strings.Contains(args, "archive") || !strings.Contains(args, "*.pfx"), "unexpected git command: %s", args)
+}
+
+// runs test with fake backends, returns error if it failed
+func runSelfTest(st *SelfTest) (err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	withFakeBackends("16000", "3.5.2", st.Run)
+	return nil
+}
+
+// do test self
+func runSelfTestsMust() {
+	nFailed := 0
+	for _, st := range selfTests {
+		if err := runSelfTest(st); err != nil {
+			logf("FAIL %s: %s\n", st.Name, err)
+			nFailed++
+			continue
+		}
+		logf("ok   %s\n", st.Name)
+	}
+	failIf(errKindVerify, nFailed > 0, "%d of %d self-tests failed", nFailed, len(selfTests))
+	logf("all %d self-tests passed\n", len(selfTests))
+}
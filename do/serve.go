@@ -0,0 +1,257 @@
+package main
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// do serve runs a small HTTP server on the build machine that accepts build
+// requests, runs them one at a time and shows their status and logs.
+// Each build runs as a separate do process so that a panic in a build
+// doesn't kill the server.
+// All requests must have "Authorization: Bearer ${BUILD_SERVER_TOKEN}" header.
+//
+// POST /build?channel=pre-rel&platform=x64&upload=true : queue a build
+// GET  /status                                        : all builds
+// GET  /status?id=3                                   : a single build
+// GET  /log?id=3                                      : log of a build
+
+const (
+	buildChannelPreRel = "pre-rel"
+	buildChannelRel    = "rel"
+	buildChannelDaily  = "daily"
+
+	buildJobQueued    = "queued"
+	buildJobRunning   = "running"
+	buildJobSucceeded = "succeeded"
+	buildJobFailed    = "failed"
+
+	serveLogDir = "out/serve"
+	// how many queued builds we accept
+	serveMaxQueued = 16
+)
+
+// BuildJob is a build requested via do serve
+type BuildJob struct {
+	ID       int       `json:"id"`
+	Channel  string    `json:"channel"`
+	Platform string    `json:"platform,omitempty"`
+	Upload   bool      `json:"upload"`
+	Status   string    `json:"status"`
+	Error    string    `json:"error,omitempty"`
+	Queued   time.Time `json:"queued"`
+	Started  time.Time `json:"started"`
+	Finished time.Time `json:"finished"`
+}
+
+// BuildServer runs builds sequentially
+type BuildServer struct {
+	token string
+	// the do executable, re-launched for each build
+	exePath string
+
+	mu     sync.Mutex
+	jobs   []*BuildJob
+	nextID int
+	queue  chan *BuildJob
+}
+
+func newBuildServer(token string) *BuildServer {
+	exePath, err := os.Executable()
+	must(err)
+	return &BuildServer{
+		token:   token,
+		exePath: exePath,
+		nextID:  1,
+		queue:   make(chan *BuildJob, serveMaxQueued),
+	}
+}
+
+// cmd-line arguments for do that perform a given build
+func buildJobArgs(job *BuildJob) ([]string, error) {
+	var args []string
+	switch job.Channel {
+	case buildChannelPreRel:
+		args = append(args, "build", "prerel")
+		if job.Platform != "" {
+			args = append(args, "-platform", job.Platform)
+		}
+	case buildChannelRel:
+		args = append(args, "build", "release")
+	case buildChannelDaily:
+		args = append(args, "build", "daily")
+	default:
+		return nil, fmt.Errorf("invalid channel '%s', must be %s, %s or %s", job.Channel, buildChannelPreRel, buildChannelRel, buildChannelDaily)
+	}
+	if job.Platform != "" {
+		if job.Channel != buildChannelPreRel {
+			return nil, fmt.Errorf("platform can only be given for channel %s", buildChannelPreRel)
+		}
+		if !stringInSlice(fanoutPlatforms, job.Platform) {
+			return nil, fmt.Errorf("invalid platform '%s', must be one of: %s", job.Platform, strings.Join(fanoutPlatforms, ", "))
+		}
+	}
+	if job.Upload {
+		args = append(args, "-upload")
+	}
+	return args, nil
+}
+
+func buildJobLogPath(id int) string {
+	return filepath.Join(serveLogDir, fmt.Sprintf("build-%d.log", id))
+}
+
+func (s *BuildServer) runJob(job *BuildJob) {
+	args, _ := buildJobArgs(job)
+	s.mu.Lock()
+	job.Status = buildJobRunning
+	job.Started = time.Now()
+	s.mu.Unlock()
+
+	cmd := exec.Command(s.exePath, args...)
+	err := runCmdShowProgressAndLog(cmd, buildJobLogPath(job.ID))
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	job.Finished = time.Now()
+	job.Status = buildJobSucceeded
+	if err != nil {
+		job.Status = buildJobFailed
+		job.Error = err.Error()
+	}
+	logf("build %d %s in %s\n", job.ID, job.Status, formatDuration(job.Finished.Sub(job.Started)))
+}
+
+func (s *BuildServer) runJobs() {
+	for job := range s.queue {
+		s.runJob(job)
+	}
+}
+
+func (s *BuildServer) findJob(r *http.Request) *BuildJob {
+	id, _ := strconv.Atoi(r.FormValue("id"))
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for _, job := range s.jobs {
+		if job.ID == id {
+			return job
+		}
+	}
+	return nil
+}
+
+func (s *BuildServer) isAuthorized(r *http.Request) bool {
+	got := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+	return subtle.ConstantTimeCompare([]byte(got), []byte(s.token)) == 1
+}
+
+// v is guarded by s.mu
+func (s *BuildServer) serveJSON(w http.ResponseWriter, v interface{}) {
+	s.mu.Lock()
+	d, err := json.MarshalIndent(v, "", "  ")
+	s.mu.Unlock()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_, _ = w.Write(d)
+}
+
+func (s *BuildServer) handleBuild(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "must be POST", http.StatusMethodNotAllowed)
+		return
+	}
+	job := &BuildJob{
+		Channel:  r.FormValue("channel"),
+		Platform: r.FormValue("platform"),
+		Upload:   r.FormValue("upload") == "true",
+		Status:   buildJobQueued,
+		Queued:   time.Now(),
+	}
+	if _, err := buildJobArgs(job); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.mu.Lock()
+	job.ID = s.nextID
+	s.nextID++
+	s.mu.Unlock()
+	select {
+	case s.queue <- job:
+	default:
+		http.Error(w, "too many queued builds", http.StatusServiceUnavailable)
+		return
+	}
+	s.mu.Lock()
+	s.jobs = append(s.jobs, job)
+	s.mu.Unlock()
+	logf("queued build %d: %s %s upload: %v\n", job.ID, job.Channel, job.Platform, job.Upload)
+	s.serveJSON(w, job)
+}
+
+func (s *BuildServer) handleStatus(w http.ResponseWriter, r *http.Request) {
+	if r.FormValue("id") == "" {
+		// s.jobs is appended to by handleBuild
+		s.mu.Lock()
+		jobs := append([]*BuildJob{}, s.jobs...)
+		s.mu.Unlock()
+		s.serveJSON(w, jobs)
+		return
+	}
+	job := s.findJob(r)
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+	s.serveJSON(w, job)
+}
+
+func (s *BuildServer) handleLog(w http.ResponseWriter, r *http.Request) {
+	job := s.findJob(r)
+	if job == nil {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	// log doesn't exist until the build starts
+	d, _ := os.ReadFile(buildJobLogPath(job.ID))
+	_, _ = w.Write(d)
+}
+
+func (s *BuildServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if !s.isAuthorized(r) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+		return
+	}
+	switch r.URL.Path {
+	case "/build":
+		s.handleBuild(w, r)
+	case "/status":
+		s.handleStatus(w, r)
+	case "/log":
+		s.handleLog(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+func runBuildServer(addr string) {
+	panicIf(buildServerToken == "", "need BUILD_SERVER_TOKEN env variable")
+	must(os.RemoveAll(serveLogDir))
+	createDirMust(serveLogDir)
+	s := newBuildServer(buildServerToken)
+	go s.runJobs()
+	logf("build server listening on %s\n", addr)
+	must(http.ListenAndServe(addr, s))
+}
@@ -0,0 +1,194 @@
+package main
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"math/big"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// do artifacts serve serves builds in out/final-prerel and out/final-rel and
+// update info generated for them over local http, with the same url layout
+// as production, so that downloading and auto-update can be tested with a
+// freshly built installer before anything is uploaded:
+// /software/sumatrapdf/${buildType}/${ver}/${file} : like storage
+// /dl/${buildType}/${ver}/${file} : like website redirects
+// /updatecheck-pre-release.txt, /update-check-rel.txt : what the app checks
+// /software/sumatrapdf/*-latest.txt and *-update.txt : like storage
+// Download urls in update info point to this server.
+// The app only accepts update info from www.sumatrapdfreader.org over https
+// so to test the app's update check, map www.sumatrapdfreader.org to
+// 127.0.0.1 in hosts file and run with -serve-tls (listens on :443 with a
+// self-signed certificate for our hosts that must be trusted)
+
+var (
+	flgServeAddr string
+	flgServeTLS  bool
+)
+
+const (
+	serveDefaultAddr = "localhost:8089"
+	serveCertPath    = "out/serve-artifacts-cert.pem"
+	serveKeyPath     = "out/serve-artifacts-key.pem"
+)
+
+var serveTLSHosts = []string{"www.sumatrapdfreader.org", "sumatra-website.onrender.com", "localhost"}
+
+// ArtifactServer maps url paths to files in out/ or generated content
+type ArtifactServer struct {
+	files   map[string]string
+	content map[string]string
+}
+
+func (s *ArtifactServer) addFile(uri string, path string) {
+	s.files[uri] = path
+}
+
+func (s *ArtifactServer) addContent(uri string, content string) {
+	s.content[uri] = content
+}
+
+// urls the app uses for update check, see src/UpdateCheck.cpp
+func getUpdateCheckURLPath(buildType BuildType) string {
+	if buildType == buildTypeRel {
+		return "/update-check-rel.txt"
+	}
+	return "/updatecheck-pre-release.txt"
+}
+
+// baseURL is the url of the server, ends with /
+func newArtifactServerMust(baseURL string) *ArtifactServer {
+	s := &ArtifactServer{
+		files:   map[string]string{},
+		content: map[string]string{},
+	}
+	for _, buildType := range []BuildType{buildTypePreRel, buildTypeRel} {
+		dir := getFinalDirForBuildType(buildType)
+		if !dirExists(dir) {
+			continue
+		}
+		ver := getVerForBuildType(buildType)
+		entries, err := os.ReadDir(dir)
+		must(err)
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			path := filepath.Join(dir, e.Name())
+			s.addFile("/"+getRemoteDir(buildType)+e.Name(), path)
+			s.addFile("/dl/"+string(buildType)+"/"+ver+"/"+e.Name(), path)
+		}
+
+		urls := getDownloadUrlsForPrefix(baseURL+"dl/"+string(buildType)+"/"+ver+"/", buildType, ver)
+		updateTxt := genUpdateTxt(urls, ver)
+		remotePaths := getRemotePaths(buildType)
+		s.addContent("/"+remotePaths[1], ver)
+		s.addContent("/"+remotePaths[2], updateTxt)
+		s.addContent(getUpdateCheckURLPath(buildType), updateTxt)
+	}
+	panicIf(len(s.files) == 0, "no builds in '%s' or '%s', build with: do build prerel or do build release first", getFinalDirForBuildType(buildTypePreRel), getFinalDirForBuildType(buildTypeRel))
+	return s
+}
+
+func (s *ArtifactServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	uri := r.URL.Path
+	logf("%s %s\n", r.Method, r.URL)
+	if path, ok := s.files[uri]; ok {
+		http.ServeFile(w, r, path)
+		return
+	}
+	if content, ok := s.content[uri]; ok {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.Write([]byte(content))
+		return
+	}
+	if uri == "/" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		for _, p := range s.urlPaths() {
+			fmt.Fprintf(w, "%s\n", p)
+		}
+		return
+	}
+	http.NotFound(w, r)
+}
+
+func (s *ArtifactServer) urlPaths() []string {
+	var res []string
+	for p := range s.files {
+		res = append(res, p)
+	}
+	for p := range s.content {
+		res = append(res, p)
+	}
+	sort.Strings(res)
+	return res
+}
+
+// creates self-signed certificate for serveTLSHosts if it doesn't exist
+func ensureServeCertMust() {
+	if fileExists(serveCertPath) && fileExists(serveKeyPath) {
+		return
+	}
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	must(err)
+	tmpl := &x509.Certificate{
+		SerialNumber:          big.NewInt(time.Now().UnixNano()),
+		Subject:               pkix.Name{CommonName: "do artifacts serve"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().AddDate(1, 0, 0),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		DNSNames:              serveTLSHosts,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	must(err)
+	keyDer, err := x509.MarshalECPrivateKey(key)
+	must(err)
+	createDirMust(filepath.Dir(serveCertPath))
+	writeFileMust(serveCertPath, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}))
+	writeFileMust(serveKeyPath, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDer}))
+	logf("created self-signed certificate '%s', trust it with:\ncertutil -addstore Root %s\n", serveCertPath, filepath.FromSlash(serveCertPath))
+}
+
+func serveArtifactsMust() {
+	addr := flgServeAddr
+	baseURL := "http://" + addr + "/"
+	if flgServeTLS {
+		if addr == serveDefaultAddr {
+			addr = "127.0.0.1:443"
+		}
+		// download urls must pass the app's https checks so they go through hosts override
+		baseURL = "https://" + serveTLSHosts[0] + "/"
+		ensureServeCertMust()
+	}
+	s := newArtifactServerMust(baseURL)
+	for _, p := range s.urlPaths() {
+		logf("  %s\n", strings.TrimSuffix(baseURL, "/")+p)
+	}
+	srv := &http.Server{
+		Addr:              addr,
+		Handler:           s,
+		ReadHeaderTimeout: 30 * time.Second,
+	}
+	var err error
+	if flgServeTLS {
+		logf("serving artifacts on https://%s, ctrl-c to stop\n", addr)
+		err = srv.ListenAndServeTLS(serveCertPath, serveKeyPath)
+	} else {
+		logf("serving artifacts on %s, ctrl-c to stop\n", baseURL)
+		err = srv.ListenAndServe()
+	}
+	must(err)
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// generates docs/Keyboard-shortcuts.md and docs/commands.json from
+// COMMANDS() in src/Commands.h and gBuiltInAccelerators in src/Accelerators.cpp
+
+var (
+	keyboardShortcutsDocPath = filepath.Join("docs", "Keyboard-shortcuts.md")
+	commandsJSONPath         = filepath.Join("docs", "commands.json")
+)
+
+// V(CmdOpenFile, "Open File...")
+var rxCommandDef = regexp.MustCompile(`^\s*V\((Cmd\w+),\s*"([^"]*)"\)`)
+
+// {FCONTROL | FVIRTKEY, 'O', CmdOpenFile},
+var rxAccelDef = regexp.MustCompile(`^\s*\{([\w| ]+),\s*('.'|'\\''|\w+),\s*(Cmd\w+)\},`)
+
+// V(VK_NUMPAD0, "numpad0")
+var rxVirtKeyDef = regexp.MustCompile(`^\s*V\((VK_\w+),\s*"([^"]*)"\)`)
+
+// keys used in gBuiltInAccelerators but not in VIRT_KEYS
+var extraVirtKeyNames = map[string]string{
+	"VK_OEM_MINUS": "-",
+	"VK_OEM_PLUS":  "=",
+}
+
+// Command is a command and its default keyboard shortcuts
+type Command struct {
+	ID        string   `json:"id"`
+	Name      string   `json:"name"`
+	Shortcuts []string `json:"shortcuts,omitempty"`
+}
+
+func parseCommandsH(s string) []*Command {
+	var res []*Command
+	for _, line := range strings.Split(s, "\n") {
+		if m := rxCommandDef.FindStringSubmatch(line); m != nil {
+			res = append(res, &Command{ID: m[1], Name: m[2]})
+		}
+	}
+	return res
+}
+
+// returns VK_* => name, first name wins
+func parseVirtKeyNames(s string) map[string]string {
+	res := map[string]string{}
+	for k, v := range extraVirtKeyNames {
+		res[k] = v
+	}
+	for _, line := range strings.Split(s, "\n") {
+		m := rxVirtKeyDef.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		if _, ok := res[m[1]]; !ok {
+			res[m[1]] = m[2]
+		}
+	}
+	return res
+}
+
+func fmtAccel(flags string, key string, virtKeys map[string]string) string {
+	var parts []string
+	if strings.Contains(flags, "FCONTROL") {
+		parts = append(parts, "Ctrl")
+	}
+	if strings.Contains(flags, "FSHIFT") {
+		parts = append(parts, "Shift")
+	}
+	if strings.Contains(flags, "FALT") {
+		parts = append(parts, "Alt")
+	}
+	if strings.HasPrefix(key, "'") {
+		key = strings.Trim(key, "'")
+		key = strings.ReplaceAll(key, `\'`, "'")
+	} else if name, ok := virtKeys[key]; ok {
+		key = name
+	} else {
+		key = strings.TrimPrefix(key, "VK_")
+	}
+	parts = append(parts, key)
+	return strings.Join(parts, " + ")
+}
+
+// returns command id => shortcuts, in order of definition
+func parseAccelerators(s string) map[string][]string {
+	virtKeys := parseVirtKeyNames(s)
+	res := map[string][]string{}
+	start := strings.Index(s, "gBuiltInAccelerators[]")
+	if start < 0 {
+		return res
+	}
+	for _, line := range strings.Split(s[start:], "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "};") {
+			break
+		}
+		m := rxAccelDef.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		accel := fmtAccel(m[1], m[2], virtKeys)
+		if !stringInSlice(res[m[3]], accel) {
+			res[m[3]] = append(res[m[3]], accel)
+		}
+	}
+	return res
+}
+
+func getCommandsWithShortcutsMust() []*Command {
+	cmds := parseCommandsH(string(readFileMust(filepath.Join("src", "Commands.h"))))
+	panicIf(len(cmds) == 0, "didn't find commands in src/Commands.h")
+	accels := parseAccelerators(string(readFileMust(filepath.Join("src", "Accelerators.cpp"))))
+	panicIf(len(accels) == 0, "didn't find gBuiltInAccelerators in src/Accelerators.cpp")
+	for _, cmd := range cmds {
+		cmd.Shortcuts = accels[cmd.ID]
+	}
+	return cmds
+}
+
+func genCommandsJSONMust() []byte {
+	d, err := json.MarshalIndent(getCommandsWithShortcutsMust(), "", "  ")
+	must(err)
+	return append(d, '\n')
+}
+
+func mdEscape(s string) string {
+	r := strings.NewReplacer("|", `\|`, "[", `\[`, "]", `\]`, "*", `\*`, "_", `\_`)
+	return r.Replace(s)
+}
+
+func genKeyboardShortcutsDocMust() []byte {
+	lines := []string{
+		"<!-- DO NOT EDIT MANUALLY !!! Generated with .\\doit.bat docs gen from src/Commands.h and src/Accelerators.cpp -->",
+		"",
+		"# Keyboard shortcuts",
+		"",
+		"Default keyboard shortcuts. They can be changed with `Shortcuts` in advanced settings, using command ids below.",
+		"",
+		"| Command | Keyboard shortcuts | Command id |",
+		"| --- | --- | --- |",
+	}
+	for _, cmd := range getCommandsWithShortcutsMust() {
+		if len(cmd.Shortcuts) == 0 {
+			continue
+		}
+		var keys []string
+		for _, s := range cmd.Shortcuts {
+			keys = append(keys, "`"+s+"`")
+		}
+		lines = append(lines, fmt.Sprintf("| %s | %s | `%s` |", mdEscape(cmd.Name), strings.Join(keys, ", "), cmd.ID))
+	}
+	lines = append(lines, "", "All commands, including those without default shortcut, are listed in [commands.json](commands.json).", "")
+	return []byte(strings.Join(lines, "\n"))
+}
@@ -2,24 +2,27 @@ package main
 
 import (
 	"fmt"
-	"os"
 	"os/exec"
 	"path/filepath"
 	"strings"
+	"sync"
 	"time"
 )
 
-func runCmdLoggedRedacted(cmd *exec.Cmd, redact string) error {
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+func runCmdLoggedRedacted(cmd *exec.Cmd, redact string, log *TaskLogger) error {
+	cmd.Stdout = log
+	cmd.Stderr = log
 	s := cmd.String()
-	s = strings.ReplaceAll(s, redact, "***")
-	fmt.Printf("> %s\n", s)
-	return cmd.Run()
+	if redact != "" {
+		s = strings.ReplaceAll(s, redact, "***")
+	}
+	log.Logf("> %s\n", s)
+	return runCmdWithTimeout(cmd)
 }
 
+// also true if we sign with hardware token
 func hasCertPwd() bool {
-	return strings.TrimSpace(certPwd) != ""
+	return strings.TrimSpace(certPwd) != "" || certSha1 != ""
 }
 
 // https://zabkat.com/blog/code-signing-sha1-armageddon.htm
@@ -35,46 +38,73 @@ func hasCertPwd() bool {
 //	/du ${url}   : URL for expanded description of the signed content.
 //	/debug       : show debugging info
 func signMust(path string) {
-	// the sign tool is finicky, so copy the cert to the same dir as
-	// the exe we're signing
+	signFilesBatchMust([]string{path})
+}
 
-	if certPwd == "" {
-		if flgSkipSign {
-			return
+// files that, in addition to primary sha256 signature, get a secondary
+// sha1 signature so that they validate on Windows 7 SP0 (no SHA-2 support)
+// We only do it for the installer to not increase the size of other files
+var dualSignFileNames = []string{
+	"SumatraPDF-dll.exe",
+}
+
+func getDualSignNames(names []string) []string {
+	var res []string
+	for _, name := range names {
+		if stringInSlice(dualSignFileNames, name) {
+			res = append(res, name)
 		}
 	}
-	panicIf(certPwd == "", "CERT_PWD env variable not set")
+	return res
+}
 
+// when signing with hardware token (certSha1 is set) the cert is selected
+// from the cert store by thumbprint. Otherwise we use cert.pfx + CERT_PWD
+func signtoolCertArgs() []string {
+	if certSha1 != "" {
+		return []string{"/sha1", certSha1}
+	}
+	return []string{"/f", "cert.pfx", "/p", certPwd}
+}
+
+// signs multiple files from the same directory with a single signtool
+// invocation (per signature type)
+func signBatchInDirMust(dir string, names []string, log *TaskLogger) {
 	// retry 3 times because signing might fail due to temorary error
 	// ("The specified timestamp server either could not be reached or")
 	var err error
 	for i := 0; i < 3; i++ {
 		signtoolPath := detectSigntoolPath()
-		fileDir := filepath.Dir(path)
-		fileName := filepath.Base(path)
-		certSrc := filepath.Join("do", "scripts", "cert.pfx")
-		certDest := filepath.Join(fileDir, "cert.pfx")
-		must(copyFile(certDest, certSrc))
+		if certSha1 == "" {
+			// the sign tool is finicky, so copy the cert to the same dir as
+			// the exe we're signing
+			certSrc := filepath.Join("do", "scripts", "cert.pfx")
+			certDest := filepath.Join(dir, "cert.pfx")
+			must(copyFile(certDest, certSrc))
+		}
 		//signServer := "http://timestamp.verisign.com/scripts/timstamp.dll"
 		signServer := "http://timestamp.sectigo.com"
 		desc := "https://www.sumatrapdfreader.org"
 		{
-			// sign with sha1 for pre-win-7
-			// TODO: remove it? We no longer support pre-win7
-			cmd := exec.Command(signtoolPath, "sign", "/t", signServer,
-				"/du", desc, "/f", "cert.pfx", "/fd", "sha1",
-				"/p", certPwd, fileName)
-			cmd.Dir = fileDir
-			err = runCmdLoggedRedacted(cmd, certPwd)
+			// primary signature is sha256
+			args := []string{"sign", "/fd", "sha256", "/tr", signServer, "/td", "sha256", "/du", desc}
+			args = append(args, signtoolCertArgs()...)
+			args = append(args, names...)
+			cmd := exec.Command(signtoolPath, args...)
+			cmd.Dir = dir
+			err = runCmdLoggedRedacted(cmd, certPwd, log)
 		}
 
-		if err == nil {
-			// double-sign with sha2 for win7+ ater Jan 2016
-			cmd := exec.Command(signtoolPath, "sign", "/fd", "sha256", "/tr", signServer,
-				"/td", "sha256", "/du", desc, "/f", "cert.pfx",
-				"/p", certPwd, "/as", fileName)
-			cmd.Dir = fileDir
-			err = runCmdLoggedRedacted(cmd, certPwd)
+		dualSignNames := getDualSignNames(names)
+		if err == nil && len(dualSignNames) > 0 {
+			// append sha1 signature for Windows 7 without SHA-2 support
+			args := []string{"sign", "/t", signServer, "/du", desc}
+			args = append(args, signtoolCertArgs()...)
+			args = append(args, "/fd", "sha1", "/as")
+			args = append(args, dualSignNames...)
+			cmd := exec.Command(signtoolPath, args...)
+			cmd.Dir = dir
+			err = runCmdLoggedRedacted(cmd, certPwd, log)
 		}
 		if err == nil {
 			return
@@ -83,3 +113,54 @@ func signMust(path string) {
 	}
 	must(err)
 }
+
+// signs files in batches, one batch per directory. Batches are signed in
+// parallel unless we're using a hardware token, which can only do one
+// signing operation at a time
+func signFilesBatchMust(paths []string) {
+	defer failOnPanic(errKindSign)
+	if !hasCertPwd() {
+		if flgSkipSign {
+			return
+		}
+	}
+	panicIf(!hasCertPwd(), "CERT_PWD env variable not set")
+
+	var dirs []string
+	byDir := map[string][]string{}
+	for _, path := range paths {
+		dir := filepath.Dir(path)
+		if byDir[dir] == nil {
+			dirs = append(dirs, dir)
+		}
+		byDir[dir] = append(byDir[dir], filepath.Base(path))
+	}
+	if certSha1 != "" || len(dirs) == 1 {
+		for _, dir := range dirs {
+			signBatchInDirMust(dir, byDir[dir], nil)
+		}
+		return
+	}
+
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	var panicked []string
+	for _, dir := range dirs {
+		wg.Add(1)
+		go func(dir string) {
+			defer wg.Done()
+			defer func() {
+				if r := recover(); r != nil {
+					mu.Lock()
+					panicked = append(panicked, fmt.Sprintf("%s: %v", dir, r))
+					mu.Unlock()
+				}
+			}()
+			log := newTaskLogger("sign-" + filepath.Base(dir))
+			defer log.Close()
+			signBatchInDirMust(dir, byDir[dir], log)
+		}(dir)
+	}
+	wg.Wait()
+	panicIf(len(panicked) > 0, "signing failed:\n%s", strings.Join(panicked, "\n"))
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// signtool can sign a file but fail to timestamp it e.g. when the timestamp
+// server times out. Such files stop validating when the certificate expires
+// so we verify all signatures are timestamped before uploading
+
+func verifySignatureTimestamps(path string) []string {
+	var errs []string
+	sigs := peAuthenticodeSigsMust(path)
+	if len(sigs) == 0 {
+		return []string{fmt.Sprintf("%s: not signed", path)}
+	}
+	for _, sig := range sigs {
+		if sig.TimestampKind == "" {
+			errs = append(errs, fmt.Sprintf("%s: %s signature is not timestamped", path, sig.DigestAlg))
+			continue
+		}
+		// sha256 signatures must use RFC 3161 timestamps
+		if sig.DigestAlg != "sha1" && sig.TimestampKind != "rfc3161" {
+			errs = append(errs, fmt.Sprintf("%s: %s signature has %s timestamp, expected rfc3161", path, sig.DigestAlg, sig.TimestampKind))
+		}
+		if sig.Signer == nil {
+			errs = append(errs, fmt.Sprintf("%s: %s signature has no signer certificate", path, sig.DigestAlg))
+			continue
+		}
+		t := sig.TimestampTime
+		cert := sig.Signer
+		if t.Before(cert.NotBefore) || t.After(cert.NotAfter) {
+			errs = append(errs, fmt.Sprintf("%s: timestamp %s outside of cert validity %s - %s", path, t.Format(time.RFC3339), cert.NotBefore.Format(time.RFC3339), cert.NotAfter.Format(time.RFC3339)))
+		}
+	}
+	return errs
+}
+
+func getSignedFilesInDir(dir string) []string {
+	var res []string
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	for _, e := range entries {
+		name := strings.ToLower(e.Name())
+		if strings.HasSuffix(name, ".exe") || strings.HasSuffix(name, ".dll") {
+			res = append(res, filepath.Join(dir, e.Name()))
+		}
+	}
+	return res
+}
+
+// uploads can happen in a different run than signing (do build ci-upload)
+// so we look at the files, not at whether this run signs: unsigned builds
+// (forks, pull requests) are skipped but if any file is signed, all must be
+func verifySignatureTimestampsMust(buildType BuildType) {
+	defer failOnPanic(errKindVerify)
+	dir := getFinalDirForBuildType(buildType)
+	paths := getSignedFilesInDir(dir)
+	panicIf(len(paths) == 0, "no .exe or .dll files in '%s'", dir)
+	nSigned := 0
+	for _, path := range paths {
+		if len(peAuthenticodeSigsMust(path)) > 0 {
+			nSigned++
+		}
+	}
+	if nSigned == 0 {
+		logf("verifySignatureTimestampsMust: skipping because files in '%s' are not signed\n", dir)
+		return
+	}
+	var errs []string
+	for _, path := range paths {
+		errs = append(errs, verifySignatureTimestamps(path)...)
+	}
+	panicIf(len(errs) > 0, "signature timestamp verification failed:\n%s\n", strings.Join(errs, "\n"))
+	logf("verified timestamps of %d files in '%s'\n", len(paths), dir)
+}
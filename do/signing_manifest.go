@@ -0,0 +1,87 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// files we sign in each of out/rel32, out/rel64, out/arm64
+var signedFileNames = []string{
+	"SumatraPDF.exe",
+	"libmupdf.dll",
+	"PdfFilter.dll",
+	"PdfPreview.dll",
+	"SumatraPDF-dll.exe",
+}
+
+func fmtAuthenticodeSig(sig *AuthenticodeSig) string {
+	s := fmt.Sprintf("digest=%s thumbprint=%s", sig.DigestAlg, sig.Thumbprint)
+	if sig.Signer != nil {
+		s += fmt.Sprintf(" subject=%q", sig.Signer.Subject.CommonName)
+	}
+	if sig.TimestampKind == "" {
+		return s + " timestamp=none"
+	}
+	s += fmt.Sprintf(" timestamp=%s time=%s", sig.TimestampKind, sig.TimestampTime.UTC().Format(time.RFC3339))
+	if sig.TSA != nil {
+		s += fmt.Sprintf(" tsa=%q", sig.TSA.Subject.CommonName)
+	}
+	return s
+}
+
+// name under which a signed file from out dir is shipped e.g.
+// "SumatraPDF-dll.exe" => "SumatraPDF-3.5-64-install.exe". dlls are only
+// shipped inside the installer
+func getSignedFileFinalName(name string, platformPrefix string) string {
+	files := getFileNamesWithPrefix(platformPrefix)
+	for _, f := range files {
+		if f[0] == name {
+			return f[1]
+		}
+	}
+	for _, f := range files {
+		if f[0] == "SumatraPDF-dll.exe" {
+			return fmt.Sprintf("%s (in %s)", name, f[1])
+		}
+	}
+	panicIf(true, "no installer in getFileNamesWithPrefix()")
+	return ""
+}
+
+// signing manifest lists every shipped binary with its hash and details
+// of its Authenticode signatures, so that people can verify the binaries
+// they have are the ones we've built
+func createSigningManifestMust(dstDir string, prefix string, buildType BuildType) {
+	var lines []string
+	push(&lines, "# sha256 of the file, followed by one line per Authenticode signature")
+	for _, platform := range filterSelectedPlatforms([]string{kPlatformIntel32, kPlatformIntel64, kPlatformArm64}) {
+		dir := getOutDirForPlatform(platform)
+		if !pathExists(dir) {
+			continue
+		}
+		platformPrefix := getPrefixForPlatform(prefix, buildType, platform)
+		for _, name := range signedFileNames {
+			path := filepath.Join(dir, name)
+			if !fileExists(path) {
+				continue
+			}
+			d := readFileMust(path)
+			sigs, err := peAuthenticodeSigs(d)
+			panicIf(err != nil, "failed to parse signatures of '%s': %s", path, err)
+			finalName := getSignedFileFinalName(name, platformPrefix)
+			push(&lines, fmt.Sprintf("%s: sha256=%s", finalName, sha256Hex(d)))
+			if len(sigs) == 0 {
+				push(&lines, "  not signed")
+			}
+			for _, sig := range sigs {
+				push(&lines, "  "+fmtAuthenticodeSig(sig))
+			}
+		}
+	}
+	s := strings.Join(lines, "\n") + "\n"
+	path := filepath.Join(dstDir, prefix+"-signatures.txt")
+	writeFileMust(path, []byte(s))
+	logf("Wrote signing manifest '%s'\n", path)
+}
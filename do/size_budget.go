@@ -0,0 +1,151 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// size budgets from do/size_budgets.txt are checked when creating the manifest.
+// Build fails if a file is bigger than its budget or grew more than
+// max-growth since the last release.
+// To allow it, use -allow-size-increase "reason". The reason is recorded
+// in the manifest
+
+var flgAllowSizeIncrease string
+
+const sizeBudgetsPath = "do/size_budgets.txt"
+
+// SizeBudgets is parsed size_budgets.txt
+type SizeBudgets struct {
+	// file name => max size in bytes
+	Max map[string]int64
+	// max % growth vs. last release, 0 means not checked
+	MaxGrowthPercent float64
+}
+
+// "12 MB" => 12*1024*1024
+func parseSizeMust(s string) int64 {
+	s = strings.TrimSpace(s)
+	mult := int64(1)
+	for _, unit := range []struct {
+		suffix string
+		mult   int64
+	}{{"KB", 1024}, {"MB", 1024 * 1024}, {"GB", 1024 * 1024 * 1024}} {
+		if strings.HasSuffix(s, unit.suffix) {
+			mult = unit.mult
+			s = strings.TrimSpace(strings.TrimSuffix(s, unit.suffix))
+			break
+		}
+	}
+	n, err := strconv.ParseFloat(s, 64)
+	panicIf(err != nil, "invalid size '%s'", s)
+	return int64(n * float64(mult))
+}
+
+func parseSizeBudgetsMust(s string) *SizeBudgets {
+	res := &SizeBudgets{Max: map[string]int64{}}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, val, ok := strings.Cut(line, ":")
+		panicIf(!ok, "invalid line '%s' in '%s'", line, sizeBudgetsPath)
+		name = strings.TrimSpace(name)
+		if name == "max-growth" {
+			n, err := strconv.ParseFloat(strings.TrimSuffix(strings.TrimSpace(val), "%"), 64)
+			panicIf(err != nil, "invalid max-growth '%s' in '%s'", val, sizeBudgetsPath)
+			res.MaxGrowthPercent = n
+			continue
+		}
+		res.Max[name] = parseSizeMust(val)
+	}
+	return res
+}
+
+// parses manifest created by createManifestMust
+// "out\rel64\SumatraPDF.exe: 123 sha256:ab12..." => "rel64/SumatraPDF.exe" : 123
+// older manifests don't have hashes
+func parseManifestSizes(s string) map[string]int64 {
+	res := map[string]int64{}
+	for _, line := range strings.Split(s, "\n") {
+		path, val, ok := strings.Cut(strings.TrimSpace(line), ": ")
+		if !ok {
+			continue
+		}
+		val, _, _ = strings.Cut(val, " ")
+		n, err := strconv.ParseInt(val, 10, 64)
+		if err != nil {
+			continue
+		}
+		path = strings.TrimPrefix(filepath.ToSlash(strings.ReplaceAll(path, `\`, "/")), "out/")
+		res[path] = n
+	}
+	return res
+}
+
+// returns nil if not available, e.g. no credentials for storage
+func getLastReleaseSizes() map[string]int64 {
+	if r2Access == "" {
+		logf("not comparing sizes with last release because no R2_ACCESS\n")
+		return nil
+	}
+	mc := newMinioR2Client()
+	ver := parseLatestVer(string(minioDownloadDataMust(mc, "sumatrapdf/sumpdf-latest.txt")))
+	remotePath := fmt.Sprintf("software/sumatrapdf/rel/%s/SumatraPDF-%s-manifest.txt", ver, ver)
+	if !mc.Exists(remotePath) {
+		logf("not comparing sizes with last release because '%s' doesn't exist\n", remotePath)
+		return nil
+	}
+	logf("comparing sizes with release %s\n", ver)
+	return parseManifestSizes(string(minioDownloadDataMust(mc, remotePath)))
+}
+
+// returns problems
+func checkSizeBudgets(sizes map[string]int64, budgets *SizeBudgets, lastRelease map[string]int64) []string {
+	var res []string
+	for path, size := range sizes {
+		name := filepath.Base(path)
+		if max, ok := budgets.Max[name]; ok && size > max {
+			res = append(res, fmt.Sprintf("%s: %s is over budget of %s", path, formatSize(size), formatSize(max)))
+		}
+		prev := lastRelease[path]
+		if budgets.MaxGrowthPercent == 0 || prev == 0 {
+			continue
+		}
+		growth := float64(size-prev) * 100 / float64(prev)
+		if growth > budgets.MaxGrowthPercent {
+			res = append(res, fmt.Sprintf("%s: grew by %.1f%% (%s => %s) since last release, max is %.1f%%", path, growth, formatSize(prev), formatSize(size), budgets.MaxGrowthPercent))
+		}
+	}
+	sort.Strings(res)
+	return res
+}
+
+// returns lines to add to the manifest
+func checkSizeBudgetsMust(sizes map[string]int64) []string {
+	budgets := parseSizeBudgetsMust(string(readFileMust(sizeBudgetsPath)))
+	problems := checkSizeBudgets(sizes, budgets, getLastReleaseSizes())
+	if len(problems) == 0 {
+		logf("all files within size budgets\n")
+		return nil
+	}
+	msg := strings.Join(problems, "\n")
+	level := "error"
+	if flgAllowSizeIncrease != "" {
+		level = "warning"
+	}
+	for _, p := range problems {
+		emitGitHubAnnotation(level, "", 0, 0, "size budget: "+p)
+	}
+	panicIf(flgAllowSizeIncrease == "", "size budgets exceeded:\n%s\nuse -allow-size-increase \"reason\" to allow", msg)
+	logf("size budgets exceeded but allowed because '%s':\n%s\n", flgAllowSizeIncrease, msg)
+	lines := []string{"size budget override: " + flgAllowSizeIncrease}
+	for _, p := range problems {
+		lines = append(lines, "  "+p)
+	}
+	return lines
+}
@@ -0,0 +1,272 @@
+package main
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// do report size breaks down out/rel64/SumatraPDF.exe by .lib / .obj that
+// contributed to it and by largest functions, using section contributions
+// and symbols from the .pdb (via llvm-pdbutil from LLVM).
+// Writes out/size-report/objects.csv, functions.csv and treemap.html
+
+const sizeReportTopFunctions = 2000
+
+var (
+	//   Mod 0003 | `D:\sumatrapdf\out\rel64\obj\SumatraPDF\Foo.obj`:
+	rxPdbModule = regexp.MustCompile("^\\s*Mod (\\d+) \\| `(.*)`:")
+	//              Obj: `D:\sumatrapdf\out\rel64\utils.lib`:
+	rxPdbModuleObj = regexp.MustCompile("^\\s*Obj: `(.*)`:")
+	//   SC[.text]   | mod = 5, 0001:00001000, size = 12, data crc = 0, reloc crc = 0
+	rxPdbSectionContrib = regexp.MustCompile(`^\s*SC\[(.*?)\]\s*\| mod = (\d+), [0-9A-Fa-f]{4}:[0-9A-Fa-f]+, size = (\d+)`)
+	//        4 | S_GPROC32 [size = 56] `Foo::bar`
+	rxPdbProc = regexp.MustCompile("S_[GL]PROC32(?:_ID)? \\[size = \\d+\\] `(.*)`")
+	//            parent = 0, end = 196, addr = 0001:1234, code size = 123
+	rxPdbCodeSize = regexp.MustCompile(`code size = (\d+)`)
+)
+
+// PdbModule is an .obj file linked into the executable
+type PdbModule struct {
+	Index int
+	Obj   string
+	// .lib the .obj is from, same as Obj if not from a .lib
+	Lib  string
+	Size int64
+	// section name => size
+	Sections map[string]int64
+}
+
+// PdbFunction is a function with its code size
+type PdbFunction struct {
+	Name   string
+	Module *PdbModule
+	Size   int64
+}
+
+func detectLlvmToolMust(name string) string {
+	if path, err := exec.LookPath(name); err == nil {
+		return path
+	}
+	path := filepath.Join(os.Getenv("ProgramFiles"), "LLVM", "bin", name+".exe")
+	failIf(errKindToolchain, !fileExists(path), "%s.exe not found in PATH or '%s'. Install LLVM or run: do doctor", name, path)
+	return path
+}
+
+func detectLlvmPdbutilMust() string {
+	return detectLlvmToolMust("llvm-pdbutil")
+}
+
+// parses output of llvm-pdbutil dump --modules
+func parsePdbModules(s string) map[int]*PdbModule {
+	res := map[int]*PdbModule{}
+	var curr *PdbModule
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := rxPdbModule.FindStringSubmatch(line); m != nil {
+			idx, _ := strconv.Atoi(m[1])
+			curr = &PdbModule{Index: idx, Obj: m[2], Lib: m[2], Sections: map[string]int64{}}
+			res[idx] = curr
+			continue
+		}
+		if m := rxPdbModuleObj.FindStringSubmatch(line); m != nil && curr != nil {
+			curr.Lib = m[1]
+		}
+	}
+	return res
+}
+
+// parses output of llvm-pdbutil dump --section-contribs
+func parsePdbSectionContribs(s string, modules map[int]*PdbModule) {
+	for _, line := range strings.Split(s, "\n") {
+		m := rxPdbSectionContrib.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+		idx, _ := strconv.Atoi(m[2])
+		size, _ := strconv.ParseInt(m[3], 10, 64)
+		mod := modules[idx]
+		if mod == nil {
+			continue
+		}
+		mod.Size += size
+		mod.Sections[m[1]] += size
+	}
+}
+
+// parses output of llvm-pdbutil dump --symbols
+func parsePdbFunctions(s string, modules map[int]*PdbModule) []*PdbFunction {
+	var res []*PdbFunction
+	var currMod *PdbModule
+	var currFn *PdbFunction
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		if m := rxPdbModule.FindStringSubmatch(line); m != nil {
+			idx, _ := strconv.Atoi(m[1])
+			currMod = modules[idx]
+			currFn = nil
+			continue
+		}
+		if m := rxPdbProc.FindStringSubmatch(line); m != nil {
+			currFn = &PdbFunction{Name: m[1], Module: currMod}
+			continue
+		}
+		if currFn == nil {
+			continue
+		}
+		if m := rxPdbCodeSize.FindStringSubmatch(line); m != nil {
+			currFn.Size, _ = strconv.ParseInt(m[1], 10, 64)
+			res = append(res, currFn)
+			currFn = nil
+		}
+	}
+	sort.Slice(res, func(i, j int) bool { return res[i].Size > res[j].Size })
+	return res
+}
+
+func writeCsvMust(path string, records [][]string) {
+	f, err := os.Create(path)
+	must(err)
+	defer f.Close()
+	w := csv.NewWriter(f)
+	must(w.WriteAll(records))
+	logf("wrote '%s'\n", path)
+}
+
+// TreemapNode is a node in treemap.html
+type TreemapNode struct {
+	Name     string
+	Size     int64
+	Children []*TreemapNode
+}
+
+// slice-and-dice layout, alternating horizontal and vertical split with depth
+func layoutTreemap(n *TreemapNode, x, y, dx, dy float64, depth int, sb *strings.Builder) {
+	title := html.EscapeString(fmt.Sprintf("%s %s", n.Name, formatSize(n.Size)))
+	label := ""
+	if dx > 5 && dy > 2 {
+		label = html.EscapeString(filepath.Base(n.Name))
+	}
+	fmt.Fprintf(sb, `<div class="d%d" style="left:%.2f%%;top:%.2f%%;width:%.2f%%;height:%.2f%%" title="%s">%s</div>`+"\n", depth, x, y, dx, dy, title, label)
+	if n.Size == 0 {
+		return
+	}
+	off := 0.0
+	for _, c := range n.Children {
+		frac := float64(c.Size) / float64(n.Size)
+		if depth%2 == 0 {
+			layoutTreemap(c, x+off, y, dx*frac, dy, depth+1, sb)
+			off += dx * frac
+		} else {
+			layoutTreemap(c, x, y+off, dx, dy*frac, depth+1, sb)
+			off += dy * frac
+		}
+	}
+}
+
+const treemapHTMLHeader = `<!doctype html>
+<html><head><meta charset="utf-8"><title>%s</title>
+<style>
+body { margin: 0; font: 11px sans-serif; }
+#map { position: relative; width: 100vw; height: 100vh; }
+#map div { position: absolute; box-sizing: border-box; border: 1px solid #fff; overflow: hidden; white-space: nowrap; }
+.d0 { background: #eee; } .d1 { background: #9ecae1; } .d2 { background: #c6dbef; }
+</style></head><body><div id="map">
+`
+
+func genTreemapHTML(title string, root *TreemapNode) string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, treemapHTMLHeader, html.EscapeString(title))
+	layoutTreemap(root, 0, 0, 100, 100, 0, &sb)
+	sb.WriteString("</div></body></html>\n")
+	return sb.String()
+}
+
+func buildSizeTreemap(exeName string, modules map[int]*PdbModule) *TreemapNode {
+	root := &TreemapNode{Name: exeName}
+	byLib := map[string]*TreemapNode{}
+	for _, mod := range modules {
+		if mod.Size == 0 {
+			continue
+		}
+		lib := byLib[mod.Lib]
+		if lib == nil {
+			lib = &TreemapNode{Name: mod.Lib}
+			byLib[mod.Lib] = lib
+			root.Children = append(root.Children, lib)
+		}
+		lib.Children = append(lib.Children, &TreemapNode{Name: mod.Obj, Size: mod.Size})
+		lib.Size += mod.Size
+		root.Size += mod.Size
+	}
+	bySizeDesc := func(a []*TreemapNode) {
+		sort.Slice(a, func(i, j int) bool { return a[i].Size > a[j].Size })
+	}
+	bySizeDesc(root.Children)
+	for _, lib := range root.Children {
+		bySizeDesc(lib.Children)
+	}
+	return root
+}
+
+func sizeReport() {
+	exePath := filepath.Join("out", "rel64", "SumatraPDF.exe")
+	pdbPath := filepath.Join("out", "rel64", "SumatraPDF.pdb")
+	panicIf(!fileExists(pdbPath), "'%s' doesn't exist, build release 64-bit first", pdbPath)
+	outDir := filepath.Join("out", "size-report")
+	must(os.RemoveAll(outDir))
+	createDirMust(outDir)
+
+	pdbutil := detectLlvmPdbutilMust()
+	dump := func(arg string) string {
+		out, err := exec.Command(pdbutil, "dump", arg, pdbPath).Output()
+		panicIf(err != nil, "llvm-pdbutil dump %s failed with '%s'", arg, err)
+		return string(out)
+	}
+	modules := parsePdbModules(dump("--modules"))
+	parsePdbSectionContribs(dump("--section-contribs"), modules)
+	funcs := parsePdbFunctions(dump("--symbols"), modules)
+
+	var mods []*PdbModule
+	for _, mod := range modules {
+		mods = append(mods, mod)
+	}
+	sort.Slice(mods, func(i, j int) bool { return mods[i].Size > mods[j].Size })
+	nObjects := 0
+	records := [][]string{{"lib", "obj", "size", "text", "rdata", "data"}}
+	for _, mod := range mods {
+		if mod.Size == 0 {
+			continue
+		}
+		nObjects++
+		sec := func(name string) string { return strconv.FormatInt(mod.Sections[name], 10) }
+		records = append(records, []string{mod.Lib, mod.Obj, strconv.FormatInt(mod.Size, 10), sec(".text"), sec(".rdata"), sec(".data")})
+	}
+	writeCsvMust(filepath.Join(outDir, "objects.csv"), records)
+
+	records = [][]string{{"function", "obj", "size"}}
+	for i, fn := range funcs {
+		if i >= sizeReportTopFunctions {
+			break
+		}
+		obj := ""
+		if fn.Module != nil {
+			obj = fn.Module.Obj
+		}
+		records = append(records, []string{fn.Name, obj, strconv.FormatInt(fn.Size, 10)})
+	}
+	writeCsvMust(filepath.Join(outDir, "functions.csv"), records)
+
+	root := buildSizeTreemap(filepath.Base(exePath), modules)
+	path := filepath.Join(outDir, "treemap.html")
+	writeFileMust(path, []byte(genTreemapHTML(exePath+" "+formatSize(root.Size), root)))
+	logf("wrote '%s'\n", path)
+	logf("%s: %s in %d objects from %d libraries\n", exePath, formatSize(root.Size), nObjects, len(root.Children))
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// distro packagers need a source archive of each release. We create it with
+// git archive so it's reproducible: the same commit always gives the same
+// .tar.gz (file times are the commit time, gzip header has no time or name).
+// Dependencies are vendored in ext/ and mupdf/ (we don't use submodules) so
+// they're part of the archive. Files with secrets are excluded.
+// Next to the archive we write .sha256 and, if signing, .asc detached gpg
+// signature made with key SOURCE_GPG_KEY (key id or fingerprint) which must
+// be in gpg keyring of the build machine.
+// Archives are created in out/final-* and uploaded with the build by daily
+// and release profiles, or with: do run source-archive:rel
+
+var sourceGpgKey string
+
+// git pathspecs of files that must not be in the source archive
+var sourceArchiveExcludes = []string{
+	":(exclude,glob)**/*.pfx",
+	":(exclude,glob)**/*.pem",
+	":(exclude,glob)**/*.env",
+	":(exclude,glob)**/*.key",
+}
+
+// returns file name of the source archive in out/final-*
+func getSourceArchiveName(buildType BuildType) string {
+	if buildType == buildTypeRel {
+		return fmt.Sprintf("SumatraPDF-%s-src.tar.gz", sumatraVersion)
+	}
+	// for pre-release version is in the remote dir, not in file names
+	return "SumatraPDF-prerel-src.tar.gz"
+}
+
+// release is archived from its git tag, pre-release from HEAD
+func getSourceArchiveRevMust(buildType BuildType) string {
+	if buildType == buildTypeRel {
+		tag := sumatraVersion + "rel"
+		if err := exec.Command("git", "rev-parse", "--verify", "--quiet", tag).Run(); err == nil {
+			return tag
+		}
+		logf("no git tag %s, archiving HEAD\n", tag)
+	}
+	return getGitSha1Must()
+}
+
+// deterministic gzip: no file name or modification time in the header
+func gzipReproducibleMust(d []byte) []byte {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	must(err)
+	_, err = w.Write(d)
+	must(err)
+	must(w.Close())
+	return buf.Bytes()
+}
+
+func gpgDetachSignMust(path string) {
+	failIf(errKindSign, sourceGpgKey == "", "need SOURCE_GPG_KEY env variable or secret to sign '%s'", path)
+	gpg := lookPathMust("gpg")
+	cmd := exec.Command(gpg, "--batch", "--yes", "--local-user", sourceGpgKey, "--armor", "--detach-sign", "--output", path+".asc", path)
+	func() {
+		defer failOnPanic(errKindSign)
+		runCmdLoggedMust(cmd)
+	}()
+}
+
+// creates source archive, its sha256 and signature in out/final-*
+func createSourceArchiveMust(buildType BuildType, sign bool) {
+	rev := getSourceArchiveRevMust(buildType)
+	name := getSourceArchiveName(buildType)
+	prefix := strings.TrimSuffix(name, "-src.tar.gz") + "-src/"
+	args := []string{"archive", "--format=tar", "--prefix=" + prefix, rev, "--", "."}
+	args = append(args, sourceArchiveExcludes...)
+	cmd := exec.Command("git", args...)
+	logf("> %s\n", cmd)
+	// not combined output, git warnings on stderr would corrupt the archive
+	var tarData bytes.Buffer
+	cmd.Stdout = &tarData
+	cmd.Stderr = os.Stderr
+	must(runCmdWithTimeout(cmd))
+	d := gzipReproducibleMust(tarData.Bytes())
+
+	dir := createDirMust(getFinalDirForBuildType(buildType))
+	path := filepath.Join(dir, name)
+	writeFileMust(path, d)
+	sha := sha256Hex(d)
+	// format of sha256sum so that it can be checked with: sha256sum -c
+	writeFileMust(path+".sha256", []byte(fmt.Sprintf("%s  %s\n", sha, name)))
+	if sign {
+		gpgDetachSignMust(path)
+	} else {
+		os.Remove(path + ".asc")
+	}
+	logf("created source archive '%s' of %s, %s, sha256: %s\n", path, rev, formatSize(int64(len(d))), sha)
+}
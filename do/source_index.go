@@ -0,0 +1,108 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// before archiving .pdb files we add srcsrv stream to them which maps
+// source files to GitHub raw URLs for the sha of the build.
+// WinDbg and Visual Studio (with source server support enabled) then fetch
+// the exact sources when debugging crash dumps.
+// Uses srctool.exe and pdbstr.exe from Debugging Tools for Windows (part
+// of Windows SDK)
+
+const srcsrvRawURLBase = "https://raw.githubusercontent.com/sumatrapdfreader/sumatrapdf"
+
+func detectSrcsrvToolMust(name string) string {
+	for _, dir := range []string{os.Getenv("ProgramFiles(x86)"), os.Getenv("ProgramFiles")} {
+		if dir == "" {
+			continue
+		}
+		path := filepath.Join(dir, "Windows Kits", "10", "Debuggers", "x64", "srcsrv", name)
+		if fileExists(path) {
+			return path
+		}
+	}
+	failIf(errKindToolchain, true, "didn't find %s, install Debugging Tools for Windows from Windows SDK", name)
+	return ""
+}
+
+// returns lower-cased absolute path => path relative to repo root
+// for files tracked in git
+func getGitTrackedFilesMust(repoDir string) map[string]string {
+	out := runExeMust("git", "ls-files")
+	res := map[string]string{}
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" {
+			continue
+		}
+		path := filepath.Join(repoDir, filepath.FromSlash(line))
+		res[strings.ToLower(path)] = line
+	}
+	return res
+}
+
+// sourceFiles are paths of source files as recorded in .pdb
+// only files tracked in git are indexed. Returns srcsrv stream and number
+// of indexed files
+func genSrcsrvStream(sourceFiles []string, tracked map[string]string, sha string) (string, int) {
+	lines := []string{
+		"SRCSRV: ini ------------------------------------------------",
+		"VERSION=2",
+		"VERCTRL=http",
+		"SRCSRV: variables ------------------------------------------",
+		fmt.Sprintf("SRCSRVTRG=%s/%s/%%var2%%", srcsrvRawURLBase, sha),
+		"SRCSRV: source files ---------------------------------------",
+	}
+	n := 0
+	seen := map[string]bool{}
+	for _, path := range sourceFiles {
+		key := strings.ToLower(filepath.Clean(path))
+		relPath, ok := tracked[key]
+		if !ok || seen[key] {
+			continue
+		}
+		seen[key] = true
+		lines = append(lines, path+"*"+relPath)
+		n++
+	}
+	lines = append(lines, "SRCSRV: end ------------------------------------------------")
+	return strings.Join(lines, "\r\n") + "\r\n", n
+}
+
+func sourceIndexPdbMust(pdbPath string, tracked map[string]string, sha string) {
+	srctool := detectSrcsrvToolMust("srctool.exe")
+	// -r lists source files in .pdb, one per line
+	// exit code is number of files so we don't check it
+	out, _ := exec.Command(srctool, "-r", pdbPath).Output()
+	var sourceFiles []string
+	for _, line := range strings.Split(string(out), "\n") {
+		line = strings.TrimSpace(line)
+		if filepath.IsAbs(line) {
+			sourceFiles = append(sourceFiles, line)
+		}
+	}
+	stream, n := genSrcsrvStream(sourceFiles, tracked, sha)
+	panicIf(n == 0, "sourceIndexPdbMust: no source files from git in '%s'", pdbPath)
+
+	streamPath := pdbPath + ".srcsrv.txt"
+	writeFileMust(streamPath, []byte(stream))
+	defer os.Remove(streamPath)
+	pdbstr := detectSrcsrvToolMust("pdbstr.exe")
+	runExeLoggedMust(pdbstr, "-w", "-p:"+pdbPath, "-s:srcsrv", "-i:"+streamPath)
+	logf("sourceIndexPdbMust: indexed %d of %d source files in '%s'\n", n, len(sourceFiles), pdbPath)
+}
+
+// adds srcsrv stream to pdbFiles in dir
+func sourceIndexPdbsMust(dir string) {
+	sha := getGitSha1()
+	tracked := getGitTrackedFilesMust(absPathMust("."))
+	for _, name := range pdbFiles {
+		sourceIndexPdbMust(filepath.Join(dir, name), tracked, sha)
+	}
+}
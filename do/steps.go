@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"strings"
+)
+
+// do run runs individual steps of the build pipeline so that after fixing
+// the cause of a failure we can redo just the failed step instead of the
+// whole build ci / build daily / build prerel run. Steps use files left in
+// out/ by previous steps. e.g.:
+// do run sign:rel64
+// do run sign:rel64,package:rel64,upload:backblaze
+// do run list
+// Step is ${name} or ${name}:${arg}. For most steps arg is directory in
+// out/ (rel32, rel64, arm64), for upload steps it's storage optionally
+// followed by build type (upload:r2:rel), pre-release by default
+
+// PipelineStep is a step that can be run with: do run
+type PipelineStep struct {
+	Name string
+	// describes arg, "" if step doesn't take arg
+	Arg  string
+	Help string
+	Run  func(arg string)
+}
+
+// "rel64" => "x64"
+func getPlatformForOutDirMust(name string) string {
+	for _, platform := range fanoutPlatforms {
+		if filepath.Base(getOutDirForPlatform(platform)) == name {
+			return platform
+		}
+	}
+	panicIf(true, "invalid out directory '%s', must be one of: rel32, rel64, arm64", name)
+	return ""
+}
+
+// "r2:rel" => uploads release build to r2
+func runUploadStep(arg string) {
+	storage, bt, _ := strings.Cut(arg, ":")
+	buildType := buildTypePreRel
+	if bt != "" {
+		panicIf(bt != string(buildTypeRel) && bt != string(buildTypePreRel), "invalid build type '%s', must be rel or prerel", bt)
+		buildType = BuildType(bt)
+	}
+	ensureAllUploadCreds()
+	encryptSelectedArtifactsMust(buildType)
+	log := newTaskLogger("upload-" + storage)
+	defer log.Close()
+	defer stepHooks("upload", storage)()
+	switch storage {
+	case "r2":
+		minioUploadBuildMust(newMinioR2Client(), buildType, log)
+	case "backblaze":
+		minioUploadBuildMust(newMinioBackblazeClient(), buildType, log)
+	default:
+		panicIf(true, "invalid storage '%s', must be r2 or backblaze", storage)
+	}
+}
+
+func getPipelineSteps() []*PipelineStep {
+	outDir := "out-dir"
+	return []*PipelineStep{
+		{"build", outDir, "build pre-release configuration, without signing", func(arg string) {
+			setBuildConfigPreRelease()
+			defer revertBuildConfig()
+			build("Release", getPlatformForOutDirMust(arg), false)
+		}},
+		{"test", outDir, "run test_util", func(arg string) {
+			runTestUtilMust(filepath.Join("out", arg))
+		}},
+		{"audit", outDir, "audit installer payload, manifests, exports and .pdb files", func(arg string) {
+			dir := filepath.Join("out", arg)
+			auditInstallerPayloadMust(dir, getPlatformForOutDirMust(arg))
+			auditManifestsMust(dir)
+			checkLibmupdfExportsMust(dir, false)
+			verifyPdbsMatchMust(dir)
+		}},
+		{"sign", outDir, "sign executables", func(arg string) {
+			signFilesMust(filepath.Join("out", arg))
+		}},
+		{"source-index", outDir, "add source server info to .pdb files", func(arg string) {
+			sourceIndexPdbsMust(filepath.Join("out", arg))
+		}},
+		{"pdb-archive", outDir, "create .pdb.zip and .pdb.lzsa", func(arg string) {
+			dir := filepath.Join("out", arg)
+			createPdbZipMust(dir)
+			createPdbLzsaMust(dir)
+		}},
+		{"package", outDir, "create portable archives and manifest, copy to out/final-prerel", func(arg string) {
+			packagePreRelease(getPlatformForOutDirMust(arg))
+		}},
+		{"source-archive", "rel|prerel", "create source archive in out/final-*, signed if SOURCE_GPG_KEY is set", func(arg string) {
+			buildType := BuildType(arg)
+			panicIf(buildType != buildTypeRel && buildType != buildTypePreRel, "invalid build type '%s', must be rel or prerel", arg)
+			createSourceArchiveMust(buildType, sourceGpgKey != "")
+		}},
+		{"verify-signatures", "", "verify signature timestamps of pre-release in out/final-prerel", func(arg string) {
+			verifySignatureTimestampsMust(buildTypePreRel)
+		}},
+		{"upload", "r2|backblaze[:rel]", "upload build from out/final-* to storage", runUploadStep},
+		{"publish-latest", "", "publish latest pre-release info for the download page", func(arg string) {
+			publishLatestJSONMust(buildTypePreRel)
+		}},
+	}
+}
+
+func fmtPipelineSteps() string {
+	s := "steps for: do run ${steps}\n"
+	for _, st := range getPipelineSteps() {
+		name := st.Name
+		if st.Arg != "" {
+			name += ":${" + st.Arg + "}"
+		}
+		s += fmt.Sprintf("  %-32s %s\n", name, st.Help)
+	}
+	return s
+}
+
+// s is comma-separated list of steps
+func runPipelineStepsMust(s string) {
+	if s == "list" {
+		logf("%s", fmtPipelineSteps())
+		return
+	}
+	steps := getPipelineSteps()
+	type toRun struct {
+		step *PipelineStep
+		arg  string
+	}
+	// validate all before running any
+	var runs []toRun
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		name, arg, _ := strings.Cut(part, ":")
+		var step *PipelineStep
+		for _, st := range steps {
+			if st.Name == name {
+				step = st
+			}
+		}
+		panicIf(step == nil, "unknown step '%s'\n%s", name, fmtPipelineSteps())
+		panicIf(step.Arg != "" && arg == "", "step '%s' needs argument: %s:${%s}", name, name, step.Arg)
+		panicIf(step.Arg == "" && arg != "", "step '%s' doesn't take argument", name)
+		runs = append(runs, toRun{step, arg})
+	}
+	acquireBuildLockMust()
+	for _, r := range runs {
+		name := r.step.Name
+		if r.arg != "" {
+			name += ":" + r.arg
+		}
+		func() {
+			defer makePrintDuration("step " + name)()
+			r.step.Run(r.arg)
+		}()
+	}
+}
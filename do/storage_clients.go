@@ -0,0 +1,172 @@
+package main
+
+import (
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"sync"
+
+	"github.com/kjk/minioutil"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// clients for R2 and Backblaze (B2), both S3-compatible, use minio-go.
+// Clients are created once and share http transport so that concurrent
+// uploads reuse connections. Files are uploaded with FPutObject, which
+// streams them in parts instead of reading into memory.
+// -storage-endpoint points both at a different S3 server e.g. local MinIO
+// (do build ci-upload -storage-endpoint http://localhost:9000) to test uploads without
+// touching production buckets. Credentials are then MINIO_ROOT_USER and
+// MINIO_ROOT_PASSWORD if set and buckets are created if they don't exist
+
+var flgStorageEndpoint string
+
+// StorageConfig describes S3-compatible storage
+type StorageConfig struct {
+	Name     string
+	Bucket   string
+	Endpoint string
+	Secure   bool
+	Access   string
+	Secret   string
+}
+
+// Storage is what we use of S3-compatible storage. Implemented by
+// minioStorage and by MemStorage for tests and do test self
+type Storage interface {
+	URLBase() string
+	URLForPath(remotePath string) string
+	Exists(remotePath string) bool
+	Copy(oldPath, newPath string) (*minio.UploadInfo, error)
+	UploadFile(remotePath string, path string, public bool) (minio.UploadInfo, error)
+	UploadData(remotePath string, data []byte, public bool) (minio.UploadInfo, error)
+	DownloadFileAtomically(dstPath string, remotePath string) error
+	DownloadData(remotePath string) ([]byte, error)
+	ListObjects(prefix string) <-chan minio.ObjectInfo
+	Remove(remotePath string) error
+}
+
+type minioStorage struct {
+	*minioutil.Client
+}
+
+// unlike minioutil.Client.Copy doesn't fail if newPath exists but, like
+// S3 CopyObject, overwrites it
+func (s *minioStorage) Copy(oldPath, newPath string) (*minio.UploadInfo, error) {
+	dst := minio.CopyDestOptions{Bucket: s.Bucket, Object: newPath}
+	src := minio.CopySrcOptions{Bucket: s.Bucket, Object: oldPath}
+	ui, err := s.Client.Client.CopyObject(ctx(), dst, src)
+	return &ui, err
+}
+
+func (s *minioStorage) DownloadData(remotePath string) ([]byte, error) {
+	obj, err := s.Client.Client.GetObject(ctx(), s.Bucket, remotePath, minio.GetObjectOptions{})
+	if err != nil {
+		return nil, err
+	}
+	defer obj.Close()
+	return io.ReadAll(obj)
+}
+
+// if set, creates storage instead of connecting to S3 (see self_test.go)
+var newStorageOverride func(c *StorageConfig) Storage
+
+var (
+	storageTransport     *http.Transport
+	storageTransportOnce sync.Once
+	storageClients       = map[string]Storage{}
+	storageClientsMu     sync.Mutex
+)
+
+func getStorageTransport() *http.Transport {
+	storageTransportOnce.Do(func() {
+		// inherits -proxy
+		storageTransport = http.DefaultTransport.(*http.Transport).Clone()
+		// we upload to the same host from multiple goroutines
+		storageTransport.MaxIdleConnsPerHost = 16
+		if uploadLimiter != nil {
+			storageTransport.DialContext = throttledDialContext(uploadLimiter)
+		}
+	})
+	return storageTransport
+}
+
+// must be called after getSecrets()
+func applyStorageEndpointCreds() {
+	if flgStorageEndpoint == "" {
+		return
+	}
+	user, pwd := os.Getenv("MINIO_ROOT_USER"), os.Getenv("MINIO_ROOT_PASSWORD")
+	if user == "" || pwd == "" {
+		return
+	}
+	r2Access, r2Secret = user, pwd
+	b2Access, b2Secret = user, pwd
+}
+
+// overrides endpoint with -storage-endpoint
+func applyStorageEndpointMust(c *StorageConfig) {
+	if flgStorageEndpoint == "" {
+		return
+	}
+	u, err := url.Parse(flgStorageEndpoint)
+	panicIf(err != nil || u.Host == "", "invalid -storage-endpoint '%s', expected e.g. http://localhost:9000", flgStorageEndpoint)
+	c.Endpoint = u.Host
+	c.Secure = u.Scheme != "http"
+}
+
+func newStorageClientMust(c *StorageConfig) Storage {
+	storageClientsMu.Lock()
+	defer storageClientsMu.Unlock()
+	if mc := storageClients[c.Name]; mc != nil {
+		return mc
+	}
+	if newStorageOverride != nil {
+		mc := newStorageOverride(c)
+		storageClients[c.Name] = mc
+		return mc
+	}
+	applyStorageEndpointMust(c)
+	panicIf(c.Access == "" || c.Secret == "", "no credentials for storage %s", c.Name)
+	client, err := minio.New(c.Endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(c.Access, c.Secret, ""),
+		Secure:    c.Secure,
+		Transport: getStorageTransport(),
+	})
+	must(err)
+	exists, err := client.BucketExists(ctx(), c.Bucket)
+	must(err)
+	if !exists && flgStorageEndpoint != "" {
+		logf("creating bucket '%s' in '%s'\n", c.Bucket, c.Endpoint)
+		must(client.MakeBucket(ctx(), c.Bucket, minio.MakeBucketOptions{}))
+		exists = true
+	}
+	panicIf(!exists, "bucket '%s' doesn't exist in '%s'", c.Bucket, c.Endpoint)
+	mc := &minioStorage{&minioutil.Client{Client: client, Bucket: c.Bucket}}
+	storageClients[c.Name] = mc
+	return mc
+}
+
+func newMinioBackblazeClient() Storage {
+	return newStorageClientMust(&StorageConfig{
+		Name:     "backblaze",
+		Bucket:   "kjk-files",
+		Endpoint: "s3.us-west-001.backblazeb2.com",
+		Secure:   true,
+		Access:   b2Access,
+		Secret:   b2Secret,
+	})
+}
+
+func newMinioR2Client() Storage {
+	return newStorageClientMust(&StorageConfig{
+		Name:     "r2",
+		Bucket:   "files",
+		Endpoint: "71694ef61795ecbe1bc331d217dbd7a7.r2.cloudflarestorage.com",
+		Secure:   true,
+		Access:   r2Access,
+		Secret:   r2Secret,
+	})
+}
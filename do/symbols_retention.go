@@ -0,0 +1,82 @@
+package main
+
+import (
+	"strconv"
+	"strings"
+)
+
+// pre-release builds are deleted by minioDeleteOldBuildsPrefix() but we want
+// to keep symbols for longer than the builds. We keep symbols for:
+// - all release versions
+// - last nPreRelSymbolsToRetain pre-release builds
+// - pre-release builds that show up in recent crash reports
+// pruned after upload of daily build (PruneSymbols in profiles.go)
+const nPreRelSymbolsToRetain = 30
+
+func isSymbolsFile(key string) bool {
+	return strings.HasSuffix(key, ".pdb.zip") || strings.HasSuffix(key, ".pdb.lzsa")
+}
+
+// returns pre-release build numbers that show up in recent crash reports
+func getPreRelBuildsInCrashReports(days int) map[int]bool {
+	res := map[int]bool{}
+	reports := loadRecentCrashReportsMust(days)
+	for _, r := range reports {
+		if !r.IsPreRel {
+			continue
+		}
+		// "3.5.15780" => 15780
+		parts := strings.Split(r.Ver, ".")
+		n, err := strconv.Atoi(parts[len(parts)-1])
+		if err != nil {
+			continue
+		}
+		res[n] = true
+	}
+	return res
+}
+
+func pruneSymbols(mc Storage, inCrashes map[int]bool, doDelete bool) {
+	remoteDir := "software/sumatrapdf/prerel/"
+	var keys []string
+	for obj := range mc.ListObjects(remoteDir) {
+		must(obj.Err)
+		if isSymbolsFile(obj.Key) {
+			keys = append(keys, obj.Key)
+		}
+	}
+	uri := mc.URLForPath(remoteDir)
+	logf("%d symbol files under '%s'\n", len(keys), uri)
+
+	byVer := groupFilesByVersion(keys)
+	nDeleted := 0
+	for i, v := range byVer {
+		if i < nPreRelSymbolsToRetain {
+			continue
+		}
+		if inCrashes[v.ver] {
+			logf("retaining symbols for %d because it's in crash reports\n", v.ver)
+			continue
+		}
+		for _, key := range v.files {
+			if doDelete {
+				err := mc.Remove(key)
+				must(err)
+				logf("  deleted %s\n", key)
+			} else {
+				logf("  would delete %s\n", key)
+			}
+			nDeleted++
+		}
+	}
+	logf("pruneSymbols: %d symbol files to delete in %s\n", nDeleted, mc.URLBase())
+}
+
+// symbols of release builds are never deleted
+func pruneSymbolsStorage(doDelete bool) {
+	ensureAllUploadCreds()
+	inCrashes := getPreRelBuildsInCrashReports(crashesRecentDays)
+	logf("%d pre-release builds in recent crash reports\n", len(inCrashes))
+	pruneSymbols(newMinioR2Client(), inCrashes, doDelete)
+	pruneSymbols(newMinioBackblazeClient(), inCrashes, doDelete)
+}
@@ -0,0 +1,104 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// tasks that run concurrently (uploading to each storage, signing each
+// directory) log with TaskLogger so that their output doesn't interleave
+// mid-line: each line is prefixed with task name (e.g. "[upload-b2] ")
+// and written as a whole. Output of commands run by the task goes through
+// the logger too.
+// With -task-logs output of each task goes to out/logs/${task}.log
+// instead of stdout.
+// nil *TaskLogger logs to stdout without prefix, like logf
+
+var flgTaskLogs bool
+
+// serializes writes to stdout of logf and all TaskLoggers
+var logMu sync.Mutex
+
+// TaskLogger prefixes and serializes output of a task
+type TaskLogger struct {
+	Name string
+	// non-empty if logging to a file
+	Path string
+
+	mu   sync.Mutex
+	file *os.File
+	// incomplete last line
+	buf []byte
+}
+
+func newTaskLogger(name string) *TaskLogger {
+	l := &TaskLogger{Name: name}
+	if flgTaskLogs {
+		l.Path = filepath.Join(createDirMust(filepath.Join("out", "logs")), name+".log")
+		f, err := os.Create(l.Path)
+		must(err)
+		l.file = f
+		logf("[%s] logging to '%s'\n", name, l.Path)
+	}
+	return l
+}
+
+func (l *TaskLogger) writeLine(line []byte) {
+	line = []byte(redactSecrets(string(line)))
+	if l.file != nil {
+		_, _ = l.file.Write(line)
+		return
+	}
+	logMu.Lock()
+	defer logMu.Unlock()
+	_, _ = fmt.Fprintf(os.Stdout, "[%s] %s", l.Name, line)
+}
+
+func (l *TaskLogger) Write(d []byte) (int, error) {
+	if l == nil {
+		logMu.Lock()
+		defer logMu.Unlock()
+		_, err := io.WriteString(os.Stdout, redactSecrets(string(d)))
+		return len(d), err
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.buf = append(l.buf, d...)
+	for {
+		idx := bytes.IndexByte(l.buf, '\n')
+		if idx < 0 {
+			break
+		}
+		l.writeLine(l.buf[:idx+1])
+		l.buf = l.buf[idx+1:]
+	}
+	return len(d), nil
+}
+
+func (l *TaskLogger) Logf(s string, args ...interface{}) {
+	if len(args) > 0 {
+		s = fmt.Sprintf(s, args...)
+	}
+	_, _ = io.WriteString(l, s)
+}
+
+// flushes incomplete line and closes the log file
+func (l *TaskLogger) Close() {
+	if l == nil {
+		return
+	}
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.buf) > 0 {
+		l.writeLine(append(l.buf, '\n'))
+		l.buf = nil
+	}
+	if l.file != nil {
+		l.file.Close()
+		l.file = nil
+	}
+}
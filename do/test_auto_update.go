@@ -0,0 +1,177 @@
+package main
+
+import (
+	"crypto/x509"
+	"encoding/pem"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// do test auto-update tests updating from the previous release to the build
+// in out/final-rel (or out/final-prerel if there's no release build):
+// - downloads previous 64-bit portable release to out/update-test with
+//   settings that trigger automatic update check at startup
+// - serves the new build like do artifacts serve -serve-tls, with
+//   www.sumatrapdfreader.org and sumatra-website.onrender.com mapped to
+//   127.0.0.1 in hosts file and our certificate trusted
+// - launches previous release, accepts the update dialog and checks that
+//   the exe was replaced with the new build and that it starts
+// Hosts file and certificate store are restored at the end.
+// Must run as administrator
+
+const autoUpdateTestTimeout = time.Minute * 3
+
+const hostsFilePath = `C:\Windows\System32\drivers\etc\hosts`
+
+// waits for update dialog of process $procId and presses Enter, which
+// selects the default "Install and relaunch" button
+const acceptUpdatePs1 = `param([int]$procId, [int]$timeoutSecs)
+$shell = New-Object -ComObject WScript.Shell
+$deadline = (Get-Date).AddSeconds($timeoutSecs)
+while ((Get-Date) -lt $deadline) {
+    if ($shell.AppActivate("SumatraPDF Update")) {
+        Start-Sleep -Milliseconds 500
+        $shell.SendKeys("{ENTER}")
+        exit 0
+    }
+    if (-not (Get-Process -Id $procId -ErrorAction SilentlyContinue)) {
+        Write-Output "process $procId exited without showing update dialog"
+        exit 1
+    }
+    Start-Sleep -Seconds 1
+}
+Write-Output "update dialog didn't show up in $timeoutSecs seconds"
+exit 1
+`
+
+// adds entries mapping our hosts to 127.0.0.1, returns function that
+// restores the original hosts file
+func overrideHostsMust() func() {
+	orig := readFileMust(hostsFilePath)
+	s := string(orig)
+	if !strings.HasSuffix(s, "\n") {
+		s += "\r\n"
+	}
+	s += "# added by do test auto-update\r\n"
+	for _, host := range serveTLSHosts {
+		s += "127.0.0.1 " + host + "\r\n"
+	}
+	writeFileMust(hostsFilePath, []byte(s))
+	_ = exec.Command("ipconfig", "/flushdns").Run()
+	return func() {
+		writeFileMust(hostsFilePath, orig)
+		_ = exec.Command("ipconfig", "/flushdns").Run()
+		logf("restored '%s'\n", hostsFilePath)
+	}
+}
+
+// adds certificate of -serve-tls to trusted roots, returns function that
+// removes it
+func trustServeCertMust() func() {
+	ensureServeCertMust()
+	block, _ := pem.Decode(readFileMust(serveCertPath))
+	panicIf(block == nil, "invalid certificate '%s'", serveCertPath)
+	cert, err := x509.ParseCertificate(block.Bytes)
+	must(err)
+	serial := cert.SerialNumber.Text(16)
+	runCmdLoggedMust(exec.Command("certutil", "-addstore", "Root", filepath.FromSlash(serveCertPath)))
+	return func() {
+		_ = exec.Command("certutil", "-delstore", "Root", serial).Run()
+		logf("removed certificate %s from trusted roots\n", serial)
+	}
+}
+
+func killSumatraInDir(dir string) {
+	script := `Get-Process SumatraPDF* -ErrorAction SilentlyContinue | Where-Object { $_.Path -like "` + dir + `\*" } | Stop-Process -Force`
+	_ = exec.Command("powershell.exe", "-NoProfile", "-Command", script).Run()
+}
+
+func testAutoUpdateMust() {
+	failIf(errKindPreflight, runtime.GOOS != "windows", "test auto-update only works on Windows")
+	f, err := os.OpenFile(hostsFilePath, os.O_WRONLY|os.O_APPEND, 0)
+	failIf(errKindPreflight, err != nil, "test auto-update must run as administrator to change '%s': %v", hostsFilePath, err)
+	f.Close()
+
+	buildType := buildTypeRel
+	if !dirExists(getFinalDirForBuildType(buildType)) {
+		buildType = buildTypePreRel
+	}
+	ver := getVerForBuildType(buildType)
+	finalDir := getFinalDirForBuildType(buildType)
+	newExe := filepath.Join(finalDir, filepath.Base(getDownloadUrlsForPrefix("", buildType, ver).portableExe64))
+	panicIf(!fileExists(newExe), "'%s' doesn't exist, build with: do build prerel or do build release first", newExe)
+	newSha := sha256Hex(readFileMust(newExe))
+
+	prevVer := strings.TrimSuffix(getLastReleaseTag(), "rel")
+	panicIf(prevVer == "", "didn't find git tag of previous release")
+
+	testDir := absPathMust(filepath.Join("out", "update-test"))
+	killSumatraInDir(testDir)
+	must(os.RemoveAll(testDir))
+	createDirMust(testDir)
+	// download before we point www.sumatrapdfreader.org to ourselves
+	prevURL := getDownloadUrlsViaWebsite(buildTypeRel, prevVer).portableExe64
+	logf("downloading previous release %s from '%s'\n", prevVer, prevURL)
+	exe := filepath.Join(testDir, "SumatraPDF.exe")
+	writeFileMust(exe, httpGetMust(prevURL))
+	// settings next to exe make it portable. very old time of last check
+	// triggers update check at startup
+	settings := "CheckForUpdates = true\nTimeOfLastUpdateCheck = 1 1\n"
+	writeFileMust(filepath.Join(testDir, "SumatraPDF-settings.txt"), []byte(settings))
+
+	s := newArtifactServerMust("https://" + serveTLSHosts[0] + "/")
+	if buildType == buildTypePreRel {
+		// previous release checks release update url
+		s.addContent(getUpdateCheckURLPath(buildTypeRel), s.content[getUpdateCheckURLPath(buildTypePreRel)])
+	}
+	defer trustServeCertMust()()
+	defer overrideHostsMust()()
+	srv := &http.Server{Addr: "127.0.0.1:443", Handler: s, ReadHeaderTimeout: 30 * time.Second}
+	go func() {
+		err := srv.ListenAndServeTLS(serveCertPath, serveKeyPath)
+		if err != http.ErrServerClosed {
+			logf("server failed: %s\n", err)
+		}
+	}()
+	defer srv.Close()
+	defer killSumatraInDir(testDir)
+
+	cmd := exec.Command(exe)
+	logf("> %s\n", cmd.String())
+	must(cmd.Start())
+	ps1Path := filepath.Join(testDir, "accept-update.ps1")
+	writeFileMust(ps1Path, []byte(acceptUpdatePs1))
+	psCmd := exec.Command("powershell.exe", "-ExecutionPolicy", "Bypass", "-File", ps1Path, strconv.Itoa(cmd.Process.Pid), "60")
+	out, err := psCmd.CombinedOutput()
+	panicIf(err != nil, "%s failed with '%s', output:\n%s\n", psCmd.String(), err, string(out))
+	_ = cmd.Wait()
+
+	timeStart := time.Now()
+	for sha256Hex(readFileMust(exe)) != newSha {
+		panicIf(time.Since(timeStart) > autoUpdateTestTimeout, "'%s' wasn't updated to '%s' in %s", exe, newExe, autoUpdateTestTimeout)
+		time.Sleep(time.Second * 2)
+	}
+	logf("'%s' updated from %s to %s in %s\n", exe, prevVer, ver, time.Since(timeStart))
+	// the update relaunches the app
+	time.Sleep(time.Second * 3)
+	killSumatraInDir(testDir)
+
+	cmd = exec.Command(exe)
+	must(cmd.Start())
+	exited := make(chan error, 1)
+	go func() { exited <- cmd.Wait() }()
+	select {
+	case err := <-exited:
+		panicIf(true, "updated '%s' exited right after start: %v", exe, err)
+	case <-time.After(time.Second * 5):
+	}
+	_ = cmd.Process.Kill()
+	<-exited
+	logf("auto-update from %s to %s passed\n", prevVer, ver)
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Tests silent install and uninstall of the installer in a clean environment.
+// By default it's Windows Sandbox. To use a VM instead, set
+// INSTALLER_TEST_VM_CMD to a command that runs {script} in a VM
+// with {dir} mapped as C:\test e.g.:
+// INSTALLER_TEST_VM_CMD="vmrun.bat {dir} {script}"
+
+const installerTestTimeout = time.Minute * 10
+
+const testInstallPs1 = `$ErrorActionPreference = "Continue"
+$errors = @()
+$installDir = "C:\SumatraTest"
+$result = "C:\test\result.txt"
+
+function Check($cond, $msg) {
+    if (-not $cond) { $script:errors += $msg }
+}
+
+$p = Start-Process -FilePath "C:\test\SumatraPDF-dll.exe" -ArgumentList "-install","-silent","-install-dir",$installDir -Wait -PassThru
+Check ($p.ExitCode -eq 0) "install exit code: $($p.ExitCode)"
+
+foreach ($f in @("SumatraPDF.exe", "libmupdf.dll", "PdfFilter.dll", "PdfPreview.dll")) {
+    Check (Test-Path (Join-Path $installDir $f)) "not installed: $f"
+}
+Check (Test-Path "HKCU:\Software\Microsoft\Windows\CurrentVersion\Uninstall\SumatraPDF") "missing uninstall registry key"
+Check (Test-Path "HKCU:\Software\Classes\Applications\SumatraPDF.exe") "missing Applications registry key"
+Check (Test-Path "HKCU:\Software\Classes\.pdf\OpenWithProgids") "missing .pdf OpenWithProgids registry key"
+$startMenu = [Environment]::GetFolderPath("StartMenu")
+Check (Test-Path (Join-Path $startMenu "SumatraPDF.lnk")) "missing start menu shortcut"
+
+$p = Start-Process -FilePath (Join-Path $installDir "SumatraPDF.exe") -ArgumentList "-uninstall","-silent" -Wait -PassThru
+Check ($p.ExitCode -eq 0) "uninstall exit code: $($p.ExitCode)"
+# uninstaller deletes some files after it exits
+Start-Sleep -Seconds 5
+
+Check (-not (Test-Path (Join-Path $installDir "SumatraPDF.exe"))) "not uninstalled: SumatraPDF.exe"
+Check (-not (Test-Path (Join-Path $installDir "libmupdf.dll"))) "not uninstalled: libmupdf.dll"
+Check (-not (Test-Path "HKCU:\Software\Microsoft\Windows\CurrentVersion\Uninstall\SumatraPDF")) "uninstall registry key not removed"
+Check (-not (Test-Path "HKCU:\Software\Classes\Applications\SumatraPDF.exe")) "Applications registry key not removed"
+Check (-not (Test-Path (Join-Path $startMenu "SumatraPDF.lnk"))) "start menu shortcut not removed"
+
+if ($errors.Count -eq 0) {
+    Set-Content -Path $result -Value "OK"
+} else {
+    Set-Content -Path $result -Value ($errors -join "` + "`" + `n")
+}
+shutdown /s /t 0
+`
+
+const testInstallWsbTmpl = `<Configuration>
+  <Networking>Disable</Networking>
+  <MappedFolders>
+    <MappedFolder>
+      <HostFolder>{{.Dir}}</HostFolder>
+      <SandboxFolder>C:\test</SandboxFolder>
+      <ReadOnly>false</ReadOnly>
+    </MappedFolder>
+  </MappedFolders>
+  <LogonCommand>
+    <Command>powershell.exe -ExecutionPolicy Bypass -File C:\test\{{.Script}}</Command>
+  </LogonCommand>
+</Configuration>
+`
+
+// runs powershell script from testDir in Windows Sandbox (or a VM) with
+// testDir mapped as C:\test. the script must write result.txt with "OK"
+// or a list of errors
+func runTestScriptInSandboxMust(testDir string, script string) {
+	resultPath := filepath.Join(testDir, "result.txt")
+	must(os.RemoveAll(resultPath))
+
+	var cmd *exec.Cmd
+	if vmCmd := os.Getenv("INSTALLER_TEST_VM_CMD"); vmCmd != "" {
+		s := strings.ReplaceAll(vmCmd, "{dir}", testDir)
+		s = strings.ReplaceAll(s, "{script}", script)
+		parts := strings.Fields(s)
+		cmd = exec.Command(parts[0], parts[1:]...)
+	} else {
+		wsb := execTextTemplate(testInstallWsbTmpl, map[string]string{"Dir": testDir, "Script": script})
+		wsbPath := filepath.Join(testDir, strings.TrimSuffix(script, ".ps1")+".wsb")
+		writeFileMust(wsbPath, []byte(wsb))
+		cmd = exec.Command("WindowsSandbox.exe", wsbPath)
+	}
+	logf("> %s\n", cmd.String())
+	must(cmd.Start())
+
+	timeStart := time.Now()
+	for !fileExists(resultPath) {
+		panicIf(time.Since(timeStart) > installerTestTimeout, "%s didn't finish in %s", script, installerTestTimeout)
+		time.Sleep(time.Second * 5)
+	}
+	// give the script time to finish writing
+	time.Sleep(time.Second)
+	res := strings.TrimSpace(string(readFileMust(resultPath)))
+	panicIf(res != "OK", "%s failed:\n%s\n", script, res)
+	logf("%s passed in %s\n", script, time.Since(timeStart))
+}
+
+func testInstallerInSandbox(dir string) {
+	installerPath := filepath.Join(dir, "SumatraPDF-dll.exe")
+	panicIf(!fileExists(installerPath), "'%s' doesn't exist", installerPath)
+
+	testDir := absPathMust(filepath.Join("out", "installer-test"))
+	must(os.RemoveAll(testDir))
+	createDirMust(testDir)
+	must(copyFile(filepath.Join(testDir, "SumatraPDF-dll.exe"), installerPath))
+	writeFileMust(filepath.Join(testDir, "test-install.ps1"), []byte(testInstallPs1))
+	runTestScriptInSandboxMust(testDir, "test-install.ps1")
+}
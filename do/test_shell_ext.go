@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// PdfPreview.dll and PdfFilter.dll are only used by Explorer and Windows Search
+// so regressions are only found by users after release. This registers them
+// in Windows Sandbox (so that we don't mess up registry of the dev machine),
+// exercises IThumbnailProvider, IPreviewHandler and IFilter with
+// PdfPreviewTest.exe and unregisters them
+
+const shellExtTestText = "SumatraPDF shell extension test"
+
+var shellExtDlls = []string{"PdfPreview.dll", "PdfFilter.dll"}
+
+const testShellExtPs1 = `$ErrorActionPreference = "Continue"
+$errors = @()
+$result = "C:\test\result.txt"
+$pdf = "C:\test\sample.pdf"
+
+function Check($cond, $msg) {
+    if (-not $cond) { $script:errors += $msg }
+}
+
+$clsids = @{
+    "PdfPreview.dll" = "{3D3B1846-CC43-42AE-BFF9-D914083C2BA3}";
+    "PdfFilter.dll" = "{55808EA8-81FE-43c6-AAE8-1D8149F941D3}"
+}
+
+foreach ($dll in $clsids.Keys) {
+    $p = Start-Process -FilePath "regsvr32.exe" -ArgumentList "/s",("C:\test\" + $dll) -Wait -PassThru
+    Check ($p.ExitCode -eq 0) "regsvr32 $dll exit code: $($p.ExitCode)"
+    Check (Test-Path ("HKCU:\Software\Classes\CLSID\" + $clsids[$dll])) "$dll not registered"
+}
+
+foreach ($mode in @("-thumb", "-preview", "-filter")) {
+    $out = & C:\test\PdfPreviewTest.exe -com $mode $pdf 2>&1 | Out-String
+    Check ($LASTEXITCODE -eq 0) "PdfPreviewTest.exe $mode exit code: $LASTEXITCODE, output: $out"
+    if ($mode -eq "-filter") {
+        Check ($out.Contains("{{.Text}}")) "PdfPreviewTest.exe -filter didn't extract expected text, output: $out"
+    }
+}
+
+foreach ($dll in $clsids.Keys) {
+    $p = Start-Process -FilePath "regsvr32.exe" -ArgumentList "/s","/u",("C:\test\" + $dll) -Wait -PassThru
+    Check ($p.ExitCode -eq 0) "regsvr32 /u $dll exit code: $($p.ExitCode)"
+    Check (-not (Test-Path ("HKCU:\Software\Classes\CLSID\" + $clsids[$dll]))) "$dll not unregistered"
+}
+
+if ($errors.Count -eq 0) {
+    Set-Content -Path $result -Value "OK"
+} else {
+    Set-Content -Path $result -Value ($errors -join "` + "`" + `n")
+}
+shutdown /s /t 0
+`
+
+// generates a minimal, one page PDF with a given text
+func genTestPdf(text string) []byte {
+	return genTestPdfPages([]string{text})
+}
+
+// generates a minimal PDF with a page for each text and with table of
+// contents (outline) that has an entry for each page
+func genTestPdfPages(pages []string) []byte {
+	n := len(pages)
+	pageRef := func(i int) string {
+		return fmt.Sprintf("%d 0 R", 5+3*i)
+	}
+	outlineRef := func(i int) string {
+		return fmt.Sprintf("%d 0 R", 7+3*i)
+	}
+	var kids []string
+	for i := range pages {
+		kids = append(kids, pageRef(i))
+	}
+	objs := []string{
+		"<< /Type /Catalog /Pages 2 0 R /Outlines 3 0 R /PageMode /UseOutlines >>",
+		fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", strings.Join(kids, " "), n),
+		fmt.Sprintf("<< /Type /Outlines /First %s /Last %s /Count %d >>", outlineRef(0), outlineRef(n-1), n),
+		"<< /Type /Font /Subtype /Type1 /BaseFont /Helvetica >>",
+	}
+	for i, text := range pages {
+		content := fmt.Sprintf("BT /F1 24 Tf 72 700 Td (%s) Tj ET", text)
+		page := fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents %d 0 R /Resources << /Font << /F1 4 0 R >> >> >>", 6+3*i)
+		stream := fmt.Sprintf("<< /Length %d >>\nstream\n%s\nendstream", len(content), content)
+		outline := fmt.Sprintf("<< /Title (%s) /Parent 3 0 R /Dest [%s /Fit]", text, pageRef(i))
+		if i > 0 {
+			outline += " /Prev " + outlineRef(i-1)
+		}
+		if i < n-1 {
+			outline += " /Next " + outlineRef(i+1)
+		}
+		outline += " >>"
+		objs = append(objs, page, stream, outline)
+	}
+
+	var b bytes.Buffer
+	b.WriteString("%PDF-1.4\n")
+	var offsets []int
+	for i, obj := range objs {
+		offsets = append(offsets, b.Len())
+		fmt.Fprintf(&b, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xrefOff := b.Len()
+	fmt.Fprintf(&b, "xref\n0 %d\n0000000000 65535 f \n", len(objs)+1)
+	for _, off := range offsets {
+		fmt.Fprintf(&b, "%010d 00000 n \n", off)
+	}
+	fmt.Fprintf(&b, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objs)+1, xrefOff)
+	return b.Bytes()
+}
+
+func testShellExtInSandbox(dir string) {
+	msbuildPath := detectMsbuildPath()
+	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
+	p := fmt.Sprintf(`/p:Configuration=Release;Platform=%s`, kPlatformIntel64)
+	runExeLoggedMust(msbuildPath, slnPath, `/t:PdfFilter;PdfPreview;PdfPreviewTest`, p, `/m`)
+
+	testDir := absPathMust(filepath.Join("out", "shell-ext-test"))
+	must(os.RemoveAll(testDir))
+	createDirMust(testDir)
+	for _, name := range append([]string{"PdfPreviewTest.exe"}, shellExtDlls...) {
+		srcPath := filepath.Join(dir, name)
+		panicIf(!fileExists(srcPath), "'%s' doesn't exist", srcPath)
+		must(copyFile(filepath.Join(testDir, name), srcPath))
+	}
+	writeFileMust(filepath.Join(testDir, "sample.pdf"), genTestPdf(shellExtTestText))
+	ps1 := execTextTemplate(testShellExtPs1, map[string]string{"Text": shellExtTestText})
+	writeFileMust(filepath.Join(testDir, "test-shell-ext.ps1"), []byte(ps1))
+	runTestScriptInSandboxMust(testDir, "test-shell-ext.ps1")
+}
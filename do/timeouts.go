@@ -0,0 +1,181 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// external processes are run with a timeout so that a hung msbuild or
+// signtool (e.g. waiting for timestamp server) doesn't block CI for hours.
+// On timeout or Ctrl-C we terminate the whole process tree, first gracefully
+// and then forcefully, and log the tail of its output to help diagnose the hang.
+// Timeouts are per executable name, overridable with
+// -timeouts msbuild=3h,signtool=20m. "http" is timeout of http requests,
+// "default" is for all other executables
+
+var flgTimeouts string
+
+var cmdTimeouts = map[string]time.Duration{
+	"msbuild":  2 * time.Hour,
+	"signtool": 15 * time.Minute,
+	"wails":    30 * time.Minute,
+	"http":     10 * time.Minute,
+	"default":  time.Hour,
+}
+
+// how long we wait for process to exit after asking it to
+const killGracePeriod = 10 * time.Second
+
+const cmdOutputTailSize = 8 * 1024
+
+// cancelled on Ctrl-C
+var rootCtx = context.Background()
+
+func parseTimeoutsMust(s string) {
+	for _, part := range strings.Split(s, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+		name, v, ok := strings.Cut(part, "=")
+		panicIf(!ok, "invalid -timeouts '%s', expected name=duration e.g. msbuild=3h", part)
+		d, err := time.ParseDuration(v)
+		panicIf(err != nil || d <= 0, "invalid duration '%s' in -timeouts for %s", v, name)
+		cmdTimeouts[strings.ToLower(name)] = d
+	}
+}
+
+// must be called after flags are parsed
+func initTimeoutsAndCancellation() {
+	parseTimeoutsMust(flgTimeouts)
+	http.DefaultClient.Timeout = cmdTimeouts["http"]
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rootCtx = ctx
+	ch := make(chan os.Signal, 1)
+	signal.Notify(ch, os.Interrupt)
+	go func() {
+		<-ch
+		logf("\ninterrupted, stopping child processes\n")
+		cancel()
+		// give running command time to be killed and cleanup to run
+		time.Sleep(killGracePeriod + 5*time.Second)
+		os.Exit(errorKinds[errKindInterrupted].ExitCode)
+	}()
+}
+
+func getCmdTimeout(cmd *exec.Cmd) time.Duration {
+	name := strings.ToLower(strings.TrimSuffix(filepath.Base(cmd.Path), filepath.Ext(cmd.Path)))
+	if d, ok := cmdTimeouts[name]; ok {
+		return d
+	}
+	return cmdTimeouts["default"]
+}
+
+func killProcessTree(cmd *exec.Cmd, done chan error) {
+	pid := strconv.Itoa(cmd.Process.Pid)
+	if runtime.GOOS == "windows" {
+		_ = exec.Command("taskkill", "/T", "/PID", pid).Run()
+	} else {
+		_ = cmd.Process.Signal(os.Interrupt)
+	}
+	select {
+	case <-done:
+		return
+	case <-time.After(killGracePeriod):
+	}
+	if runtime.GOOS == "windows" {
+		_ = exec.Command("taskkill", "/T", "/F", "/PID", pid).Run()
+	} else {
+		_ = cmd.Process.Kill()
+	}
+	<-done
+}
+
+func teeWriter(w io.Writer, tail *tailWriter) io.Writer {
+	if w == nil {
+		return tail
+	}
+	return io.MultiWriter(w, tail)
+}
+
+// ProcessRunner runs external commands. FakeProcessRunner replaces it in
+// tests and do test self
+type ProcessRunner interface {
+	Run(cmd *exec.Cmd) error
+}
+
+var processRunner ProcessRunner = timeoutProcessRunner{}
+
+// like cmd.Run() but with a timeout and cancellation on Ctrl-C
+func runCmdWithTimeout(cmd *exec.Cmd) error {
+	timeStart := time.Now()
+	err := processRunner.Run(cmd)
+	recordCommandAudit(cmd, timeStart, err)
+	return err
+}
+
+type timeoutProcessRunner struct{}
+
+func (timeoutProcessRunner) Run(cmd *exec.Cmd) error {
+	tail := &tailWriter{max: cmdOutputTailSize}
+	if cmd.Stdout != nil && cmd.Stdout == cmd.Stderr {
+		cmd.Stdout = teeWriter(cmd.Stdout, tail)
+		cmd.Stderr = cmd.Stdout
+	} else {
+		cmd.Stdout = teeWriter(cmd.Stdout, tail)
+		cmd.Stderr = teeWriter(cmd.Stderr, tail)
+	}
+	// don't wait forever for output of grandchildren that outlive the process
+	cmd.WaitDelay = killGracePeriod
+	return startAndWaitWithTimeout(cmd, tail)
+}
+
+// on timeout or interruption kills the process and logs tail of its output
+func startAndWaitWithTimeout(cmd *exec.Cmd, tail *tailWriter) error {
+	if err := cmd.Start(); err != nil {
+		return err
+	}
+	done := make(chan error, 1)
+	go func() {
+		done <- cmd.Wait()
+	}()
+
+	timeout := getCmdTimeout(cmd)
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
+	var kind ErrorKind
+	var reason string
+	select {
+	case err := <-done:
+		return err
+	case <-timer.C:
+		kind, reason = errKindTimeout, fmt.Sprintf("timed out after %s", timeout)
+	case <-rootCtx.Done():
+		kind, reason = errKindInterrupted, "interrupted"
+	}
+	killProcessTree(cmd, done)
+	msg := fmt.Sprintf("'%s' %s", fmdCmdShort(cmd), reason)
+	logf("%s, last output:\n%s\n", msg, tail.String())
+	return &DoError{Kind: kind, Msg: msg}
+}
+
+// like cmd.CombinedOutput() but with a timeout and cancellation on Ctrl-C
+func combinedOutputWithTimeout(cmd *exec.Cmd) ([]byte, error) {
+	var b bytes.Buffer
+	cmd.Stdout = &b
+	cmd.Stderr = &b
+	err := runCmdWithTimeout(cmd)
+	return b.Bytes(), err
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// official builds must be built with the same toolchain. do/toolchain.txt
+// pins versions of Visual Studio, Windows SDK and clang-format and we
+// check installed versions against it so that toolchain drift is a clear
+// error before the build instead of a surprise after it
+
+const toolchainPath = "do/toolchain.txt"
+
+// returns tool => version spec
+func parseToolchainMust(s string) map[string]string {
+	res := map[string]string{}
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, val, ok := strings.Cut(line, ":")
+		panicIf(!ok, "invalid line '%s' in '%s'", line, toolchainPath)
+		res[strings.TrimSpace(name)] = strings.TrimSpace(val)
+	}
+	return res
+}
+
+// "17.11.5" => [17 11 5]
+func parseVersionParts(ver string) []int {
+	var res []int
+	for _, s := range strings.Split(ver, ".") {
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			break
+		}
+		res = append(res, n)
+	}
+	return res
+}
+
+// compares a and b on the first n parts, n <= 0 means all
+func cmpVersionParts(a, b []int, n int) int {
+	if n <= 0 {
+		n = max(len(a), len(b))
+	}
+	for i := 0; i < n; i++ {
+		var x, y int
+		if i < len(a) {
+			x = a[i]
+		}
+		if i < len(b) {
+			y = b[i]
+		}
+		if x != y {
+			if x < y {
+				return -1
+			}
+			return 1
+		}
+	}
+	return 0
+}
+
+// spec is "min..max" or a version prefix
+func versionMatchesSpec(ver string, spec string) bool {
+	v := parseVersionParts(ver)
+	if len(v) == 0 {
+		return false
+	}
+	minVer, maxVer, isRange := strings.Cut(spec, "..")
+	if !isRange {
+		p := parseVersionParts(spec)
+		return cmpVersionParts(v, p, len(p)) == 0
+	}
+	lo, hi := parseVersionParts(minVer), parseVersionParts(maxVer)
+	return cmpVersionParts(v, lo, len(lo)) >= 0 && cmpVersionParts(v, hi, len(hi)) <= 0
+}
+
+// "17.9.8+b0d1f6b7a" => "17.9.8"
+func detectVSVersion() (string, error) {
+	out, err := exec.Command(detectMsbuildPath(), "-version", "-nologo").Output()
+	if err != nil {
+		return "", err
+	}
+	lines := toTrimmedLines(out)
+	if len(lines) == 0 {
+		return "", fmt.Errorf("no output from msbuild -version")
+	}
+	return lines[len(lines)-1], nil
+}
+
+// returns the latest installed Windows SDK version
+func detectWindowsSDKVersion() (string, error) {
+	dir := filepath.Join(os.Getenv("ProgramFiles(x86)"), "Windows Kits", "10", "bin")
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return "", err
+	}
+	var vers []string
+	for _, e := range entries {
+		if e.IsDir() && strings.HasPrefix(e.Name(), "10.") {
+			vers = append(vers, e.Name())
+		}
+	}
+	if len(vers) == 0 {
+		return "", fmt.Errorf("no SDK in '%s'", dir)
+	}
+	sort.Slice(vers, func(i, j int) bool {
+		return cmpVersionParts(parseVersionParts(vers[i]), parseVersionParts(vers[j]), 0) < 0
+	})
+	return vers[len(vers)-1], nil
+}
+
+// clang-format version 17.0.3
+var rxClangFormatVersion = regexp.MustCompile(`version (\d+(?:\.\d+)*)`)
+
+func detectClangFormatVersion() (string, error) {
+	out, err := exec.Command(detectClangFormat(), "--version").Output()
+	if err != nil {
+		return "", err
+	}
+	m := rxClangFormatVersion.FindStringSubmatch(string(out))
+	if m == nil {
+		return "", fmt.Errorf("unexpected output of clang-format --version: '%s'", strings.TrimSpace(string(out)))
+	}
+	return m[1], nil
+}
+
+var toolchainDetectors = map[string]func() (string, error){
+	"vs":           detectVSVersion,
+	"sdk":          detectWindowsSDKVersion,
+	"clang-format": detectClangFormatVersion,
+}
+
+func checkToolchainMust() {
+	pinned := parseToolchainMust(string(readFileMust(toolchainPath)))
+	var names []string
+	for name := range pinned {
+		panicIf(toolchainDetectors[name] == nil, "unknown tool '%s' in '%s'", name, toolchainPath)
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var problems []string
+	for _, name := range names {
+		spec := pinned[name]
+		ver, err := toolchainDetectors[name]()
+		if err != nil {
+			problems = append(problems, fmt.Sprintf("%s: failed to detect version: %s", name, err))
+			continue
+		}
+		if !versionMatchesSpec(ver, spec) {
+			problems = append(problems, fmt.Sprintf("%s: installed %s, %s requires %s", name, ver, toolchainPath, spec))
+			continue
+		}
+		logf("toolchain: %s %s matches %s\n", name, ver, spec)
+	}
+	failIf(errKindToolchain, len(problems) > 0, "toolchain doesn't match '%s':\n  %s", toolchainPath, strings.Join(problems, "\n  "))
+}
@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// for release builds we create .torrent files for installers and portable
+// archives, with our download server as a web seed (BEP 19) so that
+// they can be downloaded even if there are no peers.
+// Magnet links are written to ${prefix}-magnets.txt
+
+const torrentPieceSize = 256 * 1024
+
+var torrentTrackers = []string{
+	"udp://tracker.opentrackr.org:1337/announce",
+	"udp://open.demonii.com:1337/announce",
+}
+
+// https://www.bittorrent.org/beps/bep_0003.html#bencoding
+// supports string, int, int64, []interface{}, []string and map[string]interface{}
+func bencode(w *bytes.Buffer, v interface{}) {
+	switch v := v.(type) {
+	case string:
+		fmt.Fprintf(w, "%d:%s", len(v), v)
+	case int:
+		fmt.Fprintf(w, "i%de", v)
+	case int64:
+		fmt.Fprintf(w, "i%de", v)
+	case []string:
+		w.WriteByte('l')
+		for _, el := range v {
+			bencode(w, el)
+		}
+		w.WriteByte('e')
+	case []interface{}:
+		w.WriteByte('l')
+		for _, el := range v {
+			bencode(w, el)
+		}
+		w.WriteByte('e')
+	case map[string]interface{}:
+		var keys []string
+		for k := range v {
+			keys = append(keys, k)
+		}
+		// keys must be sorted as raw strings
+		sort.Strings(keys)
+		w.WriteByte('d')
+		for _, k := range keys {
+			bencode(w, k)
+			bencode(w, v[k])
+		}
+		w.WriteByte('e')
+	default:
+		panicIf(true, "bencode: unsupported type %T", v)
+	}
+}
+
+func bencodeBytes(v interface{}) []byte {
+	var buf bytes.Buffer
+	bencode(&buf, v)
+	return buf.Bytes()
+}
+
+// returns .torrent file and magnet link for a file that can be downloaded from webSeedURL
+func createTorrent(d []byte, name string, webSeedURL string) ([]byte, string) {
+	var pieces []byte
+	for off := 0; off < len(d); off += torrentPieceSize {
+		end := off + torrentPieceSize
+		if end > len(d) {
+			end = len(d)
+		}
+		h := sha1.Sum(d[off:end])
+		pieces = append(pieces, h[:]...)
+	}
+	info := map[string]interface{}{
+		"name":         name,
+		"length":       len(d),
+		"piece length": torrentPieceSize,
+		"pieces":       string(pieces),
+	}
+	var announceList []interface{}
+	for _, tr := range torrentTrackers {
+		announceList = append(announceList, []string{tr})
+	}
+	torrent := map[string]interface{}{
+		"announce":      torrentTrackers[0],
+		"announce-list": announceList,
+		"created by":    "SumatraPDF build",
+		"creation date": time.Now().Unix(),
+		"info":          info,
+		"url-list":      []string{webSeedURL},
+	}
+	infoHash := sha1.Sum(bencodeBytes(info))
+	magnet := "magnet:?xt=urn:btih:" + hex.EncodeToString(infoHash[:])
+	magnet += "&dn=" + url.QueryEscape(name)
+	magnet += "&xl=" + fmt.Sprintf("%d", len(d))
+	magnet += "&ws=" + url.QueryEscape(webSeedURL)
+	for _, tr := range torrentTrackers {
+		magnet += "&tr=" + url.QueryEscape(tr)
+	}
+	return bencodeBytes(torrent), magnet
+}
+
+func isTorrentedFile(name string) bool {
+	if strings.Contains(name, ".pdb.") {
+		return false
+	}
+	for _, f := range portableFormats {
+		if strings.HasSuffix(name, f.ReleaseSuffix) {
+			return true
+		}
+	}
+	return strings.HasSuffix(name, "-install.exe")
+}
+
+// creates ${file}.torrent for files in dir and ${prefix}-magnets.txt
+// Magnet links are also appended to ${prefix}-manifest.txt, which must
+// already be in dir
+func createReleaseTorrentsMust(dir string, prefix string, ver string) {
+	files, err := os.ReadDir(dir)
+	must(err)
+	var lines []string
+	for _, f := range files {
+		name := f.Name()
+		if f.IsDir() || !isTorrentedFile(name) {
+			continue
+		}
+		webSeed := "https://www.sumatrapdfreader.org/dl/" + string(buildTypeRel) + "/" + ver + "/" + name
+		d, magnet := createTorrent(readFileMust(filepath.Join(dir, name)), name, webSeed)
+		writeFileMust(filepath.Join(dir, name+".torrent"), d)
+		push(&lines, fmt.Sprintf("%s: %s", name, magnet))
+	}
+	panicIf(len(lines) == 0, "no files to create torrents for in '%s'", dir)
+	path := filepath.Join(dir, prefix+"-magnets.txt")
+	writeFileMust(path, []byte(strings.Join(lines, "\n")+"\n"))
+	logf("Wrote %d torrents and '%s'\n", len(lines), path)
+
+	manifestPath := filepath.Join(dir, prefix+"-manifest.txt")
+	manifest := toTrimmedRightLines(string(readFileMust(manifestPath)))
+	manifest = append(manifest, lines...)
+	writeFileMust(manifestPath, []byte(strings.Join(manifest, "\n")+"\n"))
+	logf("Added magnet links to '%s'\n", manifestPath)
+}
@@ -0,0 +1,204 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// extracts strings to translate, i.e. string literal arguments of _TR(),
+// _TRN() and _TRA(), from C++ sources.
+// We tokenize the source so that we skip comments, handle adjacent string
+// literals ("foo" "bar"), raw strings (R"(foo)") and string prefixes (L, u8).
+// Strings are returned as they are written in C source i.e. escapes like
+// \n and \" are not interpreted, because that's what apptranslator has.
+// do trans strings prints extracted strings with their locations
+
+var transMacros = []string{"_TR", "_TRN", "_TRA"}
+
+// TranslatableString is a string to translate at a given location
+type TranslatableString struct {
+	Text  string
+	Macro string
+	Path  string
+	Line  int
+}
+
+type cTokenKind int
+
+const (
+	cTokIdent cTokenKind = iota
+	cTokString
+	cTokPunct
+)
+
+type cToken struct {
+	Kind cTokenKind
+	// for cTokString, text between quotes, escaped as in C source
+	Text string
+	Line int
+}
+
+// for raw strings, which can have characters that must be escaped in
+// regular strings
+func escapeCString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\r", `\r`, "\t", `\t`)
+	return r.Replace(s)
+}
+
+func isIdentStart(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z')
+}
+
+func isIdentChar(c byte) bool {
+	return isIdentStart(c) || (c >= '0' && c <= '9')
+}
+
+// tokenizes C/C++ source. Only tells apart identifiers, string literals
+// and everything else, which is enough to find macro calls
+func tokenizeCMust(s string, path string) []cToken {
+	var res []cToken
+	line := 1
+	n := len(s)
+	i := 0
+	// returns index after closing quote of a literal starting at s[start]
+	skipQuoted := func(start int, quote byte) int {
+		j := start + 1
+		for j < n && s[j] != quote {
+			if s[j] == '\\' {
+				j++
+			}
+			if j < n && s[j] == '\n' {
+				line++
+			}
+			j++
+		}
+		panicIf(j >= n, "%s:%d: unterminated literal", path, line)
+		return j + 1
+	}
+	for i < n {
+		c := s[i]
+		switch {
+		case c == '\n':
+			line++
+			i++
+		case c == '/' && i+1 < n && s[i+1] == '/':
+			for i < n && s[i] != '\n' {
+				i++
+			}
+		case c == '/' && i+1 < n && s[i+1] == '*':
+			end := strings.Index(s[i+2:], "*/")
+			panicIf(end < 0, "%s:%d: unterminated comment", path, line)
+			comment := s[i : i+2+end+2]
+			line += strings.Count(comment, "\n")
+			i += len(comment)
+		case c == '"':
+			startLine := line
+			end := skipQuoted(i, '"')
+			res = append(res, cToken{cTokString, s[i+1 : end-1], startLine})
+			i = end
+		case c == '\'':
+			i = skipQuoted(i, '\'')
+		case isIdentStart(c):
+			start := i
+			for i < n && isIdentChar(s[i]) {
+				i++
+			}
+			ident := s[start:i]
+			isPrefix := ident == "L" || ident == "u8" || ident == "u" || ident == "U"
+			isRawPrefix := ident == "R" || ident == "LR" || ident == "u8R" || ident == "uR" || ident == "UR"
+			if i < n && s[i] == '"' && isPrefix {
+				// L"foo", the string is the next token
+				continue
+			}
+			if i < n && s[i] == '"' && isRawPrefix {
+				// R"delim(...)delim"
+				open := strings.IndexByte(s[i:], '(')
+				panicIf(open < 0, "%s:%d: invalid raw string", path, line)
+				delim := s[i+1 : i+open]
+				contentStart := i + open + 1
+				closing := ")" + delim + `"`
+				end := strings.Index(s[contentStart:], closing)
+				panicIf(end < 0, "%s:%d: unterminated raw string", path, line)
+				content := s[contentStart : contentStart+end]
+				res = append(res, cToken{cTokString, escapeCString(content), line})
+				line += strings.Count(content, "\n")
+				i = contentStart + end + len(closing)
+				continue
+			}
+			res = append(res, cToken{cTokIdent, ident, line})
+		case c == ' ' || c == '\t' || c == '\r':
+			i++
+		default:
+			res = append(res, cToken{cTokPunct, string(c), line})
+			i++
+		}
+	}
+	return res
+}
+
+// finds _TR("foo") etc. Calls whose argument is not a string literal
+// (e.g. _TR(s) in definition of _TR) are not strings to translate
+func extractTranslatableStringsFromSource(s string, path string) []*TranslatableString {
+	var res []*TranslatableString
+	toks := tokenizeCMust(s, path)
+	for i := 0; i+1 < len(toks); i++ {
+		t := toks[i]
+		if t.Kind != cTokIdent || !stringInSlice(transMacros, t.Text) {
+			continue
+		}
+		if toks[i+1].Kind != cTokPunct || toks[i+1].Text != "(" {
+			continue
+		}
+		j := i + 2
+		text := ""
+		for j < len(toks) && toks[j].Kind == cTokString {
+			text += toks[j].Text
+			j++
+		}
+		isCall := j > i+2 && j < len(toks) && toks[j].Kind == cTokPunct && toks[j].Text == ")"
+		if !isCall {
+			continue
+		}
+		res = append(res, &TranslatableString{
+			Text:  text,
+			Macro: t.Text,
+			Path:  path,
+			Line:  t.Line,
+		})
+		i = j
+	}
+	return res
+}
+
+// returns all occurrences, in order of files and lines
+func extractTranslatableStringsMust() []*TranslatableString {
+	var res []*TranslatableString
+	for _, path := range getFilesToProcess() {
+		d := readFileMust(path)
+		res = append(res, extractTranslatableStringsFromSource(string(d), path)...)
+	}
+	return res
+}
+
+// do trans strings
+func printTranslatableStrings() {
+	all := extractTranslatableStringsMust()
+	byText := map[string][]*TranslatableString{}
+	var texts []string
+	for _, ts := range all {
+		if byText[ts.Text] == nil {
+			texts = append(texts, ts.Text)
+		}
+		byText[ts.Text] = append(byText[ts.Text], ts)
+	}
+	sort.Strings(texts)
+	for _, text := range texts {
+		var locs []string
+		for _, ts := range byText[text] {
+			locs = append(locs, fmt.Sprintf("%s:%d %s", ts.Path, ts.Line, ts.Macro))
+		}
+		logf("\"%s\"\n  %s\n", text, strings.Join(locs, "\n  "))
+	}
+	logf("%d strings to translate, %d uses\n", len(texts), len(all))
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"fmt"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// release builds fail if translations of languages we ship are not
+// complete enough:
+// - every shipping language (i.e. in translations-good.txt) must have at
+//   least -trans-min-complete percent of strings in the sources translated
+// - strings added since the last release must be translated in
+//   topTranslationLangs
+// The report lists the strings that block the release.
+// -skip-trans-gate overrides it, do trans gate runs just the check
+
+var (
+	flgTransMinComplete = 75
+	flgSkipTransGate    bool
+)
+
+// languages with most users
+var topTranslationLangs = []string{"de", "fr", "es", "ru", "cn", "br", "it", "ja", "pl", "tw"}
+
+var translationsGoodPath = filepath.Join(translationsDir, "translations-good.txt")
+
+// returns the tag of the last release e.g. 3.5.2rel, "" if there are no
+// release tags
+func getLastReleaseTag() string {
+	out, err := exec.Command("git", "tag", "--list", "*rel", "--sort=-v:refname").Output()
+	if err != nil {
+		return ""
+	}
+	curr := sumatraVersion + "rel"
+	for _, tag := range toTrimmedLines(out) {
+		if tag != curr {
+			return tag
+		}
+	}
+	return ""
+}
+
+// returns strings to translate in sources at git tag
+func extractStringsAtTagMust(tag string) map[string]bool {
+	res := map[string]bool{}
+	out := runExeMust("git", "ls-tree", "--name-only", tag, "src/")
+	for _, path := range toTrimmedLines(out) {
+		if strings.ToLower(filepath.Ext(path)) != ".cpp" {
+			continue
+		}
+		d := runExeMust("git", "show", tag+":"+path)
+		for _, s := range extractTranslations(string(d)) {
+			res[s] = true
+		}
+	}
+	return res
+}
+
+// returns problems, one per blocked language, with strings that block it
+func checkTranslationGate(strs []string, newStrs []string, byLang map[string]map[string]string, shipping []string, minComplete int) []string {
+	var problems []string
+	for _, lang := range shipping {
+		m := byLang[lang]
+		var missing []string
+		for _, s := range strs {
+			if m[s] == "" {
+				missing = append(missing, s)
+			}
+		}
+		nTranslated := len(strs) - len(missing)
+		pct := 100
+		if len(strs) > 0 {
+			pct = nTranslated * 100 / len(strs)
+		}
+		if pct >= minComplete {
+			continue
+		}
+		problems = append(problems, fmt.Sprintf("%s: %d%% translated, below %d%%, missing %d strings:\n  %s", lang, pct, minComplete, len(missing), strings.Join(missing, "\n  ")))
+	}
+	for _, lang := range topTranslationLangs {
+		m := byLang[lang]
+		var missing []string
+		for _, s := range newStrs {
+			if m[s] == "" {
+				missing = append(missing, s)
+			}
+		}
+		if len(missing) > 0 {
+			problems = append(problems, fmt.Sprintf("%s: %d new strings since last release not translated:\n  %s", lang, len(missing), strings.Join(missing, "\n  ")))
+		}
+	}
+	return problems
+}
+
+func checkTranslationGateMust() {
+	if flgSkipTransGate {
+		logf("skipping translation gate because -skip-trans-gate\n")
+		return
+	}
+	strs := extractStringsFromCFilesNoPaths()
+	sort.Strings(strs)
+	byLang := translationsByLang(parseTranslations(string(readFileMust(translationsTxtPath))))
+	var shipping []string
+	for lang := range translationsByLang(parseTranslations(string(readFileMust(translationsGoodPath)))) {
+		shipping = append(shipping, lang)
+	}
+	sort.Strings(shipping)
+
+	var newStrs []string
+	if tag := getLastReleaseTag(); tag != "" {
+		prev := extractStringsAtTagMust(tag)
+		for _, s := range strs {
+			if !prev[s] {
+				newStrs = append(newStrs, s)
+			}
+		}
+		logf("translation gate: %d strings, %d new since %s, %d shipping languages\n", len(strs), len(newStrs), tag, len(shipping))
+	} else {
+		logf("translation gate: no release tag, not checking new strings\n")
+	}
+
+	problems := checkTranslationGate(strs, newStrs, byLang, shipping, flgTransMinComplete)
+	if len(problems) == 0 {
+		return
+	}
+	for _, p := range problems {
+		logf("%s\n", p)
+	}
+	failIf(errKindVerify, true, "translation gate: %d languages not translated enough. Get translations at https://www.apptranslator.org/app/SumatraPDF and run do trans download, or use -skip-trans-gate", len(problems))
+}
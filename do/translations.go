@@ -4,14 +4,13 @@ import (
 	"bytes"
 	"io/ioutil"
 	"path/filepath"
-	"regexp"
 	"strings"
 )
 
 func verifyTranslationsMust() {
 	d := downloadTranslationsMust()
 	curr := readFileMust(translationsTxtPath)
-	panicIf(!bytes.Equal(d, curr), "Translations did change!!!\nRun:\n.\\doit.bat -trans-dl\nto update translations\n")
+	panicIf(!bytes.Equal(d, curr), "Translations did change!!!\nRun:\n.\\doit.bat trans download\nto update translations\n")
 }
 
 // Translation describes a single translated text
@@ -101,22 +100,22 @@ func getFilesToProcess() []string {
 	return res
 }
 
-var (
-	translationPattern = regexp.MustCompile(`\b_TR[AN]?\("(.*?)"\)`)
-)
-
+// returns strings to translate in C source s
 func extractTranslations(s string) []string {
 	var res []string
-	a := translationPattern.FindAllStringSubmatch(s, -1)
-	for _, el := range a {
-		res = append(res, el[1])
+	for _, ts := range extractTranslatableStringsFromSource(s, "") {
+		res = append(res, ts.Text)
 	}
 	return res
 }
 
 func extractStringsFromCFile(path string) []string {
 	d := readFileMust(path)
-	return extractTranslations(string(d))
+	var res []string
+	for _, ts := range extractTranslatableStringsFromSource(string(d), path) {
+		res = append(res, ts.Text)
+	}
+	return res
 }
 
 func uniquifyStrings(a []string) []string {
@@ -132,12 +131,9 @@ func uniquifyStrings(a []string) []string {
 }
 
 func extractStringsFromCFilesNoPaths() []string {
-	filesToProcess := getFilesToProcess()
-	logf("Files to process: %d\n", len(filesToProcess))
 	var res []string
-	for _, path := range filesToProcess {
-		a := extractStringsFromCFile(path)
-		res = append(res, a...)
+	for _, ts := range extractTranslatableStringsMust() {
+		res = append(res, ts.Text)
 	}
 	res = uniquifyStrings(res)
 	logf("%d strings to translate\n", len(res))
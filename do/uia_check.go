@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// drives SumatraPDF via Windows UI Automation and checks that main window,
+// toolbar, tabs and menus expose names and roles. UIA tree is compared
+// against do/uia_baseline.txt so that we notice when we break accessibility
+// use -update-baseline to re-create the baseline after intentional changes
+
+// outputs one line per element: depth, control type, name
+const uiaDumpPs1 = `param([int]$procId, [int]$maxDepth)
+Add-Type -AssemblyName UIAutomationClient
+Add-Type -AssemblyName UIAutomationTypes
+$p = Get-Process -Id $procId
+$root = [System.Windows.Automation.AutomationElement]::FromHandle($p.MainWindowHandle)
+$walker = [System.Windows.Automation.TreeWalker]::ControlViewWalker
+
+function Dump($el, $depth) {
+    $ct = $el.Current.ControlType.ProgrammaticName -replace "^ControlType\.", ""
+    $name = $el.Current.Name -replace "[\r\n\t]", " "
+    Write-Output ("{0}` + "`" + `t{1}` + "`" + `t{2}" -f $depth, $ct, $name)
+    if ($depth -ge $maxDepth) { return }
+    $child = $walker.GetFirstChild($el)
+    while ($child -ne $null) {
+        Dump $child ($depth + 1)
+        $child = $walker.GetNextSibling($child)
+    }
+}
+Dump $root 0
+`
+
+// UIA tree deeper than that is document content
+const uiaMaxDepth = 5
+
+// UiaScenario is UI state in which we check UIA tree
+type UiaScenario struct {
+	Name     string
+	Settings string
+	NumDocs  int
+}
+
+var uiaScenarios = []*UiaScenario{
+	{Name: "tabs", Settings: "UseTabs = true\nShowToolbar = true", NumDocs: 2},
+	{Name: "menu", Settings: "UseTabs = false\nShowMenubar = true\nShowToolbar = true", NumDocs: 1},
+}
+
+// UI elements with those roles must have a name
+var uiaMustHaveName = []string{"Window", "Button", "SplitButton", "MenuItem", "TabItem", "ToolBar", "Edit"}
+
+// UiaElement is an element of UIA tree
+type UiaElement struct {
+	Depth       int
+	ControlType string
+	Name        string
+}
+
+// key used to compare with the baseline
+func (e *UiaElement) Key(scenario string) string {
+	return fmt.Sprintf("%s %s %q", scenario, e.ControlType, e.Name)
+}
+
+func parseUiaDump(s string) []*UiaElement {
+	var res []*UiaElement
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		parts := strings.SplitN(line, "\t", 3)
+		if len(parts) != 3 {
+			continue
+		}
+		depth, err := strconv.Atoi(parts[0])
+		if err != nil {
+			continue
+		}
+		e := &UiaElement{
+			Depth:       depth,
+			ControlType: parts[1],
+			Name:        strings.TrimSpace(parts[2]),
+		}
+		res = append(res, e)
+	}
+	return res
+}
+
+func dumpUiaTreeMust(exe string, ps1Path string, docs []string, sc *UiaScenario, outDir string) []*UiaElement {
+	appDataDir := filepath.Join(outDir, "appdata-"+sc.Name)
+	cmd := startSumatraWithSettingsMust(exe, appDataDir, sc.Settings, docs[:sc.NumDocs])
+	defer killCmd(cmd)
+	time.Sleep(time.Second * 3)
+
+	args := []string{"-ExecutionPolicy", "Bypass", "-File", ps1Path, strconv.Itoa(cmd.Process.Pid), strconv.Itoa(uiaMaxDepth)}
+	psCmd := exec.Command("powershell.exe", args...)
+	out, err := psCmd.CombinedOutput()
+	panicIf(err != nil, "%s failed with '%s', output:\n%s\n", psCmd.String(), err, string(out))
+	writeFileMust(filepath.Join(outDir, sc.Name+".txt"), out)
+	return parseUiaDump(string(out))
+}
+
+func checkUia(dir string, updateBaseline bool) {
+	exe := absPathMust(filepath.Join(dir, "SumatraPDF.exe"))
+	panicIf(!fileExists(exe), "'%s' doesn't exist", exe)
+
+	outDir := absPathMust(filepath.Join("out", "uia-check"))
+	must(os.RemoveAll(outDir))
+	docsDir := filepath.Join(outDir, "docs")
+	createDirMust(docsDir)
+	docs := genScreenshotDocsMust(docsDir)
+	ps1Path := filepath.Join(outDir, "uia-dump.ps1")
+	writeFileMust(ps1Path, []byte(uiaDumpPs1))
+
+	var errs []string
+	current := map[string]bool{}
+	for _, sc := range uiaScenarios {
+		elements := dumpUiaTreeMust(exe, ps1Path, docs, sc, outDir)
+		logf("%s: %d UIA elements\n", sc.Name, len(elements))
+		for _, e := range elements {
+			if e.Name == "" && stringInSlice(uiaMustHaveName, e.ControlType) {
+				errs = append(errs, fmt.Sprintf("%s: %s at depth %d has no name", sc.Name, e.ControlType, e.Depth))
+				continue
+			}
+			if e.Name != "" {
+				current[e.Key(sc.Name)] = true
+			}
+		}
+	}
+
+	baselinePath := filepath.Join("do", "uia_baseline.txt")
+	if updateBaseline {
+		var lines []string
+		for k := range current {
+			lines = append(lines, k)
+		}
+		sort.Strings(lines)
+		s := "# UIA elements expected in SumatraPDF, re-generate with: do check uia -update-baseline\n"
+		s += strings.Join(lines, "\n") + "\n"
+		writeFileMust(baselinePath, []byte(s))
+		logf("wrote '%s' with %d elements\n", baselinePath, len(lines))
+	} else if fileExists(baselinePath) {
+		for _, line := range strings.Split(string(readFileMust(baselinePath)), "\n") {
+			line = strings.TrimSpace(line)
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			if !current[line] {
+				errs = append(errs, fmt.Sprintf("missing: %s", line))
+			}
+		}
+	} else {
+		logf("'%s' doesn't exist, create it with -update-baseline\n", baselinePath)
+	}
+	panicIf(len(errs) > 0, "UIA check failed:\n%s\n", strings.Join(errs, "\n"))
+	logf("UIA check passed, UIA trees saved in '%s'\n", outDir)
+}
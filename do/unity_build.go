@@ -0,0 +1,157 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// unity (jumbo) build: instead of compiling each .cpp file separately we
+// compile out/unity/<project>/unity_N.cpp files that #include many .cpp files.
+// This is much faster for full rebuilds because common headers are parsed
+// only once per unity file
+// files that don't compile when combined (e.g. because of static functions
+// with the same name) are listed in do/unity_exclude.txt
+// do build unity does a Debug x64 build using unity files. vs2022 projects are
+// not modified: we inject a .targets file that replaces the files
+// with unity files via ForceImportBeforeCppTargets
+
+const (
+	unityConfig = "Debug|x64"
+	// number of .cpp files per unity file. Bigger is faster for full builds
+	// but slower for incremental builds
+	unityFilesPerChunk = 24
+)
+
+var unityDir = filepath.Join("out", "unity")
+
+func readUnityExcludes() []string {
+	var res []string
+	path := filepath.Join("do", "unity_exclude.txt")
+	if !fileExists(path) {
+		return res
+	}
+	for _, line := range strings.Split(string(readFileMust(path)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		res = append(res, line)
+	}
+	return res
+}
+
+func isUnityExcluded(path string, excludes []string) bool {
+	for _, pattern := range excludes {
+		if matchSuppressionPattern(pattern, path) {
+			return true
+		}
+	}
+	return false
+}
+
+// UnityFile is a generated file that includes Sources
+type UnityFile struct {
+	Path    string
+	Sources []string
+}
+
+// groups .cpp and .c files of a project into unity files. c and c++ files
+// must be in separate unity files
+func groupIntoUnityFiles(project string, files []string) []*UnityFile {
+	byExt := map[string][]string{}
+	for _, f := range files {
+		ext := strings.ToLower(filepath.Ext(f))
+		byExt[ext] = append(byExt[ext], f)
+	}
+	var res []*UnityFile
+	for _, ext := range []string{".c", ".cpp"} {
+		srcs := byExt[ext]
+		sort.Strings(srcs)
+		for i := 0; i < len(srcs); i += unityFilesPerChunk {
+			end := i + unityFilesPerChunk
+			if end > len(srcs) {
+				end = len(srcs)
+			}
+			name := fmt.Sprintf("unity_%d%s", len(res), ext)
+			res = append(res, &UnityFile{
+				Path:    filepath.Join(unityDir, project, name),
+				Sources: srcs[i:end],
+			})
+		}
+	}
+	return res
+}
+
+func writeUnityFileMust(uf *UnityFile) {
+	s := "// auto-generated by: do gen unity\n"
+	dir := filepath.Dir(uf.Path)
+	for _, src := range uf.Sources {
+		rel, err := filepath.Rel(dir, filepath.FromSlash(src))
+		must(err)
+		s += fmt.Sprintf("#include \"%s\"\n", filepath.ToSlash(rel))
+	}
+	must(createDirForFile(uf.Path))
+	writeFileMust(uf.Path, []byte(s))
+}
+
+// returns path of .targets file for ForceImportBeforeCppTargets
+func genUnityFiles() string {
+	must(os.RemoveAll(unityDir))
+	excludes := readUnityExcludes()
+	projects, err := filepath.Glob(filepath.Join("vs2022", "*.vcxproj"))
+	must(err)
+	sort.Strings(projects)
+	root := currDirAbsMust()
+
+	targets := `<?xml version="1.0" encoding="utf-8"?>` + "\r\n"
+	targets += `<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">` + "\r\n"
+	nFiles, nUnity := 0, 0
+	for _, proj := range projects {
+		cmds, err := compileCommandsForVcxproj(proj, unityConfig)
+		panicIf(err != nil, "failed to parse '%s': %s", proj, err)
+		var files []string
+		for _, c := range cmds {
+			rel, err := filepath.Rel(root, c.File)
+			must(err)
+			rel = filepath.ToSlash(rel)
+			if !isUnityExcluded(rel, excludes) {
+				files = append(files, rel)
+			}
+		}
+		// not worth it for projects with just a few files
+		if len(files) < 2 {
+			continue
+		}
+		name := strings.TrimSuffix(filepath.Base(proj), ".vcxproj")
+		unityFiles := groupIntoUnityFiles(name, files)
+		targets += fmt.Sprintf("  <ItemGroup Condition=\"'$(ProjectName)'=='%s'\">\r\n", name)
+		for _, f := range files {
+			targets += fmt.Sprintf("    <ClCompile Remove=\"%s\" />\r\n", vcxprojInclude(f))
+		}
+		for _, uf := range unityFiles {
+			writeUnityFileMust(uf)
+			targets += fmt.Sprintf("    <ClCompile Include=\"%s\" />\r\n", absPathMust(uf.Path))
+		}
+		targets += "  </ItemGroup>\r\n"
+		logf("%s: %d files in %d unity files\n", name, len(files), len(unityFiles))
+		nFiles += len(files)
+		nUnity += len(unityFiles)
+	}
+	targets += "</Project>\r\n"
+	targetsPath := filepath.Join(unityDir, "unity.targets")
+	writeFileMust(targetsPath, []byte(targets))
+	logf("%d files in %d unity files, wrote '%s'\n", nFiles, nUnity, targetsPath)
+	return targetsPath
+}
+
+func buildUnity() {
+	targetsPath := absPathMust(genUnityFiles())
+	msbuildPath := detectMsbuildPath()
+	slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
+	parts := strings.Split(unityConfig, "|")
+	p := fmt.Sprintf(`/p:Configuration=%s;Platform=%s`, parts[0], parts[1])
+	runExeLoggedMust(msbuildPath, slnPath, `/t:SumatraPDF`, p, "/p:ForceImportBeforeCppTargets="+targetsPath, `/m`)
+}
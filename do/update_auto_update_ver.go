@@ -5,15 +5,16 @@ import (
 	"path/filepath"
 	"strconv"
 	"strings"
-
-	"github.com/kjk/minioutil"
 )
 
 // Format of auto-update file:
 /*
 [SumatraPDF]
 Latest 3.1.2
+Rollout 25
 */
+// Rollout is optional, only written for partial rollout (see IsInRollout()
+// in src/UpdateCheck.cpp). Versions before it was added ignore it
 
 /*
 for 3.1.2 and earlier, we upload:
@@ -23,8 +24,8 @@ https://kjkpub.s3.amazonaws.com/sumatrapdf/sumpdf-latest.txt
 for 3.2 and later, we use
 https://www.sumatrapdfreader.org/update-check-rel.txt
 
-This script uploads to s3 and updates website/update-check-rel.txt,
-which must be then deployed.
+This script uploads to s3 and updates server/www/update-check-rel.txt
+in sumatra-website repo, which is deployed on push.
 */
 
 // ver should be in format:
@@ -42,17 +43,32 @@ func validateVer(ver string) {
 	}
 }
 
+func validateRolloutPercentMust(n int) {
+	panicIf(n < 1 || n > 100, "invalid rollout percent %d", n)
+}
+
 func updateAutoUpdateVer(ver string) {
+	updateAutoUpdateVerWithRollout(ver, 100)
+}
+
+// rolloutPercent < 100 means only that percentage of users is offered the
+// update by automatic update check
+func updateAutoUpdateVerWithRollout(ver string, rolloutPercent int) {
 	validateVer(ver)
+	validateRolloutPercentMust(rolloutPercent)
+	verifyAutoUpdateTargetMust(ver)
 	// TODO: verify it's bigger than the current version
 	// TODO: add download links
 	s := fmt.Sprintf(`[SumatraPDF]
 Latest %s
 `, ver)
+	if rolloutPercent < 100 {
+		s += fmt.Sprintf("Rollout %d\n", rolloutPercent)
+	}
 	fmt.Printf("Content of update file:\n%s\n\n", s)
 	d := []byte(s)
 
-	uploadInfo := func(mc *minioutil.Client) {
+	uploadInfo := func(mc Storage) {
 		{
 			remotePath := "sumatrapdf/sumpdf-update.txt"
 			_, err := mc.UploadData(remotePath, d, true)
@@ -71,8 +87,11 @@ Latest %s
 	uploadInfo(newMinioBackblazeClient())
 	uploadInfo(newMinioR2Client())
 
-	path := filepath.Join("website", "update-check-rel.txt")
+	websiteDir := updateSumatraWebsite()
+	path := filepath.Join(websiteDir, "update-check-rel.txt")
 	writeFileMust(path, []byte(s))
-
-	fmt.Printf("Don't forget to checkin file '%s' and deploy website\n", path)
+	msg := fmt.Sprintf("update check: %s, rollout %d%%", ver, rolloutPercent)
+	if !commitAndPushWebsiteMust(websiteDir, msg) {
+		logf("'%s' didn't change\n", path)
+	}
 }
@@ -0,0 +1,74 @@
+package main
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// before we tell users to update to a new version we make sure
+// the files we'll point them to exist and are what we think they are
+
+func sha256Hex(d []byte) string {
+	h := sha256.Sum256(d)
+	return hex.EncodeToString(h[:])
+}
+
+// "3.5" => "3.5.0.0"
+func verToFileVersion(ver string) string {
+	parts := strings.Split(ver, ".")
+	for len(parts) < 4 {
+		parts = append(parts, "0")
+	}
+	return strings.Join(parts, ".")
+}
+
+func verifySignatureMust(path string) {
+	signtoolPath := detectSigntoolPath()
+	cmd := exec.Command(signtoolPath, "verify", "/pa", "/q", path)
+	out, err := cmd.CombinedOutput()
+	panicIf(err != nil, "signature verification of '%s' failed with '%s'. Output:\n%s\n", path, err, string(out))
+}
+
+// download files the update check will point to, verify they are signed,
+// have the right version and the same content as what's in storage
+func verifyAutoUpdateTargetMust(ver string) {
+	logf("verifyAutoUpdateTargetMust: %s\n", ver)
+	urls := getDownloadUrlsViaWebsite(buildTypeRel, ver)
+	toVerify := []string{
+		urls.installer64,
+		urls.portableExe64,
+		urls.installerArm64,
+		urls.portableExeArm64,
+		urls.installer32,
+		urls.portableExe32,
+	}
+
+	mc := newMinioR2Client()
+	remoteDir := "software/sumatrapdf/rel/" + ver + "/"
+	expectedVer := verToFileVersion(ver)
+	tmpDir := filepath.Join("out", "verify-update", ver)
+	must(os.MkdirAll(tmpDir, 0755))
+	for _, uri := range toVerify {
+		name := filepath.Base(uri)
+		d := httpGetMust(uri)
+		dStorage := httpGetMust(mc.URLForPath(remoteDir + name))
+		h1 := sha256Hex(d)
+		h2 := sha256Hex(dStorage)
+		panicIf(h1 != h2, "'%s' has sha256 %s but the file in storage has %s", uri, h1, h2)
+
+		fileVer, err := peFileVersion(d)
+		panicIf(err != nil, "couldn't get version of '%s': %s", uri, err)
+		panicIf(fileVer != expectedVer, "'%s' has version %s, expected %s", uri, fileVer, expectedVer)
+
+		path := filepath.Join(tmpDir, name)
+		writeFileMust(path, d)
+		verifySignatureMust(path)
+		logf("verified %s, size: %s, sha256: %s, ver: %s\n", uri, formatSize(int64(len(d))), h1, fileVer)
+	}
+	fmt.Printf("All files for version %s verified\n", ver)
+}
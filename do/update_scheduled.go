@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Promotion of a release to the auto-update channel can be scheduled in advance.
+// Schedule is stored in the bucket so that it can be executed by CI calling
+// "do release tick" on a cron schedule (.github/workflows/release-tick.yml).
+
+const scheduledPromotionsRemotePath = "software/sumatrapdf/scheduled-promotions.json"
+
+type ScheduledPromotion struct {
+	Ver            string    `json:"ver"`
+	At             time.Time `json:"at"`
+	RolloutPercent int       `json:"rolloutPercent"`
+	Done           bool      `json:"done"`
+	DoneAt         time.Time `json:"doneAt,omitempty"`
+}
+
+func loadScheduledPromotionsMust(mc Storage) []*ScheduledPromotion {
+	var res []*ScheduledPromotion
+	if !mc.Exists(scheduledPromotionsRemotePath) {
+		return res
+	}
+	d := minioDownloadDataMust(mc, scheduledPromotionsRemotePath)
+	err := json.Unmarshal(d, &res)
+	must(err)
+	return res
+}
+
+func saveScheduledPromotionsMust(mc Storage, a []*ScheduledPromotion) {
+	d, err := json.MarshalIndent(a, "", "  ")
+	must(err)
+	_, err = mc.UploadData(scheduledPromotionsRemotePath, d, false)
+	must(err)
+	logf("Saved %d scheduled promotions to '%s'\n", len(a), mc.URLForPath(scheduledPromotionsRemotePath))
+}
+
+// s is "${ver}" or "${ver},${time}" or "${ver},${time},${rolloutPercent}"
+// ${time} is in RFC 3339 format e.g. 2024-05-03T10:00:00Z
+func parseScheduledPromotionMust(s string) *ScheduledPromotion {
+	parts := strings.Split(s, ",")
+	panicIf(len(parts) > 3, "invalid promotion '%s', must be 'ver[,time[,rolloutPercent]]'", s)
+	res := &ScheduledPromotion{
+		Ver:            strings.TrimSpace(parts[0]),
+		At:             time.Now().UTC(),
+		RolloutPercent: 100,
+	}
+	validateVer(res.Ver)
+	if len(parts) > 1 {
+		t, err := time.Parse(time.RFC3339, strings.TrimSpace(parts[1]))
+		panicIf(err != nil, "invalid time in '%s': %s", s, err)
+		res.At = t.UTC()
+	}
+	if len(parts) > 2 {
+		v := strings.TrimSuffix(strings.TrimSpace(parts[2]), "%")
+		n, err := strconv.Atoi(v)
+		panicIf(err != nil, "invalid rollout percent in '%s'", s)
+		validateRolloutPercentMust(n)
+		res.RolloutPercent = n
+	}
+	return res
+}
+
+func schedulePromotion(s string) {
+	ensureAllUploadCreds()
+	p := parseScheduledPromotionMust(s)
+	// verify early so that we don't schedule something that will fail
+	verifyAutoUpdateTargetMust(p.Ver)
+	mc := newMinioBackblazeClient()
+	a := loadScheduledPromotionsMust(mc)
+	a = append(a, p)
+	saveScheduledPromotionsMust(mc, a)
+	logf("Scheduled promotion of %s at %s with %d%% rollout\n", p.Ver, p.At.Format(time.RFC3339), p.RolloutPercent)
+}
+
+func printScheduledPromotions() {
+	ensureAllUploadCreds()
+	a := loadScheduledPromotionsMust(newMinioBackblazeClient())
+	if len(a) == 0 {
+		logf("No scheduled promotions\n")
+		return
+	}
+	for _, p := range a {
+		status := "pending"
+		if p.Done {
+			status = "done at " + p.DoneAt.Format(time.RFC3339)
+		}
+		fmt.Printf("%s at %s, rollout: %d%%, %s\n", p.Ver, p.At.Format(time.RFC3339), p.RolloutPercent, status)
+	}
+}
+
+// executes promotions whose time has come. Meant to be called from cron
+func tickScheduledPromotions() {
+	ensureAllUploadCreds()
+	runDuePromotionsMust(newMinioBackblazeClient(), time.Now().UTC(), updateAutoUpdateVerWithRollout)
+}
+
+// promotes with promote() versions scheduled at or before now and marks
+// them as done in mc. Each promotion is saved as done right after it's done
+// so that if a later one fails, it's not repeated by the next tick
+func runDuePromotionsMust(mc Storage, now time.Time, promote func(ver string, rolloutPercent int)) {
+	a := loadScheduledPromotionsMust(mc)
+	sort.SliceStable(a, func(i, j int) bool {
+		return a[i].At.Before(a[j].At)
+	})
+	nDone := 0
+	for _, p := range a {
+		if p.Done || p.At.After(now) {
+			continue
+		}
+		logf("tick: promoting %s, rollout: %d%%\n", p.Ver, p.RolloutPercent)
+		promote(p.Ver, p.RolloutPercent)
+		p.Done = true
+		p.DoneAt = now
+		saveScheduledPromotionsMust(mc, a)
+		nDone++
+	}
+	if nDone == 0 {
+		logf("tick: no pending promotions\n")
+	}
+}
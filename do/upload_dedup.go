@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// many files don't change between pre-release builds (e.g. PdfFilter.dll
+// when only SumatraPDF.exe code changed). With each build we upload sha256
+// of uploaded files. When uploading the next build, files with the same hash
+// as in the previous build are copied on the server instead of uploaded
+
+// in the remote dir of each build, maps file name to sha256
+const uploadHashesFileName = "upload-hashes.json"
+
+func calcDirHashesMust(dir string) map[string]string {
+	files, err := os.ReadDir(dir)
+	must(err)
+	res := map[string]string{}
+	for _, f := range files {
+		if f.IsDir() {
+			continue
+		}
+		res[f.Name()] = sha256Hex(readFileMust(filepath.Join(dir, f.Name())))
+	}
+	return res
+}
+
+// returns remote dir and hashes of the most recent build uploaded before ver
+// "" and nil if there isn't one
+func findPrevUploadHashes(mc Storage, buildType BuildType, ver int) (string, map[string]string) {
+	prefix := "software/sumatrapdf/" + string(buildType) + "/"
+	prevVer := 0
+	prevDir := ""
+	for obj := range mc.ListObjects(prefix) {
+		// software/sumatrapdf/prerel/14028/upload-hashes.json
+		if path.Base(obj.Key) != uploadHashesFileName {
+			continue
+		}
+		dir := path.Dir(obj.Key)
+		v, err := strconv.Atoi(path.Base(dir))
+		if err != nil || v >= ver || v <= prevVer {
+			continue
+		}
+		prevVer = v
+		prevDir = dir + "/"
+	}
+	if prevDir == "" {
+		return "", nil
+	}
+	var res map[string]string
+	d := minioDownloadDataMust(mc, prevDir+uploadHashesFileName)
+	if err := json.Unmarshal(d, &res); err != nil {
+		logf("failed to parse '%s': %s\n", prevDir+uploadHashesFileName, err)
+		return "", nil
+	}
+	return prevDir, res
+}
+
+// like UploadDir but files identical to those in previous build are
+// copied on the server
+func uploadDirDedupMust(mc Storage, buildType BuildType, dirRemote string, dirLocal string, log *TaskLogger) {
+	defer failOnPanic(errKindUpload)
+	hashes := calcDirHashesMust(dirLocal)
+	ver, err := strconv.Atoi(getVerForBuildType(buildType))
+	must(err)
+	prevDir, prevHashes := findPrevUploadHashes(mc, buildType, ver)
+	if prevDir != "" {
+		log.Logf("comparing with previous build in '%s'\n", prevDir)
+	}
+
+	var names []string
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	var nCopied int
+	var sizeCopied int64
+	progress := loadUploadProgress(mc)
+	for _, name := range names {
+		pathLocal := filepath.Join(dirLocal, name)
+		pathRemote := path.Join(dirRemote, name)
+		if progress.IsUploaded(pathRemote, hashes[name]) {
+			log.Logf("Skipping %s, uploaded by previous run\n", pathLocal)
+			continue
+		}
+		waitForUploadSlot(log)
+		timeStart := time.Now()
+		if prevHash, ok := prevHashes[name]; ok && prevHash == hashes[name] {
+			_, err := mc.Copy(prevDir+name, pathRemote)
+			if err == nil {
+				nCopied++
+				sizeCopied += fileSizeMust(pathLocal)
+				progress.MarkUploaded(pathRemote, hashes[name])
+				log.Logf("Copied unchanged %s => %s in %s\n", prevDir+name, pathRemote, time.Since(timeStart))
+				continue
+			}
+			log.Logf("Copying '%s' failed with '%s', uploading instead\n", prevDir+name, err)
+		}
+		_, err := mc.UploadFile(pathRemote, pathLocal, true)
+		panicIf(err != nil, "upload of '%s' as '%s' failed with '%s'", pathLocal, pathRemote, err)
+		progress.MarkUploaded(pathRemote, hashes[name])
+		log.Logf("Uploaded %s => %s in %s\n", pathLocal, mc.URLForPath(pathRemote), time.Since(timeStart))
+	}
+
+	mergePriorUploadHashes(mc, dirRemote, hashes)
+	d, err := json.MarshalIndent(hashes, "", "  ")
+	must(err)
+	_, err = mc.UploadData(path.Join(dirRemote, uploadHashesFileName), d, false)
+	must(err)
+	if nCopied > 0 {
+		log.Logf("%d of %d files unchanged since previous build, saved uploading %s\n", nCopied, len(names), formatSize(sizeCopied))
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"encoding/json"
 	"fmt"
 	"io/ioutil"
 	"os"
@@ -12,7 +13,6 @@ import (
 	"sync"
 	"time"
 
-	"github.com/kjk/minioutil"
 	"github.com/kjk/u"
 )
 
@@ -174,14 +174,14 @@ func getDownloadUrlsViaWebsite(buildType BuildType, ver string) *DownloadUrls {
 	return getDownloadUrlsForPrefix(prefix, buildType, ver)
 }
 
-func getDownloadUrlsDirectS3(mc *minioutil.Client, buildType BuildType, ver string) *DownloadUrls {
+func getDownloadUrlsDirectS3(mc Storage, buildType BuildType, ver string) *DownloadUrls {
 	prefix := mc.URLBase()
 	prefix += getRemoteDir(buildType)
 	return getDownloadUrlsForPrefix(prefix, buildType, ver)
 }
 
 // sumatrapdf/sumatralatest.js
-func createSumatraLatestJs(mc *minioutil.Client, buildType BuildType) string {
+func createSumatraLatestJs(mc Storage, buildType BuildType) string {
 	var appName string
 	switch buildType {
 	case buildTypePreRel:
@@ -252,7 +252,7 @@ var sumLatestInstallerArm64 = "{{.Host}}/{{.Prefix}}-arm64-install.exe";
 	return execTextTemplate(tmplText, d)
 }
 
-func getVersionFilesForLatestInfo(mc *minioutil.Client, buildType BuildType) [][]string {
+func getVersionFilesForLatestInfo(mc Storage, buildType BuildType) [][]string {
 	panicIf(buildType == buildTypeRel)
 	remotePaths := getRemotePaths(buildType)
 	var res [][]string
@@ -284,7 +284,7 @@ func getVersionFilesForLatestInfo(mc *minioutil.Client, buildType BuildType) [][
 
 // we shouldn't re-upload files. We upload manifest-${ver}.txt last, so we
 // consider a pre-release build already present in s3 if manifest file exists
-func isBuildAlreadyUploaded(mc *minioutil.Client, buildType BuildType) bool {
+func isBuildAlreadyUploaded(mc Storage, buildType BuildType) bool {
 	dirRemote := getRemoteDir(buildType)
 	ver := getVerForBuildType(buildType)
 	fname := "SumatraPDF-prerel-manifest.txt"
@@ -300,27 +300,53 @@ func isBuildAlreadyUploaded(mc *minioutil.Client, buildType BuildType) bool {
 	return exists
 }
 
-func verifyBuildNotInStorageMust(mc *minioutil.Client, buildType BuildType) {
+// sha of the last daily build, so that we don't rebuild if there were no commits
+const dailyLastShaRemotePath = "software/sumatrapdf/daily-last-sha.txt"
+
+func getDailyLastSha(mc Storage) string {
+	if !mc.Exists(dailyLastShaRemotePath) {
+		return ""
+	}
+	return strings.TrimSpace(string(minioDownloadDataMust(mc, dailyLastShaRemotePath)))
+}
+
+// called after daily build was uploaded, by the profile or, in CI where
+// upload is a separate step, by: do build ci-upload -daily
+func setDailyLastShaMust(sha string) {
+	_, err := newMinioR2Client().UploadData(dailyLastShaRemotePath, []byte(sha), false)
+	must(err)
+	logf("recorded '%s' as last daily build\n", sha)
+}
+
+func verifyBuildNotInStorageMust(mc Storage, buildType BuildType) {
 	exists := isBuildAlreadyUploaded(mc, buildType)
 	panicIf(exists, "build already exists")
 }
 
-func UploadDir(c *minioutil.Client, dirRemote string, dirLocal string, public bool) error {
+func UploadDir(c Storage, dirRemote string, dirLocal string, public bool, log *TaskLogger) error {
 	files, err := ioutil.ReadDir(dirLocal)
 	if err != nil {
 		return err
 	}
+	progress := loadUploadProgress(c)
 	for _, f := range files {
 		fname := f.Name()
 		pathLocal := filepath.Join(dirLocal, fname)
 		pathRemote := path.Join(dirRemote, fname)
+		sha := sha256Hex(readFileMust(pathLocal))
+		if progress.IsUploaded(pathRemote, sha) {
+			log.Logf("Skipping %s, uploaded by previous run\n", pathLocal)
+			continue
+		}
+		waitForUploadSlot(log)
 		timeStart := time.Now()
 		_, err := c.UploadFile(pathRemote, pathLocal, public)
 		if err != nil {
 			return fmt.Errorf("upload of '%s' as '%s' failed with '%s'", pathLocal, pathRemote, err)
 		}
+		progress.MarkUploaded(pathRemote, sha)
 		uri := c.URLForPath(pathRemote)
-		logf("Uploaded %s => %s in %s\n", pathLocal, uri, time.Since(timeStart))
+		log.Logf("Uploaded %s => %s in %s\n", pathLocal, uri, time.Since(timeStart))
 	}
 	return nil
 }
@@ -341,23 +367,33 @@ func getFinalDirForBuildType(buildType BuildType) string {
 }
 
 // https://kjkpubsf.sfo2.digitaloceanspaces.com/software/sumatrapdf/prerel/1024/SumatraPDF-prerelease-install.exe etc.
-func minioUploadBuildMust(mc *minioutil.Client, buildType BuildType) {
+func minioUploadBuildMust(mc Storage, buildType BuildType, log *TaskLogger) {
+	defer failOnPanic(errKindUpload)
 	timeStart := time.Now()
 	defer func() {
-		logf("Uploaded build '%s' to %s in %s\n", buildType, mc.URLBase(), time.Since(timeStart))
+		log.Logf("Uploaded build '%s' to %s in %s\n", buildType, mc.URLBase(), time.Since(timeStart))
 	}()
 
 	dirRemote := getRemoteDir(buildType)
 	dirLocal := getFinalDirForBuildType(buildType)
 
-	err := UploadDir(mc, dirRemote, dirLocal, true)
-	must(err)
-
-	// for release build we don't upload files with version info
 	if buildType == buildTypeRel {
-		logf("Skipping uploading version for release builds\n")
+		err := UploadDir(mc, dirRemote, dirLocal, true, log)
+		must(err)
+		// for the download page
+		hashes := calcDirHashesMust(dirLocal)
+		mergePriorUploadHashes(mc, dirRemote, hashes)
+		d, err := json.MarshalIndent(hashes, "", "  ")
+		must(err)
+		_, err = mc.UploadData(path.Join(dirRemote, uploadHashesFileName), d, false)
+		must(err)
+		uploadCommandAuditMust(mc, dirRemote, log)
+		loadUploadProgress(mc).Clear()
+		// for release build we don't upload files with version info
+		log.Logf("Skipping uploading version for release builds\n")
 		return
 	}
+	uploadDirDedupMust(mc, buildType, dirRemote, dirLocal, log)
 
 	uploadBuildUpdateInfoMust := func(buildType BuildType) {
 		files := getVersionFilesForLatestInfo(mc, buildType)
@@ -365,11 +401,13 @@ func minioUploadBuildMust(mc *minioutil.Client, buildType BuildType) {
 			remotePath := f[0]
 			_, err := mc.UploadData(remotePath, []byte(f[1]), true)
 			must(err)
-			logf("Uploaded `%s'\n", mc.URLForPath(remotePath))
+			log.Logf("Uploaded `%s'\n", mc.URLForPath(remotePath))
 		}
 	}
 
+	uploadCommandAuditMust(mc, dirRemote, log)
 	uploadBuildUpdateInfoMust(buildType)
+	loadUploadProgress(mc).Clear()
 }
 
 type filesByVer struct {
@@ -411,7 +449,7 @@ func groupFilesByVersion(files []string) []*filesByVer {
 	return res
 }
 
-func minioDeleteOldBuildsPrefix(mc *minioutil.Client, buildType BuildType) {
+func minioDeleteOldBuildsPrefix(mc Storage, buildType BuildType, log *TaskLogger) {
 	nBuildsToRetain := nBuildsToRetainPreRel
 	var remoteDir string
 	switch buildType {
@@ -427,60 +465,51 @@ func minioDeleteOldBuildsPrefix(mc *minioutil.Client, buildType BuildType) {
 	objectsCh := mc.ListObjects(remoteDir)
 	var keys []string
 	for f := range objectsCh {
+		// symbols are retained longer, see pruneSymbolsStorage()
+		if isSymbolsFile(f.Key) {
+			continue
+		}
 		keys = append(keys, f.Key)
 		//logf("  %s\n", f.Key)
 	}
 
 	uri := mc.URLForPath(remoteDir)
-	logf("%d files under '%s'\n", len(keys), uri)
+	log.Logf("%d files under '%s'\n", len(keys), uri)
 	byVer := groupFilesByVersion(keys)
 	for i, v := range byVer {
 		deleting := (i >= nBuildsToRetain)
 		if deleting {
-			logf("deleting %d\n", v.ver)
+			log.Logf("deleting %d\n", v.ver)
 			if true {
 				for _, key := range v.files {
 					err := mc.Remove(key)
 					must(err)
-					logf("  deleted %s\n", key)
+					log.Logf("  deleted %s\n", key)
 				}
 			}
 		} else {
-			logf("not deleting %d\n", v.ver)
+			log.Logf("not deleting %d\n", v.ver)
 		}
 	}
 }
 
-func newMinioBackblazeClient() *minioutil.Client {
-	config := &minioutil.Config{
-		Bucket:   "kjk-files",
-		Endpoint: "s3.us-west-001.backblazeb2.com",
-		Access:   b2Access,
-		Secret:   b2Secret,
-	}
-	mc, err := minioutil.New(config)
-	must(err)
-	return mc
-}
-
-func newMinioR2Client() *minioutil.Client {
-	config := &minioutil.Config{
-		Bucket:   "files",
-		Endpoint: "71694ef61795ecbe1bc331d217dbd7a7.r2.cloudflarestorage.com",
-		Access:   r2Access,
-		Secret:   r2Secret,
-	}
-	mc, err := minioutil.New(config)
+func minioDownloadDataMust(mc Storage, remotePath string) []byte {
+	d, err := mc.DownloadData(remotePath)
 	must(err)
-	return mc
+	return d
 }
 
-func uploadToStorage(buildType BuildType) {
-	isUploaded := isBuildAlreadyUploaded(newMinioBackblazeClient(), buildType)
+// returns false if the build was already uploaded
+func uploadToStorage(buildType BuildType) bool {
+	verifyOutDirsBranchMust()
+	// respin of some platforms (-platforms) re-uploads to existing build
+	isUploaded := !isPartialPlatformsBuild() && isBuildAlreadyUploaded(newMinioBackblazeClient(), buildType)
 	if isUploaded {
 		logf("uploadToStorage: skipping upload because already uploaded")
-		return
+		return false
 	}
+	encryptSelectedArtifactsMust(buildType)
+	verifySignatureTimestampsMust(buildType)
 
 	timeStart := time.Now()
 	defer func() {
@@ -490,12 +519,17 @@ func uploadToStorage(buildType BuildType) {
 
 	wg.Add(1)
 	go func() {
-		mc := newMinioR2Client()
-		minioUploadBuildMust(mc, buildType)
-		if buildType != buildTypeRel {
-			minioDeleteOldBuildsPrefix(mc, buildType)
-		}
-		wg.Done()
+		defer wg.Done()
+		log := newTaskLogger("upload-r2")
+		defer log.Close()
+		runIndependentStep("upload to r2", func() {
+			defer stepHooks("upload", "r2")()
+			mc := newMinioR2Client()
+			minioUploadBuildMust(mc, buildType, log)
+			if buildType != buildTypeRel {
+				minioDeleteOldBuildsPrefix(mc, buildType, log)
+			}
+		})
 	}()
 
 	// downloads of pre-release 64-bit installer often fail
@@ -513,15 +547,23 @@ func uploadToStorage(buildType BuildType) {
 
 	wg.Add(1)
 	go func() {
-		mc := newMinioBackblazeClient()
-		minioUploadBuildMust(mc, buildType)
-		if buildType != buildTypeRel {
-			minioDeleteOldBuildsPrefix(mc, buildType)
-		}
-		wg.Done()
+		defer wg.Done()
+		log := newTaskLogger("upload-b2")
+		defer log.Close()
+		runIndependentStep("upload to backblaze", func() {
+			defer stepHooks("upload", "backblaze")()
+			mc := newMinioBackblazeClient()
+			minioUploadBuildMust(mc, buildType, log)
+			if buildType != buildTypeRel {
+				minioDeleteOldBuildsPrefix(mc, buildType, log)
+			}
+		})
 	}()
 
 	wg.Wait()
+	failIfStepsFailed()
+	publishLatestJSONMust(buildType)
+	return true
 }
 
 func uploadLogView() {
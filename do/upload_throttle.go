@@ -0,0 +1,211 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// uploading a daily build from a home build machine can saturate the
+// connection:
+// -upload-limit 2MB caps bandwidth of uploads to storage (per second),
+// shared by concurrent uploads. It throttles connections of the storage
+// transport (see storage_clients.go)
+// -upload-window 01:00-07:00 uploads only in off-peak hours (local time).
+// We wait for the window before uploading each file, so an upload that
+// runs past the end of the window pauses and resumes the next day
+// Creating out/upload.pause pauses uploads before the next file, deleting
+// it resumes them.
+// Files uploaded by a build upload are recorded in
+// out/upload-progress-${storage}.json so that re-running an interrupted
+// upload skips files that were already uploaded
+
+var (
+	flgUploadLimit  string
+	flgUploadWindow string
+)
+
+const uploadPausePath = "out/upload.pause"
+
+// how often we check if paused upload can continue
+const uploadPausePollInterval = 30 * time.Second
+
+// size of writes we throttle, smaller makes bandwidth smoother
+const throttleChunkSize = 16 * 1024
+
+// nil if not limited
+var uploadLimiter *bandwidthLimiter
+
+// bandwidthLimiter spreads writes of all connections over time so that
+// together they don't exceed bytesPerSec
+type bandwidthLimiter struct {
+	mu          sync.Mutex
+	bytesPerSec int64
+	// when the bandwidth is available again
+	next time.Time
+}
+
+// waits until n bytes can be sent
+func (l *bandwidthLimiter) wait(n int) {
+	l.mu.Lock()
+	now := time.Now()
+	if l.next.Before(now) {
+		l.next = now
+	}
+	l.next = l.next.Add(time.Duration(int64(n) * int64(time.Second) / l.bytesPerSec))
+	d := l.next.Sub(now)
+	l.mu.Unlock()
+	time.Sleep(d)
+}
+
+// throttledConn limits the speed of writes to the connection
+type throttledConn struct {
+	net.Conn
+	limiter *bandwidthLimiter
+}
+
+func (c *throttledConn) Write(d []byte) (int, error) {
+	nWritten := 0
+	for len(d) > 0 {
+		n := min(len(d), throttleChunkSize)
+		c.limiter.wait(n)
+		n, err := c.Conn.Write(d[:n])
+		nWritten += n
+		if err != nil {
+			return nWritten, err
+		}
+		d = d[n:]
+	}
+	return nWritten, nil
+}
+
+// UploadWindow is time of day in minutes since midnight, End can be
+// smaller than Start if the window spans midnight
+type UploadWindow struct {
+	Start int
+	End   int
+}
+
+var uploadWindow *UploadWindow
+
+// "22:30" => 22*60+30
+func parseTimeOfDayMust(s string) int {
+	hh, mm, ok := strings.Cut(strings.TrimSpace(s), ":")
+	h, err1 := strconv.Atoi(hh)
+	m, err2 := strconv.Atoi(mm)
+	panicIf(!ok || err1 != nil || err2 != nil || h < 0 || h > 24 || m < 0 || m > 59, "invalid time '%s', expected e.g. 01:00", s)
+	return h*60 + m
+}
+
+// "01:00-07:00" => UploadWindow
+func parseUploadWindowMust(s string) *UploadWindow {
+	start, end, ok := strings.Cut(s, "-")
+	panicIf(!ok, "invalid -upload-window '%s', expected e.g. 01:00-07:00", s)
+	w := &UploadWindow{Start: parseTimeOfDayMust(start), End: parseTimeOfDayMust(end)}
+	panicIf(w.Start == w.End, "-upload-window '%s' is empty", s)
+	return w
+}
+
+func (w *UploadWindow) Contains(t time.Time) bool {
+	m := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return m >= w.Start && m < w.End
+	}
+	return m >= w.Start || m < w.End
+}
+
+// returns when the window starts next time after t
+func (w *UploadWindow) NextStart(t time.Time) time.Time {
+	midnight := time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, t.Location())
+	res := midnight.Add(time.Duration(w.Start) * time.Minute)
+	if !res.After(t) {
+		res = res.AddDate(0, 0, 1)
+	}
+	return res
+}
+
+// must be called after flags are parsed, before creating storage clients
+func initUploadThrottleMust() {
+	if flgUploadLimit != "" {
+		n := parseSizeMust(flgUploadLimit)
+		panicIf(n < 1024, "-upload-limit '%s' is too small, must be at least 1KB", flgUploadLimit)
+		uploadLimiter = &bandwidthLimiter{bytesPerSec: n}
+		logf("limiting uploads to %s/s\n", formatSize(n))
+	}
+	if flgUploadWindow != "" {
+		uploadWindow = parseUploadWindowMust(flgUploadWindow)
+	}
+}
+
+// dialer of storage transport that throttles connections
+func throttledDialContext(limiter *bandwidthLimiter) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dialer.DialContext(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &throttledConn{Conn: conn, limiter: limiter}, nil
+	}
+}
+
+// blocks while uploads are paused with out/upload.pause or outside
+// of -upload-window
+func waitForUploadSlot(log *TaskLogger) {
+	logged := false
+	for fileExists(uploadPausePath) {
+		if !logged {
+			log.Logf("uploads paused, delete '%s' to resume\n", uploadPausePath)
+			logged = true
+		}
+		time.Sleep(uploadPausePollInterval)
+	}
+	if uploadWindow == nil || uploadWindow.Contains(time.Now()) {
+		return
+	}
+	next := uploadWindow.NextStart(time.Now())
+	log.Logf("waiting for upload window %s, until %s\n", flgUploadWindow, next.Format("2006-01-02 15:04"))
+	time.Sleep(time.Until(next))
+	// might have been paused in the meantime
+	waitForUploadSlot(log)
+}
+
+// UploadProgress records files uploaded to a storage, remote path => sha256
+type UploadProgress struct {
+	path     string
+	Uploaded map[string]string
+}
+
+func loadUploadProgress(mc Storage) *UploadProgress {
+	p := &UploadProgress{
+		path:     filepath.Join("out", "upload-progress-"+urlify(mc.URLBase())+".json"),
+		Uploaded: map[string]string{},
+	}
+	if d, err := os.ReadFile(p.path); err == nil {
+		_ = json.Unmarshal(d, &p.Uploaded)
+	}
+	return p
+}
+
+func (p *UploadProgress) IsUploaded(remotePath string, sha256 string) bool {
+	return p.Uploaded[remotePath] == sha256
+}
+
+func (p *UploadProgress) MarkUploaded(remotePath string, sha256 string) {
+	p.Uploaded[remotePath] = sha256
+	d, err := json.MarshalIndent(p.Uploaded, "", "  ")
+	must(err)
+	createDirMust(filepath.Dir(p.path))
+	writeFileMust(p.path, d)
+}
+
+// called after the whole build was uploaded
+func (p *UploadProgress) Clear() {
+	os.Remove(p.path)
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+// with -upx, before signing we compress some of the binaries with UPX
+// (https://upx.github.io/, must be in PATH) and measure how it changes
+// size and startup time. Results are recorded in the manifest so that
+// we can decide if shipping compressed binaries is worth it
+// UPX doesn't support ARM64 PE files so we skip arm64 builds
+
+var flgUpx bool
+
+var upxFiles = []string{"SumatraPDF.exe", "libmupdf.dll"}
+
+// startup time is median of that many runs
+const upxStartupRuns = 5
+
+// measures time from launching exe until its main window is ready for input
+const startupTimePs1 = `param([string]$exe, [string]$appData, [int]$n)
+$times = @()
+for ($i = 0; $i -lt $n; $i++) {
+    $sw = [Diagnostics.Stopwatch]::StartNew()
+    $p = Start-Process -FilePath $exe -ArgumentList "-appdata", $appData, "-new-window" -PassThru
+    $p.WaitForInputIdle(30000) | Out-Null
+    $sw.Stop()
+    $times += $sw.ElapsedMilliseconds
+    Stop-Process -Id $p.Id -Force
+    Start-Sleep -Milliseconds 500
+}
+$sorted = $times | Sort-Object
+Write-Output $sorted[[int][Math]::Floor($n / 2)]
+`
+
+// UpxResult is recorded in the manifest
+type UpxResult struct {
+	Path         string
+	Size         int64
+	SizeUpx      int64
+	StartupMs    int
+	StartupUpxMs int
+}
+
+var upxResults []*UpxResult
+
+func measureStartupMsMust(exe string, tmpDir string) int {
+	ps1Path := filepath.Join(tmpDir, "startup-time.ps1")
+	writeFileMust(ps1Path, []byte(startupTimePs1))
+	appDataDir := filepath.Join(tmpDir, "appdata")
+	must(os.RemoveAll(appDataDir))
+	createDirMust(appDataDir)
+	writeFileMust(filepath.Join(appDataDir, "SumatraPDF-settings.txt"), []byte("CheckForUpdates = false\n"))
+	out := runScreenshotsPs1Must(ps1Path, exe, appDataDir, strconv.Itoa(upxStartupRuns))
+	ms, err := strconv.Atoi(strings.TrimSpace(out))
+	panicIf(err != nil, "unexpected output of '%s': '%s'", ps1Path, out)
+	return ms
+}
+
+// compresses upxFiles in dir in place
+func upxCompressMust(dir string, platform string) {
+	if platform == kPlatformArm64 {
+		logf("upxCompressMust: skipping %s because UPX doesn't support it\n", platform)
+		return
+	}
+	upxPath, err := exec.LookPath("upx")
+	panicIf(err != nil, "upx.exe not found in PATH")
+	tmpDir := absPathMust(filepath.Join("out", "upx"))
+	createDirMust(tmpDir)
+
+	for _, name := range upxFiles {
+		path := filepath.Join(dir, name)
+		res := &UpxResult{
+			Path: strings.TrimPrefix(filepath.ToSlash(path), "out/"),
+			Size: fileSizeMust(path),
+		}
+		isExe := strings.HasSuffix(name, ".exe")
+		if isExe {
+			res.StartupMs = measureStartupMsMust(absPathMust(path), tmpDir)
+		}
+		runExeLoggedMust(upxPath, "--best", "-q", path)
+		res.SizeUpx = fileSizeMust(path)
+		if isExe {
+			res.StartupUpxMs = measureStartupMsMust(absPathMust(path), tmpDir)
+		}
+		logf("%s\n", fmtUpxResult(res))
+		upxResults = append(upxResults, res)
+	}
+}
+
+func fmtUpxResult(r *UpxResult) string {
+	s := fmt.Sprintf("upx: %s: %d => %d (%.0f%%)", r.Path, r.Size, r.SizeUpx, float64(r.SizeUpx*100)/float64(r.Size))
+	if r.StartupMs > 0 {
+		s += fmt.Sprintf(", startup %d ms => %d ms", r.StartupMs, r.StartupUpxMs)
+	}
+	return s
+}
+
+// lines to add to the manifest
+func fmtUpxResultsForManifest() []string {
+	var res []string
+	for _, r := range upxResults {
+		res = append(res, fmtUpxResult(r))
+	}
+	return res
+}
@@ -43,6 +43,9 @@ func logf(s string, arg ...interface{}) {
 	if len(arg) > 0 {
 		s = fmt.Sprintf(s, arg...)
 	}
+	s = redactSecrets(s)
+	logMu.Lock()
+	defer logMu.Unlock()
 	fmt.Print(s)
 }
 
@@ -93,18 +96,18 @@ func absPathMust(path string) string {
 func runExeMust(c string, args ...string) []byte {
 	cmd := exec.Command(c, args...)
 	logf("> %s\n", cmd)
-	out, err := cmd.CombinedOutput()
+	out, err := combinedOutputWithTimeout(cmd)
 	must(err)
-	return []byte(out)
+	return out
 }
 
 func runExeInDirMust(dir string, c string, args ...string) []byte {
 	cmd := exec.Command(c, args...)
 	logf("> %s\n", cmd)
 	cmd.Dir = dir
-	out, err := cmd.CombinedOutput()
+	out, err := combinedOutputWithTimeout(cmd)
 	must(err)
-	return []byte(out)
+	return out
 }
 
 func runExeLoggedMust(c string, args ...string) []byte {
@@ -118,6 +121,9 @@ func makePrintDuration(name string) func() {
 	timeStart := time.Now()
 	return func() {
 		dur := time.Since(timeStart)
+		stepDurationsMu.Lock()
+		stepDurations = append(stepDurations, &StepDuration{Name: name, Dur: dur})
+		stepDurationsMu.Unlock()
 		logf("%s took %s\n", name, formatDuration(dur))
 	}
 }
@@ -157,11 +163,11 @@ func fileNewerThan(path1, path2 string) bool {
 }
 
 func cmdRunLoggedMust(cmd *exec.Cmd) {
-	fmt.Printf("> %s\n", cmd.String())
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	logf("> %s\n", cmd.String())
+	flush := redactCmdOutput(cmd, os.Stdout, os.Stderr)
 	cmd.Stdin = os.Stdin
-	err := cmd.Run()
+	err := runCmdWithTimeout(cmd)
+	flush()
 	must(err)
 }
 
@@ -230,10 +236,10 @@ func fmdCmdShort(cmd *exec.Cmd) string {
 
 func runCmdLoggedMust(cmd *exec.Cmd) string {
 	logf(">2 %s\n", fmdCmdShort(cmd))
-	cmd.Stdout = os.Stdout
-	cmd.Stderr = os.Stderr
+	flush := redactCmdOutput(cmd, os.Stdout, os.Stderr)
 	cmd.Stdin = os.Stdin
-	err := cmd.Run()
+	err := runCmdWithTimeout(cmd)
+	flush()
 	must(err)
 	return ""
 }
@@ -264,10 +270,10 @@ func fmtCmdShort(cmd exec.Cmd) string {
 }
 
 func runCmdMust(cmd *exec.Cmd) string {
-	fmt.Printf("> %s\n", fmtCmdShort(*cmd))
+	logf("> %s\n", fmtCmdShort(*cmd))
 	canCapture := (cmd.Stdout == nil) && (cmd.Stderr == nil)
 	if canCapture {
-		out, err := cmd.CombinedOutput()
+		out, err := combinedOutputWithTimeout(cmd)
 		if err == nil {
 			if len(out) > 0 {
 				logf("Output:\n%s\n", string(out))
@@ -278,7 +284,7 @@ func runCmdMust(cmd *exec.Cmd) string {
 		must(err)
 		return string(out)
 	}
-	err := cmd.Run()
+	err := runCmdWithTimeout(cmd)
 	if err == nil {
 		return ""
 	}
@@ -0,0 +1,372 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io/fs"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// checks that vs2022/*.vcxproj and *.vcxproj.filters match the files on disk:
+// - .c, .cpp and .h files under src/ that are not in any project are
+//   silently not compiled
+// - project entries pointing to files that no longer exist
+// - entries in .vcxproj.filters that are not in .vcxproj (and vice-versa)
+// files that are intentionally not part of any project are listed
+// in do/vcxproj_sync_ignore.txt
+// with -fix we remove stale entries and add missing files next to
+// files from the same directory. The projects are generated by premake so
+// the fix must also be made in do/source_sets.go
+
+// VcxprojItem is a file reference in .vcxproj or .vcxproj.filters
+type VcxprojItem struct {
+	// ClCompile, ClInclude, None etc.
+	Kind string
+	// as written in the project, relative to vs2022 directory
+	Include string
+	// relative to the root of the repository, with forward slashes
+	Path   string
+	Filter string
+}
+
+type vcxprojXML struct {
+	ItemGroups []struct {
+		Items []struct {
+			XMLName xml.Name
+			Include string `xml:"Include,attr"`
+			Filter  string `xml:"Filter"`
+		} `xml:",any"`
+	} `xml:"ItemGroup"`
+}
+
+// item kinds that are not files
+var vcxprojNonFileKinds = []string{"ProjectConfiguration", "ProjectReference", "Filter"}
+
+func parseVcxprojItems(d []byte) ([]*VcxprojItem, error) {
+	var p vcxprojXML
+	if err := xml.Unmarshal(d, &p); err != nil {
+		return nil, err
+	}
+	var res []*VcxprojItem
+	for _, g := range p.ItemGroups {
+		for _, it := range g.Items {
+			kind := it.XMLName.Local
+			if it.Include == "" || stringInSlice(vcxprojNonFileKinds, kind) {
+				continue
+			}
+			s := strings.ReplaceAll(it.Include, `\`, "/")
+			res = append(res, &VcxprojItem{
+				Kind:    kind,
+				Include: it.Include,
+				Path:    path.Clean(path.Join("vs2022", s)),
+				Filter:  it.Filter,
+			})
+		}
+	}
+	return res, nil
+}
+
+func readVcxprojItemsMust(path string) []*VcxprojItem {
+	items, err := parseVcxprojItems(readFileMust(path))
+	panicIf(err != nil, "failed to parse '%s': %s", path, err)
+	return items
+}
+
+func isSourceFileForVcxproj(path string) bool {
+	ext := strings.ToLower(filepath.Ext(path))
+	return stringInSlice([]string{".c", ".cpp", ".h"}, ext)
+}
+
+func vcxprojKindForFile(path string) string {
+	if strings.ToLower(filepath.Ext(path)) == ".h" {
+		return "ClInclude"
+	}
+	return "ClCompile"
+}
+
+func readVcxprojSyncIgnore() []string {
+	var res []string
+	ignorePath := filepath.Join("do", "vcxproj_sync_ignore.txt")
+	if !fileExists(ignorePath) {
+		return res
+	}
+	for _, line := range strings.Split(string(readFileMust(ignorePath)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		res = append(res, line)
+	}
+	return res
+}
+
+// VcxprojSyncProblems are the results of checkVcxprojSync
+type VcxprojSyncProblems struct {
+	// files on disk not in any project
+	NotInProject []string
+	// project => items pointing to files that don't exist
+	Missing map[string][]*VcxprojItem
+	// project => items in .vcxproj but not in .vcxproj.filters
+	NotInFilters map[string][]*VcxprojItem
+	// .vcxproj.filters => items not in .vcxproj
+	NotInVcxproj map[string][]*VcxprojItem
+}
+
+func (p *VcxprojSyncProblems) Count() int {
+	n := len(p.NotInProject)
+	for _, m := range []map[string][]*VcxprojItem{p.Missing, p.NotInFilters, p.NotInVcxproj} {
+		for _, items := range m {
+			n += len(items)
+		}
+	}
+	return n
+}
+
+func findVcxprojSyncProblems() *VcxprojSyncProblems {
+	res := &VcxprojSyncProblems{
+		Missing:      map[string][]*VcxprojItem{},
+		NotInFilters: map[string][]*VcxprojItem{},
+		NotInVcxproj: map[string][]*VcxprojItem{},
+	}
+	projects, err := filepath.Glob(filepath.Join("vs2022", "*.vcxproj"))
+	must(err)
+	sort.Strings(projects)
+
+	inProject := map[string]bool{}
+	for _, proj := range projects {
+		items := readVcxprojItemsMust(proj)
+		inVcxproj := map[string]bool{}
+		for _, it := range items {
+			inProject[it.Path] = true
+			inVcxproj[it.Path] = true
+			if !fileExists(filepath.FromSlash(it.Path)) {
+				res.Missing[proj] = append(res.Missing[proj], it)
+			}
+		}
+
+		filtersPath := proj + ".filters"
+		if !fileExists(filtersPath) {
+			continue
+		}
+		filterItems := readVcxprojItemsMust(filtersPath)
+		inFilters := map[string]bool{}
+		for _, it := range filterItems {
+			inFilters[it.Path] = true
+			if !inVcxproj[it.Path] {
+				res.NotInVcxproj[filtersPath] = append(res.NotInVcxproj[filtersPath], it)
+			}
+		}
+		for _, it := range items {
+			if !inFilters[it.Path] {
+				res.NotInFilters[proj] = append(res.NotInFilters[proj], it)
+			}
+		}
+	}
+
+	ignore := readVcxprojSyncIgnore()
+	err = filepath.WalkDir("src", func(p string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() || !isSourceFileForVcxproj(p) {
+			return err
+		}
+		p = filepath.ToSlash(p)
+		if inProject[p] {
+			return nil
+		}
+		for _, pattern := range ignore {
+			if matchSuppressionPattern(pattern, p) {
+				return nil
+			}
+		}
+		res.NotInProject = append(res.NotInProject, p)
+		return nil
+	})
+	must(err)
+	return res
+}
+
+// project files use CRLF line endings and we must preserve that
+func splitVcxprojLines(d []byte) []string {
+	return strings.Split(string(d), "\r\n")
+}
+
+func joinVcxprojLines(lines []string) []byte {
+	return []byte(strings.Join(lines, "\r\n"))
+}
+
+// removes element with a given Include, both <Kind Include="..." />
+// and <Kind Include="...">...</Kind> forms
+func removeVcxprojItem(lines []string, it *VcxprojItem) []string {
+	open := fmt.Sprintf(`<%s Include="%s"`, it.Kind, it.Include)
+	for i, line := range lines {
+		s := strings.TrimSpace(line)
+		if !strings.HasPrefix(s, open) {
+			continue
+		}
+		end := i
+		if !strings.HasSuffix(s, "/>") {
+			closeTag := fmt.Sprintf("</%s>", it.Kind)
+			for end < len(lines) && strings.TrimSpace(lines[end]) != closeTag {
+				end++
+			}
+			if end == len(lines) {
+				logf("no %s for '%s', remove it manually\n", closeTag, it.Include)
+				return lines
+			}
+		}
+		return append(lines[:i], lines[end+1:]...)
+	}
+	return lines
+}
+
+// finds a project item for a file in the same directory as path
+func findSiblingVcxprojItem(items []*VcxprojItem, path string, kind string) *VcxprojItem {
+	dir := filepath.ToSlash(filepath.Dir(path))
+	for _, it := range items {
+		if it.Kind == kind && filepath.ToSlash(filepath.Dir(it.Path)) == dir {
+			return it
+		}
+	}
+	return nil
+}
+
+// inserts new lines before the element of the sibling
+func insertVcxprojLines(lines []string, sibling *VcxprojItem, toInsert []string) []string {
+	open := fmt.Sprintf(`<%s Include="%s"`, sibling.Kind, sibling.Include)
+	for i, line := range lines {
+		if strings.HasPrefix(strings.TrimSpace(line), open) {
+			res := append([]string{}, lines[:i]...)
+			res = append(res, toInsert...)
+			return append(res, lines[i:]...)
+		}
+	}
+	return lines
+}
+
+func vcxprojInclude(path string) string {
+	return `..\` + strings.ReplaceAll(path, "/", `\`)
+}
+
+func fixVcxprojFileMust(projPath string, fn func([]string) []string) {
+	lines := splitVcxprojLines(readFileMust(projPath))
+	lines = fn(lines)
+	writeFileMust(projPath, joinVcxprojLines(lines))
+}
+
+// adds path to .vcxproj.filters using the filter of a file from the same directory
+func addToVcxprojFilters(filtersPath string, path string, kind string) bool {
+	if !fileExists(filtersPath) {
+		return false
+	}
+	sibling := findSiblingVcxprojItem(readVcxprojItemsMust(filtersPath), path, kind)
+	if sibling == nil {
+		return false
+	}
+	include := vcxprojInclude(path)
+	toInsert := []string{
+		fmt.Sprintf(`    <%s Include="%s">`, kind, include),
+		fmt.Sprintf(`      <Filter>%s</Filter>`, sibling.Filter),
+		fmt.Sprintf(`    </%s>`, kind),
+	}
+	fixVcxprojFileMust(filtersPath, func(lines []string) []string {
+		return insertVcxprojLines(lines, sibling, toInsert)
+	})
+	return true
+}
+
+func fixVcxprojSyncProblems(p *VcxprojSyncProblems) {
+	removeAll := func(m map[string][]*VcxprojItem, filters bool) {
+		for proj, items := range m {
+			path := proj
+			if filters && !strings.HasSuffix(proj, ".filters") {
+				path = proj + ".filters"
+				// not all projects have .filters
+				if !fileExists(path) {
+					continue
+				}
+			}
+			fixVcxprojFileMust(path, func(lines []string) []string {
+				for _, it := range items {
+					lines = removeVcxprojItem(lines, it)
+				}
+				return lines
+			})
+			logf("removed %d stale entries from '%s'\n", len(items), path)
+		}
+	}
+	removeAll(p.Missing, false)
+	removeAll(p.Missing, true)
+	removeAll(p.NotInVcxproj, false)
+	for proj, items := range p.NotInFilters {
+		for _, it := range items {
+			if fileExists(filepath.FromSlash(it.Path)) && !addToVcxprojFilters(proj+".filters", it.Path, it.Kind) {
+				logf("couldn't add '%s' to '%s.filters', add it manually\n", it.Path, proj)
+			}
+		}
+	}
+
+	projects, err := filepath.Glob(filepath.Join("vs2022", "*.vcxproj"))
+	must(err)
+	sort.Strings(projects)
+	var notFixed []string
+	for _, path := range p.NotInProject {
+		kind := vcxprojKindForFile(path)
+		added := false
+		for _, proj := range projects {
+			sibling := findSiblingVcxprojItem(readVcxprojItemsMust(proj), path, kind)
+			if sibling == nil {
+				continue
+			}
+			include := vcxprojInclude(path)
+			fixVcxprojFileMust(proj, func(lines []string) []string {
+				return insertVcxprojLines(lines, sibling, []string{fmt.Sprintf(`    <%s Include="%s" />`, kind, include)})
+			})
+			addToVcxprojFilters(proj+".filters", path, kind)
+			logf("added '%s' to '%s'\n", path, proj)
+			added = true
+			break
+		}
+		if !added {
+			notFixed = append(notFixed, path)
+		}
+	}
+	for _, path := range notFixed {
+		logf("couldn't find a project for '%s', add it manually\n", path)
+	}
+	logf("also update do/source_sets.go, vs2022 projects are generated with 'do gen projects'\n")
+}
+
+func checkVcxprojSync(fix bool) {
+	p := findVcxprojSyncProblems()
+	for _, path := range p.NotInProject {
+		logf("not in any project: %s\n", path)
+	}
+	logItems := func(m map[string][]*VcxprojItem, msg string) {
+		var keys []string
+		for k := range m {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		for _, k := range keys {
+			for _, it := range m[k] {
+				logf("%s: %s %s\n", k, msg, it.Path)
+			}
+		}
+	}
+	logItems(p.Missing, "file doesn't exist:")
+	logItems(p.NotInFilters, "not in .filters:")
+	logItems(p.NotInVcxproj, "not in .vcxproj:")
+
+	n := p.Count()
+	if n == 0 {
+		logf("vs2022 projects match files on disk\n")
+		return
+	}
+	if fix {
+		fixVcxprojSyncProblems(p)
+		return
+	}
+	panicIf(true, "%d problems in vs2022 projects, run with -fix to fix them", n)
+}
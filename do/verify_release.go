@@ -0,0 +1,275 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// do release verify ${ver} checks that a release is everywhere it should be
+// before we announce it. upload-hashes.json in R2 is the source of truth
+// for names and sha256 of files. We download every file from every public
+// location (R2, Backblaze, website /dl/ links, GitHub release, SourceForge)
+// and check presence, size and sha256, check Authenticode signature and
+// version of executables, and check that update info and latest.json
+// point to the release and its files.
+// Prints a report and fails unless everything is a go
+
+// ReleaseLocation is a public place a release should be downloadable from
+type ReleaseLocation struct {
+	Name string
+	// url of file name
+	URL func(name string) string
+	// only mirrored files (no symbols, manifests etc.)
+	MirrorOnly bool
+}
+
+// ReleaseCheck is a line in verification report
+type ReleaseCheck struct {
+	Location string
+	What     string
+	// "" means ok
+	Problem string
+	// doesn't block announcing the release
+	Warning bool
+}
+
+type releaseVerifier struct {
+	ver    string
+	checks []*ReleaseCheck
+}
+
+func (v *releaseVerifier) add(location string, what string, problem string) {
+	v.checks = append(v.checks, &ReleaseCheck{Location: location, What: what, Problem: problem})
+}
+
+func (v *releaseVerifier) warn(location string, what string, problem string) {
+	v.checks = append(v.checks, &ReleaseCheck{Location: location, What: what, Problem: problem, Warning: true})
+}
+
+func (v *releaseVerifier) isGo() bool {
+	for _, c := range v.checks {
+		if c.Problem != "" && !c.Warning {
+			return false
+		}
+	}
+	return true
+}
+
+// files we publish on mirrors
+func isMirrorFile(name string) bool {
+	// symbols, manifests and torrents are only on our storage
+	return !strings.Contains(name, ".pdb") && !strings.HasSuffix(name, ".txt") && !strings.HasSuffix(name, ".torrent")
+}
+
+func getReleaseLocations(ver string) []*ReleaseLocation {
+	remoteDir := "software/sumatrapdf/rel/" + ver + "/"
+	mcR2 := newMinioR2Client()
+	mcBackblaze := newMinioBackblazeClient()
+	return []*ReleaseLocation{
+		{Name: "r2", URL: func(name string) string { return mcR2.URLForPath(remoteDir + name) }},
+		{Name: "backblaze", URL: func(name string) string { return mcBackblaze.URLForPath(remoteDir + name) }},
+		{Name: "website", URL: func(name string) string { return "https://www.sumatrapdfreader.org/dl/rel/" + ver + "/" + name }},
+		{Name: "github", MirrorOnly: true, URL: func(name string) string {
+			return fmt.Sprintf("https://github.com/%s/releases/download/%srel/%s", getGitHubRepo(), ver, name)
+		}},
+		{Name: "sourceforge", MirrorOnly: true, URL: func(name string) string {
+			return fmt.Sprintf("https://downloads.sourceforge.net/project/%s/%s/%s", sourceForgeProject, ver, name)
+		}},
+	}
+}
+
+// returns content of uri and "" or a problem if it's not the expected content
+func checkReleaseURL(uri string, wantSha256 string) (d []byte, problem string) {
+	rsp, err := http.Get(uri)
+	if err != nil {
+		return nil, err.Error()
+	}
+	defer rsp.Body.Close()
+	if rsp.StatusCode != http.StatusOK {
+		return nil, "status " + rsp.Status
+	}
+	d, err = io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err.Error()
+	}
+	if rsp.ContentLength >= 0 && rsp.ContentLength != int64(len(d)) {
+		return nil, fmt.Sprintf("size is %d, Content-Length is %d", len(d), rsp.ContentLength)
+	}
+	if got := sha256Hex(d); got != wantSha256 {
+		return nil, fmt.Sprintf("sha256 is %s, expected %s", got, wantSha256)
+	}
+	return d, ""
+}
+
+// checks signature and version of executables
+func (v *releaseVerifier) checkExecutable(name string, d []byte, dir string) {
+	if !strings.HasSuffix(name, ".exe") {
+		return
+	}
+	expectedVer := verToFileVersion(v.ver)
+	fileVer, err := peFileVersion(d)
+	if err != nil {
+		v.add("r2", name, "couldn't get version: "+err.Error())
+	} else if fileVer != expectedVer {
+		v.add("r2", name, fmt.Sprintf("version is %s, expected %s", fileVer, expectedVer))
+	}
+	path := filepath.Join(dir, name)
+	writeFileMust(path, d)
+	problem := ""
+	func() {
+		defer func() {
+			if r := recover(); r != nil {
+				problem = fmt.Sprintf("%v", r)
+			}
+		}()
+		verifySignatureMust(path)
+	}()
+	v.add("r2", name+" signature", problem)
+}
+
+// parses "Key: value" and old "Key value" update info
+func parseUpdateInfoTxt(s string) map[string]string {
+	res := map[string]string{}
+	for _, line := range toTrimmedLines([]byte(s)) {
+		if strings.HasPrefix(line, "[") {
+			continue
+		}
+		k, val, ok := strings.Cut(line, ":")
+		if !ok || strings.Contains(k, " ") {
+			k, val, _ = strings.Cut(line, " ")
+		}
+		res[strings.TrimSpace(k)] = strings.TrimSpace(val)
+	}
+	return res
+}
+
+// update info and latest.json must point to this release and to files that
+// we've verified
+func (v *releaseVerifier) checkUpdateInfo(verified map[string]bool) {
+	for _, uri := range []string{
+		"https://www.sumatrapdfreader.org/update-check-rel.txt",
+		"https://sumatra-website.onrender.com/update-check-rel.txt",
+	} {
+		d, err := httpGet(uri)
+		if err != nil {
+			v.add("update-info", uri, err.Error())
+			continue
+		}
+		info := parseUpdateInfoTxt(string(d))
+		if latest := info["Latest"]; latest != v.ver {
+			// auto-update might be promoted later, see do release schedule
+			v.warn("update-info", uri, fmt.Sprintf("Latest is '%s', not %s", latest, v.ver))
+			continue
+		}
+		problem := ""
+		for k, val := range info {
+			if strings.HasPrefix(val, "http") && !verified[val] {
+				problem += fmt.Sprintf("%s '%s' is not a verified url; ", k, val)
+			}
+		}
+		v.add("update-info", uri, strings.TrimSuffix(problem, "; "))
+	}
+
+	d, err := httpGet(newMinioR2Client().URLForPath(latestJSONRemotePath))
+	if err != nil {
+		v.add("latest.json", latestJSONRemotePath, err.Error())
+		return
+	}
+	var doc LatestJSON
+	if err = json.Unmarshal(d, &doc); err != nil {
+		v.add("latest.json", latestJSONRemotePath, err.Error())
+		return
+	}
+	ch := doc.Channels[latestJSONChannel(buildTypeRel)]
+	if ch == nil || ch.Ver != v.ver {
+		v.add("latest.json", "stable channel", fmt.Sprintf("doesn't point to %s", v.ver))
+		return
+	}
+	problem := ""
+	for _, f := range ch.Files {
+		if !verified[f.URL] {
+			problem += fmt.Sprintf("'%s' is not a verified url; ", f.URL)
+		}
+	}
+	v.add("latest.json", "stable channel", strings.TrimSuffix(problem, "; "))
+}
+
+// like httpGetMust but returns an error
+func httpGet(uri string) (d []byte, err error) {
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("%v", r)
+		}
+	}()
+	return httpGetMust(uri), nil
+}
+
+func (v *releaseVerifier) printReport() {
+	nOk := 0
+	for _, c := range v.checks {
+		if c.Problem == "" {
+			nOk++
+			continue
+		}
+		kind := "FAIL"
+		if c.Warning {
+			kind = "WARN"
+		}
+		logf("%s %-12s %s: %s\n", kind, c.Location, c.What, c.Problem)
+	}
+	logf("%d of %d checks ok\n", nOk, len(v.checks))
+	if v.isGo() {
+		logf("GO: release %s can be announced\n", v.ver)
+	} else {
+		logf("NO-GO: release %s is not ready to be announced\n", v.ver)
+	}
+}
+
+func verifyReleaseMust(ver string) {
+	validateVer(ver)
+	ensureAllUploadCreds()
+	v := &releaseVerifier{ver: ver}
+	mcR2 := newMinioR2Client()
+	// upload-hashes.json is not public
+	hashesPath := "software/sumatrapdf/rel/" + ver + "/" + uploadHashesFileName
+	failIf(errKindVerify, !mcR2.Exists(hashesPath), "'%s' doesn't exist, was %s uploaded?", hashesPath, ver)
+	var hashes map[string]string
+	must(json.Unmarshal(minioDownloadDataMust(mcR2, hashesPath), &hashes))
+	var names []string
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	logf("verifying %d files of release %s\n", len(names), ver)
+
+	tmpDir := createDirMust(filepath.Join("out", "verify-release", ver))
+	defer os.RemoveAll(tmpDir)
+	verified := map[string]bool{}
+	for _, loc := range getReleaseLocations(ver) {
+		for _, name := range names {
+			if loc.MirrorOnly && !isMirrorFile(name) {
+				continue
+			}
+			uri := loc.URL(name)
+			d, problem := checkReleaseURL(uri, hashes[name])
+			v.add(loc.Name, name, problem)
+			if problem != "" {
+				continue
+			}
+			verified[uri] = true
+			if loc.Name == "r2" {
+				v.checkExecutable(name, d, tmpDir)
+			}
+		}
+		logf("checked %s\n", loc.Name)
+	}
+	v.checkUpdateInfo(verified)
+	v.printReport()
+	failIf(errKindVerify, !v.isGo(), "release %s failed verification", ver)
+}
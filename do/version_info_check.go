@@ -0,0 +1,126 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// checks that VS_VERSIONINFO resources of built binaries match src/Version.h:
+// file and product version, company, copyright (which must include
+// the current year) and original file name
+
+const versionHPath = "src/Version.h"
+
+// OriginalFilename if different than the name of the file
+var versionInfoOriginalFilename = map[string]string{
+	// built from the same SumatraPDF.rc
+	"SumatraPDF-dll.exe": "SumatraPDF.exe",
+}
+
+var rxVersionHStrDefine = regexp.MustCompile(`(?m)^#define\s+(k\w+)\s+"(.*)"`)
+
+// returns values of #define kFoo "..." in Version.h
+func parseVersionHStrings(s string) map[string]string {
+	res := map[string]string{}
+	for _, m := range rxVersionHStrDefine.FindAllStringSubmatch(s, -1) {
+		res[m[1]] = m[2]
+	}
+	return res
+}
+
+// ExpectedVersionInfo is what we expect in VS_VERSIONINFO of a binary
+type ExpectedVersionInfo struct {
+	// VS_FIXEDFILEINFO, "3.6.0.0" or "3.6.0.15432" for pre-release
+	FixedVersion string
+	// FileVersion and ProductVersion strings, "3.6" or "3.6.0.15432"
+	VersionStr string
+	Company    string
+	Copyright  string
+	Year       int
+}
+
+// mirrors VER_RESOURCE and VER_RESOURCE_STR in Version.h
+func getExpectedVersionInfo(ver string, preRelVer string, versionH map[string]string, year int) *ExpectedVersionInfo {
+	parts := strings.Split(ver, ".")
+	for len(parts) < 3 {
+		parts = append(parts, "0")
+	}
+	res := &ExpectedVersionInfo{
+		FixedVersion: strings.Join(parts, ".") + ".0",
+		VersionStr:   ver,
+		Company:      versionH["kPublisherStr"],
+		Copyright:    versionH["kCopyrightStr"],
+		Year:         year,
+	}
+	if preRelVer != "" {
+		res.FixedVersion = strings.Join(parts, ".") + "." + preRelVer
+		res.VersionStr = ver + ".0." + preRelVer
+	}
+	return res
+}
+
+// returns problems
+func checkVersionInfo(name string, vi *PeVersionInfo, exp *ExpectedVersionInfo) []string {
+	var res []string
+	add := func(format string, args ...interface{}) {
+		res = append(res, name+": "+fmt.Sprintf(format, args...))
+	}
+	if vi.FileVersion != exp.FixedVersion {
+		add("fixed file version is '%s', expected '%s'", vi.FileVersion, exp.FixedVersion)
+	}
+	if vi.ProductVersion != exp.FixedVersion {
+		add("fixed product version is '%s', expected '%s'", vi.ProductVersion, exp.FixedVersion)
+	}
+	checkStr := func(key string, expected string) {
+		if got := vi.Strings[key]; got != expected {
+			add("%s is '%s', expected '%s'", key, got, expected)
+		}
+	}
+	checkStr("FileVersion", exp.VersionStr)
+	checkStr("ProductVersion", exp.VersionStr)
+	company := exp.Company
+	for _, rc := range versionRcs {
+		if rc.OriginalFilename == name && rc.Company != "" {
+			company = rc.Company
+		}
+	}
+	checkStr("CompanyName", company)
+	checkStr("LegalCopyright", exp.Copyright)
+	if !strings.Contains(vi.Strings["LegalCopyright"], strconv.Itoa(exp.Year)) {
+		add("LegalCopyright '%s' doesn't include current year %d, update kCopyrightStr in %s", vi.Strings["LegalCopyright"], exp.Year, versionHPath)
+	}
+	originalName := name
+	if s, ok := versionInfoOriginalFilename[name]; ok {
+		originalName = s
+	}
+	checkStr("OriginalFilename", originalName)
+	return res
+}
+
+// preRelVer is "" for release builds
+func verifyVersionInfoMust(preRelVer string, dirs ...string) {
+	defer failOnPanic(errKindVerify)
+	versionH := parseVersionHStrings(string(readFileMust(versionHPath)))
+	exp := getExpectedVersionInfo(sumatraVersion, preRelVer, versionH, time.Now().Year())
+	var problems []string
+	nChecked := 0
+	for _, dir := range dirs {
+		for _, path := range getFilesToSign(dir) {
+			vi, err := peVersionInfo(readFileMust(path))
+			if err != nil {
+				problems = append(problems, fmt.Sprintf("%s: %s", path, err))
+				continue
+			}
+			for _, p := range checkVersionInfo(filepath.Base(path), vi, exp) {
+				problems = append(problems, filepath.Dir(path)+string(filepath.Separator)+p)
+			}
+			nChecked++
+		}
+	}
+	panicIf(len(problems) > 0, "version info doesn't match %s:\n%s\n", versionHPath, strings.Join(problems, "\n"))
+	logf("version info of %d files matches %s\n", nChecked, versionHPath)
+}
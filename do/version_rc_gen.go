@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+// VERSIONINFO resources of our binaries are generated from a single template
+// so that they don't drift apart. Version and copyright are macros from
+// src/Version.h (VER_RESOURCE, VER_RESOURCE_STR, kCopyrightStr), resolved
+// by the resource compiler, so they follow CURR_VERSION, VER_QUALIFIER and
+// PRE_RELEASE_VER (set in BuildConfig.h by pre-release builds).
+// Generated *.version.rc files are included by .rc files of each binary,
+// after Version.h.
+// To re-generate after changing versionRcs: .\doit.bat gen version-rc (or docs gen)
+
+// VersionRc describes generated version resource of a binary
+type VersionRc struct {
+	Path             string
+	Description      string
+	OriginalFilename string
+	// if empty, kPublisherStr
+	Company string
+	IsDll   bool
+}
+
+var versionRcs = []*VersionRc{
+	{
+		Path:             filepath.Join("src", "SumatraPDF.version.rc"),
+		Description:      "SumatraPDF",
+		OriginalFilename: "SumatraPDF.exe",
+	},
+	{
+		Path:             filepath.Join("src", "libmupdf.version.rc"),
+		Description:      "MuPDF rendering library, DjVu rendering library and various helper libraries",
+		OriginalFilename: "libmupdf.dll",
+		Company:          "Artifex Software et al.",
+		IsDll:            true,
+	},
+	{
+		Path:             filepath.Join("src", "ifilter", "PdfFilter.version.rc"),
+		Description:      "SumatraPDF IFilter Search Helper",
+		OriginalFilename: "PdfFilter.dll",
+		IsDll:            true,
+	},
+	{
+		Path:             filepath.Join("src", "previewer", "PdfPreview.version.rc"),
+		Description:      "SumatraPDF Preview Shell Extension",
+		OriginalFilename: "PdfPreview.dll",
+		IsDll:            true,
+	},
+}
+
+const versionRcTmpl = `// DO NOT EDIT MANUALLY !!!
+// Generated by .\doit.bat gen version-rc, values are from Version.h
+
+VS_VERSION_INFO VERSIONINFO
+  FILEVERSION    VER_RESOURCE
+  PRODUCTVERSION VER_RESOURCE
+  FILEFLAGSMASK  VS_FFI_FILEFLAGSMASK
+#ifdef _DEBUG
+  FILEFLAGS      VS_FF_DEBUG
+#else
+  FILEFLAGS      0
+#endif
+  FILEOS         VOS_NT_WINDOWS32
+  FILETYPE       {{.FileType}}
+  FILESUBTYPE    0
+BEGIN
+  BLOCK "StringFileInfo"
+  BEGIN
+    // U.S. English, Windows Multilingual
+    BLOCK "040904E4"
+    BEGIN
+      VALUE "FileDescription",  "{{.Description}}"
+      VALUE "FileVersion",      VER_RESOURCE_STR
+      VALUE "LegalCopyright",   kCopyrightStr
+      VALUE "OriginalFilename", "{{.OriginalFilename}}"
+      VALUE "ProductName",      kAppName
+      VALUE "ProductVersion",   VER_RESOURCE_STR
+      VALUE "CompanyName",      {{.Company}}
+    END
+  END
+  BLOCK "VarFileInfo"
+  BEGIN
+    VALUE "Translation", 0x0409, 1252
+  END
+END
+`
+
+func genVersionRc(rc *VersionRc) string {
+	company := "kPublisherStr"
+	if rc.Company != "" {
+		company = fmt.Sprintf("%q", rc.Company)
+	}
+	fileType := "VFT_APP"
+	if rc.IsDll {
+		fileType = "VFT_DLL"
+	}
+	v := map[string]string{
+		"FileType":         fileType,
+		"Description":      rc.Description,
+		"OriginalFilename": rc.OriginalFilename,
+		"Company":          company,
+	}
+	return execTextTemplate(versionRcTmpl, v)
+}
+
+func genVersionRcsMust() {
+	for _, rc := range versionRcs {
+		writeFileMust(rc.Path, []byte(genVersionRc(rc)))
+	}
+	logf("generated %d version resources\n", len(versionRcs))
+}
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"fmt"
 	"path/filepath"
 )
 
@@ -48,14 +47,15 @@ func detectPathInSDK(name string) string {
 			return path
 		}
 	}
-	panic(fmt.Sprintf("Didn't find %s", name))
+	failIf(errKindToolchain, true, "didn't find %s in Windows SDK", name)
+	return ""
 }
 
 var printedMsbuildPath bool
 
 func detectMsbuildPath() string {
 	path := detectPath(vsBasePaths, msBuildName)
-	panicIf(path == "", fmt.Sprintf("Didn't find %s", msBuildName))
+	failIf(errKindToolchain, path == "", "didn't find %s, install Visual Studio 2022", msBuildName)
 	if !printedMsbuildPath {
 		logf("msbuild.exe: %s\n", path)
 		printedMsbuildPath = true
@@ -0,0 +1,248 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// detects versions of vendored libraries in ext/ and mupdf/ from their
+// headers (ext/versions.txt is often out of date) and checks NVD for
+// CVEs affecting those versions
+// CVEs we've patched or that don't affect us are listed in do/cve_ignore.txt
+
+// VendoredLib describes how to detect version of a vendored library
+type VendoredLib struct {
+	Name string
+	// cpe:2.3:a:${Cpe}:${ver}
+	Cpe  string
+	File string
+	// each regexp captures one component of the version
+	Rx []string
+	// optional, converts captured version to a version used in CPE
+	FixVer func(string) string
+}
+
+var vendoredLibs = []*VendoredLib{
+	{Name: "freetype", Cpe: "freetype:freetype", File: "ext/freetype/include/freetype/freetype.h", Rx: []string{`define FREETYPE_MAJOR\s+(\d+)`, `define FREETYPE_MINOR\s+(\d+)`, `define FREETYPE_PATCH\s+(\d+)`}},
+	{Name: "libjpeg-turbo", Cpe: "libjpeg-turbo:libjpeg-turbo", File: "ext/libjpeg-turbo/jconfig.h", Rx: []string{`define LIBJPEG_TURBO_VERSION\s+([\d.]+)`}},
+	{Name: "openjpeg", Cpe: "uclouvain:openjpeg", File: "ext/openjpeg/src/lib/openjp2/opj_config_private.h", Rx: []string{`define OPJ_PACKAGE_VERSION\s+"([\d.]+)"`}},
+	{Name: "zlib", Cpe: "zlib:zlib", File: "ext/zlib/zlib.h", Rx: []string{`define ZLIB_VERSION\s+"([\d.]+)"`}},
+	{Name: "harfbuzz", Cpe: "harfbuzz_project:harfbuzz", File: "ext/harfbuzz/src/hb-version.h", Rx: []string{`define HB_VERSION_STRING\s+"([\d.]+)"`}},
+	{Name: "lcms2", Cpe: "littlecms:little_cms_color_engine", File: "ext/lcms2/include/lcms2mt.h", Rx: []string{`define LCMS_VERSION\s+\((\d+)`}, FixVer: func(s string) string {
+		// 2140 => 2.14
+		n, err := strconv.Atoi(s)
+		must(err)
+		return fmt.Sprintf("%d.%d", n/1000, (n%1000)/10)
+	}},
+	{Name: "jbig2dec", Cpe: "artifex:jbig2dec", File: "ext/jbig2dec/jbig2.h", Rx: []string{`define JBIG2_VERSION_MAJOR\s+\((\d+)\)`, `define JBIG2_VERSION_MINOR\s+\((\d+)\)`}},
+	{Name: "bzip2", Cpe: "bzip:bzip2", File: "ext/bzip2/bzlib_private.h", Rx: []string{`define BZ_VERSION\s+"([\d.]+)`}},
+	{Name: "libwebp", Cpe: "webmproject:libwebp", File: "ext/libwebp/src/dec/vp8i_dec.h", Rx: []string{`define DEC_MAJ_VERSION\s+(\d+)`, `define DEC_MIN_VERSION\s+(\d+)`, `define DEC_REV_VERSION\s+(\d+)`}},
+	{Name: "libheif", Cpe: "struktur:libheif", File: "ext/libheif/libheif/heif_version.h", Rx: []string{`define LIBHEIF_VERSION\s+"(\d+\.\d+\.\d+)`}},
+	{Name: "mujs", Cpe: "artifex:mujs", File: "ext/mujs/mujs.h", Rx: []string{`define JS_VERSION_MAJOR\s+(\d+)`, `define JS_VERSION_MINOR\s+(\d+)`, `define JS_VERSION_PATCH\s+(\d+)`}},
+	{Name: "unrar", Cpe: "rarlab:unrar", File: "ext/unrar/version.hpp", Rx: []string{`define RARVER_MAJOR\s+(\d+)`, `define RARVER_MINOR\s+(\d+)`}},
+	{Name: "mupdf", Cpe: "artifex:mupdf", File: "mupdf/include/mupdf/fitz/version.h", Rx: []string{`define FZ_VERSION\s+"([\d.]+)"`}},
+}
+
+func detectLibVersion(lib *VendoredLib) (string, error) {
+	d, err := os.ReadFile(filepath.FromSlash(lib.File))
+	if err != nil {
+		return "", err
+	}
+	var parts []string
+	for _, s := range lib.Rx {
+		m := regexp.MustCompile(s).FindSubmatch(d)
+		if m == nil {
+			return "", fmt.Errorf("'%s' didn't match in '%s'", s, lib.File)
+		}
+		parts = append(parts, string(m[1]))
+	}
+	ver := strings.Join(parts, ".")
+	if lib.FixVer != nil {
+		ver = lib.FixVer(ver)
+	}
+	return ver, nil
+}
+
+// Vuln is a CVE affecting a vendored library
+type Vuln struct {
+	Lib         string
+	Ver         string
+	ID          string
+	Severity    string
+	Score       float64
+	Description string
+}
+
+type nvdCvssData struct {
+	BaseScore    float64 `json:"baseScore"`
+	BaseSeverity string  `json:"baseSeverity"`
+}
+
+type nvdMetric struct {
+	CvssData     nvdCvssData `json:"cvssData"`
+	BaseSeverity string      `json:"baseSeverity"` // only in v2
+}
+
+type nvdResponse struct {
+	Vulnerabilities []struct {
+		Cve struct {
+			ID           string `json:"id"`
+			Descriptions []struct {
+				Lang  string `json:"lang"`
+				Value string `json:"value"`
+			} `json:"descriptions"`
+			Metrics struct {
+				V31 []nvdMetric `json:"cvssMetricV31"`
+				V30 []nvdMetric `json:"cvssMetricV30"`
+				V2  []nvdMetric `json:"cvssMetricV2"`
+			} `json:"metrics"`
+		} `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+// https://nvd.nist.gov/developers/vulnerabilities
+func queryNvd(lib *VendoredLib, ver string) ([]*Vuln, error) {
+	cpe := fmt.Sprintf("cpe:2.3:a:%s:%s:*:*:*:*:*:*:*", lib.Cpe, ver)
+	uri := "https://services.nvd.nist.gov/rest/json/cves/2.0?cpeName=" + url.QueryEscape(cpe)
+	req, err := http.NewRequest(http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, err
+	}
+	if key := os.Getenv("NVD_API_KEY"); key != "" {
+		req.Header.Set("apiKey", key)
+	}
+	rsp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer rsp.Body.Close()
+	d, err := io.ReadAll(rsp.Body)
+	if err != nil {
+		return nil, err
+	}
+	if rsp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned %s", uri, rsp.Status)
+	}
+	var js nvdResponse
+	if err = json.Unmarshal(d, &js); err != nil {
+		return nil, err
+	}
+	var res []*Vuln
+	for _, v := range js.Vulnerabilities {
+		vuln := &Vuln{
+			Lib: lib.Name,
+			Ver: ver,
+			ID:  v.Cve.ID,
+		}
+		for _, desc := range v.Cve.Descriptions {
+			if desc.Lang == "en" {
+				vuln.Description = desc.Value
+			}
+		}
+		m := v.Cve.Metrics
+		switch {
+		case len(m.V31) > 0:
+			vuln.Severity, vuln.Score = m.V31[0].CvssData.BaseSeverity, m.V31[0].CvssData.BaseScore
+		case len(m.V30) > 0:
+			vuln.Severity, vuln.Score = m.V30[0].CvssData.BaseSeverity, m.V30[0].CvssData.BaseScore
+		case len(m.V2) > 0:
+			vuln.Severity, vuln.Score = m.V2[0].BaseSeverity, m.V2[0].CvssData.BaseScore
+		}
+		res = append(res, vuln)
+	}
+	return res, nil
+}
+
+// each line is: CVE-2020-1234 reason
+func readCveIgnoreList() map[string]bool {
+	res := map[string]bool{}
+	path := filepath.Join("do", "cve_ignore.txt")
+	if !fileExists(path) {
+		return res
+	}
+	for _, line := range strings.Split(string(readFileMust(path)), "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		res[strings.Fields(line)[0]] = true
+	}
+	return res
+}
+
+// returns critical vulnerabilities that are not in the ignore list and
+// libraries we couldn't check
+func checkVulns() ([]*Vuln, []string) {
+	ignored := readCveIgnoreList()
+	// without API key NVD allows 5 requests in 30 seconds
+	delay := time.Second * 6
+	if os.Getenv("NVD_API_KEY") != "" {
+		delay = time.Second
+	}
+	var critical []*Vuln
+	var failed []string
+	for i, lib := range vendoredLibs {
+		ver, err := detectLibVersion(lib)
+		if err != nil {
+			msg := fmt.Sprintf("%s: failed to detect version: %s", lib.Name, err)
+			logf("%s\n", msg)
+			failed = append(failed, msg)
+			continue
+		}
+		if i > 0 {
+			time.Sleep(delay)
+		}
+		vulns, err := queryNvd(lib, ver)
+		if err != nil {
+			msg := fmt.Sprintf("%s %s: failed to query NVD: %s", lib.Name, ver, err)
+			logf("%s\n", msg)
+			failed = append(failed, msg)
+			continue
+		}
+		sort.Slice(vulns, func(i, j int) bool {
+			return vulns[i].Score > vulns[j].Score
+		})
+		logf("%s %s: %d CVEs\n", lib.Name, ver, len(vulns))
+		for _, v := range vulns {
+			isIgnored := ignored[v.ID]
+			suffix := ""
+			if isIgnored {
+				suffix = " (ignored)"
+			}
+			logf("  %s %s %.1f%s\n", v.ID, v.Severity, v.Score, suffix)
+			if v.Severity == "CRITICAL" && !isIgnored {
+				critical = append(critical, v)
+			}
+		}
+	}
+	return critical, failed
+}
+
+func fmtVulns(vulns []*Vuln) string {
+	var lines []string
+	for _, v := range vulns {
+		lines = append(lines, fmt.Sprintf("%s %s: %s (%.1f) %s", v.Lib, v.Ver, v.ID, v.Score, v.Description))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func checkVulnsMust() {
+	critical, failed := checkVulns()
+	// not being able to check is not the same as having no vulnerabilities
+	panicIf(len(failed) > 0, "failed to check vendored libraries for CVEs:\n%s\n", strings.Join(failed, "\n"))
+	if len(critical) == 0 {
+		logf("no unpatched critical CVEs in vendored libraries\n")
+		return
+	}
+	panicIf(true, "critical CVEs in vendored libraries (add to do/cve_ignore.txt if patched):\n%s\n", fmtVulns(critical))
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// do report why-included <query> answers "why is this pulled into SumatraPDF.exe"
+// query is a case-insensitive substring of a .lib, .obj or symbol name
+// e.g. "gdiplus", "tif_dir.obj", "fz_open_document"
+// We link Release x64 with /VERBOSE which logs every object
+// loaded from a library together with the symbol that caused it and the
+// object referencing that symbol. Following that back to an object given
+// to the linker directly gives the inclusion chain.
+// The link log is cached in out/why-included, delete it to re-link
+
+const whyIncludedMaxMatches = 10
+
+const whyIncludedTargets = `<?xml version="1.0" encoding="utf-8"?>
+<Project xmlns="http://schemas.microsoft.com/developer/msbuild/2003">
+  <ItemDefinitionGroup>
+    <Link>
+      <AdditionalOptions>%(AdditionalOptions) /VERBOSE</AdditionalOptions>
+    </Link>
+  </ItemDefinitionGroup>
+</Project>
+`
+
+var (
+	rxLinkSearching    = regexp.MustCompile(`^\s*Searching (.+):\s*$`)
+	rxLinkFound        = regexp.MustCompile(`^\s*Found (\S+)\s*$`)
+	rxLinkReferencedIn = regexp.MustCompile(`^\s*Referenced in (\S+)\s*$`)
+	rxLinkLoaded       = regexp.MustCompile(`^\s*Loaded (\S+)\s*$`)
+)
+
+// LinkInclusion records why the linker loaded an object from a library
+type LinkInclusion struct {
+	// "libmupdf.lib(document.obj)" or "gdiplus.lib(gdiplus.dll)"
+	Loaded string
+	// library being searched, full path
+	Lib    string
+	Symbol string
+	// object that referenced Symbol, "document.obj"
+	ReferencedIn string
+}
+
+// "libmupdf.lib(document.obj)" => "document.obj"
+func linkObjectName(s string) string {
+	if i := strings.LastIndex(s, "("); i >= 0 && strings.HasSuffix(s, ")") {
+		return s[i+1 : len(s)-1]
+	}
+	return filepath.Base(s)
+}
+
+// parses output of link.exe /VERBOSE, only the first load of an object
+// is recorded
+func parseLinkInclusions(s string) []*LinkInclusion {
+	var res []*LinkInclusion
+	seen := map[string]bool{}
+	var lib, symbol, referencedIn string
+	for _, line := range strings.Split(s, "\n") {
+		line = strings.TrimRight(line, "\r")
+		line = rxMsbuildNodePrefix.ReplaceAllString(line, "")
+		if m := rxLinkSearching.FindStringSubmatch(line); m != nil {
+			lib = m[1]
+			continue
+		}
+		if m := rxLinkFound.FindStringSubmatch(line); m != nil {
+			symbol = m[1]
+			referencedIn = ""
+			continue
+		}
+		if m := rxLinkReferencedIn.FindStringSubmatch(line); m != nil {
+			if referencedIn == "" {
+				referencedIn = m[1]
+			}
+			continue
+		}
+		if m := rxLinkLoaded.FindStringSubmatch(line); m != nil {
+			loaded := m[1]
+			if seen[loaded] || symbol == "" {
+				continue
+			}
+			seen[loaded] = true
+			res = append(res, &LinkInclusion{Loaded: loaded, Lib: lib, Symbol: symbol, ReferencedIn: referencedIn})
+		}
+	}
+	return res
+}
+
+// returns chain of inclusions, starting with inc, ending with an object
+// that was given to the linker directly
+func linkInclusionChain(inc *LinkInclusion, byObject map[string]*LinkInclusion) []*LinkInclusion {
+	res := []*LinkInclusion{inc}
+	seen := map[*LinkInclusion]bool{inc: true}
+	for {
+		next := byObject[strings.ToLower(inc.ReferencedIn)]
+		if next == nil || seen[next] {
+			return res
+		}
+		seen[next] = true
+		res = append(res, next)
+		inc = next
+	}
+}
+
+func fmtWhyIncluded(query string, incs []*LinkInclusion) string {
+	byObject := map[string]*LinkInclusion{}
+	for _, inc := range incs {
+		byObject[strings.ToLower(linkObjectName(inc.Loaded))] = inc
+	}
+	q := strings.ToLower(query)
+	var matches []*LinkInclusion
+	for _, inc := range incs {
+		if strings.Contains(strings.ToLower(inc.Loaded), q) || strings.Contains(strings.ToLower(inc.Lib), q) || strings.Contains(strings.ToLower(inc.Symbol), q) {
+			matches = append(matches, inc)
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Sprintf("'%s' doesn't match any object loaded from a library\n", query)
+	}
+	s := fmt.Sprintf("'%s' matches %d objects loaded from libraries\n", query, len(matches))
+	for i, inc := range matches {
+		if i >= whyIncludedMaxMatches {
+			s += fmt.Sprintf("\n... and %d more\n", len(matches)-i)
+			break
+		}
+		s += "\n" + inc.Loaded + "\n"
+		for _, c := range linkInclusionChain(inc, byObject) {
+			s += fmt.Sprintf("  loaded for %s referenced in %s\n", c.Symbol, c.ReferencedIn)
+		}
+	}
+	return s
+}
+
+func whyIncluded(query string) {
+	outDir := filepath.Join("out", "why-included")
+	logPath := filepath.Join(outDir, "msbuild.log")
+	if fileExists(logPath) {
+		logf("using cached link log '%s', delete it to re-link\n", logPath)
+	} else {
+		must(os.RemoveAll(outDir))
+		createDirMust(outDir)
+		targetsPath := absPathMust(filepath.Join(outDir, "why-included.targets"))
+		writeFileMust(targetsPath, []byte(whyIncludedTargets))
+		slnPath := filepath.Join("vs2022", "SumatraPDF.sln")
+		flp := fmt.Sprintf(`/flp:logfile=%s;verbosity=normal`, logPath)
+		runExeLoggedMust(detectMsbuildPath(), slnPath, `/t:SumatraPDF:Rebuild`, `/p:Configuration=Release;Platform=x64`, "/p:ForceImportBeforeCppTargets="+targetsPath, `/m`, `/fl`, flp)
+	}
+	incs := parseLinkInclusions(string(readFileMust(logPath)))
+	logf("%s", fmtWhyIncluded(query, incs))
+}
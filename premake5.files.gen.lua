@@ -1,7 +1,8 @@
+-- auto-generated by do/project_files.go, edit sourceSets in do/source_sets.go and run: do gen projects
+
 function files_in_dir(dir, files_in_dir)
   local paths = {}
   for _, file in ipairs(files_in_dir) do
-    -- TODO: don't add "/" if dir ends with it of file starts with it
     local path = dir .. "/" .. file
     table.insert(paths, path)
   end
@@ -16,9 +17,9 @@ function preview_test_files()
     "StrUtil.*",
     "StrconvUtil.*",
   })
-  files {
-    "src/tools/preview_test.cpp",
-  }
+  files_in_dir("src/tools", {
+    "preview_test.cpp",
+  })
 end
 
 function makelzsa_files()
@@ -39,23 +40,42 @@ function makelzsa_files()
     "WinDynCalls.*",
     "WinUtil.*",
   })
+  files_in_dir("src/tools", {
+    "MakeLzSA.cpp",
+  })
+end
 
-  files {
-    "src/tools/MakeLzSA.cpp",
-  }
+function uia_files()
+  files_in_dir("src/uia", {
+    "Provider.*",
+    "StartPageProvider.*",
+    "DocumentProvider.*",
+    "PageProvider.*",
+    "TextRange.*",
+  })
 end
 
 function zlib_files()
   files_in_dir("ext/zlib", {
-    "adler32.c", "compress.c", "crc32.c", "deflate.c", "inffast.c",
-    "inflate.c", "inftrees.c", "trees.c", "zutil.c", "gzlib.c",
-    "gzread.c", "gzwrite.c", "gzclose.c",
+    "adler32.c",
+    "compress.c",
+    "crc32.c",
+    "deflate.c",
+    "inffast.c",
+    "inflate.c",
+    "inftrees.c",
+    "trees.c",
+    "zutil.c",
+    "gzlib.c",
+    "gzread.c",
+    "gzwrite.c",
+    "gzclose.c",
   })
 end
 
 function zlib_ng_files()
   files_in_dir("ext/zlib-ng", {
-    "adler32.c", 
+    "adler32.c",
     "chunkset.c",
     "compare258.c",
     "compress.c",
@@ -79,7 +99,6 @@ function zlib_ng_files()
     "uncompr.c",
     "zutil.c",
   })
-
   files_in_dir("ext/zlib-ng/arch/x86", {
     "*.c",
   })
@@ -140,33 +159,91 @@ end
 
 function libdjvu_files()
   files_in_dir("ext/libdjvu", {
-    "Arrays.cpp", "atomic.cpp", "BSByteStream.cpp", "BSEncodeByteStream.cpp",
-    "ByteStream.cpp", "DataPool.cpp", "DjVmDir0.cpp", "DjVmDoc.cpp", "DjVmNav.cpp",
-    "DjVuAnno.cpp", "DjVuDocEditor.cpp", "DjVuDocument.cpp", "DjVuDumpHelper.cpp",
-    "DjVuErrorList.cpp", "DjVuFile.cpp", "DjVuFileCache.cpp", "DjVuGlobal.cpp",
-    "DjVuGlobalMemory.cpp", "DjVuImage.cpp", "DjVuInfo.cpp", "DjVuMessage.cpp",
-    "DjVuMessageLite.cpp", "DjVuNavDir.cpp", "DjVuPalette.cpp", "DjVuPort.cpp",
-    "DjVuText.cpp", "DjVuToPS.cpp", "GBitmap.cpp", "GContainer.cpp", "GException.cpp",
-    "GIFFManager.cpp", "GMapAreas.cpp", "GOS.cpp", "GPixmap.cpp", "GRect.cpp",
-    "GScaler.cpp", "GSmartPointer.cpp", "GString.cpp", "GThreads.cpp",
-    "GUnicode.cpp", "GURL.cpp", "IFFByteStream.cpp", "IW44EncodeCodec.cpp",
-    "IW44Image.cpp", "JB2EncodeCodec.cpp", "DjVmDir.cpp", "JB2Image.cpp",
-    "JPEGDecoder.cpp", "MMRDecoder.cpp", "MMX.cpp", "UnicodeByteStream.cpp",
-    "XMLParser.cpp", "XMLTags.cpp", "ZPCodec.cpp", "ddjvuapi.cpp", "debug.cpp",
+    "Arrays.cpp",
+    "atomic.cpp",
+    "BSByteStream.cpp",
+    "BSEncodeByteStream.cpp",
+    "ByteStream.cpp",
+    "DataPool.cpp",
+    "DjVmDir0.cpp",
+    "DjVmDoc.cpp",
+    "DjVmNav.cpp",
+    "DjVuAnno.cpp",
+    "DjVuDocEditor.cpp",
+    "DjVuDocument.cpp",
+    "DjVuDumpHelper.cpp",
+    "DjVuErrorList.cpp",
+    "DjVuFile.cpp",
+    "DjVuFileCache.cpp",
+    "DjVuGlobal.cpp",
+    "DjVuGlobalMemory.cpp",
+    "DjVuImage.cpp",
+    "DjVuInfo.cpp",
+    "DjVuMessage.cpp",
+    "DjVuMessageLite.cpp",
+    "DjVuNavDir.cpp",
+    "DjVuPalette.cpp",
+    "DjVuPort.cpp",
+    "DjVuText.cpp",
+    "DjVuToPS.cpp",
+    "GBitmap.cpp",
+    "GContainer.cpp",
+    "GException.cpp",
+    "GIFFManager.cpp",
+    "GMapAreas.cpp",
+    "GOS.cpp",
+    "GPixmap.cpp",
+    "GRect.cpp",
+    "GScaler.cpp",
+    "GSmartPointer.cpp",
+    "GString.cpp",
+    "GThreads.cpp",
+    "GUnicode.cpp",
+    "GURL.cpp",
+    "IFFByteStream.cpp",
+    "IW44EncodeCodec.cpp",
+    "IW44Image.cpp",
+    "JB2EncodeCodec.cpp",
+    "DjVmDir.cpp",
+    "JB2Image.cpp",
+    "JPEGDecoder.cpp",
+    "MMRDecoder.cpp",
+    "MMX.cpp",
+    "UnicodeByteStream.cpp",
+    "XMLParser.cpp",
+    "XMLTags.cpp",
+    "ZPCodec.cpp",
+    "ddjvuapi.cpp",
+    "debug.cpp",
     "miniexp.cpp",
   })
 end
 
 function unarrr_lzmasdk_files()
   files_in_dir("ext/unarr/lzmasdk", {
-    "CpuArch.c", "Ppmd7.c", "Ppmd7Dec.c", "Ppmd8.c", "Ppmd8Dec.c",
+    "CpuArch.c",
+    "Ppmd7.c",
+    "Ppmd7Dec.c",
+    "Ppmd8.c",
+    "Ppmd8Dec.c",
   })
 end
 
 function unarr_lzma_files()
   files_in_dir("ext/lzma/C", {
-    "LzmaDec.c", "Bra86.c", "LzmaEnc.c", "LzFind.c", "LzFindMt.c", "Threads.c",
-    "7zBuf.c", "7zDec.c", "7zIn.c", "7zStream.c", "Bcj2.c", "Bra.c", "Lzma2Dec.c",
+    "LzmaDec.c",
+    "Bra86.c",
+    "LzmaEnc.c",
+    "LzFind.c",
+    "LzFindMt.c",
+    "Threads.c",
+    "7zBuf.c",
+    "7zDec.c",
+    "7zIn.c",
+    "7zStream.c",
+    "Bcj2.c",
+    "Bra.c",
+    "Lzma2Dec.c",
   })
 end
 
@@ -177,7 +254,6 @@ function unarr_files()
     "ext/unarr/zip/*",
     "ext/unarr/tar/*",
     "ext/unarr/_7z/*",
-
     "ext/bzip2/bzip_all.c",
   }
   unarrr_lzmasdk_files()
@@ -191,14 +267,12 @@ function unarr_no_lzma_files()
     "ext/unarr/zip/*",
     "ext/unarr/tar/*",
     "ext/unarr/_7z/*",
-
     "ext/unarr/lzmasdk/LzmaDec.*",
     "ext/bzip2/bzip_all.c",
   }
   unarrr_lzmasdk_files()
 end
 
-
 function unarr_no_bzip_files()
   files {
     "ext/unarr/common/*",
@@ -212,8 +286,6 @@ function unarr_no_bzip_files()
 end
 
 function jbig2dec_files()
-  -- TODO: probably can be
-  -- files { "ext/jbig2dec/jbig2*.c", "ext/jbig2dec/jbig2*.h" }
   files_in_dir("ext/jbig2dec", {
     "jbig2.c",
     "jbig2_arith.c",
@@ -233,7 +305,7 @@ function jbig2dec_files()
   })
 end
 
-function libheif_files() 
+function libheif_files()
   files_in_dir("ext/libheif/libheif", {
     "bitstream.*",
     "box.*",
@@ -250,7 +322,6 @@ function libheif_files()
     "heif_plugin_registry.*",
     "nclx.*",
   })
-
 end
 
 function dav1d_x68_files()
@@ -259,7 +330,6 @@ function dav1d_x68_files()
     "msac_init.c",
     "refmvs_init.c",
   })
-
   files_in_dir("ext/dav1d/src/x86", {
     "cpuid.asm",
     "msac.asm",
@@ -334,20 +404,22 @@ function dav1d_files()
     "wedge.c",
     "win32/thread.c",
   })
-
   files_in_dir("ext/dav1d/src", {
     "sumatra_bitdepth_8.c",
     "sumatra_bitdepth_8_2.c",
     "sumatra_bitdepth_16.c",
     "sumatra_bitdepth_16_2.c",
   })
-
-  files("ext/dav1d/include/common/*.h")
-  files("ext/dav1d/include/dav1d/*.h")
+  files {
+    "ext/dav1d/include/common/*.h",
+  }
+  files {
+    "ext/dav1d/include/dav1d/*.h",
+  }
 end
 
 function openjpeg_files()
-  files_in_dir( "ext/openjpeg/src/lib/openjp2", {
+  files_in_dir("ext/openjpeg/src/lib/openjp2", {
     "bio.c",
     "cidx_manager.c",
     "cio.c",
@@ -363,7 +435,6 @@ function openjpeg_files()
     "mqc.c",
     "openjpeg.c",
     "opj_clock.c",
-    --"opj_malloc.c",
     "phix_manager.c",
     "pi.c",
     "ppix_manager.c",
@@ -409,8 +480,9 @@ function extract_files()
 end
 
 function libwebp_files()
-  files("ext/libwebp/src/dec/*.c")
-
+  files {
+    "ext/libwebp/src/dec/*.c",
+  }
   files_in_dir("ext/libwebp/src/dsp", {
     "alpha_processing.c",
     "alpha_processing_sse2.c",
@@ -444,59 +516,140 @@ function libwebp_files()
     "yuv_sse41.c",
     "yuv_neon.c",
   })
-
-  files("ext/libwebp/src/sharpyuv/*.c")
-
-  files("ext/libwebp/src/utils/*.c")
+  files {
+    "ext/libwebp/src/sharpyuv/*.c",
+  }
+  files {
+    "ext/libwebp/src/utils/*.c",
+  }
 end
 
 function libjpeg_turbo_files()
   files_in_dir("ext/libjpeg-turbo", {
-    "jcomapi.c", "jdapimin.c", "jdapistd.c", "jdatadst.c", "jdatasrc.c",
-    "jdcoefct.c", "jdcolor.c", "jddctmgr.c", "jdhuff.c", "jdinput.c", "jdmainct.c",
-    "jdmarker.c", "jdmaster.c", "jdmerge.c", "jdpostct.c", "jdsample.c", "jdtrans.c",
-    "jerror.c", "jfdctflt.c", "jfdctint.c", "jidctflt.c", "jidctfst.c",
-    "jidctint.c", "jquant1.c", "jquant2.c", "jutils.c", "jmemmgr.c", "jmemnobs.c",
-    "jaricom.c", "jdarith.c", "jfdctfst.c", "jdphuff.c", "jidctred.c",
-    "jcapimin.c", "jcapistd.c", "jcarith.c", "jccoefct.c", "jccolor.c",
-    "jcdctmgr.c", "jchuff.c", "jcinit.c", "jcmainct.c", "jcmarker.c",
-    "jcmaster.c", "jcparam.c", "jcprepct.c", "jcsample.c", "jcphuff.c"
+    "jcomapi.c",
+    "jdapimin.c",
+    "jdapistd.c",
+    "jdatadst.c",
+    "jdatasrc.c",
+    "jdcoefct.c",
+    "jdcolor.c",
+    "jddctmgr.c",
+    "jdhuff.c",
+    "jdinput.c",
+    "jdmainct.c",
+    "jdmarker.c",
+    "jdmaster.c",
+    "jdmerge.c",
+    "jdpostct.c",
+    "jdsample.c",
+    "jdtrans.c",
+    "jerror.c",
+    "jfdctflt.c",
+    "jfdctint.c",
+    "jidctflt.c",
+    "jidctfst.c",
+    "jidctint.c",
+    "jquant1.c",
+    "jquant2.c",
+    "jutils.c",
+    "jmemmgr.c",
+    "jmemnobs.c",
+    "jaricom.c",
+    "jdarith.c",
+    "jfdctfst.c",
+    "jdphuff.c",
+    "jidctred.c",
+    "jcapimin.c",
+    "jcapistd.c",
+    "jcarith.c",
+    "jccoefct.c",
+    "jccolor.c",
+    "jcdctmgr.c",
+    "jchuff.c",
+    "jcinit.c",
+    "jcmainct.c",
+    "jcmarker.c",
+    "jcmaster.c",
+    "jcparam.c",
+    "jcprepct.c",
+    "jcsample.c",
+    "jcphuff.c",
   })
-
-  filter {'platforms:arm64'}
-    files {"ext/libjpeg-turbo/jsimd_none.c"}
-
-  filter {'platforms:x32'}
+  filter {"platforms:arm64"}
+    files {
+      "ext/libjpeg-turbo/jsimd_none.c",
+    }
+  filter {"platforms:x32"}
     files_in_dir("ext/libjpeg-turbo/simd", {
-      "jsimdcpu.asm", "jccolmmx.asm", "jcgrammx.asm", "jdcolmmx.asm",
-    	"jcsammmx.asm", "jdsammmx.asm", "jdmermmx.asm", "jcqntmmx.asm",
-    	"jfmmxfst.asm", "jfmmxint.asm", "jimmxred.asm", "jimmxint.asm",
-    	"jimmxfst.asm", "jcqnt3dn.asm", "jf3dnflt.asm", "ji3dnflt.asm",
-    	"jcqntsse.asm", "jfsseflt.asm", "jisseflt.asm", "jccolss2.asm",
-    	"jcgrass2.asm", "jdcolss2.asm", "jcsamss2.asm", "jdsamss2.asm",
-    	"jdmerss2.asm", "jcqnts2i.asm", "jfss2fst.asm", "jfss2int.asm",
-    	"jiss2red.asm", "jiss2int.asm", "jiss2fst.asm", "jcqnts2f.asm",
-    	"jiss2flt.asm",
+      "jsimdcpu.asm",
+      "jccolmmx.asm",
+      "jcgrammx.asm",
+      "jdcolmmx.asm",
+      "jcsammmx.asm",
+      "jdsammmx.asm",
+      "jdmermmx.asm",
+      "jcqntmmx.asm",
+      "jfmmxfst.asm",
+      "jfmmxint.asm",
+      "jimmxred.asm",
+      "jimmxint.asm",
+      "jimmxfst.asm",
+      "jcqnt3dn.asm",
+      "jf3dnflt.asm",
+      "ji3dnflt.asm",
+      "jcqntsse.asm",
+      "jfsseflt.asm",
+      "jisseflt.asm",
+      "jccolss2.asm",
+      "jcgrass2.asm",
+      "jdcolss2.asm",
+      "jcsamss2.asm",
+      "jdsamss2.asm",
+      "jdmerss2.asm",
+      "jcqnts2i.asm",
+      "jfss2fst.asm",
+      "jfss2int.asm",
+      "jiss2red.asm",
+      "jiss2int.asm",
+      "jiss2fst.asm",
+      "jcqnts2f.asm",
+      "jiss2flt.asm",
     })
-    files {"ext/libjpeg-turbo/simd/jsimd_i386.c"}
-
-  filter {'platforms:x64 or x64_asan'}
+    files {
+      "ext/libjpeg-turbo/simd/jsimd_i386.c",
+    }
+  filter {"platforms:x64 or x64_asan"}
     files_in_dir("ext/libjpeg-turbo/simd", {
-      "jfsseflt-64.asm", "jccolss2-64.asm", "jdcolss2-64.asm", "jcgrass2-64.asm",
-    	"jcsamss2-64.asm", "jdsamss2-64.asm", "jdmerss2-64.asm", "jcqnts2i-64.asm",
-    	"jfss2fst-64.asm", "jfss2int-64.asm", "jiss2red-64.asm", "jiss2int-64.asm",
-    	"jiss2fst-64.asm", "jcqnts2f-64.asm", "jiss2flt-64.asm",
+      "jfsseflt-64.asm",
+      "jccolss2-64.asm",
+      "jdcolss2-64.asm",
+      "jcgrass2-64.asm",
+      "jcsamss2-64.asm",
+      "jdsamss2-64.asm",
+      "jdmerss2-64.asm",
+      "jcqnts2i-64.asm",
+      "jfss2fst-64.asm",
+      "jfss2int-64.asm",
+      "jiss2red-64.asm",
+      "jiss2int-64.asm",
+      "jiss2fst-64.asm",
+      "jcqnts2f-64.asm",
+      "jiss2flt-64.asm",
     })
-    files {"ext/libjpeg-turbo/simd/jsimd_x86_64.c"}
-
+    files {
+      "ext/libjpeg-turbo/simd/jsimd_x86_64.c",
+    }
   filter {}
 end
 
 function lcms2_files()
   files_in_dir("ext/lcms2/src", {
-    "*.c", "*.h"
+    "*.c",
+    "*.h",
   })
-  files{ "ext/lcms/include.*.h" }
+  files {
+    "ext/lcms/include.*.h",
+  }
 end
 
 function harfbuzz_files()
@@ -572,11 +725,8 @@ function freetype_files()
     "ftsynth.c",
     "ftsystem.c",
     "fttype1.c",
-
-    -- TODO: temporary
-    "ftotval.c"
+    "ftotval.c",
   })
-
   files_in_dir("ext/freetype/src", {
     "cff/cff.c",
     "psaux/psaux.c",
@@ -591,9 +741,6 @@ function freetype_files()
   })
 end
 
-files {
-}
-
 function sumatrapdf_files()
   files_in_dir("src", {
     "Accelerators.*",
@@ -661,7 +808,6 @@ function sumatrapdf_files()
     "Uninstaller.cpp",
     "Widget.*",
     "WindowTab.*",
-
     "ext/versions.txt",
     "scratch.txt",
     "../docs/*.txt",
@@ -677,27 +823,16 @@ function sumatrapdf_files()
       "TestApp.cpp",
       "TestTab.cpp",
       "TestLayout.cpp",
-      --"TestLice.cpp",
     })
     files_in_dir("src/utils/tests", {
       "*.cpp",
     })
     files_in_dir("src/utils", {
-        "UtAssert.*",
+      "UtAssert.*",
     })
   filter {}
 end
 
-function uia_files()
-  files_in_dir("src/uia", {
-    "Provider.*",
-    "StartPageProvider.*",
-    "DocumentProvider.*",
-    "PageProvider.*",
-    "TextRange.*",
-  })
-end
-
 function utils_files()
   files_in_dir("src/utils", {
     "AvifReader.*",
@@ -800,19 +935,21 @@ end
 function chm_files()
   files_in_dir("ext/CHMLib/src", {
     "chm_lib.c",
-    "lzx.c" ,
+    "lzx.c",
   })
 end
 
 function mupdf_files()
-  files { "ext/mupdf_load_system_font.c" }
-
+  files {
+    "ext/mupdf_load_system_font.c",
+  }
   files_in_dir("mupdf/source/cbz", {
     "mucbz.c",
     "muimg.c",
   })
-
-  files { "mupdf/source/fitz/*.h" }
+  files {
+    "mupdf/source/fitz/*.h",
+  }
   files_in_dir("mupdf/source/fitz", {
     "archive.c",
     "bbox-device.c",
@@ -882,7 +1019,6 @@ function mupdf_files()
     "load-jbig2.c",
     "load-jpeg.c",
     "load-jpx.c",
-    -- "load-jxr.c",
     "load-jxr-win.c",
     "load-png.c",
     "load-pnm.c",
@@ -943,7 +1079,6 @@ function mupdf_files()
     "xml.c",
     "zip.c",
   })
-
   files_in_dir("mupdf/source/html", {
     "css-apply.c",
     "css-parse.c",
@@ -961,7 +1096,6 @@ function mupdf_files()
     "txt.c",
     "xml-dom.c",
   })
-
   files_in_dir("mupdf/source/pdf", {
     "pdf-annot.c",
     "pdf-appearance.c",
@@ -1013,14 +1147,12 @@ function mupdf_files()
     "pdf-xref.c",
     "*.h",
   })
-
   files_in_dir("mupdf/source/svg", {
     "svg-color.c",
     "svg-doc.c",
     "svg-parse.c",
     "svg-run.c",
   })
-
   files_in_dir("mupdf/source/xps", {
     "xps-common.c",
     "xps-doc.c",
@@ -1042,7 +1174,7 @@ function mupdf_files()
     "mupdf/include/mupdf/fitz/*.h",
     "mupdf/include/mupdf/helpers/*.h",
     "mupdf/include/mupdf/pdf/*.h",
-    "mupdf/include/mupdf/*.h"
+    "mupdf/include/mupdf/*.h",
   }
 end
 
@@ -1063,32 +1195,32 @@ end
 
 function mutools_files()
   files_in_dir("mupdf/source/tools", {
-      "mudraw.c",
-      "mutool.c",
-      "pdfclean.c",
-      "pdfextract.c",
-      "pdfinfo.c",
-      "pdfposter.c",
-      "pdfshow.c",
+    "mudraw.c",
+    "mutool.c",
+    "pdfclean.c",
+    "pdfextract.c",
+    "pdfinfo.c",
+    "pdfposter.c",
+    "pdfshow.c",
   })
 end
 
 function mutool_files()
-  mudoc_files() -- TODO: could turn into a .lib
+  mudoc_files()
   files_in_dir("mupdf/source/tools", {
-      "mutool.c",
-      "pdfshow.c",
-      "pdfclean.c",
-      "pdfinfo.c",
-      "pdfextract.c",
-      "pdfposter.c",
+    "mutool.c",
+    "pdfshow.c",
+    "pdfclean.c",
+    "pdfinfo.c",
+    "pdfextract.c",
+    "pdfposter.c",
   })
 end
 
 function mudraw_files()
   mudoc_files()
   files_in_dir("mupdf/source/tools", {
-      "mudraw.c",
+    "mudraw.c",
   })
 end
 
@@ -1117,7 +1249,7 @@ function sizer_files()
 end
 
 function test_util_files()
-  files_in_dir( "src/utils", {
+  files_in_dir("src/utils", {
     "BaseUtil.*",
     "BitManip.*",
     "ByteOrderDecoder.*",
@@ -1146,17 +1278,15 @@ function test_util_files()
     "Vec.*",
     "WinUtil.*",
     "WinDynCalls.*",
-    "tests/*"
+    "tests/*",
   })
   files_in_dir("src", {
-    --"StressTesting.*",
-    --"AppTools.*",
     "DisplayMode.*",
     "Flags.*",
     "SumatraConfig.*",
     "SettingsStructs.*",
     "SumatraUnitTests.cpp",
-    "tools/test_util.cpp"
+    "tools/test_util.cpp",
   })
 end
 
@@ -1166,7 +1296,7 @@ function engine_dump_files()
     "SumatraConfig.*",
     "FzImgReader.*",
     "mui/Mui.*",
-    "mui/TextRender.*"
+    "mui/TextRender.*",
   })
 end
 
@@ -1176,7 +1306,6 @@ function pdf_preview_files()
     "PdfPreviewBase.h",
     "PdfPreviewDll.cpp",
   })
-
   files_in_dir("src", {
     "utils/LogDbg.*",
     "mui/Mui.*",
@@ -1221,7 +1350,6 @@ function search_filter_files()
     "MobiDoc.*",
     "EbookDoc.*",
   })
-
   filter {"configurations:Debug"}
     files_in_dir("src/ifilter", {
       "TeXFilter.*",
@@ -1235,65 +1363,6 @@ function search_filter_files()
   filter {}
 end
 
-
---[[
-function wdl_files()
-  files_in_dir("ext/WDL", {
-    "projectcontext.cpp",
-  })
-
-  files_in_dir("ext/WDL/tinyxml", {
-    "*.cpp",
-    "*.c",
-    "*.h",
-  })
-
-  files_in_dir("ext/WDL/lice", {
-    "lice.*",
-    "lice_arc.cpp",
-    "lice_bezier.h",
-    -- "lice_bmp.cpp",
-    "lice_colorspace.*",
-    "lice_combine.h",
-    "lice_extended.h",
-    -- "lice_gif.cpp",
-    -- "lice_gif_write.cpp",
-    -- "lice_gl_ctx.*",
-    -- "lice_glbitmap.*",
-    -- "lice_ico.cpp",
-    -- "lice_image.cpp",
-    "lice_import.h",
-    -- "lice_jpg.cpp",
-    -- "lice_jpg_write.cpp",
-    -- "lice_lcf.*",
-    "lice_line.cpp",
-    "lice_lvg.cpp",
-    "lice_palette.cpp",
-    -- "lice_pcx.cpp",
-    -- "lice_png.cpp",
-    -- "lice_png_write.cpp",
-    "lice_svg.cpp",
-    -- "lice_texgen.cpp",
-    "lice_text.*",
-    "lice_textnew.cpp",
-  })
-
-  files_in_dir("ext/WDL/wingui", {
-    "dlgitemborder.h",
-    "membitmap.h",
-    "riceditctrl.h",
-    "virtwnd.*",
-    "virtwnd-controls.h",
-    "virtwnd-iaccessible.cpp",
-    "virtwnd-iconbutton.cpp",
-    "virtwnd-listbox.cpp",
-    "virtwnd-skin.h",
-    "virtwnd-slider.cpp",
-    "wndsize.*",
-  })
-end
---]]
-
 function gumbo_files()
   files_in_dir("ext/gumbo-parser/src", {
     "*.c",
@@ -1306,6 +1375,6 @@ end
 
 function bin2coff_files()
   files_in_dir("tools", {
-    "bin2coff.c"
+    "bin2coff.c",
   })
-end
\ No newline at end of file
+end
@@ -56,7 +56,8 @@ Prefast:
  28253 - Inconsistent annotaion
 --]]
 
-include("premake5.files.lua")
+-- generated by: do gen projects
+include("premake5.files.gen.lua")
 
 -- TODO: could fold 9 libraries used by mupdf into a single
 -- project mupdf-libs, to make solution smaller
@@ -1037,7 +1038,10 @@ workspace "SumatraPDF"
     linkoptions { "/DELAYLOAD:urlmon.dll /DELAYLOAD:wininet.dll" }
     linkoptions { "/DELAYLOAD:uiautomationcore.dll" }
     dependson { "PdfFilter", "PdfPreview", "test_util" }
-    prebuildcommands { "cd %{cfg.targetdir} & ..\\..\\bin\\MakeLZSA.exe InstallerData.dat libmupdf.dll:libmupdf.dll PdfFilter.dll:PdfFilter.dll PdfPreview.dll:PdfPreview.dll"  }
+    -- do build passes MakeLzsaExe built from src/tools/MakeLzSA.cpp, which creates
+    -- the same InstallerData.dat from the same files. bin\MakeLZSA.exe stores file times
+    local makelzsa_args = "InstallerData.dat libmupdf.dll:libmupdf.dll PdfFilter.dll:PdfFilter.dll PdfPreview.dll:PdfPreview.dll"
+    prebuildcommands { "cd %{cfg.targetdir} & if \"$(MakeLzsaExe)\"==\"\" (..\\..\\bin\\MakeLZSA.exe " .. makelzsa_args .. ") else (\"$(MakeLzsaExe)\" " .. makelzsa_args .. ")" }
 
 workspace "MakeLZSA"
   configurations { "Debug", "Release" }
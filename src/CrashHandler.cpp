@@ -55,7 +55,8 @@ static char* BuildSymbolsUrl() {
         // assuming this is release version
         urlBase = "https://www.sumatrapdfreader.org/dl/rel/SumatraPDF-" QM(CURR_VERSION);
     }
-    const char* suff = "-32.pdb.lzsa";
+    // 32-bit release files have no suffix, must match getPrefixForPlatform() in do/build.go
+    const char* suff = gIsPreReleaseBuild ? "-32.pdb.lzsa" : ".pdb.lzsa";
 #if IS_ARM_64 == 1
     suff = "-arm64.pdb.lzsa";
 #elif IS_INTEL_64 == 1
@@ -422,11 +422,12 @@ static EngineBase* CreateEngineMultiFromFiles(const char* dir, StrVec& files) {
 }
 
 // clang-format off
+// generated with .\doit.bat docs gen from DEF_EXT_KIND in utils/GuessFileType.cpp
 static SeqStrings gSupportedExtsForMulti = 
-    ".pdf\0.xps\0.oxps\0.cbz\0.cbr\0.cb7\0.cbt\0" \
-    ".djvu\0.chm\0.mobi\0.epub\0.azw\0.azw3\0.azw4\0" \
-    ".fb2\0.fb2z\0.prc\0.tif\0.tiff\0.jp2\0.png\0" \
-    ".jpg\0.jpeg\0.tga\0.gif\0.avif\0.heic\0";
+    ".fb2\0.fb2z\0.cbz\0.cbr\0.cb7\0.cbt\0.pdf\0" \
+    ".xps\0.oxps\0.chm\0.png\0.jpg\0.jpeg\0.gif\0" \
+    ".tif\0.tiff\0.tga\0.webp\0.epub\0.mobi\0.prc\0" \
+    ".azw\0.azw3\0.azw4\0.djvu\0.jp2\0.heic\0.avif\0";
 // clang-format on
 
 static bool isSupportedForMultis(const char* path) {
@@ -1051,7 +1051,12 @@ bool MaybeMismatchedOSDialog(HWND hwndParent) {
 }
 
 int RunInstaller() {
-    trans::SetCurrentLangByCode(trans::DetectUserLang());
+    // -lang overrides language detected from Windows UI language
+    const char* lang = trans::DetectUserLang();
+    if (gCli->lang && trans::ValidateLangCode(gCli->lang)) {
+        lang = trans::ValidateLangCode(gCli->lang);
+    }
+    trans::SetCurrentLangByCode(lang);
 
     const char* installerLogPath = nullptr;
     if (gCli->log) {
@@ -1060,6 +1065,7 @@ int RunInstaller() {
         StartLogToFile(installerLogPath, removeLog);
     }
     logf("------------- Starting SumatraPDF installation\n");
+    logf("installer language: '%s'\n", trans::GetCurrentLangCode());
 
     gWnd = new InstallerWnd();
     GetPreviousInstallInfo(&gWnd->prevInstall);
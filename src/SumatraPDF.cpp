@@ -5821,6 +5821,9 @@ void GetProgramInfo(str::Str& s) {
     }
     if (IsProcess64()) {
         s.Append(" 64-bit");
+#if IS_ARM_64 == 1
+        s.Append(" arm64");
+#endif
     } else {
         s.Append(" 32-bit");
         if (IsRunningInWow64()) {
@@ -177,7 +177,7 @@ void ZipCreateTest() {
         printf("ZipCreateTest(): failed to add makefile.msvc");
         return;
     }
-    ok = zc.AddFile("premake5.files.lua");
+    ok = zc.AddFile("premake5.files.gen.lua");
     if (!ok) {
         printf("ZipCreateTest(): failed to add makefile.msvc");
         return;
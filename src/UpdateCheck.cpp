@@ -64,6 +64,8 @@ bool gUpdateCheckInProgress = false;
 struct UpdateInfo {
     HWND hwndParent = nullptr;
     const char* latestVer = nullptr;
+    // percentage of users that should be offered the update
+    int rolloutPercent = 100;
     const char* installer64 = nullptr;
     const char* installerArm64 = nullptr;
     const char* installer32 = nullptr;
@@ -100,6 +102,9 @@ PortableExe64: https://www.sumatrapdfreader.org/dl/prerel/14276/SumatraPDF-prere
 PortableExe32: https://www.sumatrapdfreader.org/dl/prerel/14276/SumatraPDF-prerel.exe
 PortableZip64: https://www.sumatrapdfreader.org/dl/prerel/14276/SumatraPDF-prerel-64.zip
 PortableZip32: https://www.sumatrapdfreader.org/dl/prerel/14276/SumatraPDF-prerel.zip
+Rollout: 25
+
+Rollout is optional, see IsInRollout()
 */
 static UpdateInfo* ParseUpdateInfo(const char* d) {
     // if a user configures os-wide proxy that is not a regular ie proxy
@@ -124,6 +129,13 @@ static UpdateInfo* ParseUpdateInfo(const char* d) {
     }
     auto res = new UpdateInfo();
     res->latestVer = str::Dup(latestVer);
+    const char* rollout = node->GetValue("Rollout");
+    if (rollout) {
+        int n = atoi(rollout);
+        if (n >= 0 && n <= 100) {
+            res->rolloutPercent = n;
+        }
+    }
 
     // those are optional. if missing, we'll just tell the user to go to website to download
     res->installer64 = str::Dup(node->GetValue("Installer64"));
@@ -147,6 +159,23 @@ static UpdateInfo* ParseUpdateInfo(const char* d) {
     return res;
 }
 
+// for staged rollout only rolloutPercent of computers are offered the update by
+// automatic update check. A computer is in the same bucket for a given version
+// on every check. Including the version makes different computers be first
+// for different releases
+static bool IsInRollout(const char* ver, int rolloutPercent) {
+    if (rolloutPercent >= 100) {
+        return true;
+    }
+    const char* id = ReadRegStrTemp(HKEY_LOCAL_MACHINE, "SOFTWARE\\Microsoft\\Cryptography", "MachineGuid");
+    if (!id) {
+        id = "";
+    }
+    TempStr s = str::JoinTemp(id, ver);
+    int bucket = (int)(MurmurHash2(s, str::Len(s)) % 100);
+    return bucket < rolloutPercent;
+}
+
 static bool ShouldCheckForUpdate(UpdateCheck updateCheckType) {
     if (gUpdateCheckInProgress) {
         logf("CheckForUpdate: skipping because gUpdateCheckInProgress\n");
@@ -347,6 +376,11 @@ static DWORD ShowAutoUpdateDialog(HWND hwndParent, HttpRsp* rsp, UpdateCheck upd
                      latestVer);
                 return 0;
             }
+            if (!IsInRollout(latestVer, updateInfo->rolloutPercent)) {
+                logf("ShowAutoUpdateDialog: skipping auto-update of ver '%s' because not in %d%% rollout\n", latestVer,
+                     updateInfo->rolloutPercent);
+                return 0;
+            }
         }
     }
 
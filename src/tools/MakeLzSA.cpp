@@ -78,17 +78,27 @@ static bool Compress(const char* uncompressed, size_t uncompressedSize, char* co
     return true;
 }
 
+// all entries get the same modification time so that archives created
+// from identical files are identical. Must match archiveModTime in do/build.go
+// 2000-01-01 00:00:00 UTC
+static const FILETIME kArchiveModTime = {0x256d4000, 0x01bf53eb};
+
 static bool AppendEntry(str::Str& data, str::Str& content, const WCHAR* filePath, const char* inArchiveName,
                         lzma::FileInfo* fi = nullptr) {
     size_t nameLen = str::Len(inArchiveName);
     CrashIf(nameLen > UINT32_MAX - 25);
     u32 headerSize = 25 + (u32)nameLen;
-    FILETIME ft = file::GetModificationTime(filePath);
+    FILETIME ft = kArchiveModTime;
 
     constexpr size_t kBufSize = 24;
 
-    if (fi && FileTimeEq(ft, fi->ftModified)) {
-    ReusePrevious:
+    ByteSlice fileData = file::ReadFile(filePath);
+    if (!fileData.data() || fileData.size() >= UINT32_MAX) {
+        fprintf(stderr, "Failed to read \"%S\" for compression\n", filePath);
+        return false;
+    }
+    u32 fileDataCrc = crc32(0, (const u8*)fileData.data, (u32)fileData.size());
+    if (fi && fi->uncompressedCrc32 == fileDataCrc && fi->uncompressedSize == fileData.size()) {
         ByteWriterLE meta(kBufSize);
         meta.Write32(headerSize);
         meta.Write32(fi->compressedSize);
@@ -102,15 +112,6 @@ static bool AppendEntry(str::Str& data, str::Str& content, const WCHAR* filePath
         return content.Append(fi->compressedData, fi->compressedSize);
     }
 
-    ByteSlice fileData = file::ReadFile(filePath);
-    if (!fileData.data() || fileData.size() >= UINT32_MAX) {
-        fprintf(stderr, "Failed to read \"%S\" for compression\n", filePath);
-        return false;
-    }
-    u32 fileDataCrc = crc32(0, (const u8*)fileData.data, (u32)fileData.size());
-    if (fi && fi->uncompressedCrc32 == fileDataCrc && fi->uncompressedSize == fileData.size())
-        goto ReusePrevious;
-
     size_t compressedSize = fileData.size() + 1;
     AutoFree compressed((char*)malloc(compressedSize));
     if (!compressed.Get()) {
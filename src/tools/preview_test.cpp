@@ -6,6 +6,9 @@
 #include <ObjBase.h>
 #include <Shlwapi.h>
 #include <Thumbcache.h>
+#include <ShObjIdl.h>
+#include <Filter.h>
+#include <FiltErr.h>
 #include <Unknwn.h>
 
 #include <stdio.h>
@@ -14,6 +17,8 @@
 
 #pragma comment(lib, "Shlwapi.lib")
 #pragma comment(lib, "Ole32.lib")
+#pragma comment(lib, "User32.lib")
+#pragma comment(lib, "Gdi32.lib")
 
 #define kPdfPreviewClsid L"{3D3B1846-CC43-42AE-BFF9-D914083C2BA3}"
 #define kXpsPreviewClsid L"{D427A82C-6545-4FBE-8E87-030EDB3BE46D}"
@@ -23,6 +28,7 @@
 #define kMobiPreviewClsid L"{42CA907E-BDF5-4A75-994A-E1AEC8A10954}"
 #define kCbxPreviewClsid L"{C29D3E2B-8FF6-4033-A4E8-54221D859D74}"
 #define kTgaPreviewClsid L"{CB1D63A6-FE5E-4DED-BEA5-3F6AF1A70D08}"
+#define kPdfFilterClsid L"{55808EA8-81FE-43c6-AAE8-1D8149F941D3}"
 
 // Our GUID here:
 LPCOLESTR myGuid = kPdfPreviewClsid;
@@ -44,29 +50,38 @@ void log(const char* s) {
 }
 
 constexpr const char* kPdfPreviewDllName = "PdfPreview.dll";
+constexpr const char* kPdfFilterDllName = "PdfFilter.dll";
 
-int main(int c, char** v) {
-    GUID clsid{};
-    IIDFromString(myGuid, &clsid);
+// usage: PdfPreviewTest.exe [-com] [-thumb|-preview|-filter] <file>
+// -thumb (default) : IThumbnailProvider from PdfPreview.dll
+// -preview         : IPreviewHandler from PdfPreview.dll
+// -filter          : IFilter from PdfFilter.dll, prints extracted text
+// -com             : create objects via COM i.e. dll must be registered
+//                    (regsvr32). default is to load the dll directly
+static bool gUseCom = false;
 
-    if (c < 2) {
-        printf("not enough arguments: file name\n");
-        return 1;
+static HRESULT GetClassFactory(const char* dllName, LPCOLESTR clsidStr, IClassFactory** factory) {
+    GUID clsid{};
+    IIDFromString(clsidStr, &clsid);
+    if (gUseCom) {
+        return CoGetClassObject(clsid, CLSCTX_INPROC_SERVER, NULL, IID_IClassFactory, (void**)factory);
     }
-    HRESULT r;
-    IStream* pStream = NULL;
-    HMODULE dll = NULL;
-
-    dll = LoadLibraryA(kPdfPreviewDllName);
+    HMODULE dll = LoadLibraryA(dllName);
     if (!dll) {
-        printf("can't open DLL\n");
-        return 1;
+        printf("can't open DLL %s\n", dllName);
+        return E_FAIL;
     }
+    auto ourDllGetClassObject = (ourDllGetClassObjectT*)GetProcAddress(dll, "DllGetClassObject");
+    if (!ourDllGetClassObject) {
+        return E_FAIL;
+    }
+    return ourDllGetClassObject(clsid, IID_IClassFactory, (void**)factory);
+}
 
-    ourDllGetClassObjectT* ourDllGetClassObject = (ourDllGetClassObjectT*)GetProcAddress(dll, "DllGetClassObject");
-
+// creates the object and initializes it with a stream of the file
+static int CreateInitialized(const char* dllName, LPCOLESTR clsidStr, const char* path, REFIID riid, void** ppv) {
     IClassFactory* pFactory = NULL;
-    r = ourDllGetClassObject(clsid, IID_IClassFactory, (void**)&pFactory);
+    HRESULT r = GetClassFactory(dllName, clsidStr, &pFactory);
     if (r != S_OK) {
         printf("failed: get factory: %08x\n", r);
         return 2;
@@ -74,43 +89,167 @@ int main(int c, char** v) {
 
     IInitializeWithStream* pInit;
     r = pFactory->CreateInstance(NULL, IID_IInitializeWithStream, (void**)&pInit);
+    pFactory->Release();
     if (r != S_OK) {
         printf("failed: get object\n");
         return 3;
     }
-    pFactory->Release();
 
-    IThumbnailProvider* pProvider;
-    r = pInit->QueryInterface(IID_IThumbnailProvider, (void**)&pProvider);
+    r = pInit->QueryInterface(riid, ppv);
     if (r != S_OK) {
-        printf("failed: get provider\n");
+        pInit->Release();
+        printf("failed: get interface\n");
         return 5;
     }
 
-    wchar_t wfile[256]{};
-    MultiByteToWideChar(CP_ACP, 0, v[1], -1, wfile, 256);
+    wchar_t wfile[MAX_PATH]{};
+    MultiByteToWideChar(CP_ACP, 0, path, -1, wfile, MAX_PATH);
+    IStream* pStream = NULL;
     r = SHCreateStreamOnFileEx(wfile, STGM_READ, 0, FALSE, NULL, &pStream);
     if (r != S_OK || !pStream) {
+        pInit->Release();
         printf("can't open file\n");
         return 10;
     }
 
-    r = pInit->Initialize(pStream, 0);
+    r = pInit->Initialize(pStream, STGM_READ);
     pInit->Release();
     pStream->Release();
     if (r != S_OK) {
-        printf("failed: init provider\n");
+        printf("failed: init: %08x\n", r);
         return 11;
     }
+    return 0;
+}
+
+static int TestThumbnail(const char* path) {
+    IThumbnailProvider* pProvider;
+    int res = CreateInitialized(kPdfPreviewDllName, myGuid, path, IID_IThumbnailProvider, (void**)&pProvider);
+    if (res != 0) {
+        return res;
+    }
 
     HBITMAP bmp;
     WTS_ALPHATYPE alpha;
-    r = pProvider->GetThumbnail(256, &bmp, &alpha);
+    HRESULT r = pProvider->GetThumbnail(256, &bmp, &alpha);
     pProvider->Release();
     if (r != S_OK) {
         printf("failed: make thumbnail\n");
         return 12;
     }
+    BITMAP info{};
+    GetObject(bmp, sizeof(info), &info);
+    DeleteObject(bmp);
+    if (info.bmWidth <= 0 || info.bmHeight <= 0) {
+        printf("failed: empty thumbnail\n");
+        return 13;
+    }
+    printf("thumbnail: %dx%d\n", (int)info.bmWidth, (int)info.bmHeight);
+    return 0;
+}
+
+static int TestPreview(const char* path) {
+    IPreviewHandler* pHandler;
+    int res = CreateInitialized(kPdfPreviewDllName, myGuid, path, IID_IPreviewHandler, (void**)&pHandler);
+    if (res != 0) {
+        return res;
+    }
 
-    printf("done");
+    HWND hwnd = CreateWindowExW(0, L"STATIC", L"preview", WS_OVERLAPPEDWINDOW, 0, 0, 640, 480, NULL, NULL,
+                                GetModuleHandle(NULL), NULL);
+    RECT rc{0, 0, 640, 480};
+    HRESULT r = pHandler->SetWindow(hwnd, &rc);
+    if (r == S_OK) {
+        r = pHandler->DoPreview();
+    }
+    pHandler->Unload();
+    pHandler->Release();
+    DestroyWindow(hwnd);
+    if (r != S_OK) {
+        printf("failed: preview: %08x\n", r);
+        return 14;
+    }
+    printf("preview: ok\n");
+    return 0;
+}
+
+static int TestFilter(const char* path) {
+    IFilter* pFilter;
+    int res = CreateInitialized(kPdfFilterDllName, kPdfFilterClsid, path, IID_IFilter, (void**)&pFilter);
+    if (res != 0) {
+        return res;
+    }
+
+    ULONG flags = 0;
+    HRESULT r = pFilter->Init(IFILTER_INIT_CANON_PARAGRAPHS, 0, NULL, &flags);
+    if (r != S_OK) {
+        pFilter->Release();
+        printf("failed: filter init: %08x\n", r);
+        return 15;
+    }
+
+    int nChars = 0;
+    STAT_CHUNK chunk;
+    while (pFilter->GetChunk(&chunk) == S_OK) {
+        if (chunk.flags != CHUNK_TEXT) {
+            continue;
+        }
+        WCHAR buf[1024];
+        for (;;) {
+            ULONG n = dimof(buf) - 1;
+            r = pFilter->GetText(&n, buf);
+            if (FAILED(r) || n == 0) {
+                break;
+            }
+            buf[n] = 0;
+            nChars += (int)n;
+            printf("%S", buf);
+            if (r == FILTER_S_LAST_TEXT) {
+                break;
+            }
+        }
+    }
+    pFilter->Release();
+    printf("\nfilter: %d chars\n", nChars);
+    if (nChars == 0) {
+        printf("failed: no text\n");
+        return 16;
+    }
+    return 0;
+}
+
+int main(int c, char** v) {
+    const char* mode = "-thumb";
+    const char* path = nullptr;
+    for (int i = 1; i < c; i++) {
+        if (str::Eq(v[i], "-com")) {
+            gUseCom = true;
+        } else if (v[i][0] == '-') {
+            mode = v[i];
+        } else {
+            path = v[i];
+        }
+    }
+    if (!path) {
+        printf("not enough arguments: file name\n");
+        return 1;
+    }
+
+    CoInitialize(NULL);
+    int res;
+    if (str::Eq(mode, "-thumb")) {
+        res = TestThumbnail(path);
+    } else if (str::Eq(mode, "-preview")) {
+        res = TestPreview(path);
+    } else if (str::Eq(mode, "-filter")) {
+        res = TestFilter(path);
+    } else {
+        printf("unknown mode %s\n", mode);
+        res = 1;
+    }
+    CoUninitialize();
+    if (res == 0) {
+        printf("done");
+    }
+    return res;
 }
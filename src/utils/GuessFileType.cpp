@@ -98,6 +98,7 @@ Kind kindFileAvif = "fileAvif";
     V(".azw", kindFileMobi)       \
     V(".azw1", kindFileMobi)      \
     V(".azw3", kindFileMobi)      \
+    V(".azw4", kindFileMobi)      \
     V(".pdb", kindFilePalmDoc)    \
     V(".html", kindFileHTML)      \
     V(".htm", kindFileHTML)       \